@@ -32,6 +32,16 @@ func TestIndividualSQLFiles(t *testing.T) {
 		"data_types",
 		"functions",
 		"type_safety",
+		"indexes",
+		"explain",
+		"cursors",
+		"prepared_statements",
+		"json",
+		"arrays",
+		"catalog",
+		"settings",
+		"schemas",
+		"databases",
 	}
 
 	for _, category := range testCategories {
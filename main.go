@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
@@ -44,11 +45,30 @@ func main() {
 	var filePaths fileList
 	var quit bool
 	var help bool
+	var serverVersion string
+	var user, password, authMethod string
+	var ssl bool
+	var sslCertFile, sslKeyFile string
+	var socketDir string
+	var noTCP bool
+	var statementTimeout, maxIntermediateRows, statementMemoryLimit string
 	
 	flag.IntVar(&port, "port", 5432, "Port to listen on")
 	flag.Var(&commands, "c", "Execute command (can be specified multiple times)")
 	flag.Var(&filePaths, "f", "Execute SQL from file (can be specified multiple times)")
 	flag.BoolVar(&quit, "q", false, "Quit after executing commands (don't start server)")
+	flag.StringVar(&serverVersion, "server-version", parser.ServerVersion(), "PostgreSQL version to report to clients")
+	flag.StringVar(&user, "user", os.Getenv("POSTGRES_USER"), "User clients must connect as when a password is set (default: postgres)")
+	flag.StringVar(&password, "password", os.Getenv("POSTGRES_PASSWORD"), "Password clients must authenticate with")
+	flag.StringVar(&authMethod, "auth-method", os.Getenv("POSTGRES_HOST_AUTH_METHOD"), "Authentication method: trust, password, md5 or scram-sha-256")
+	flag.BoolVar(&ssl, "ssl", false, "Accept TLS connections, with a self-signed certificate unless one is given")
+	flag.StringVar(&sslCertFile, "ssl-cert-file", "", "PEM certificate to serve TLS connections with (implies -ssl)")
+	flag.StringVar(&sslKeyFile, "ssl-key-file", "", "PEM private key of the certificate given with -ssl-cert-file")
+	flag.StringVar(&socketDir, "unix-socket-dir", "", "Also listen on a Unix socket in this directory, such as /tmp")
+	flag.BoolVar(&noTCP, "no-tcp", false, "Listen only on the Unix socket")
+	flag.StringVar(&statementTimeout, "statement-timeout", "", "Default statement_timeout of every session, such as 30s")
+	flag.StringVar(&maxIntermediateRows, "max-intermediate-rows", "", "Default max_intermediate_rows of every session")
+	flag.StringVar(&statementMemoryLimit, "statement-memory-limit", "", "Default statement_memory_limit of every session, such as 512MB")
 	flag.BoolVar(&help, "h", false, "Show help")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.Parse()
@@ -62,6 +82,28 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  -c COMMAND    Execute command (can be specified multiple times)\n")
 		fmt.Fprintf(os.Stderr, "  -f FILE       Execute SQL from file (can be specified multiple times)\n")
 		fmt.Fprintf(os.Stderr, "  -q            Quit after executing commands (don't start server)\n")
+		fmt.Fprintf(os.Stderr, "  -server-version VERSION\n")
+		fmt.Fprintf(os.Stderr, "                PostgreSQL version to report to clients (default: %s)\n", parser.ServerVersion())
+		fmt.Fprintf(os.Stderr, "  -user USER    User clients must connect as when a password is set\n")
+		fmt.Fprintf(os.Stderr, "                (default: $POSTGRES_USER, or postgres)\n")
+		fmt.Fprintf(os.Stderr, "  -password PASSWORD\n")
+		fmt.Fprintf(os.Stderr, "                Password clients must authenticate with (default: $POSTGRES_PASSWORD)\n")
+		fmt.Fprintf(os.Stderr, "  -auth-method METHOD\n")
+		fmt.Fprintf(os.Stderr, "                trust, password, md5 or scram-sha-256 (default: $POSTGRES_HOST_AUTH_METHOD,\n")
+		fmt.Fprintf(os.Stderr, "                or scram-sha-256 when a password is set and trust otherwise)\n")
+		fmt.Fprintf(os.Stderr, "  -ssl          Accept TLS connections, with a self-signed certificate unless one is given\n")
+		fmt.Fprintf(os.Stderr, "  -ssl-cert-file FILE, -ssl-key-file FILE\n")
+		fmt.Fprintf(os.Stderr, "                PEM certificate and private key to serve TLS connections with\n")
+		fmt.Fprintf(os.Stderr, "  -unix-socket-dir DIR\n")
+		fmt.Fprintf(os.Stderr, "                Also listen on the Unix socket DIR/.s.PGSQL.PORT\n")
+		fmt.Fprintf(os.Stderr, "  -no-tcp       Listen only on the Unix socket\n")
+		fmt.Fprintf(os.Stderr, "  -statement-timeout DURATION\n")
+		fmt.Fprintf(os.Stderr, "                Cancel statements running longer than this, such as 30s (default: no limit)\n")
+		fmt.Fprintf(os.Stderr, "  -max-intermediate-rows N\n")
+		fmt.Fprintf(os.Stderr, "                Fail statements that build more than N intermediate rows (default: no limit)\n")
+		fmt.Fprintf(os.Stderr, "  -statement-memory-limit SIZE\n")
+		fmt.Fprintf(os.Stderr, "                Fail statements whose intermediate results need more memory than this,\n")
+		fmt.Fprintf(os.Stderr, "                such as 512MB (default: no limit)\n")
 		fmt.Fprintf(os.Stderr, "  -h, -help     Show this help message\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  # Start server on default port\n")
@@ -78,13 +120,46 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  vsql -f seed.sql\n\n")
 		fmt.Fprintf(os.Stderr, "  # Execute multiple SQL files in order\n")
 		fmt.Fprintf(os.Stderr, "  vsql -f schema.sql -f data.sql -f indexes.sql -q\n\n")
+		fmt.Fprintf(os.Stderr, "  # Require a password, checked with SCRAM-SHA-256\n")
+		fmt.Fprintf(os.Stderr, "  vsql -user app -password secret\n\n")
+		fmt.Fprintf(os.Stderr, "  # Accept clients connecting with sslmode=require\n")
+		fmt.Fprintf(os.Stderr, "  vsql -ssl\n\n")
+		fmt.Fprintf(os.Stderr, "  # Accept local clients on /tmp/.s.PGSQL.5432, as psql tries by default\n")
+		fmt.Fprintf(os.Stderr, "  vsql -unix-socket-dir /tmp\n\n")
+		fmt.Fprintf(os.Stderr, "  # Keep runaway queries from taking down a shared server\n")
+		fmt.Fprintf(os.Stderr, "  vsql -statement-timeout 30s -max-intermediate-rows 10000000 -statement-memory-limit 1GB\n\n")
 		fmt.Fprintf(os.Stderr, "  # Execute multiple commands\n")
 		fmt.Fprintf(os.Stderr, "  vsql -c \"CREATE TABLE t1 (id int)\" -c \"CREATE TABLE t2 (id int)\" -q\n")
 		os.Exit(0)
 	}
 
-	store := storage.NewDataStore()
-	metaStore := storage.NewMetaStore()
+	if err := parser.SetServerVersion(serverVersion); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resource limits apply to every session, which may still change them
+	for _, setting := range [][2]string{
+		{"statement_timeout", statementTimeout},
+		{"max_intermediate_rows", maxIntermediateRows},
+		{"statement_memory_limit", statementMemoryLimit},
+	} {
+		if setting[1] == "" {
+			continue
+		}
+		if err := parser.SetDefaultSetting(setting[0], setting[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Commands run from the command line share one session on the default
+	// database, which every database clients connect to starts as a copy of
+	cluster := storage.NewCluster(parser.DefaultDatabase)
+	db := cluster.Connect(parser.DefaultDatabase)
+	store, metaStore := db.DataStore, db.MetaStore
+	session := parser.NewSession()
+	session.SetCluster(cluster)
 
 	// Execute files if provided (first)
 	for _, filePath := range filePaths {
@@ -93,12 +168,12 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", filePath, err)
 			os.Exit(1)
 		}
-		executeCommand(string(content), store, metaStore)
+		executeCommand(string(content), session, store, metaStore)
 	}
 
 	// Execute commands if provided (second)
 	for _, command := range commands {
-		executeCommand(command, store, metaStore)
+		executeCommand(command, session, store, metaStore)
 	}
 
 	// If quit flag is set, exit after executing commands
@@ -107,7 +182,23 @@ func main() {
 	}
 
 	// Otherwise, start the server
-	srv := server.New(port, store, metaStore)
+	srv := server.New(port, cluster)
+	if err := srv.SetAuth(serverAuth(user, password, authMethod)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if ssl || sslCertFile != "" || sslKeyFile != "" {
+		tlsConfig, err := serverTLS(sslCertFile, sslKeyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		srv.SetTLS(tlsConfig)
+	}
+	if socketDir != "" {
+		srv.SetUnixSocketDir(socketDir)
+	}
+	srv.SetTCP(!noTCP)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -119,12 +210,52 @@ func main() {
 		os.Exit(0)
 	}()
 
-	fmt.Printf("VSQL server starting on port %d\n", port)
+	if !noTCP {
+		fmt.Printf("VSQL server starting on port %d\n", port)
+	}
+	if socketDir != "" {
+		fmt.Printf("VSQL server starting on Unix socket %s\n", server.UnixSocketPath(socketDir, port))
+	}
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
+// serverAuth returns the authentication clients must pass. Clients are
+// trusted unless a password is set, which is checked with SCRAM-SHA-256
+// unless another method is given.
+func serverAuth(user, password, method string) server.Auth {
+	auth := server.Auth{Method: server.AuthTrust, User: user, Password: password}
+	if auth.User == "" {
+		auth.User = "postgres"
+	}
+	if method == "" {
+		if password != "" {
+			auth.Method = server.AuthSCRAMSHA256
+		}
+		return auth
+	}
+	parsed, err := server.ParseAuthMethod(method)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	auth.Method = parsed
+	return auth
+}
+
+// serverTLS returns the TLS configuration for the given certificate and
+// key files, or for a self-signed certificate when neither is given
+func serverTLS(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return server.SelfSignedTLSConfig()
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("-ssl-cert-file and -ssl-key-file must be given together")
+	}
+	return server.LoadTLSConfig(certFile, keyFile)
+}
+
 // splitSQLStatements splits SQL statements by semicolon while respecting comments and string literals
 func splitSQLStatements(sql string) []string {
 	var statements []string
@@ -198,7 +329,7 @@ func splitSQLStatements(sql string) []string {
 	return statements
 }
 
-func executeCommand(command string, store *storage.DataStore, metaStore *storage.MetaStore) {
+func executeCommand(command string, session *parser.Session, store *storage.DataStore, metaStore *storage.MetaStore) {
 	// Split multiple commands by semicolon, respecting comments
 	commands := splitSQLStatements(command)
 	
@@ -209,7 +340,7 @@ func executeCommand(command string, store *storage.DataStore, metaStore *storage
 		}
 		
 		// Execute the query
-		columns, rows, message, err := parser.ExecutePgQuery(cmd, store, metaStore)
+		columns, rows, message, err := parser.ExecutePgQuery(cmd, session, store, metaStore)
 		if err != nil {
 			// Skip "no statements found" errors which happen with comment-only segments
 			if err.Error() == "no statements found" {
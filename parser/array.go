@@ -0,0 +1,373 @@
+package parser
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// evaluateArrayExpr builds the array an ARRAY[...] constructor describes
+func evaluateArrayExpr(expr *pg_query.A_ArrayExpr, row storage.Row, ctx *QueryContext) interface{} {
+	values := make([]interface{}, len(expr.Elements))
+	for i, elem := range expr.Elements {
+		values[i] = evaluateExpression(elem, row, ctx)
+	}
+	array, err := makeArray(values)
+	if err != nil {
+		ctx.fail(err)
+		return nil
+	}
+	return array
+}
+
+// makeArray converts values to the type they have in common and returns
+// them as an array. Text is an untyped literal and takes the type of the
+// other elements; integers widen to numerics and floats.
+func makeArray(values []interface{}) (storage.Array, error) {
+	elemType := storage.TypeUnknown
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if _, nested := value.(storage.Array); nested {
+			return storage.Array{}, newSQLError(SQLStateFeatureNotSupported, "multidimensional arrays are not supported")
+		}
+		common, ok := commonElementType(elemType, valueType(value))
+		if !ok {
+			return storage.Array{}, newSQLError(SQLStateDatatypeMismatch, "ARRAY types %s and %s cannot be matched", sqlTypeName(elemType), sqlTypeName(valueType(value)))
+		}
+		elemType = common
+	}
+	return convertElements(values, elemType)
+}
+
+// commonElementType returns the type that values of types a and b can
+// both be converted to
+func commonElementType(a, b storage.ColumnType) (storage.ColumnType, bool) {
+	switch {
+	case a == storage.TypeUnknown || a == b:
+		return b, true
+	case b == storage.TypeString:
+		return a, true
+	case a == storage.TypeString:
+		return b, true
+	case typeConvertible(a, b):
+		return b, true
+	case typeConvertible(b, a):
+		return a, true
+	}
+	return storage.TypeUnknown, false
+}
+
+// convertElements converts each value to elemType
+func convertElements(values []interface{}, elemType storage.ColumnType) (storage.Array, error) {
+	if elemType == storage.TypeUnknown {
+		return storage.Array{ElemType: elemType, Elems: values}, nil
+	}
+	elems := make([]interface{}, len(values))
+	for i, value := range values {
+		converted, err := coerceValue(value, elemType)
+		if err != nil {
+			return storage.Array{}, err
+		}
+		elems[i] = converted
+	}
+	return storage.Array{ElemType: elemType, Elems: elems}, nil
+}
+
+// coerceArray converts an array, or text such as '{1,2}', to an array of
+// the element type of colType
+func coerceArray(value interface{}, colType storage.ColumnType) (interface{}, error) {
+	elemType := storage.ElementType(colType)
+	switch v := value.(type) {
+	case storage.Array:
+		if elemType == storage.TypeUnknown || v.ElemType == elemType {
+			return v, nil
+		}
+		return convertElements(v.Elems, elemType)
+	case string:
+		elems, err := storage.ParseArrayText(v)
+		if err != nil {
+			return nil, err
+		}
+		if elemType == storage.TypeUnknown {
+			// Untyped text arrays are text[]
+			return storage.Array{ElemType: storage.TypeString, Elems: elems}, nil
+		}
+		return convertElements(elems, elemType)
+	}
+	return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to %s", valueTypeName(value), sqlTypeName(colType))
+}
+
+// arrayOperand reads an operand as an array. Text is an untyped literal,
+// read as an array of elemType.
+func arrayOperand(value interface{}, elemType storage.ColumnType) (storage.Array, error) {
+	if array, ok := value.(storage.Array); ok {
+		return array, nil
+	}
+	converted, err := coerceArray(value, storage.ArrayOf(elemType))
+	if err != nil {
+		return storage.Array{}, err
+	}
+	return converted.(storage.Array), nil
+}
+
+// evaluateIndirection applies subscripts such as arr[2] or arr[2:3] to a
+// value. Subscripts out of range give NULL, and slices out of range give
+// the elements that are in range. Documents take keys and indexes.
+func evaluateIndirection(expr *pg_query.A_Indirection, row storage.Row, ctx *QueryContext) interface{} {
+	value := evaluateExpression(expr.Arg, row, ctx)
+	for _, node := range expr.Indirection {
+		indices, ok := node.Node.(*pg_query.Node_AIndices)
+		if !ok || value == nil {
+			return nil
+		}
+		bound := func(node *pg_query.Node, fallback int) (int, bool) {
+			if node == nil {
+				return fallback, true
+			}
+			n, err := coerceValue(evaluateExpression(node, row, ctx), storage.TypeInteger)
+			if err != nil {
+				ctx.fail(err)
+				return 0, false
+			}
+			if n == nil {
+				return 0, false
+			}
+			return n.(int), true
+		}
+
+		if doc, isJSON := value.(storage.JSON); isJSON {
+			key := evaluateExpression(indices.AIndices.Uidx, row, ctx)
+			elem, found := jsonElement(doc.Jsonb().Doc, key)
+			if !found {
+				return nil
+			}
+			value = storage.JSON{Doc: elem}
+			continue
+		}
+		array, err := arrayOperand(value, storage.TypeUnknown)
+		if err != nil {
+			ctx.fail(newSQLError(SQLStateDatatypeMismatch, "cannot subscript type %s because it does not support subscripting", valueTypeName(value)))
+			return nil
+		}
+
+		if !indices.AIndices.IsSlice {
+			index, ok := bound(indices.AIndices.Uidx, 0)
+			if !ok || index < 1 || index > len(array.Elems) {
+				return nil
+			}
+			value = array.Elems[index-1]
+			continue
+		}
+		lower, ok := bound(indices.AIndices.Lidx, 1)
+		if !ok {
+			return nil
+		}
+		upper, ok := bound(indices.AIndices.Uidx, len(array.Elems))
+		if !ok {
+			return nil
+		}
+		if lower < 1 {
+			lower = 1
+		}
+		if upper > len(array.Elems) {
+			upper = len(array.Elems)
+		}
+		slice := storage.Array{ElemType: array.ElemType, Elems: []interface{}{}}
+		if lower <= upper {
+			slice.Elems = append(slice.Elems, array.Elems[lower-1:upper]...)
+		}
+		value = slice
+	}
+	return value
+}
+
+// arrayOperator applies an operator when an operand is an array: @> and <@
+// for containment, && for overlap, || for concatenation, and comparisons.
+// The second return value is false when the operator does not apply.
+func arrayOperator(op string, left, right interface{}) (interface{}, bool, error) {
+	leftArray, leftIsArray := left.(storage.Array)
+	rightArray, rightIsArray := right.(storage.Array)
+	if !leftIsArray && !rightIsArray {
+		return nil, false, nil
+	}
+	switch op {
+	case "@>", "<@", "&&", "||", "=", "<>", "!=", "<", ">", "<=", ">=":
+	default:
+		return nil, false, nil
+	}
+	if left == nil || right == nil {
+		if op == "||" {
+			// Concatenating NULL leaves the array unchanged
+			if leftIsArray {
+				return leftArray, true, nil
+			}
+			return rightArray, true, nil
+		}
+		return nil, true, nil
+	}
+	if op == "||" {
+		result, err := arrayConcat(left, right)
+		return result, true, err
+	}
+
+	// The other operand may be an untyped literal
+	var err error
+	if !leftIsArray {
+		leftArray, err = arrayOperand(left, rightArray.ElemType)
+	} else if !rightIsArray {
+		rightArray, err = arrayOperand(right, leftArray.ElemType)
+	}
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch op {
+	case "@>":
+		return arrayContains(leftArray, rightArray), true, nil
+	case "<@":
+		return arrayContains(rightArray, leftArray), true, nil
+	case "&&":
+		for _, elem := range rightArray.Elems {
+			if arrayHas(leftArray, elem) {
+				return true, true, nil
+			}
+		}
+		return false, true, nil
+	}
+	cmp := compareArrays(leftArray, rightArray)
+	switch op {
+	case "=":
+		return cmp == 0, true, nil
+	case "<":
+		return cmp < 0, true, nil
+	case ">":
+		return cmp > 0, true, nil
+	case "<=":
+		return cmp <= 0, true, nil
+	case ">=":
+		return cmp >= 0, true, nil
+	default:
+		return cmp != 0, true, nil
+	}
+}
+
+// arrayContains reports whether every element of sub is in array. NULL
+// elements are never found.
+func arrayContains(array, sub storage.Array) bool {
+	for _, elem := range sub.Elems {
+		if !arrayHas(array, elem) {
+			return false
+		}
+	}
+	return true
+}
+
+func arrayHas(array storage.Array, value interface{}) bool {
+	for _, elem := range array.Elems {
+		if compareValuesPg(elem, "=", value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareArrays orders arrays element by element, with NULL elements
+// after all others and shorter arrays first when one is a prefix
+func compareArrays(a, b storage.Array) int {
+	for i := 0; i < len(a.Elems) && i < len(b.Elems); i++ {
+		x, y := a.Elems[i], b.Elems[i]
+		switch {
+		case x == nil && y == nil:
+			continue
+		case x == nil:
+			return 1
+		case y == nil:
+			return -1
+		}
+		if cmp := compareForSort(x, y); cmp != 0 {
+			return cmp
+		}
+	}
+	return len(a.Elems) - len(b.Elems)
+}
+
+// arrayConcat joins two arrays, or adds an element to either end of one
+func arrayConcat(left, right interface{}) (storage.Array, error) {
+	leftArray, leftIsArray := left.(storage.Array)
+	rightArray, rightIsArray := right.(storage.Array)
+	// Text that reads as an array literal is an array
+	if s, ok := left.(string); ok && !leftIsArray {
+		if parsed, err := arrayOperand(s, rightArray.ElemType); err == nil {
+			leftArray, leftIsArray = parsed, true
+		}
+	}
+	if s, ok := right.(string); ok && !rightIsArray {
+		if parsed, err := arrayOperand(s, leftArray.ElemType); err == nil {
+			rightArray, rightIsArray = parsed, true
+		}
+	}
+
+	var values []interface{}
+	switch {
+	case leftIsArray && rightIsArray:
+		values = append(append(values, leftArray.Elems...), rightArray.Elems...)
+	case leftIsArray:
+		values = append(append(values, leftArray.Elems...), right)
+	default:
+		values = append(append(values, left), rightArray.Elems...)
+	}
+	result, err := makeArray(values)
+	if err == nil && result.ElemType == storage.TypeUnknown {
+		result.ElemType = leftArray.ElemType
+	}
+	return result, err
+}
+
+// arrayElementKey identifies an element for DISTINCT, keeping NULL apart
+// from the text "NULL"
+func arrayElementKey(value interface{}) string {
+	if value == nil {
+		return "\x00null"
+	}
+	return fmt.Sprint(value)
+}
+
+// evaluateAnyAll compares left with each element of an array, as
+// "left op ANY (array)" or "left op ALL (array)". The result is NULL when
+// no comparison decides it and an element, or left, is NULL.
+func evaluateAnyAll(op string, left, right interface{}, all bool) (interface{}, error) {
+	if right == nil {
+		return nil, nil
+	}
+	array, err := arrayOperand(right, valueType(left))
+	if err != nil {
+		return nil, err
+	}
+	if len(array.Elems) == 0 {
+		return all, nil
+	}
+	if left == nil {
+		return nil, nil
+	}
+	sawNull := false
+	for _, elem := range array.Elems {
+		if elem == nil {
+			sawNull = true
+			continue
+		}
+		matched := compareValuesPg(left, op, elem)
+		if all && !matched {
+			return false, nil
+		}
+		if !all && matched {
+			return true, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return all, nil
+}
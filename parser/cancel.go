@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"context"
+)
+
+// Statements run with a context that is canceled when the client sends a
+// CancelRequest or statement_timeout expires. The executor's loops over
+// rows poll it through QueryContext.interrupted and stop with the cause,
+// a query_canceled error.
+
+// pollInterval is how many rows a loop handles between looks at the
+// statement's context
+const pollInterval = 1024
+
+// statementGuard lets the executor notice that its statement was canceled
+// or went over the session's resource limits. It is used by the goroutine
+// running the statement only.
+type statementGuard struct {
+	ctx         context.Context
+	polls       int
+	canceled    error // Set once the cancellation is noticed
+	maxRows     int   // Intermediate rows the statement may build, 0 for no limit
+	rows        int   // Intermediate rows built so far
+	memoryLimit int64 // Bytes intermediate rows may use, 0 for no limit
+	memory      int64 // Estimated bytes of the intermediate rows built so far
+}
+
+// check returns the reason the statement was canceled, looking at the
+// context once every pollInterval calls. A nil guard never cancels.
+func (g *statementGuard) check() error {
+	if g == nil || g.canceled != nil {
+		return g.cancellation()
+	}
+	g.polls++
+	if g.polls%pollInterval == 0 && g.ctx.Err() != nil {
+		g.canceled = context.Cause(g.ctx)
+	}
+	return g.canceled
+}
+
+func (g *statementGuard) cancellation() error {
+	if g == nil {
+		return nil
+	}
+	return g.canceled
+}
+
+// interrupted reports whether the statement was canceled, recording the
+// cancellation as the query's error. Loops call it once per row.
+func (ctx *QueryContext) interrupted() bool {
+	if err := ctx.guard.check(); err != nil {
+		ctx.fail(err)
+		return true
+	}
+	return false
+}
+
+// userCancel is the error of a statement canceled by a CancelRequest
+func userCancel() error {
+	return newSQLError(SQLStateQueryCanceled, "canceling statement due to user request")
+}
+
+// statementTimeoutCancel is the error of a statement that ran longer than
+// statement_timeout
+func statementTimeoutCancel() error {
+	return newSQLError(SQLStateQueryCanceled, "canceling statement due to statement timeout")
+}
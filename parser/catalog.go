@@ -0,0 +1,729 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Object identifiers PostgreSQL assigns to built-in objects
+const (
+	pgCatalogNamespaceOID         = 11
+	publicNamespaceOID            = storage.PublicNamespaceOID
+	informationSchemaNamespaceOID = 13000
+	bootstrapSuperuserOID         = 10
+	heapAccessMethodOID           = 2
+	btreeAccessMethodOID          = 403
+	hashAccessMethodOID           = 405
+	defaultCollationOID           = 100
+)
+
+// catalogDatabaseName is the database name information_schema reports
+const catalogDatabaseName = DefaultDatabase
+
+// pgType describes a built-in type as listed in pg_type
+type pgType struct {
+	oid      uint32
+	name     string
+	sqlName  string // Name used by information_schema.columns.data_type
+	length   int
+	category string
+	array    uint32
+}
+
+var pgTypes = []pgType{
+	{16, "bool", "boolean", 1, "B", 1000},
+	{17, "bytea", "bytea", -1, "U", 1001},
+	{18, "char", "\"char\"", 1, "Z", 1002},
+	{19, "name", "name", 64, "S", 1003},
+	{20, "int8", "bigint", 8, "N", 1016},
+	{21, "int2", "smallint", 2, "N", 1005},
+	{22, "int2vector", "int2vector", -1, "A", 1006},
+	{23, "int4", "integer", 4, "N", 1007},
+	{25, "text", "text", -1, "S", 1009},
+	{26, "oid", "oid", 4, "N", 1028},
+	{114, "json", "json", -1, "U", 199},
+	{700, "float4", "real", 4, "N", 1021},
+	{701, "float8", "double precision", 8, "N", 1022},
+	{1042, "bpchar", "character", -1, "S", 1014},
+	{1043, "varchar", "character varying", -1, "S", 1015},
+	{1082, "date", "date", 4, "D", 1182},
+	{1083, "time", "time without time zone", 8, "D", 1183},
+	{1114, "timestamp", "timestamp without time zone", 8, "D", 1115},
+	{1184, "timestamptz", "timestamp with time zone", 8, "D", 1185},
+	{1186, "interval", "interval", 16, "T", 1187},
+	{1700, "numeric", "numeric", -1, "N", 1231},
+	{2950, "uuid", "uuid", 16, "U", 2951},
+	{3802, "jsonb", "jsonb", -1, "U", 3807},
+}
+
+// pgTypeByName returns the built-in type with the given pg_type name
+func pgTypeByName(name string) (pgType, bool) {
+	for _, t := range pgTypes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return pgType{}, false
+}
+
+// pgTypeByOID returns the built-in type with the given OID
+func pgTypeByOID(oid uint32) (pgType, bool) {
+	for _, t := range pgTypes {
+		if t.oid == oid {
+			return t, true
+		}
+	}
+	return pgType{}, false
+}
+
+// catalogColumn is a column of a system catalog relation, with the name of
+// its type in pg_type
+type catalogColumn struct {
+	name    string
+	typname string
+}
+
+// catalogRelation is a table or view of pg_catalog or information_schema.
+// Its rows are generated from the data and meta stores for each query.
+type catalogRelation struct {
+	namespace uint32
+	name      string
+	oid       uint32
+	kind      string // relkind: r for a table, v for a view
+	columns   []catalogColumn
+	rows      func(c *catalogSource) []storage.Row
+}
+
+// catalogRelations lists every relation of the system catalog
+var catalogRelations []*catalogRelation
+
+func init() {
+	catalogRelations = []*catalogRelation{
+		{pgCatalogNamespaceOID, "pg_namespace", 2615, "r", []catalogColumn{
+			{"oid", "oid"}, {"nspname", "name"}, {"nspowner", "oid"},
+		}, (*catalogSource).namespaceRows},
+		{pgCatalogNamespaceOID, "pg_class", 1259, "r", []catalogColumn{
+			{"oid", "oid"}, {"relname", "name"}, {"relnamespace", "oid"}, {"reltype", "oid"},
+			{"reloftype", "oid"}, {"relowner", "oid"}, {"relam", "oid"}, {"relfilenode", "oid"},
+			{"reltablespace", "oid"}, {"relpages", "int4"}, {"reltuples", "float4"},
+			{"relhasindex", "bool"}, {"relisshared", "bool"}, {"relpersistence", "char"},
+			{"relkind", "char"}, {"relnatts", "int2"}, {"relchecks", "int2"},
+			{"relhasrules", "bool"}, {"relhastriggers", "bool"}, {"relhassubclass", "bool"},
+			{"relrowsecurity", "bool"}, {"relforcerowsecurity", "bool"}, {"relispopulated", "bool"},
+			{"relreplident", "char"}, {"relispartition", "bool"},
+		}, (*catalogSource).classRows},
+		{pgCatalogNamespaceOID, "pg_attribute", 1249, "r", []catalogColumn{
+			{"attrelid", "oid"}, {"attname", "name"}, {"atttypid", "oid"}, {"attlen", "int2"},
+			{"attnum", "int2"}, {"attndims", "int2"}, {"atttypmod", "int4"}, {"attnotnull", "bool"},
+			{"atthasdef", "bool"}, {"attidentity", "char"}, {"attgenerated", "char"},
+			{"attisdropped", "bool"}, {"attislocal", "bool"}, {"attinhcount", "int2"},
+			{"attcollation", "oid"},
+		}, (*catalogSource).attributeRows},
+		{pgCatalogNamespaceOID, "pg_type", 1247, "r", []catalogColumn{
+			{"oid", "oid"}, {"typname", "name"}, {"typnamespace", "oid"}, {"typowner", "oid"},
+			{"typlen", "int2"}, {"typbyval", "bool"}, {"typtype", "char"}, {"typcategory", "char"},
+			{"typispreferred", "bool"}, {"typisdefined", "bool"}, {"typdelim", "char"},
+			{"typrelid", "oid"}, {"typelem", "oid"}, {"typarray", "oid"}, {"typnotnull", "bool"},
+			{"typbasetype", "oid"}, {"typtypmod", "int4"}, {"typndims", "int4"},
+			{"typcollation", "oid"},
+		}, (*catalogSource).typeRows},
+		{pgCatalogNamespaceOID, "pg_index", 2610, "r", []catalogColumn{
+			{"indexrelid", "oid"}, {"indrelid", "oid"}, {"indnatts", "int2"}, {"indnkeyatts", "int2"},
+			{"indisunique", "bool"}, {"indnullsnotdistinct", "bool"}, {"indisprimary", "bool"},
+			{"indisexclusion", "bool"}, {"indimmediate", "bool"}, {"indisclustered", "bool"},
+			{"indisvalid", "bool"}, {"indcheckxmin", "bool"}, {"indisready", "bool"},
+			{"indislive", "bool"}, {"indisreplident", "bool"}, {"indkey", "int2vector"},
+			{"indexprs", "text"}, {"indpred", "text"},
+		}, (*catalogSource).indexRows},
+		{pgCatalogNamespaceOID, "pg_enum", 3501, "r", []catalogColumn{
+			{"oid", "oid"}, {"enumtypid", "oid"}, {"enumsortorder", "float4"}, {"enumlabel", "name"},
+		}, (*catalogSource).enumRows},
+		{pgCatalogNamespaceOID, "pg_attrdef", 2604, "r", []catalogColumn{
+			{"oid", "oid"}, {"adrelid", "oid"}, {"adnum", "int2"}, {"adbin", "text"},
+		}, (*catalogSource).attrdefRows},
+		{pgCatalogNamespaceOID, "pg_collation", 3456, "r", []catalogColumn{
+			{"oid", "oid"}, {"collname", "name"}, {"collnamespace", "oid"}, {"collowner", "oid"},
+			{"collprovider", "char"}, {"collisdeterministic", "bool"}, {"collencoding", "int4"},
+		}, (*catalogSource).collationRows},
+		{pgCatalogNamespaceOID, "pg_tables", 12000, "v", []catalogColumn{
+			{"schemaname", "name"}, {"tablename", "name"}, {"tableowner", "name"},
+			{"tablespace", "name"}, {"hasindexes", "bool"}, {"hasrules", "bool"},
+			{"hastriggers", "bool"}, {"rowsecurity", "bool"},
+		}, (*catalogSource).pgTablesRows},
+		{informationSchemaNamespaceOID, "tables", 13001, "v", []catalogColumn{
+			{"table_catalog", "name"}, {"table_schema", "name"}, {"table_name", "name"},
+			{"table_type", "varchar"}, {"is_insertable_into", "varchar"}, {"is_typed", "varchar"},
+		}, (*catalogSource).tablesRows},
+		{informationSchemaNamespaceOID, "columns", 13002, "v", []catalogColumn{
+			{"table_catalog", "name"}, {"table_schema", "name"}, {"table_name", "name"},
+			{"column_name", "name"}, {"ordinal_position", "int4"}, {"column_default", "varchar"},
+			{"is_nullable", "varchar"}, {"data_type", "varchar"},
+			{"character_maximum_length", "int4"}, {"numeric_precision", "int4"},
+			{"numeric_precision_radix", "int4"}, {"numeric_scale", "int4"},
+			{"datetime_precision", "int4"}, {"udt_catalog", "name"}, {"udt_schema", "name"},
+			{"udt_name", "name"}, {"is_identity", "varchar"}, {"is_generated", "varchar"},
+			{"is_updatable", "varchar"},
+		}, (*catalogSource).columnsRows},
+	}
+}
+
+// namespaceName returns the name of a catalog namespace
+func namespaceName(oid uint32) string {
+	switch oid {
+	case pgCatalogNamespaceOID:
+		return "pg_catalog"
+	case informationSchemaNamespaceOID:
+		return "information_schema"
+	}
+	return "public"
+}
+
+// lookupCatalogRelation finds the catalog relation a FROM item names.
+// pg_catalog relations are found with or without their schema, as they come
+// first in the search path; information_schema ones need the schema.
+func lookupCatalogRelation(rv *pg_query.RangeVar) *catalogRelation {
+	schema := strings.ToLower(rv.Schemaname)
+	for _, rel := range catalogRelations {
+		if rel.name != rv.Relname {
+			continue
+		}
+		if schema == namespaceName(rel.namespace) || (schema == "" && rel.namespace == pgCatalogNamespaceOID) {
+			return rel
+		}
+	}
+	return nil
+}
+
+// WithSystemCatalog returns the stores a SELECT reads from. When the query
+// names pg_catalog or information_schema relations, they are overlays that
+// add those relations, generated from the current contents of the stores.
+func WithSystemCatalog(stmt *pg_query.SelectStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) (*storage.DataStore, *storage.MetaStore) {
+	var referenced []*catalogRelation
+	walkSelectRangeVars(stmt, func(rv *pg_query.RangeVar) {
+		if rel := lookupCatalogRelation(rv); rel != nil {
+			referenced = append(referenced, rel)
+		}
+	})
+	if len(referenced) == 0 {
+		return dataStore, metaStore
+	}
+
+	source := &catalogSource{dataStore: dataStore, metaStore: metaStore}
+	catalogData := dataStore.Overlay()
+	catalogMeta := metaStore.Overlay()
+	for _, rel := range referenced {
+		catalogData.AddTable(storage.NewTable(rel.name, rel.oid, rel.rows(source)))
+		catalogMeta.DropTable(rel.name)
+		columns := make([]string, len(rel.columns))
+		for i, col := range rel.columns {
+			columns[i] = col.name
+		}
+		catalogMeta.AddColumns(rel.name, columns)
+		for _, col := range rel.columns {
+			declareCatalogColumn(catalogMeta, rel.name, col)
+		}
+	}
+	return catalogData, catalogMeta
+}
+
+// declareCatalogColumn records the type of a catalog column the way CREATE
+// TABLE would
+func declareCatalogColumn(metaStore *storage.MetaStore, table string, col catalogColumn) {
+	switch col.typname {
+	case "bool":
+		metaStore.SetColumnTypeFromSchema(table, col.name, storage.TypeBoolean)
+	case "float4":
+		metaStore.SetColumnTypeFromSchema(table, col.name, storage.TypeFloat)
+	case "int2", "int4", "int8", "oid":
+		metaStore.SetColumnTypeFromSchema(table, col.name, storage.TypeInteger)
+		size := 4
+		if t, ok := pgTypeByName(col.typname); ok && col.typname != "oid" {
+			size = t.length
+		}
+		metaStore.SetColumnIntegerSize(table, col.name, size)
+	default:
+		metaStore.SetColumnTypeFromSchema(table, col.name, storage.TypeString)
+		if col.typname == "varchar" {
+			metaStore.SetColumnTypeName(table, col.name, "varchar")
+		}
+	}
+}
+
+// walkSelectRangeVars calls visit for every table named in a SELECT,
+// including those in joins, set operations, CTEs and subqueries
+func walkSelectRangeVars(stmt *pg_query.SelectStmt, visit func(*pg_query.RangeVar)) {
+	if stmt == nil {
+		return
+	}
+	var walkNode func(node *pg_query.Node)
+	walkSubqueries := func(node *pg_query.Node) {
+		walkExpr(node, func(n *pg_query.Node) bool {
+			if subLink, ok := n.Node.(*pg_query.Node_SubLink); ok {
+				walkNode(subLink.SubLink.Subselect)
+			}
+			return true
+		})
+	}
+	walkNode = func(node *pg_query.Node) {
+		if node == nil {
+			return
+		}
+		switch n := node.Node.(type) {
+		case *pg_query.Node_RangeVar:
+			visit(n.RangeVar)
+		case *pg_query.Node_JoinExpr:
+			walkNode(n.JoinExpr.Larg)
+			walkNode(n.JoinExpr.Rarg)
+			walkSubqueries(n.JoinExpr.Quals)
+		case *pg_query.Node_RangeSubselect:
+			walkNode(n.RangeSubselect.Subquery)
+		case *pg_query.Node_SelectStmt:
+			walkSelectRangeVars(n.SelectStmt, visit)
+		case *pg_query.Node_CommonTableExpr:
+			walkNode(n.CommonTableExpr.Ctequery)
+		}
+	}
+
+	for _, from := range stmt.FromClause {
+		walkNode(from)
+	}
+	if stmt.WithClause != nil {
+		for _, cte := range stmt.WithClause.Ctes {
+			walkNode(cte)
+		}
+	}
+	walkSelectRangeVars(stmt.Larg, visit)
+	walkSelectRangeVars(stmt.Rarg, visit)
+	for _, target := range stmt.TargetList {
+		if resTarget, ok := target.Node.(*pg_query.Node_ResTarget); ok {
+			walkSubqueries(resTarget.ResTarget.Val)
+		}
+	}
+	walkSubqueries(stmt.WhereClause)
+	walkSubqueries(stmt.HavingClause)
+}
+
+// catalogSource generates catalog rows from the stores a query runs against
+type catalogSource struct {
+	dataStore *storage.DataStore
+	metaStore *storage.MetaStore
+}
+
+// resolvedType returns the type of a table column, or the type it was
+// declared with while it holds no values
+func (c *catalogSource) resolvedType(table, column string) storage.ColumnType {
+	colType := c.metaStore.GetColumnType(table, column)
+	if colType == storage.TypeUnknown {
+		if declared, ok := c.metaStore.GetDeclaredType(table, column); ok {
+			colType = declared
+		}
+	}
+	return colType
+}
+
+// columnType returns the pg_type OID and type modifier of a table column
+func (c *catalogSource) columnType(table, column string) (uint32, int) {
+	colType := c.resolvedType(table, column)
+	typeName := c.metaStore.GetColumnTypeName(table, column)
+	oid := typeOID(colType, c.metaStore.GetColumnIntegerSize(table, column), typeName)
+	modifiers := c.metaStore.GetColumnTypeModifiers(table, column)
+	if len(modifiers) == 0 {
+		return oid, -1
+	}
+	switch oid {
+	case 1042, 1043:
+		return oid, modifiers[0] + 4
+	case 1700:
+		scale := 0
+		if len(modifiers) > 1 {
+			scale = modifiers[1]
+		}
+		return oid, modifiers[0]<<16 | scale + 4
+	}
+	return oid, -1
+}
+
+// typeOID returns the pg_type OID of a column type. Integers take the OID
+// of their declared width, text the OID of varchar or bpchar and JSON the
+// OID of json when declared as one.
+func typeOID(colType storage.ColumnType, integerSize int, typeName string) uint32 {
+	if storage.IsArrayType(colType) {
+		if elem, ok := pgTypeByOID(typeOID(storage.ElementType(colType), 0, "")); ok {
+			return elem.array
+		}
+		return 1009
+	}
+	if def := storage.EnumOf(colType); def != nil {
+		return def.OID
+	}
+	switch colType {
+	case storage.TypeBoolean:
+		return 16
+	case storage.TypeInteger:
+		switch integerSize {
+		case 2:
+			return 21
+		case 8:
+			return 20
+		}
+		return 23
+	case storage.TypeFloat:
+		return 701
+	case storage.TypeNumeric:
+		return 1700
+	case storage.TypeString:
+		switch typeName {
+		case "varchar":
+			return 1043
+		case "bpchar":
+			return 1042
+		}
+		return 25
+	case storage.TypeTimestamp:
+		return 1114
+	case storage.TypeTimestampTZ:
+		return 1184
+	case storage.TypeDate:
+		return 1082
+	case storage.TypeTime:
+		return 1083
+	case storage.TypeInterval:
+		return 1186
+	case storage.TypeJSON:
+		if typeName == "json" {
+			return 114
+		}
+		return 3802
+	case storage.TypeUUID:
+		return 2950
+	case storage.TypeBytea:
+		return 17
+	}
+	return 25
+}
+
+// CastType returns the pg_type OID of the type a cast names in the
+// database, and the modifiers declared with it, as they describe the cast's
+// result
+func CastType(typeName *pg_query.TypeName, metaStore *storage.MetaStore) (uint32, []int) {
+	bindTypeName(typeName, metaStore)
+	return typeOID(getColumnTypeFromTypeName(typeName), integerSize(typeName), declaredTypeName(typeName)), typeModifiers(typeName)
+}
+
+// typeCollation returns the collation of a type: the default collation
+// for character types, none for others
+func typeCollation(category string) uint32 {
+	if category == "S" {
+		return defaultCollationOID
+	}
+	return 0
+}
+
+func (c *catalogSource) namespaceRows() []storage.Row {
+	var rows []storage.Row
+	for _, oid := range []uint32{pgCatalogNamespaceOID, informationSchemaNamespaceOID} {
+		rows = append(rows, storage.Row{"oid": int(oid), "nspname": namespaceName(oid), "nspowner": bootstrapSuperuserOID})
+	}
+	for _, name := range c.dataStore.ListSchemas() {
+		oid, _ := c.dataStore.GetSchema(name)
+		rows = append(rows, storage.Row{"oid": int(oid), "nspname": name, "nspowner": bootstrapSuperuserOID})
+	}
+	return rows
+}
+
+// relationNamespace returns the schema OID and own name of a table or index
+// stored under key
+func (c *catalogSource) relationNamespace(key string) (uint32, string) {
+	schema, name := storage.SplitTableKey(key)
+	oid, _ := c.dataStore.GetSchema(schema)
+	return oid, name
+}
+
+// classRow returns a pg_class row with the defaults shared by every relation
+func classRow(oid uint32, name string, namespace uint32, kind string, columns int) storage.Row {
+	return storage.Row{
+		"oid": int(oid), "relname": name, "relnamespace": int(namespace), "reltype": 0,
+		"reloftype": 0, "relowner": bootstrapSuperuserOID, "relam": 0, "relfilenode": int(oid),
+		"reltablespace": 0, "relpages": 0, "reltuples": float64(-1),
+		"relhasindex": false, "relisshared": false, "relpersistence": "p",
+		"relkind": kind, "relnatts": columns, "relchecks": 0,
+		"relhasrules": false, "relhastriggers": false, "relhassubclass": false,
+		"relrowsecurity": false, "relforcerowsecurity": false, "relispopulated": true,
+		"relreplident": "d", "relispartition": false,
+	}
+}
+
+func (c *catalogSource) classRows() []storage.Row {
+	var rows []storage.Row
+	for _, name := range c.dataStore.ListTables() {
+		table, _ := c.dataStore.GetTable(name)
+		namespace, relname := c.relationNamespace(name)
+		row := classRow(table.OID, relname, namespace, "r", len(c.metaStore.GetTableColumns(name)))
+		row["relam"] = heapAccessMethodOID
+		row["reltuples"] = float64(len(table.GetRows()))
+		row["relhasindex"] = len(table.Indexes()) > 0
+		rows = append(rows, row)
+	}
+	for _, idx := range c.dataStore.ListIndexes() {
+		namespace, relname := c.relationNamespace(idx.Name)
+		row := classRow(idx.OID, relname, namespace, "i", len(idx.Columns))
+		row["relam"] = btreeAccessMethodOID
+		if idx.Method == storage.IndexMethodHash {
+			row["relam"] = hashAccessMethodOID
+		}
+		rows = append(rows, row)
+	}
+	for _, rel := range catalogRelations {
+		rows = append(rows, classRow(rel.oid, rel.name, rel.namespace, rel.kind, len(rel.columns)))
+	}
+	return rows
+}
+
+// attributeRow returns a pg_attribute row for a column of a given type
+func attributeRow(relid uint32, name string, num int, typid uint32, typmod int) storage.Row {
+	length, ndims, collation := -1, 0, uint32(0)
+	if t, ok := pgTypeByOID(typid); ok {
+		length = t.length
+		collation = typeCollation(t.category)
+	} else if elem, ok := arrayElementPgType(typid); ok {
+		ndims = 1
+		collation = typeCollation(elem.category)
+	} else {
+		// Enums are stored in four bytes
+		length = 4
+	}
+	return storage.Row{
+		"attrelid": int(relid), "attname": name, "atttypid": int(typid), "attlen": length,
+		"attnum": num, "attndims": ndims, "atttypmod": typmod, "attnotnull": false,
+		"atthasdef": false, "attidentity": "", "attgenerated": "",
+		"attisdropped": false, "attislocal": true, "attinhcount": 0,
+		"attcollation": int(collation),
+	}
+}
+
+// arrayElementPgType returns the element type of a built-in array type
+func arrayElementPgType(oid uint32) (pgType, bool) {
+	for _, t := range pgTypes {
+		if t.array == oid {
+			return t, true
+		}
+	}
+	return pgType{}, false
+}
+
+func (c *catalogSource) attributeRows() []storage.Row {
+	var rows []storage.Row
+	for _, name := range c.dataStore.ListTables() {
+		table, _ := c.dataStore.GetTable(name)
+		for _, col := range c.metaStore.GetTableColumns(name) {
+			typid, typmod := c.columnType(name, col)
+			rows = append(rows, attributeRow(table.OID, col, c.metaStore.GetColumnNumber(name, col), typid, typmod))
+		}
+	}
+	for _, rel := range catalogRelations {
+		for i, col := range rel.columns {
+			t, _ := pgTypeByName(col.typname)
+			rows = append(rows, attributeRow(rel.oid, col.name, i+1, t.oid, -1))
+		}
+	}
+	return rows
+}
+
+// typeRow returns a pg_type row with the defaults shared by every type
+func typeRow(oid uint32, name string, namespace uint32, length int, typtype, category string) storage.Row {
+	return storage.Row{
+		"oid": int(oid), "typname": name, "typnamespace": int(namespace), "typowner": bootstrapSuperuserOID,
+		"typlen": length, "typbyval": length > 0 && length <= 8, "typtype": typtype, "typcategory": category,
+		"typispreferred": false, "typisdefined": true, "typdelim": ",", "typrelid": 0,
+		"typelem": 0, "typarray": 0, "typnotnull": false, "typbasetype": 0,
+		"typtypmod": -1, "typndims": 0, "typcollation": int(typeCollation(category)),
+	}
+}
+
+func (c *catalogSource) typeRows() []storage.Row {
+	var rows []storage.Row
+	for _, t := range pgTypes {
+		row := typeRow(t.oid, t.name, pgCatalogNamespaceOID, t.length, "b", t.category)
+		row["typarray"] = int(t.array)
+		rows = append(rows, row)
+
+		array := typeRow(t.array, "_"+t.name, pgCatalogNamespaceOID, -1, "b", "A")
+		array["typelem"] = int(t.oid)
+		array["typcollation"] = int(typeCollation(t.category))
+		rows = append(rows, array)
+	}
+	for _, def := range c.metaStore.ListEnums() {
+		rows = append(rows, typeRow(def.OID, def.Name, publicNamespaceOID, 4, "e", "E"))
+	}
+	return rows
+}
+
+func (c *catalogSource) indexRows() []storage.Row {
+	var rows []storage.Row
+	for _, idx := range c.dataStore.ListIndexes() {
+		table, exists := c.dataStore.GetTable(idx.Table)
+		if !exists {
+			continue
+		}
+		keys := make([]string, len(idx.Columns))
+		for i, col := range idx.Columns {
+			keys[i] = strconv.Itoa(c.metaStore.GetColumnNumber(idx.Table, col))
+		}
+		rows = append(rows, storage.Row{
+			"indexrelid": int(idx.OID), "indrelid": int(table.OID),
+			"indnatts": len(idx.Columns), "indnkeyatts": len(idx.Columns),
+			"indisunique": idx.Unique, "indnullsnotdistinct": false, "indisprimary": false,
+			"indisexclusion": false, "indimmediate": true, "indisclustered": false,
+			"indisvalid": true, "indcheckxmin": false, "indisready": true,
+			"indislive": true, "indisreplident": false, "indkey": strings.Join(keys, " "),
+			"indexprs": nil, "indpred": nil,
+		})
+	}
+	return rows
+}
+
+func (c *catalogSource) enumRows() []storage.Row {
+	var rows []storage.Row
+	for _, def := range c.metaStore.ListEnums() {
+		for i, label := range def.Labels {
+			rows = append(rows, storage.Row{
+				"oid": int(def.LabelOIDs[i]), "enumtypid": int(def.OID),
+				"enumsortorder": float64(i + 1), "enumlabel": label,
+			})
+		}
+	}
+	return rows
+}
+
+// attrdefRows is empty: columns have no defaults
+func (c *catalogSource) attrdefRows() []storage.Row {
+	return nil
+}
+
+func (c *catalogSource) collationRows() []storage.Row {
+	var rows []storage.Row
+	for _, coll := range []struct {
+		oid      uint32
+		name     string
+		provider string
+	}{{defaultCollationOID, "default", "d"}, {950, "C", "c"}, {951, "POSIX", "c"}} {
+		rows = append(rows, storage.Row{
+			"oid": int(coll.oid), "collname": coll.name, "collnamespace": pgCatalogNamespaceOID,
+			"collowner": bootstrapSuperuserOID, "collprovider": coll.provider,
+			"collisdeterministic": true, "collencoding": -1,
+		})
+	}
+	return rows
+}
+
+func (c *catalogSource) pgTablesRows() []storage.Row {
+	var rows []storage.Row
+	for _, key := range c.dataStore.ListTables() {
+		table, _ := c.dataStore.GetTable(key)
+		schema, name := storage.SplitTableKey(key)
+		rows = append(rows, pgTablesRow(schema, name, len(table.Indexes()) > 0))
+	}
+	for _, rel := range catalogRelations {
+		if rel.kind == "r" {
+			rows = append(rows, pgTablesRow(namespaceName(rel.namespace), rel.name, false))
+		}
+	}
+	return rows
+}
+
+// pgTablesRow returns a pg_tables row for a table owned by the superuser
+func pgTablesRow(schema, name string, hasIndexes bool) storage.Row {
+	return storage.Row{
+		"schemaname": schema, "tablename": name, "tableowner": defaultUser, "tablespace": nil,
+		"hasindexes": hasIndexes, "hasrules": false, "hastriggers": false, "rowsecurity": false,
+	}
+}
+
+func (c *catalogSource) tablesRows() []storage.Row {
+	var rows []storage.Row
+	for _, key := range c.dataStore.ListTables() {
+		schema, name := storage.SplitTableKey(key)
+		rows = append(rows, storage.Row{
+			"table_catalog": catalogDatabaseName, "table_schema": schema, "table_name": name,
+			"table_type": "BASE TABLE", "is_insertable_into": "YES", "is_typed": "NO",
+		})
+	}
+	for _, rel := range catalogRelations {
+		tableType := "BASE TABLE"
+		if rel.kind == "v" {
+			tableType = "VIEW"
+		}
+		rows = append(rows, storage.Row{
+			"table_catalog": catalogDatabaseName, "table_schema": namespaceName(rel.namespace), "table_name": rel.name,
+			"table_type": tableType, "is_insertable_into": "NO", "is_typed": "NO",
+		})
+	}
+	return rows
+}
+
+func (c *catalogSource) columnsRows() []storage.Row {
+	var rows []storage.Row
+	for _, name := range c.dataStore.ListTables() {
+		schema, table := storage.SplitTableKey(name)
+		for _, col := range c.metaStore.GetTableColumns(name) {
+			typid, typmod := c.columnType(name, col)
+			row := storage.Row{
+				"table_catalog": catalogDatabaseName, "table_schema": schema, "table_name": table,
+				"column_name": col, "ordinal_position": c.metaStore.GetColumnNumber(name, col), "column_default": nil,
+				"is_nullable": "YES", "data_type": "USER-DEFINED",
+				"character_maximum_length": nil, "numeric_precision": nil,
+				"numeric_precision_radix": nil, "numeric_scale": nil, "datetime_precision": nil,
+				"udt_catalog": catalogDatabaseName, "udt_schema": "pg_catalog", "udt_name": nil,
+				"is_identity": "NO", "is_generated": "NEVER", "is_updatable": "YES",
+			}
+			if t, ok := pgTypeByOID(typid); ok {
+				row["data_type"] = t.sqlName
+				row["udt_name"] = t.name
+				describeColumnType(row, t.name, typmod)
+			} else if elem, ok := arrayElementPgType(typid); ok {
+				row["data_type"] = "ARRAY"
+				row["udt_name"] = "_" + elem.name
+			} else if def := storage.EnumOf(c.resolvedType(name, col)); def != nil {
+				row["udt_schema"] = "public"
+				row["udt_name"] = def.Name
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// describeColumnType fills in the length, precision and scale columns of
+// information_schema.columns for a built-in type
+func describeColumnType(row storage.Row, typname string, typmod int) {
+	switch typname {
+	case "varchar", "bpchar":
+		if typmod > 4 {
+			row["character_maximum_length"] = typmod - 4
+		}
+	case "int2", "int4", "int8":
+		t, _ := pgTypeByName(typname)
+		row["numeric_precision"] = t.length * 8
+		row["numeric_precision_radix"] = 2
+		row["numeric_scale"] = 0
+	case "float4", "float8":
+		row["numeric_precision"] = 24
+		if typname == "float8" {
+			row["numeric_precision"] = 53
+		}
+		row["numeric_precision_radix"] = 2
+	case "numeric":
+		row["numeric_precision_radix"] = 10
+		if typmod > 4 {
+			row["numeric_precision"] = (typmod - 4) >> 16
+			row["numeric_scale"] = (typmod - 4) & 0xffff
+		}
+	case "date":
+		row["datetime_precision"] = 0
+	case "time", "timestamp", "timestamptz", "interval":
+		row["datetime_precision"] = 6
+	}
+}
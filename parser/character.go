@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// maxCharacterLength is the longest length varchar(n) and char(n) accept
+const maxCharacterLength = 10485760
+
+// baseTypeName returns the last part of a type's name, such as int4 for
+// pg_catalog.int4
+func baseTypeName(typeName *pg_query.TypeName) string {
+	if typeName == nil || len(typeName.Names) == 0 {
+		return ""
+	}
+	if str, ok := typeName.Names[len(typeName.Names)-1].Node.(*pg_query.Node_String_); ok {
+		return strings.ToLower(str.String_.Sval)
+	}
+	return ""
+}
+
+// characterTypeName returns the name of a character type: text, varchar or
+// bpchar, which is char(n). Other types and arrays return "".
+func characterTypeName(typeName *pg_query.TypeName) string {
+	if typeName == nil || len(typeName.ArrayBounds) > 0 {
+		return ""
+	}
+	switch baseTypeName(typeName) {
+	case "text":
+		return "text"
+	case "varchar":
+		return "varchar"
+	case "char", "bpchar":
+		return "bpchar"
+	}
+	return ""
+}
+
+// characterTypeDisplay returns the name PostgreSQL uses for a character
+// type in messages
+func characterTypeDisplay(name string) string {
+	switch name {
+	case "varchar":
+		return "character varying"
+	case "bpchar":
+		return "character"
+	}
+	return name
+}
+
+// checkCharacterModifiers validates the length declared for a character
+// type, as CREATE TABLE and casts do
+func checkCharacterModifiers(name string, modifiers []int) error {
+	if name == "" || len(modifiers) == 0 {
+		return nil
+	}
+	if name == "text" {
+		return newSQLError(SQLStateSyntaxError, "type modifier is not allowed for type \"text\"")
+	}
+	if len(modifiers) > 1 {
+		return newSQLError(SQLStateSyntaxError, "invalid type modifier")
+	}
+	if modifiers[0] < 1 {
+		return newSQLError(SQLStateInvalidParameter, "length for type %s must be at least 1", name)
+	}
+	if modifiers[0] > maxCharacterLength {
+		return newSQLError(SQLStateInvalidParameter, "length for type %s cannot exceed %d", name, maxCharacterLength)
+	}
+	return nil
+}
+
+// fitCharacterLength fits a string to the length of varchar(n) or char(n).
+// Storing a longer string is an error unless only spaces would be cut off,
+// while an explicit cast truncates it. char(n) is padded with spaces, and a
+// char(n) value loses its padding before it is fitted to another type.
+func fitCharacterLength(value interface{}, name string, modifiers []int, explicit bool) (interface{}, error) {
+	if b, isBpchar := value.(storage.Bpchar); isBpchar {
+		value = b.Text()
+	}
+	str, ok := value.(string)
+	if !ok || len(modifiers) == 0 || (name != "varchar" && name != "bpchar") {
+		return value, nil
+	}
+	length := modifiers[0]
+	count := utf8.RuneCountInString(str)
+	if count > length {
+		cut := str
+		for i := 0; i < length; i++ {
+			_, size := utf8.DecodeRuneInString(cut)
+			cut = cut[size:]
+		}
+		if !explicit && strings.Trim(cut, " ") != "" {
+			return nil, newSQLError(SQLStateStringDataRightTruncation, "value too long for type %s(%d)", characterTypeDisplay(name), length)
+		}
+		str = str[:len(str)-len(cut)]
+		count = length
+	}
+	if name == "bpchar" {
+		return storage.Bpchar(str + strings.Repeat(" ", length-count)), nil
+	}
+	return str, nil
+}
@@ -0,0 +1,385 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Cursor is an open statement result that is read incrementally.
+// Row-returning statements that can be evaluated lazily produce each row as it
+// is fetched; other statements run to completion when the cursor is opened and
+// their rows are handed out from memory.
+type Cursor struct {
+	columns []string
+	tag     string // Fixed command tag; empty for "SELECT n" tags
+	next    func() ([]interface{}, bool, error)
+
+	pending []interface{} // Row read ahead by Exhausted
+	err     error         // Error hit while reading ahead
+	done    bool
+}
+
+// newCursor creates a cursor over a row-returning query producing "SELECT n" tags
+func newCursor(columns []string, next func() ([]interface{}, bool, error)) *Cursor {
+	return &Cursor{columns: columns, next: next}
+}
+
+// newMaterializedCursor creates a cursor over an already computed result
+func newMaterializedCursor(columns []string, rows [][]interface{}, tag string) *Cursor {
+	pos := 0
+	cursor := newCursor(columns, func() ([]interface{}, bool, error) {
+		if pos >= len(rows) {
+			return nil, false, nil
+		}
+		pos++
+		return rows[pos-1], true, nil
+	})
+	if tag != fmt.Sprintf("SELECT %d", len(rows)) {
+		cursor.tag = tag
+	}
+	return cursor
+}
+
+// OpenPgQuery starts executing a query in session and returns a cursor over its result
+func OpenPgQuery(query string, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) (*Cursor, error) {
+	if session == nil {
+		session = NewSession()
+	}
+
+	result, err := ParsePostgreSQL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Stmts) == 0 {
+		return nil, fmt.Errorf("no statements found")
+	}
+
+	stmt := result.Stmts[0].Stmt
+	var cursor *Cursor
+	err = session.run(stmt, dataStore, metaStore, func() (err error) {
+		if selectStmt, ok := stmt.Node.(*pg_query.Node_SelectStmt); ok {
+			cursor, err = openPgSelect(selectStmt.SelectStmt, session, dataStore, metaStore)
+			return err
+		}
+
+		columns, rows, tag, err := executePgStatement(stmt, session, dataStore, metaStore)
+		if err != nil {
+			return err
+		}
+		cursor = newMaterializedCursor(columns, rows, tag)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are shown in the session's time zone and DateStyle
+	next := cursor.next
+	cursor.next = func() ([]interface{}, bool, error) {
+		row, ok, err := next()
+		if ok {
+			row = session.localizeRow(row)
+		}
+		return row, ok, err
+	}
+	return cursor, nil
+}
+
+// Columns returns the result column names, or nil if the statement returns no rows
+func (c *Cursor) Columns() []string {
+	return c.columns
+}
+
+// ReturnsRows reports whether the statement produces a result set
+func (c *Cursor) ReturnsRows() bool {
+	return c.columns != nil
+}
+
+// Fetch returns up to max rows, or all remaining rows if max <= 0
+func (c *Cursor) Fetch(max int) ([][]interface{}, error) {
+	rows := [][]interface{}{}
+	for max <= 0 || len(rows) < max {
+		row, ok, err := c.read()
+		if err != nil {
+			return rows, err
+		}
+		if !ok {
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Exhausted reports whether every row has been fetched
+func (c *Cursor) Exhausted() bool {
+	if c.pending == nil && !c.done && c.err == nil {
+		row, ok, err := c.next()
+		switch {
+		case err != nil:
+			c.err = err
+		case ok:
+			c.pending = row
+		default:
+			c.done = true
+		}
+	}
+	return c.done
+}
+
+// Tag returns the command tag reported after fetching rows rows
+func (c *Cursor) Tag(rows int) string {
+	if c.tag != "" {
+		return c.tag
+	}
+	return fmt.Sprintf("SELECT %d", rows)
+}
+
+func (c *Cursor) read() ([]interface{}, bool, error) {
+	if c.err != nil {
+		err := c.err
+		c.err = nil
+		return nil, false, err
+	}
+	if c.pending != nil {
+		row := c.pending
+		c.pending = nil
+		return row, true, nil
+	}
+	if c.done {
+		return nil, false, nil
+	}
+	row, ok, err := c.next()
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		c.done = true
+		return nil, false, nil
+	}
+	return row, true, nil
+}
+
+// CursorSet holds the cursors a connection opened with DECLARE
+type CursorSet struct {
+	mu      sync.Mutex
+	cursors map[string]*declaredCursor
+}
+
+// NewCursorSet creates an empty cursor set
+func NewCursorSet() *CursorSet {
+	return &CursorSet{cursors: make(map[string]*declaredCursor)}
+}
+
+// Clear closes every cursor in the set
+func (cs *CursorSet) Clear() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.cursors = make(map[string]*declaredCursor)
+}
+
+func (cs *CursorSet) add(name string, cursor *declaredCursor) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, exists := cs.cursors[name]; exists {
+		return fmt.Errorf("cursor %q already exists", name)
+	}
+	cs.cursors[name] = cursor
+	return nil
+}
+
+// closeAtTransactionEnd closes cursors that do not outlive the transaction:
+// those declared without WITH HOLD, and on rollback those declared within it
+func (cs *CursorSet) closeAtTransactionEnd(rollback bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for name, cursor := range cs.cursors {
+		if !cursor.hold || (rollback && cursor.uncommitted) {
+			delete(cs.cursors, name)
+			continue
+		}
+		cursor.uncommitted = false
+	}
+}
+
+func (cs *CursorSet) get(name string) (*declaredCursor, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cursor, exists := cs.cursors[name]
+	if !exists {
+		return nil, fmt.Errorf("cursor %q does not exist", name)
+	}
+	return cursor, nil
+}
+
+func (cs *CursorSet) remove(name string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, exists := cs.cursors[name]; !exists {
+		return fmt.Errorf("cursor %q does not exist", name)
+	}
+	delete(cs.cursors, name)
+	return nil
+}
+
+// declaredCursor is a named cursor that can be repositioned.
+// Positions follow PostgreSQL: 0 is before the first row, n is on row n and
+// count+1 is after the last row.
+type declaredCursor struct {
+	cursor *Cursor
+	scroll bool // Declared with SCROLL, so it may move backward
+	hold   bool // Declared WITH HOLD
+
+	uncommitted bool // Declared in the current transaction block
+
+	rows  [][]interface{} // Rows read so far; only the latest unless scrollable
+	base  int             // Number of rows dropped from the front of rows
+	pos   int
+	count int // Total number of rows, or -1 until the cursor is exhausted
+}
+
+func newDeclaredCursor(cursor *Cursor, scroll, hold bool) *declaredCursor {
+	return &declaredCursor{cursor: cursor, scroll: scroll, hold: hold, count: -1}
+}
+
+// load reads rows until row p is available and reports whether it exists
+func (d *declaredCursor) load(p int) (bool, error) {
+	for d.base+len(d.rows) < p && d.count < 0 {
+		row, ok, err := d.cursor.read()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			d.count = d.base + len(d.rows)
+			break
+		}
+		if !d.scroll {
+			// Forward-only cursors never revisit earlier rows
+			d.base += len(d.rows)
+			d.rows = d.rows[:0]
+		}
+		d.rows = append(d.rows, row)
+	}
+	return p >= d.base+1 && p <= d.base+len(d.rows), nil
+}
+
+// seek moves to row target and returns it if it exists
+func (d *declaredCursor) seek(target int) ([]interface{}, bool, error) {
+	if target < d.pos && !d.scroll {
+		return nil, false, fmt.Errorf("cursor can only scan forward")
+	}
+	if target <= 0 {
+		d.pos = 0
+		return nil, false, nil
+	}
+	ok, err := d.load(target)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		d.pos = d.count + 1
+		return nil, false, nil
+	}
+	d.pos = target
+	return d.rows[target-d.base-1], true, nil
+}
+
+// total reads every remaining row and returns the row count
+func (d *declaredCursor) total() (int, error) {
+	if _, err := d.load(int(^uint(0) >> 1)); err != nil {
+		return 0, err
+	}
+	return d.count, nil
+}
+
+// move repositions the cursor as FETCH or MOVE would, returning the rows
+// passed over in fetch order (only counted when keep is false)
+func (d *declaredCursor) move(direction pg_query.FetchDirection, howMany int64, keep bool) ([][]interface{}, int, error) {
+	rows := [][]interface{}{}
+	n := 0
+	take := func(row []interface{}) {
+		if keep {
+			rows = append(rows, row)
+		}
+		n++
+	}
+
+	switch {
+	case direction == pg_query.FetchDirection_FETCH_FORWARD && howMany < 0:
+		direction, howMany = pg_query.FetchDirection_FETCH_BACKWARD, -howMany
+	case direction == pg_query.FetchDirection_FETCH_BACKWARD && howMany < 0:
+		direction, howMany = pg_query.FetchDirection_FETCH_FORWARD, -howMany
+	}
+
+	switch direction {
+	case pg_query.FetchDirection_FETCH_FORWARD, pg_query.FetchDirection_FETCH_BACKWARD:
+		step := 1
+		if direction == pg_query.FetchDirection_FETCH_BACKWARD {
+			step = -1
+		}
+		if howMany == 0 {
+			// FORWARD 0 and BACKWARD 0 re-fetch the current row
+			return d.move(pg_query.FetchDirection_FETCH_RELATIVE, 0, keep)
+		}
+		for i := int64(0); i < howMany; i++ {
+			if step < 0 && d.pos == 0 {
+				break
+			}
+			if step > 0 && d.count >= 0 && d.pos > d.count {
+				break
+			}
+			row, ok, err := d.seek(d.pos + step)
+			if err != nil {
+				return nil, 0, err
+			}
+			if !ok {
+				break
+			}
+			take(row)
+		}
+	case pg_query.FetchDirection_FETCH_ABSOLUTE:
+		target := int(howMany)
+		if howMany < 0 {
+			if !d.scroll {
+				return nil, 0, fmt.Errorf("cursor can only scan forward")
+			}
+			count, err := d.total()
+			if err != nil {
+				return nil, 0, err
+			}
+			target = count + 1 + int(howMany)
+		}
+		row, ok, err := d.seek(target)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			take(row)
+		}
+	case pg_query.FetchDirection_FETCH_RELATIVE:
+		if howMany == 0 {
+			if d.pos >= 1 && (d.count < 0 || d.pos <= d.count) {
+				if ok, err := d.load(d.pos); err != nil {
+					return nil, 0, err
+				} else if ok {
+					take(d.rows[d.pos-d.base-1])
+				}
+			}
+			break
+		}
+		row, ok, err := d.seek(d.pos + int(howMany))
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			take(row)
+		}
+	default:
+		return nil, 0, fmt.Errorf("unsupported fetch direction: %v", direction)
+	}
+	return rows, n, nil
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// TestCursorFetch tests that a cursor resumes where the previous fetch stopped
+func TestCursorFetch(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	if _, _, _, err := ExecutePgQuery("CREATE TABLE items (id int)", nil, dataStore, metaStore); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, _, _, err := ExecutePgQuery(fmt.Sprintf("INSERT INTO items (id) VALUES (%d)", i), nil, dataStore, metaStore); err != nil {
+			t.Fatalf("INSERT failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "streamed simple select", query: "SELECT id FROM items WHERE id > 1"},
+		{name: "materialized ordered select", query: "SELECT id FROM items WHERE id > 1 ORDER BY id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := OpenPgQuery(tt.query, nil, dataStore, metaStore)
+			if err != nil {
+				t.Fatalf("OpenPgQuery failed: %v", err)
+			}
+
+			var ids []interface{}
+			for _, batch := range []int{3, 1, 2} {
+				rows, err := cursor.Fetch(batch)
+				if err != nil {
+					t.Fatalf("Fetch failed: %v", err)
+				}
+				for _, row := range rows {
+					ids = append(ids, row[0])
+				}
+			}
+
+			if fmt.Sprint(ids) != "[2 3 4 5]" {
+				t.Errorf("Expected ids [2 3 4 5], got %v", ids)
+			}
+			if !cursor.Exhausted() {
+				t.Error("Expected cursor to be exhausted")
+			}
+			if tag := cursor.Tag(0); tag != "SELECT 0" {
+				t.Errorf("Expected tag SELECT 0, got %s", tag)
+			}
+		})
+	}
+
+	// Inserts after the cursor was opened are not visible to it
+	cursor, err := OpenPgQuery("SELECT id FROM items", nil, dataStore, metaStore)
+	if err != nil {
+		t.Fatalf("OpenPgQuery failed: %v", err)
+	}
+	if cursor.Exhausted() {
+		t.Fatal("Expected rows to remain")
+	}
+	ExecutePgQuery("INSERT INTO items (id) VALUES (6)", nil, dataStore, metaStore)
+	rows, _ := cursor.Fetch(0)
+	if len(rows) != 5 {
+		t.Errorf("Expected 5 rows from the snapshot, got %d", len(rows))
+	}
+}
+
+// TestCursorStreamingShapes tests which queries a cursor evaluates lazily.
+// The WHERE clause divides by zero on the last row, so a lazy cursor can
+// fetch the rows before it while a materialized one fails when opened.
+func TestCursorStreamingShapes(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	for _, query := range []string{
+		"CREATE TABLE items (id int, tag text)",
+		"CREATE TABLE tags (tag text, label text)",
+		"INSERT INTO items (id, tag) VALUES (1, 'a'), (2, 'b'), (3, 'a')",
+		"INSERT INTO tags (tag, label) VALUES ('a', 'Alpha'), ('b', 'Beta')",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, nil, dataStore, metaStore); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	const failsOnLast = "6 / (3 - i.id) > 0"
+	tests := []struct {
+		query string
+		lazy  bool
+	}{
+		{"SELECT i.id FROM items i WHERE " + failsOnLast, true},
+		{"SELECT * FROM items i WHERE " + failsOnLast, true},
+		{"SELECT i.id, upper(i.tag) FROM items i WHERE " + failsOnLast + " LIMIT 5", true},
+		{"SELECT i.id, t.label FROM items i JOIN tags t ON i.tag = t.tag WHERE " + failsOnLast, true},
+		{"SELECT i.id, t.label FROM items i, tags t WHERE i.tag = t.tag AND " + failsOnLast, true},
+		{"SELECT i.id FROM items i WHERE " + failsOnLast + " ORDER BY i.id", false},
+		{"SELECT DISTINCT i.tag FROM items i WHERE " + failsOnLast, false},
+		{"SELECT count(*) FROM items i WHERE " + failsOnLast, false},
+		{"SELECT i.id, t.label FROM items i LEFT JOIN tags t ON i.tag = t.tag WHERE " + failsOnLast, false},
+		{"SELECT * FROM items i JOIN tags t ON i.tag = t.tag WHERE " + failsOnLast, false},
+	}
+
+	for _, tt := range tests {
+		cursor, err := OpenPgQuery(tt.query, nil, dataStore, metaStore)
+		if !tt.lazy {
+			if err == nil {
+				t.Errorf("%s: expected the query to run when the cursor is opened", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: expected a lazy cursor, got %v", tt.query, err)
+		}
+		rows, err := cursor.Fetch(2)
+		if err != nil || len(rows) != 2 {
+			t.Fatalf("%s: expected 2 rows before the failing one, got %v, %v", tt.query, rows, err)
+		}
+		if _, err := cursor.Fetch(1); err == nil {
+			t.Errorf("%s: expected the division by zero on the last row", tt.query)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// CREATE DATABASE and DROP DATABASE, which manage the databases of the
+// session's cluster
+
+// sessionCluster returns the session's cluster, for a statement that
+// cannot run without one or inside a transaction block
+func sessionCluster(session *Session, command string) (*storage.Cluster, error) {
+	cluster := session.Cluster()
+	if cluster == nil {
+		return nil, newSQLError(SQLStateFeatureNotSupported, "%s is not supported in this session", command)
+	}
+	if session.InTransaction() {
+		return nil, newSQLError(SQLStateActiveSQLTransaction, "%s cannot run inside a transaction block", command)
+	}
+	return cluster, nil
+}
+
+func executePgCreateDatabase(stmt *pg_query.CreatedbStmt, session *Session) ([]string, [][]interface{}, string, error) {
+	cluster, err := sessionCluster(session, "CREATE DATABASE")
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// Options other than the template, such as ENCODING or OWNER, are
+	// accepted and ignored
+	template := ""
+	for _, option := range stmt.Options {
+		defElem, ok := option.Node.(*pg_query.Node_DefElem)
+		if !ok || strings.ToLower(defElem.DefElem.Defname) != "template" {
+			continue
+		}
+		if str, ok := defElem.DefElem.Arg.GetNode().(*pg_query.Node_String_); ok {
+			template = str.String_.Sval
+		}
+	}
+
+	if _, exists := cluster.Get(stmt.Dbname); exists {
+		return nil, nil, "", newSQLError(SQLStateDuplicateDatabase, "database \"%s\" already exists", stmt.Dbname)
+	}
+	if template != "" && template != storage.EmptyTemplate {
+		if _, exists := cluster.Get(template); !exists {
+			return nil, nil, "", newSQLError(SQLStateInvalidCatalogName, "template database \"%s\" does not exist", template)
+		}
+	}
+	if _, err := cluster.Create(stmt.Dbname, template); err != nil {
+		return nil, nil, "", newSQLError(SQLStateDuplicateDatabase, "%s", err.Error())
+	}
+	return nil, nil, "CREATE DATABASE", nil
+}
+
+func executePgDropDatabase(stmt *pg_query.DropdbStmt, session *Session) ([]string, [][]interface{}, string, error) {
+	cluster, err := sessionCluster(session, "DROP DATABASE")
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if _, exists := cluster.Get(stmt.Dbname); !exists {
+		if stmt.MissingOk {
+			return nil, nil, "DROP DATABASE", nil
+		}
+		return nil, nil, "", newSQLError(SQLStateInvalidCatalogName, "database \"%s\" does not exist", stmt.Dbname)
+	}
+	if stmt.Dbname == session.Database() {
+		return nil, nil, "", newSQLError(SQLStateObjectInUse, "cannot drop the currently open database")
+	}
+	if stmt.Dbname == cluster.Template() {
+		return nil, nil, "", newSQLError(SQLStateWrongObjectType, "cannot drop a template database")
+	}
+	if err := cluster.Drop(stmt.Dbname); err != nil {
+		if inUse, ok := err.(*storage.DatabaseInUseError); ok {
+			detail := "There is 1 other session using the database."
+			if inUse.Sessions > 1 {
+				detail = fmt.Sprintf("There are %d other sessions using the database.", inUse.Sessions)
+			}
+			return nil, nil, "", newSQLError(SQLStateObjectInUse, "%s", inUse.Error()).withDetail(detail)
+		}
+		return nil, nil, "", err
+	}
+	return nil, nil, "DROP DATABASE", nil
+}
@@ -0,0 +1,359 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// temporalType returns the column type of a date/time value, or TypeUnknown
+// for any other value
+func temporalType(value interface{}) storage.ColumnType {
+	switch value.(type) {
+	case storage.Date:
+		return storage.TypeDate
+	case storage.TimeOfDay:
+		return storage.TypeTime
+	case storage.Timestamp:
+		return storage.TypeTimestamp
+	case storage.TimestampTZ:
+		return storage.TypeTimestampTZ
+	case storage.Interval:
+		return storage.TypeInterval
+	}
+	return storage.TypeUnknown
+}
+
+// coerceTemporal converts text or another date/time value to a date/time type
+func coerceTemporal(value interface{}, colType storage.ColumnType) (interface{}, error) {
+	switch colType {
+	case storage.TypeDate:
+		switch v := value.(type) {
+		case storage.Date:
+			return v, nil
+		case storage.Timestamp:
+			return storage.DateOf(v.Time), nil
+		case storage.TimestampTZ:
+			return storage.DateOf(v.UTC()), nil
+		case string:
+			return storage.ParseDate(v)
+		}
+	case storage.TypeTime:
+		switch v := value.(type) {
+		case storage.TimeOfDay:
+			return v, nil
+		case storage.Timestamp:
+			return storage.TimeOf(v.Time), nil
+		case storage.TimestampTZ:
+			return storage.TimeOf(v.UTC()), nil
+		case string:
+			return storage.ParseTime(v)
+		}
+	case storage.TypeTimestamp:
+		switch v := value.(type) {
+		case storage.Timestamp:
+			return v, nil
+		case storage.Date:
+			return storage.Timestamp{Time: v.Time}, nil
+		case storage.TimestampTZ:
+			return storage.NewTimestamp(v.UTC()), nil
+		case string:
+			return storage.ParseTimestamp(v)
+		}
+	case storage.TypeTimestampTZ:
+		switch v := value.(type) {
+		case storage.TimestampTZ:
+			return v, nil
+		case storage.Date:
+			return storage.TimestampTZ{Time: v.Time}, nil
+		case storage.Timestamp:
+			return storage.TimestampTZ{Time: v.Time}, nil
+		case string:
+			return storage.ParseTimestampTZ(v)
+		}
+	case storage.TypeInterval:
+		switch v := value.(type) {
+		case storage.Interval:
+			return v, nil
+		case string:
+			return storage.ParseInterval(v)
+		}
+	}
+	return nil, fmt.Errorf("cannot cast type %s to %s", valueTypeName(value), sqlTypeName(colType))
+}
+
+// compareTemporal orders two values when at least one is a date/time value.
+// Text on the other side is read as the same type, and a date compares with
+// a timestamp as midnight. The second return value is false when the values
+// are not comparable as times.
+func compareTemporal(left, right interface{}) (int, bool) {
+	leftType, rightType := temporalType(left), temporalType(right)
+	target := leftType
+	switch {
+	case leftType == storage.TypeUnknown && rightType == storage.TypeUnknown:
+		return 0, false
+	case leftType == storage.TypeUnknown:
+		target = rightType
+	case rightType == storage.TypeUnknown, leftType == rightType:
+	case typeConvertible(leftType, rightType):
+		target = rightType
+	case !typeConvertible(rightType, leftType):
+		return 0, false
+	}
+
+	l, err := coerceTemporal(left, target)
+	if err != nil {
+		return 0, false
+	}
+	r, err := coerceTemporal(right, target)
+	if err != nil {
+		return 0, false
+	}
+	return compareTemporalValues(l, r), true
+}
+
+// compareTemporalValues orders two date/time values of the same type
+func compareTemporalValues(left, right interface{}) int {
+	var a, b int64
+	switch l := left.(type) {
+	case storage.Date:
+		a, b = l.UnixMicro(), right.(storage.Date).UnixMicro()
+	case storage.Timestamp:
+		a, b = l.UnixMicro(), right.(storage.Timestamp).UnixMicro()
+	case storage.TimestampTZ:
+		a, b = l.UnixMicro(), right.(storage.TimestampTZ).UnixMicro()
+	case storage.TimeOfDay:
+		a, b = l.Micros, right.(storage.TimeOfDay).Micros
+	case storage.Interval:
+		a, b = l.Span(), right.(storage.Interval).Span()
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// temporalArithmetic applies +, -, * or / when an operand is a date/time
+// value. Text on the other side takes the type the operator needs: an
+// interval for addition, and the same type (or an interval) for subtraction.
+// The second return value is false when neither operand is a date/time value.
+func temporalArithmetic(op string, left, right interface{}) (interface{}, bool, error) {
+	if temporalType(left) == storage.TypeUnknown && temporalType(right) == storage.TypeUnknown {
+		return nil, false, nil
+	}
+	switch op {
+	case "+", "-", "*", "/":
+	default:
+		return nil, false, nil
+	}
+	if left == nil || right == nil {
+		return nil, true, nil
+	}
+
+	left, right = resolveTemporalOperands(op, left, right)
+	var result interface{}
+	switch op {
+	case "+":
+		result = addTemporal(left, right)
+		if result == nil {
+			result = addTemporal(right, left)
+		}
+	case "-":
+		result = subtractTemporal(left, right)
+	case "*":
+		if iv, ok := left.(storage.Interval); ok {
+			if f, err := toFloat64(right); err == nil {
+				result = iv.Mul(f)
+			}
+		} else if iv, ok := right.(storage.Interval); ok {
+			if f, err := toFloat64(left); err == nil {
+				result = iv.Mul(f)
+			}
+		}
+	case "/":
+		if iv, ok := left.(storage.Interval); ok {
+			if f, err := toFloat64(right); err == nil {
+				if f == 0 {
+					return nil, true, newSQLError(SQLStateDivisionByZero, "division by zero")
+				}
+				result = iv.Mul(1 / f)
+			}
+		}
+	}
+	if result == nil {
+		return nil, true, newSQLError(SQLStateUndefinedFunction, "operator does not exist: %s %s %s", valueTypeName(left), op, valueTypeName(right)).
+			withHint("No operator matches the given name and argument types. You might need to add explicit type casts.")
+	}
+	return result, true, nil
+}
+
+// resolveTemporalOperands reads a text operand as the type the other operand needs
+func resolveTemporalOperands(op string, left, right interface{}) (interface{}, interface{}) {
+	resolve := func(text string, other interface{}) interface{} {
+		if _, isDate := other.(storage.Date); isDate && op != "*" && op != "/" {
+			if n, err := coerceValue(text, storage.TypeInteger); err == nil {
+				return n
+			}
+		}
+		if op == "-" {
+			if value, err := coerceTemporal(text, temporalType(other)); err == nil {
+				return value
+			}
+		}
+		if iv, err := storage.ParseInterval(text); err == nil {
+			return iv
+		}
+		return text
+	}
+	if text, ok := left.(string); ok {
+		left = resolve(text, right)
+	}
+	if text, ok := right.(string); ok {
+		right = resolve(text, left)
+	}
+	return left, right
+}
+
+// addTemporal adds b to a, returning nil if the types cannot be added in this order
+func addTemporal(a, b interface{}) interface{} {
+	switch x := a.(type) {
+	case storage.Date:
+		switch y := b.(type) {
+		case int:
+			return storage.Date{Time: x.AddDate(0, 0, y)}
+		case storage.Interval:
+			return storage.Timestamp{Time: storage.AddInterval(x.Time, y)}
+		case storage.TimeOfDay:
+			return storage.Timestamp{Time: x.Add(time.Duration(y.Micros) * time.Microsecond)}
+		}
+	case storage.Timestamp:
+		if y, ok := b.(storage.Interval); ok {
+			return storage.Timestamp{Time: storage.AddInterval(x.Time, y)}
+		}
+	case storage.TimestampTZ:
+		if y, ok := b.(storage.Interval); ok {
+			return storage.TimestampTZ{Time: storage.AddInterval(x.Time, y)}
+		}
+	case storage.TimeOfDay:
+		if y, ok := b.(storage.Interval); ok {
+			micros := (x.Micros + y.Micros) % storage.MicrosPerDay
+			if micros < 0 {
+				micros += storage.MicrosPerDay
+			}
+			return storage.TimeOfDay{Micros: micros}
+		}
+	case storage.Interval:
+		if y, ok := b.(storage.Interval); ok {
+			return x.Add(y)
+		}
+	}
+	return nil
+}
+
+// subtractTemporal returns a - b, or nil if the types cannot be subtracted
+func subtractTemporal(a, b interface{}) interface{} {
+	if iv, ok := b.(storage.Interval); ok {
+		if _, isInt := a.(int); !isInt {
+			return addTemporal(a, iv.Neg())
+		}
+	}
+	switch x := a.(type) {
+	case storage.Date:
+		switch y := b.(type) {
+		case int:
+			return storage.Date{Time: x.AddDate(0, 0, -y)}
+		case storage.Date:
+			return storage.Difference(x.Time, y.Time).Days
+		case storage.Timestamp:
+			return storage.Difference(x.Time, y.Time)
+		}
+	case storage.Timestamp:
+		switch y := b.(type) {
+		case storage.Timestamp:
+			return storage.Difference(x.Time, y.Time)
+		case storage.Date:
+			return storage.Difference(x.Time, y.Time)
+		case storage.TimestampTZ:
+			return storage.Difference(x.Time, y.Time)
+		}
+	case storage.TimestampTZ:
+		switch y := b.(type) {
+		case storage.TimestampTZ:
+			return storage.Difference(x.Time, y.Time)
+		case storage.Timestamp:
+			return storage.Difference(x.Time, y.Time)
+		case storage.Date:
+			return storage.Difference(x.Time, y.Time)
+		}
+	case storage.TimeOfDay:
+		if y, ok := b.(storage.TimeOfDay); ok {
+			return storage.Interval{Micros: x.Micros - y.Micros}
+		}
+	}
+	return nil
+}
+
+// evaluateSQLValueFunction computes CURRENT_DATE, CURRENT_TIMESTAMP and similar keywords
+func evaluateSQLValueFunction(fn *pg_query.SQLValueFunction) interface{} {
+	now := time.Now()
+	round := func(t time.Time) time.Time {
+		if fn.Typmod >= 0 && fn.Typmod < 6 {
+			return t.Round(time.Duration(pow10(6-int(fn.Typmod))) * time.Microsecond)
+		}
+		return t
+	}
+	switch fn.Op {
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_DATE:
+		return storage.DateOf(now.UTC())
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIME, pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIME_N,
+		pg_query.SQLValueFunctionOp_SVFOP_LOCALTIME, pg_query.SQLValueFunctionOp_SVFOP_LOCALTIME_N:
+		return storage.TimeOf(round(now.UTC()).Round(time.Microsecond))
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP, pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP_N:
+		return storage.NewTimestampTZ(round(now))
+	case pg_query.SQLValueFunctionOp_SVFOP_LOCALTIMESTAMP, pg_query.SQLValueFunctionOp_SVFOP_LOCALTIMESTAMP_N:
+		return storage.NewTimestamp(round(now.UTC()))
+	}
+	return nil
+}
+
+// SQLValueFunctionName is the column name PostgreSQL gives a SQL value function
+func SQLValueFunctionName(fn *pg_query.SQLValueFunction) string {
+	switch fn.Op {
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_DATE:
+		return "current_date"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIME, pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIME_N:
+		return "current_time"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP, pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP_N:
+		return "current_timestamp"
+	case pg_query.SQLValueFunctionOp_SVFOP_LOCALTIME, pg_query.SQLValueFunctionOp_SVFOP_LOCALTIME_N:
+		return "localtime"
+	case pg_query.SQLValueFunctionOp_SVFOP_LOCALTIMESTAMP, pg_query.SQLValueFunctionOp_SVFOP_LOCALTIMESTAMP_N:
+		return "localtimestamp"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_ROLE:
+		return "current_role"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_USER:
+		return "current_user"
+	case pg_query.SQLValueFunctionOp_SVFOP_USER:
+		return "user"
+	case pg_query.SQLValueFunctionOp_SVFOP_SESSION_USER:
+		return "session_user"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_CATALOG:
+		return "current_catalog"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_SCHEMA:
+		return "current_schema"
+	}
+	return "?column?"
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
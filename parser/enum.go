@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// executePgCreateEnum handles CREATE TYPE name AS ENUM ('label', ...)
+func executePgCreateEnum(stmt *pg_query.CreateEnumStmt, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	name := qualifiedTypeName(stmt.TypeName)
+	var labels []string
+	seen := make(map[string]bool)
+	for _, val := range stmt.Vals {
+		str, ok := val.Node.(*pg_query.Node_String_)
+		if !ok {
+			continue
+		}
+		label := str.String_.Sval
+		if seen[label] {
+			return nil, nil, "", newSQLError(SQLStateInvalidParameter, "enum label \"%s\" used more than once", label)
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	if _, err := metaStore.DefineEnum(name, labels); err != nil {
+		return nil, nil, "", err
+	}
+	return nil, nil, "CREATE TYPE", nil
+}
+
+// executePgDropType handles DROP TYPE. A type still used by a column can
+// only be dropped with CASCADE, which here leaves the column's values as
+// they are.
+func executePgDropType(stmt *pg_query.DropStmt, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	for _, obj := range stmt.Objects {
+		typeName, ok := obj.Node.(*pg_query.Node_TypeName)
+		if !ok {
+			continue
+		}
+		name := qualifiedTypeName(typeName.TypeName.Names)
+		colType, exists := metaStore.LookupEnum(name)
+		if !exists {
+			if stmt.MissingOk {
+				continue
+			}
+			return nil, nil, "", newSQLError(SQLStateUndefinedObject, "type \"%s\" does not exist", name)
+		}
+		if stmt.Behavior != pg_query.DropBehavior_DROP_CASCADE {
+			if columns := metaStore.ColumnsOfType(colType); len(columns) > 0 {
+				return nil, nil, "", newSQLError(SQLStateDependentObjectsStillExist, "cannot drop type %s because other objects depend on it", name).
+					withDetail(fmt.Sprintf("column %s of table %s depends on type %s", columns[0][1], columns[0][0], name)).
+					withHint("Use DROP ... CASCADE to drop the dependent objects too.")
+			}
+		}
+		metaStore.DropEnum(name)
+	}
+	return nil, nil, "DROP TYPE", nil
+}
+
+// bindTypeNames looks up the enum types named by a statement's casts,
+// column definitions and PREPARE parameter types in the database's meta
+// store. Each TypeName that names one records the enum's OID, which is how
+// getColumnTypeFromTypeName finds the type later.
+func bindTypeNames(stmt *pg_query.Node, metaStore *storage.MetaStore) {
+	var bindExprs func(node *pg_query.Node)
+	bindExprs = func(node *pg_query.Node) {
+		walkExpr(node, func(n *pg_query.Node) bool {
+			switch expr := n.Node.(type) {
+			case *pg_query.Node_TypeCast:
+				bindTypeName(expr.TypeCast.TypeName, metaStore)
+			case *pg_query.Node_SubLink:
+				walkStatementExprs(expr.SubLink.Subselect, bindExprs)
+			}
+			return true
+		})
+	}
+	walkStatementExprs(stmt, bindExprs)
+
+	switch n := stmt.Node.(type) {
+	case *pg_query.Node_CreateStmt:
+		for _, elem := range n.CreateStmt.TableElts {
+			if colDef, ok := elem.Node.(*pg_query.Node_ColumnDef); ok {
+				bindTypeName(colDef.ColumnDef.TypeName, metaStore)
+			}
+		}
+	case *pg_query.Node_PrepareStmt:
+		for _, arg := range n.PrepareStmt.Argtypes {
+			if typeName, ok := arg.Node.(*pg_query.Node_TypeName); ok {
+				bindTypeName(typeName.TypeName, metaStore)
+			}
+		}
+	}
+}
+
+// bindTypeName records the OID of the enum type a type name, or the element
+// type of an array type name, refers to in the database
+func bindTypeName(typeName *pg_query.TypeName, metaStore *storage.MetaStore) {
+	if typeName == nil {
+		return
+	}
+	if enumType, ok := metaStore.LookupEnum(qualifiedTypeName(typeName.Names)); ok {
+		typeName.TypeOid = storage.EnumOf(enumType).OID
+	}
+}
+
+// qualifiedTypeName returns the unqualified name of a type from its name
+// parts, such as public.mood
+func qualifiedTypeName(names []*pg_query.Node) string {
+	var name string
+	for _, n := range names {
+		if str, ok := n.Node.(*pg_query.Node_String_); ok {
+			name = strings.ToLower(str.String_.Sval)
+		}
+	}
+	return name
+}
+
+// compareOpaque compares uuid, bytea, enum, char(n) and object identifier
+// values, each in its own order: uuids and byteas bytewise, enums in
+// declaration order, char(n) values as text without trailing spaces and
+// object identifiers by OID. Text on the other side is read as the same
+// type. The second return value is false when neither operand is one of
+// these types or the text cannot be read.
+func compareOpaque(left, right interface{}) (int, bool) {
+	switch l := left.(type) {
+	case regObject:
+		return compareRegObjects(l, right)
+	case storage.Bpchar:
+		switch r := right.(type) {
+		case storage.Bpchar:
+			return strings.Compare(l.Text(), r.Text()), true
+		case string:
+			return strings.Compare(l.Text(), storage.Bpchar(r).Text()), true
+		}
+		return 0, false
+	case storage.UUID, storage.Bytea, storage.Enum:
+		other, err := coerceValue(right, valueType(l))
+		if err != nil {
+			return 0, false
+		}
+		return compareSameOpaque(l, other)
+	}
+	switch right.(type) {
+	case storage.UUID, storage.Bytea, storage.Enum, storage.Bpchar, regObject:
+		cmp, ok := compareOpaque(right, left)
+		return -cmp, ok
+	}
+	return 0, false
+}
+
+func compareSameOpaque(left, right interface{}) (int, bool) {
+	switch l := left.(type) {
+	case storage.UUID:
+		if r, ok := right.(storage.UUID); ok {
+			return strings.Compare(string(l[:]), string(r[:])), true
+		}
+	case storage.Bytea:
+		if r, ok := right.(storage.Bytea); ok {
+			return strings.Compare(string(l), string(r)), true
+		}
+	case storage.Enum:
+		if r, ok := right.(storage.Enum); ok && r.Type == l.Type {
+			return l.Position() - r.Position(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package parser
+
+import "fmt"
+
+// PostgreSQL error codes raised by the parser
+const (
+	SQLStateUndefinedFunction           = "42883"
+	SQLStateDatatypeMismatch            = "42804"
+	SQLStateInvalidParameter            = "22023"
+	SQLStateSubstringError              = "22011"
+	SQLStateNullNotAllowed              = "22004"
+	SQLStateDivisionByZero              = "22012"
+	SQLStateDatetimeOverflow            = "22008"
+	SQLStateDatetimeFormat              = "22007"
+	SQLStateNumericOutOfRange           = "22003"
+	SQLStateInvalidArgumentForPower     = "2201F"
+	SQLStateInvalidArgumentForLogarithm = "2201E"
+	SQLStateInvalidTextRepresentation   = "22P02"
+	SQLStateCannotCoerce                = "42846"
+	SQLStateFeatureNotSupported         = "0A000"
+	SQLStateGroupingError               = "42803"
+	SQLStateUndefinedObject             = "42704"
+	SQLStateDependentObjectsStillExist  = "2BP01"
+	SQLStateStringDataRightTruncation   = "22001"
+	SQLStateSyntaxError                 = "42601"
+	SQLStateCantChangeRuntimeParam      = "55P02"
+	SQLStateQueryCanceled               = "57014"
+	SQLStateInvalidSchemaName           = "3F000"
+	SQLStateDuplicateSchema             = "42P06"
+	SQLStateInvalidSchemaDefinition     = "42P15"
+	SQLStateReservedName                = "42939"
+	SQLStateDuplicateDatabase           = "42P04"
+	SQLStateInvalidCatalogName          = "3D000"
+	SQLStateObjectInUse                 = "55006"
+	SQLStateActiveSQLTransaction        = "25001"
+	SQLStateWrongObjectType             = "42809"
+	SQLStateProgramLimitExceeded        = "54000"
+	SQLStateOutOfMemory                 = "53200"
+	SQLStateInvalidTableDefinition      = "42P16"
+	SQLStateUndefinedTable              = "42P01"
+)
+
+// SQLError is an error carrying a PostgreSQL SQLSTATE code, with optional
+// detail and hint lines for the client
+type SQLError struct {
+	code    string
+	message string
+	detail  string
+	hint    string
+}
+
+func newSQLError(code, format string, args ...interface{}) *SQLError {
+	return &SQLError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// withHint attaches a hint to the error
+func (e *SQLError) withHint(hint string) *SQLError {
+	e.hint = hint
+	return e
+}
+
+// withDetail attaches a detail line to the error
+func (e *SQLError) withDetail(detail string) *SQLError {
+	e.detail = detail
+	return e
+}
+
+func (e *SQLError) Error() string {
+	return e.message
+}
+
+// SQLState returns the PostgreSQL error code
+func (e *SQLError) SQLState() string {
+	return e.code
+}
+
+// Detail returns the error's detail line, if any
+func (e *SQLError) Detail() string {
+	return e.detail
+}
+
+// Hint returns the error's hint line, if any
+func (e *SQLError) Hint() string {
+	return e.hint
+}
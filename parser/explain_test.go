@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// TestExplainCosts checks that plan lines carry PostgreSQL's cost section
+// unless COSTS OFF is given
+func TestExplainCosts(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+	for _, query := range []string{
+		"CREATE TABLE users (id int, name text)",
+		"INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob')",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		line  string
+	}{
+		{"EXPLAIN SELECT * FROM users", `^Seq Scan on users  \(cost=0\.00\.\.\d+\.\d{2} rows=2 width=\d+\)$`},
+		{"EXPLAIN SELECT * FROM users LIMIT 1", `^Limit  \(cost=0\.00\.\.\d+\.\d{2} rows=1 width=\d+\)$`},
+		{"EXPLAIN ANALYZE SELECT * FROM users", `^Seq Scan on users  \(cost=\S+ rows=2 width=\d+\) \(actual time=\S+ rows=2 loops=1\)$`},
+		{"EXPLAIN (COSTS OFF) SELECT * FROM users", `^Seq Scan on users$`},
+		{"EXPLAIN (ANALYZE, COSTS OFF, TIMING OFF) SELECT * FROM users", `^Seq Scan on users  \(actual rows=2 loops=1\)$`},
+	}
+	for _, test := range tests {
+		_, rows, _, err := ExecutePgQuery(test.query, session, dataStore, metaStore)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if line := rows[0][0].(string); !regexp.MustCompile(test.line).MatchString(line) {
+			t.Errorf("%s: plan line %q does not match %s", test.query, line, test.line)
+		}
+	}
+}
+
+// TestExplainModifyIndexScan checks that UPDATE and DELETE plans show the
+// index the executor reads their rows through
+func TestExplainModifyIndexScan(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+	for _, query := range []string{
+		"CREATE TABLE users (id int, name text)",
+		"INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob'), (3, 'Carol')",
+		"CREATE INDEX users_id_idx ON users (id)",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		lines []string
+	}{
+		{"EXPLAIN (ANALYZE, COSTS OFF, TIMING OFF) UPDATE users SET name = 'Robert' WHERE id = 2 AND name = 'Bob'", []string{
+			`^Update on users  \(actual rows=0 loops=1\)$`,
+			`^  ->  Index Scan using users_id_idx on users  \(actual rows=1 loops=1\)$`,
+			`^        Index Cond: \(id = 2\)$`,
+			`^        Filter: \(name = 'Bob'\)$`,
+		}},
+		{"EXPLAIN (COSTS OFF) DELETE FROM users WHERE id = 3", []string{
+			`^Delete on users$`,
+			`^  ->  Index Scan using users_id_idx on users$`,
+			`^        Index Cond: \(id = 3\)$`,
+		}},
+		{"EXPLAIN (COSTS OFF) DELETE FROM users WHERE name = 'Alice'", []string{
+			`^Delete on users$`,
+			`^  ->  Seq Scan on users$`,
+		}},
+	}
+	for _, test := range tests {
+		_, rows, _, err := ExecutePgQuery(test.query, session, dataStore, metaStore)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		for i, pattern := range test.lines {
+			if i >= len(rows) {
+				t.Errorf("%s: missing plan line %d", test.query, i)
+				break
+			}
+			if line := rows[i][0].(string); !regexp.MustCompile(pattern).MatchString(line) {
+				t.Errorf("%s: plan line %q does not match %s", test.query, line, pattern)
+			}
+		}
+	}
+
+	_, rows, _, err := ExecutePgQuery("SELECT name FROM users WHERE id = 2", session, dataStore, metaStore)
+	if err != nil || len(rows) != 1 || rows[0][0] != "Robert" {
+		t.Errorf("UPDATE through the index: got %v, %v", rows, err)
+	}
+}
@@ -0,0 +1,653 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// FunctionKind distinguishes scalar functions from aggregates and
+// set-returning functions
+type FunctionKind int
+
+const (
+	ScalarFunction       FunctionKind = iota // Computed once per row
+	AggregateFunction                        // Computed over a group of rows
+	SetReturningFunction                     // Computes a set of rows, used in FROM
+)
+
+// FunctionImpl computes a function from its evaluated arguments. The
+// result of a set-returning function is a []storage.Row.
+type FunctionImpl func(args []interface{}) (interface{}, error)
+
+// FunctionSignature is one overload of a function.
+// A TypeUnknown argument accepts any type, and a TypeUnknown result has the
+// type of the first argument.
+type FunctionSignature struct {
+	Args         []storage.ColumnType
+	Variadic     bool // The last argument may repeat any number of times
+	Result       storage.ColumnType
+	CalledOnNull bool // Call Impl with NULL arguments instead of returning NULL
+	Impl         FunctionImpl
+}
+
+// Function is a named function with one or more overloads
+type Function struct {
+	Name       string
+	Kind       FunctionKind
+	Signatures []FunctionSignature
+	Columns    []string // Output columns of a set-returning function
+}
+
+// FunctionRegistry maps function names to their implementations
+type FunctionRegistry struct {
+	mu        sync.RWMutex
+	functions map[string]*Function
+}
+
+// NewFunctionRegistry creates an empty registry
+func NewFunctionRegistry() *FunctionRegistry {
+	return &FunctionRegistry{functions: make(map[string]*Function)}
+}
+
+// Register adds fn to the registry. Registering a name twice adds the new
+// overloads to the existing function.
+func (r *FunctionRegistry) Register(fn *Function) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := strings.ToLower(fn.Name)
+	if existing, ok := r.functions[name]; ok {
+		existing.Signatures = append(existing.Signatures, fn.Signatures...)
+		return
+	}
+	r.functions[name] = &Function{Name: name, Kind: fn.Kind, Signatures: fn.Signatures, Columns: fn.Columns}
+}
+
+// Lookup returns the function registered under name, ignoring case
+func (r *FunctionRegistry) Lookup(name string) (*Function, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.functions[strings.ToLower(name)]
+	return fn, ok
+}
+
+// builtinFunctions is the function library queries are evaluated against
+var builtinFunctions = NewFunctionRegistry()
+
+// RegisterFunction adds a function to the built-in library
+func RegisterFunction(fn *Function) {
+	builtinFunctions.Register(fn)
+}
+
+// LookupFunction returns a built-in function by name
+func LookupFunction(name string) (*Function, bool) {
+	return builtinFunctions.Lookup(name)
+}
+
+// FunctionResultType returns the result type of calling a built-in function
+// with arguments of the given types, if an overload accepts them
+func FunctionResultType(name string, argTypes []storage.ColumnType) (storage.ColumnType, bool) {
+	fn, ok := LookupFunction(name)
+	if !ok {
+		return storage.TypeUnknown, false
+	}
+	sig := fn.resolve(len(argTypes), func(sig *FunctionSignature) (bool, int) {
+		exact := 0
+		for j, argType := range argTypes {
+			want := sig.argType(j)
+			if !typeConvertible(argType, want) {
+				return false, 0
+			}
+			if argType == want {
+				exact++
+			}
+		}
+		return true, exact
+	})
+	if sig == nil {
+		return storage.TypeUnknown, false
+	}
+	if sig.Result == storage.TypeUnknown && len(argTypes) > 0 {
+		return argTypes[0], true
+	}
+	if sig.Result == storage.ArrayOf(storage.TypeUnknown) && len(argTypes) > 0 {
+		// Functions such as array_agg return an array of their argument's type
+		if storage.IsArrayType(argTypes[0]) {
+			return argTypes[0], true
+		}
+		return storage.ArrayOf(argTypes[0]), true
+	}
+	return sig.Result, true
+}
+
+// resolve picks the overload a call with n arguments uses. accepts reports
+// whether an overload takes the arguments and how many of them already have
+// the declared type. As in PostgreSQL, the overload with the most exact
+// matches wins, and ties go to the overload registered first.
+func (fn *Function) resolve(n int, accepts func(sig *FunctionSignature) (bool, int)) *FunctionSignature {
+	var best *FunctionSignature
+	bestExact := -1
+	for i := range fn.Signatures {
+		sig := &fn.Signatures[i]
+		if !sig.acceptsCount(n) {
+			continue
+		}
+		if ok, exact := accepts(sig); ok && exact > bestExact {
+			best, bestExact = sig, exact
+		}
+	}
+	return best
+}
+
+// acceptsCount reports whether the overload takes n arguments
+func (sig *FunctionSignature) acceptsCount(n int) bool {
+	if sig.Variadic {
+		return n >= len(sig.Args)
+	}
+	return n == len(sig.Args)
+}
+
+// argType returns the declared type of argument i
+func (sig *FunctionSignature) argType(i int) storage.ColumnType {
+	if i >= len(sig.Args) {
+		return sig.Args[len(sig.Args)-1]
+	}
+	return sig.Args[i]
+}
+
+// acceptsCount reports whether any overload takes n arguments
+func (fn *Function) acceptsCount(n int) bool {
+	for i := range fn.Signatures {
+		if fn.Signatures[i].acceptsCount(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Call evaluates the function with the overload resolve picks for args
+func (fn *Function) Call(args []interface{}) (interface{}, error) {
+	sig := fn.resolve(len(args), func(sig *FunctionSignature) (bool, int) {
+		if _, ok := convertArgs(args, sig); !ok {
+			return false, 0
+		}
+		exact := 0
+		for j, arg := range args {
+			if arg != nil && valueType(arg) == sig.argType(j) {
+				exact++
+			}
+		}
+		return true, exact
+	})
+	if sig != nil {
+		converted, _ := convertArgs(args, sig)
+		if !sig.CalledOnNull {
+			for _, arg := range converted {
+				if arg == nil {
+					return nil, nil
+				}
+			}
+		}
+		return sig.Impl(converted)
+	}
+
+	argTypes := make([]string, len(args))
+	for i, arg := range args {
+		argTypes[i] = valueTypeName(arg)
+	}
+	return nil, undefinedFunctionError(fn.Name, argTypes)
+}
+
+// convertArgs converts each argument to its declared type, reporting false if one cannot be
+func convertArgs(args []interface{}, sig *FunctionSignature) ([]interface{}, bool) {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, ok := convertArg(arg, sig.argType(i))
+		if !ok {
+			return nil, false
+		}
+		converted[i] = value
+	}
+	return converted, true
+}
+
+// convertArg applies the implicit conversions PostgreSQL allows for function
+// arguments: integers widen to numerics and floats, numerics widen to
+// floats, and untyped text is read as the declared type
+func convertArg(value interface{}, want storage.ColumnType) (interface{}, bool) {
+	if value == nil || want == storage.TypeUnknown {
+		return value, true
+	}
+	if b, isBpchar := value.(storage.Bpchar); isBpchar {
+		// char(n) is passed to text parameters without its padding
+		if want == storage.TypeString {
+			return b.Text(), true
+		}
+		value = string(b)
+	}
+	if r, isRegObject := value.(regObject); isRegObject {
+		// Object identifiers are passed to integer parameters as their OIDs
+		if want == storage.TypeString {
+			return r.name, true
+		}
+		value = r.oid
+	}
+	if want == storage.TypeJSON || storage.IsArrayType(want) || want == storage.TypeUUID ||
+		want == storage.TypeBytea || storage.IsEnumType(want) {
+		// Text is read as the declared type
+		converted, err := coerceValue(value, want)
+		return converted, err == nil
+	}
+	if storage.IsTemporalType(want) {
+		// Text is read as the declared type; dates and times widen implicitly
+		if _, isText := value.(string); !isText && !typeConvertible(temporalType(value), want) {
+			return nil, false
+		}
+		converted, err := coerceTemporal(value, want)
+		return converted, err == nil
+	}
+	switch v := value.(type) {
+	case int:
+		switch want {
+		case storage.TypeInteger:
+			return v, true
+		case storage.TypeNumeric:
+			return storage.NumericFromInt(int64(v)), true
+		case storage.TypeFloat:
+			return float64(v), true
+		}
+	case int64:
+		switch want {
+		case storage.TypeInteger:
+			return int(v), true
+		case storage.TypeNumeric:
+			return storage.NumericFromInt(v), true
+		case storage.TypeFloat:
+			return float64(v), true
+		}
+	case storage.Numeric:
+		switch want {
+		case storage.TypeNumeric:
+			return v, true
+		case storage.TypeFloat:
+			return v.Float64(), true
+		}
+	case float64:
+		if want == storage.TypeFloat {
+			return v, true
+		}
+	case bool:
+		if want == storage.TypeBoolean {
+			return v, true
+		}
+	case string:
+		switch want {
+		case storage.TypeString:
+			return v, true
+		case storage.TypeInteger:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n, true
+			}
+		case storage.TypeNumeric:
+			if n, err := storage.ParseNumeric(v); err == nil {
+				return n, true
+			}
+		case storage.TypeFloat:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, true
+			}
+		case storage.TypeBoolean:
+			if b, err := coerceValue(v, storage.TypeBoolean); err == nil {
+				return b, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// typeConvertible reports whether a value of type from may be passed as type to
+func typeConvertible(from, to storage.ColumnType) bool {
+	switch {
+	case from == storage.TypeUnknown, to == storage.TypeUnknown, from == to:
+		return true
+	case from == storage.TypeInteger && (to == storage.TypeFloat || to == storage.TypeNumeric):
+		return true
+	case from == storage.TypeNumeric && to == storage.TypeFloat:
+		return true
+	case from == storage.TypeString:
+		// Text may be an untyped literal; the value is checked when called
+		return true
+	case from == storage.TypeTimestamp && to == storage.TypeString:
+		// Timestamps inferred from text may still be passed as text
+		return true
+	case from == storage.TypeDate && (to == storage.TypeTimestamp || to == storage.TypeTimestampTZ):
+		return true
+	case from == storage.TypeTimestamp && to == storage.TypeTimestampTZ:
+		return true
+	case storage.IsArrayType(from) && storage.IsArrayType(to):
+		return typeConvertible(storage.ElementType(from), storage.ElementType(to))
+	}
+	return false
+}
+
+// undefinedFunctionError is the error PostgreSQL raises when no overload matches a call
+func undefinedFunctionError(name string, argTypes []string) error {
+	return newSQLError(SQLStateUndefinedFunction, "function %s(%s) does not exist", strings.ToLower(name), strings.Join(argTypes, ", ")).
+		withHint("No function matches the given name and argument types. You might need to add explicit type casts.")
+}
+
+// valueType returns the column type of a runtime value
+func valueType(value interface{}) storage.ColumnType {
+	switch v := value.(type) {
+	case int, int64, regObject:
+		return storage.TypeInteger
+	case storage.Numeric:
+		return storage.TypeNumeric
+	case float64:
+		return storage.TypeFloat
+	case bool:
+		return storage.TypeBoolean
+	case string, storage.Bpchar:
+		return storage.TypeString
+	case storage.JSON:
+		return storage.TypeJSON
+	case storage.Array:
+		return storage.ArrayOf(v.ElemType)
+	case storage.UUID:
+		return storage.TypeUUID
+	case storage.Bytea:
+		return storage.TypeBytea
+	case storage.Enum:
+		return v.Type
+	}
+	return temporalType(value)
+}
+
+// valueTypeName names the SQL type of a runtime value
+func valueTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "unknown"
+	case regObject:
+		return v.typ
+	case int, int64:
+		return "integer"
+	case storage.Numeric:
+		return "numeric"
+	case float64:
+		return "double precision"
+	case bool:
+		return "boolean"
+	case storage.JSON:
+		return "jsonb"
+	case storage.Array, storage.UUID, storage.Bytea, storage.Enum:
+		return sqlTypeName(valueType(value))
+	}
+	if colType := temporalType(value); colType != storage.TypeUnknown {
+		return sqlTypeName(colType)
+	}
+	return "text"
+}
+
+// sqlTypeName names a column type the way PostgreSQL error messages do
+func sqlTypeName(colType storage.ColumnType) string {
+	if storage.IsArrayType(colType) {
+		return sqlTypeName(storage.ElementType(colType)) + "[]"
+	}
+	if storage.IsEnumType(colType) {
+		return storage.TypeToString(colType)
+	}
+	switch colType {
+	case storage.TypeInteger:
+		return "integer"
+	case storage.TypeFloat:
+		return "double precision"
+	case storage.TypeNumeric:
+		return "numeric"
+	case storage.TypeString:
+		return "text"
+	case storage.TypeBoolean:
+		return "boolean"
+	case storage.TypeTimestamp:
+		return "timestamp without time zone"
+	case storage.TypeTimestampTZ:
+		return "timestamp with time zone"
+	case storage.TypeDate:
+		return "date"
+	case storage.TypeTime:
+		return "time without time zone"
+	case storage.TypeInterval:
+		return "interval"
+	case storage.TypeJSON:
+		return "jsonb"
+	case storage.TypeUUID:
+		return "uuid"
+	case storage.TypeBytea:
+		return "bytea"
+	default:
+		return "unknown"
+	}
+}
+
+// evaluateScalarFunction evaluates a scalar function call through the registry.
+// Errors are recorded on ctx and the call evaluates to NULL.
+func evaluateScalarFunction(funcCall *pg_query.FuncCall, row storage.Row, ctx *QueryContext) interface{} {
+	fn, ok := LookupFunction(getFunctionName(funcCall))
+	if ok && fn.Kind == SetReturningFunction {
+		ctx.fail(newSQLError(SQLStateFeatureNotSupported, "set-returning function %s() is only supported in FROM", fn.Name))
+		return nil
+	}
+	if ok && fn.Kind != ScalarFunction {
+		return nil
+	}
+
+	args := make([]interface{}, len(funcCall.Args))
+	for i, arg := range funcCall.Args {
+		args[i] = evaluateExpression(arg, row, ctx)
+	}
+	if !ok {
+		argTypes := make([]string, len(args))
+		for i, arg := range args {
+			argTypes[i] = valueTypeName(arg)
+		}
+		ctx.fail(undefinedFunctionError(getFunctionName(funcCall), argTypes))
+		return nil
+	}
+	result, err := fn.Call(args)
+	if err != nil {
+		ctx.fail(err)
+		return nil
+	}
+	return result
+}
+
+// checkFunctionCalls reports calls to unknown functions, and calls with an
+// argument count no overload accepts, anywhere in stmt
+func checkFunctionCalls(stmt *pg_query.SelectStmt, metaStore *storage.MetaStore) error {
+	if stmt == nil {
+		return nil
+	}
+	if stmt.Larg != nil || stmt.Rarg != nil {
+		if err := checkFunctionCalls(stmt.Larg, metaStore); err != nil {
+			return err
+		}
+		return checkFunctionCalls(stmt.Rarg, metaStore)
+	}
+
+	tables := fromTableNames(stmt.FromClause)
+	var err error
+	check := func(node *pg_query.Node) {
+		walkExpr(node, func(n *pg_query.Node) bool {
+			if err != nil {
+				return false
+			}
+			switch expr := n.Node.(type) {
+			case *pg_query.Node_FuncCall:
+				err = checkFunctionCall(expr.FuncCall, tables, metaStore)
+			case *pg_query.Node_SubLink:
+				if sel, ok := expr.SubLink.Subselect.GetNode().(*pg_query.Node_SelectStmt); ok {
+					err = checkFunctionCalls(sel.SelectStmt, metaStore)
+				}
+			}
+			return err == nil
+		})
+	}
+
+	for _, nodes := range [][]*pg_query.Node{stmt.TargetList, stmt.GroupClause, stmt.SortClause, stmt.ValuesLists} {
+		for _, node := range nodes {
+			check(node)
+		}
+	}
+	check(stmt.WhereClause)
+	check(stmt.HavingClause)
+	for _, from := range stmt.FromClause {
+		// check records errors in err itself, so keep one it has set
+		if fromErr := checkFromFunctionCalls(from, metaStore, check); fromErr != nil && err == nil {
+			err = fromErr
+		}
+	}
+	return err
+}
+
+// checkFromFunctionCalls checks join conditions and subqueries in a FROM item
+func checkFromFunctionCalls(node *pg_query.Node, metaStore *storage.MetaStore, check func(*pg_query.Node)) error {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_JoinExpr:
+		if err := checkFromFunctionCalls(n.JoinExpr.Larg, metaStore, check); err != nil {
+			return err
+		}
+		if err := checkFromFunctionCalls(n.JoinExpr.Rarg, metaStore, check); err != nil {
+			return err
+		}
+		check(n.JoinExpr.Quals)
+	case *pg_query.Node_RangeSubselect:
+		if sel, ok := n.RangeSubselect.Subquery.GetNode().(*pg_query.Node_SelectStmt); ok {
+			return checkFunctionCalls(sel.SelectStmt, metaStore)
+		}
+	case *pg_query.Node_RangeFunction:
+		if funcCall := rangeFunctionCall(n.RangeFunction); funcCall != nil {
+			check(&pg_query.Node{Node: &pg_query.Node_FuncCall{FuncCall: funcCall}})
+		}
+	}
+	return nil
+}
+
+// checkFunctionCall checks that a called function exists and takes the given number of arguments
+func checkFunctionCall(funcCall *pg_query.FuncCall, tables []string, metaStore *storage.MetaStore) error {
+	name := getFunctionName(funcCall)
+	fn, ok := LookupFunction(name)
+	if ok && (funcCall.AggStar || fn.acceptsCount(len(funcCall.Args))) {
+		return nil
+	}
+
+	argTypes := make([]string, len(funcCall.Args))
+	for i, arg := range funcCall.Args {
+		argTypes[i] = sqlTypeName(staticArgType(arg, tables, metaStore))
+	}
+	return undefinedFunctionError(name, argTypes)
+}
+
+// staticArgType determines the type of a function argument without evaluating it
+func staticArgType(node *pg_query.Node, tables []string, metaStore *storage.MetaStore) storage.ColumnType {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_AConst:
+		switch n.AConst.Val.(type) {
+		case *pg_query.A_Const_Ival:
+			return storage.TypeInteger
+		case *pg_query.A_Const_Fval:
+			if _, ok := bigintLiteral(n.AConst.GetFval().Fval); ok {
+				return storage.TypeInteger
+			}
+			return storage.TypeNumeric
+		case *pg_query.A_Const_Boolval:
+			return storage.TypeBoolean
+		}
+	case *pg_query.Node_TypeCast:
+		return getColumnTypeFromTypeName(n.TypeCast.TypeName)
+	case *pg_query.Node_AArrayExpr:
+		elemType := storage.TypeUnknown
+		for _, elem := range n.AArrayExpr.Elements {
+			if common, ok := commonElementType(elemType, staticArgType(elem, tables, metaStore)); ok {
+				elemType = common
+			}
+		}
+		return storage.ArrayOf(elemType)
+	case *pg_query.Node_ColumnRef:
+		if metaStore == nil {
+			break
+		}
+		column := extractColumnNameFromRef(n.ColumnRef)
+		for _, table := range tables {
+			if colType := metaStore.GetColumnType(table, column); colType != storage.TypeUnknown {
+				return colType
+			}
+		}
+	case *pg_query.Node_FuncCall:
+		var argTypes []storage.ColumnType
+		for _, arg := range n.FuncCall.Args {
+			argTypes = append(argTypes, staticArgType(arg, tables, metaStore))
+		}
+		if colType, ok := FunctionResultType(getFunctionName(n.FuncCall), argTypes); ok {
+			return colType
+		}
+	}
+	return storage.TypeUnknown
+}
+
+// fromTableNames lists the tables referenced in a FROM clause
+func fromTableNames(fromClause []*pg_query.Node) []string {
+	var tables []string
+	var visit func(node *pg_query.Node)
+	visit = func(node *pg_query.Node) {
+		switch n := node.Node.(type) {
+		case *pg_query.Node_RangeVar:
+			tables = append(tables, n.RangeVar.Relname)
+		case *pg_query.Node_JoinExpr:
+			visit(n.JoinExpr.Larg)
+			visit(n.JoinExpr.Rarg)
+		}
+	}
+	for _, from := range fromClause {
+		visit(from)
+	}
+	return tables
+}
+
+func init() {
+	text := []storage.ColumnType{storage.TypeString}
+	any := []storage.ColumnType{storage.TypeUnknown}
+
+	RegisterFunction(&Function{Name: "upper", Signatures: []FunctionSignature{
+		{Args: text, Result: storage.TypeString, Impl: func(args []interface{}) (interface{}, error) {
+			return strings.ToUpper(args[0].(string)), nil
+		}},
+	}})
+	RegisterFunction(&Function{Name: "lower", Signatures: []FunctionSignature{
+		{Args: text, Result: storage.TypeString, Impl: func(args []interface{}) (interface{}, error) {
+			return strings.ToLower(args[0].(string)), nil
+		}},
+	}})
+
+	// Aggregates are computed by evaluateAggregateFunction; the registry
+	// records their names and result types
+	RegisterFunction(&Function{Name: "count", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: any, Result: storage.TypeInteger},
+	}})
+	RegisterFunction(&Function{Name: "sum", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: any},
+	}})
+	// avg of integers and numerics is an exact numeric; of floats, a float
+	RegisterFunction(&Function{Name: "avg", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: []storage.ColumnType{storage.TypeNumeric}, Result: storage.TypeNumeric},
+		{Args: []storage.ColumnType{storage.TypeFloat}, Result: storage.TypeFloat},
+	}})
+	RegisterFunction(&Function{Name: "max", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: any},
+	}})
+	RegisterFunction(&Function{Name: "min", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: any},
+	}})
+}
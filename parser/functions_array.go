@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+func init() {
+	integer := storage.TypeInteger
+	text := storage.TypeString
+	any := storage.TypeUnknown
+	// anyarray accepts an array of any element type
+	anyarray := storage.ArrayOf(storage.TypeUnknown)
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+	array := func(args []interface{}, i int) storage.Array { return args[i].(storage.Array) }
+
+	// unnest is a set-returning function used in FROM
+	RegisterFunction(&Function{Name: "unnest", Kind: SetReturningFunction, Columns: []string{"unnest"}, Signatures: []FunctionSignature{
+		{Args: []storage.ColumnType{anyarray}, Impl: func(args []interface{}) (interface{}, error) {
+			elems := array(args, 0).Elems
+			rows := make([]storage.Row, len(elems))
+			for i, elem := range elems {
+				rows[i] = storage.Row{"unnest": elem}
+			}
+			return rows, nil
+		}},
+	}})
+
+	// Arrays here have a single dimension, so other dimensions have no length
+	scalar("array_length", FunctionSignature{Args: []storage.ColumnType{anyarray, integer}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+		elems := array(args, 0).Elems
+		if len(elems) == 0 || args[1].(int) != 1 {
+			return nil, nil
+		}
+		return len(elems), nil
+	}})
+	scalar("cardinality", FunctionSignature{Args: []storage.ColumnType{anyarray}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+		return len(array(args, 0).Elems), nil
+	}})
+
+	// array_append and array_cat treat a NULL array as empty
+	scalar("array_append", FunctionSignature{Args: []storage.ColumnType{anyarray, any}, Result: anyarray, CalledOnNull: true, Impl: func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return makeArray([]interface{}{args[1]})
+		}
+		return arrayConcat(args[0], args[1])
+	}})
+	scalar("array_cat", FunctionSignature{Args: []storage.ColumnType{anyarray, anyarray}, Result: anyarray, CalledOnNull: true, Impl: func(args []interface{}) (interface{}, error) {
+		switch {
+		case args[0] == nil:
+			return args[1], nil
+		case args[1] == nil:
+			return args[0], nil
+		}
+		return arrayConcat(args[0], args[1])
+	}})
+
+	// array_to_string(array, delimiter [, null_string]) skips NULL elements
+	// unless given text to show for them
+	toString := func(args []interface{}) (interface{}, error) {
+		var parts []string
+		for _, elem := range array(args, 0).Elems {
+			switch {
+			case elem != nil:
+				parts = append(parts, textOf(elem))
+			case len(args) > 2 && args[2] != nil:
+				parts = append(parts, args[2].(string))
+			}
+		}
+		return strings.Join(parts, args[1].(string)), nil
+	}
+	scalar("array_to_string",
+		FunctionSignature{Args: []storage.ColumnType{anyarray, text}, Result: text, Impl: toString},
+		FunctionSignature{Args: []storage.ColumnType{anyarray, text, text}, Result: text, CalledOnNull: true, Impl: func(args []interface{}) (interface{}, error) {
+			if args[0] == nil || args[1] == nil {
+				return nil, nil
+			}
+			return toString(args)
+		}},
+	)
+
+	// array_agg is computed by evaluateAggregateFunction
+	RegisterFunction(&Function{Name: "array_agg", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: []storage.ColumnType{any}, Result: anyarray},
+	}})
+}
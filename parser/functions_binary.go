@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Functions on uuid and bytea values
+
+func init() {
+	bytea := storage.TypeBytea
+	text := storage.TypeString
+	integer := storage.TypeInteger
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+	data := func(args []interface{}, i int) []byte { return []byte(args[i].(storage.Bytea)) }
+
+	scalar("gen_random_uuid", FunctionSignature{Result: storage.TypeUUID, Impl: func(args []interface{}) (interface{}, error) {
+		return storage.NewRandomUUID(), nil
+	}})
+
+	// The length of a bytea is in bytes; these add to the text overloads
+	length := FunctionSignature{Args: []storage.ColumnType{bytea}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+		return len(data(args, 0)), nil
+	}}
+	scalar("length", length)
+	scalar("octet_length", length)
+	scalar("md5", FunctionSignature{Args: []storage.ColumnType{bytea}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		sum := md5.Sum(data(args, 0))
+		return hex.EncodeToString(sum[:]), nil
+	}})
+
+	// encode and decode convert between bytea and its hex, base64 or
+	// escape text form
+	scalar("encode", FunctionSignature{Args: []storage.ColumnType{bytea, text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		switch strings.ToLower(args[1].(string)) {
+		case "hex":
+			return hex.EncodeToString(data(args, 0)), nil
+		case "base64":
+			return base64.StdEncoding.EncodeToString(data(args, 0)), nil
+		case "escape":
+			var sb strings.Builder
+			for _, b := range data(args, 0) {
+				switch {
+				case b == '\\':
+					sb.WriteString(`\\`)
+				case b < 0x20 || b >= 0x7f:
+					sb.WriteByte('\\')
+					sb.WriteByte('0' + b>>6)
+					sb.WriteByte('0' + b>>3&7)
+					sb.WriteByte('0' + b&7)
+				default:
+					sb.WriteByte(b)
+				}
+			}
+			return sb.String(), nil
+		}
+		return nil, newSQLError(SQLStateInvalidParameter, "unrecognized encoding: \"%s\"", args[1])
+	}})
+	scalar("decode", FunctionSignature{Args: []storage.ColumnType{text, text}, Result: bytea, Impl: func(args []interface{}) (interface{}, error) {
+		s := args[0].(string)
+		switch strings.ToLower(args[1].(string)) {
+		case "hex":
+			return storage.ParseBytea(`\x` + s)
+		case "base64":
+			decoded, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, newSQLError(SQLStateInvalidParameter, "invalid base64 end sequence")
+			}
+			return storage.Bytea(decoded), nil
+		case "escape":
+			return storage.ParseBytea(s)
+		}
+		return nil, newSQLError(SQLStateInvalidParameter, "unrecognized encoding: \"%s\"", args[1])
+	}})
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Functions used with the system catalog
+
+func init() {
+	integer := storage.TypeInteger
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+
+	// format_type(type_oid, typmod) names a type the way \d and ORMs show
+	// it. A NULL typmod means the type has none.
+	scalar("format_type", FunctionSignature{Args: []storage.ColumnType{integer, integer}, Result: storage.TypeString, CalledOnNull: true, Impl: func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		typmod := -1
+		if args[1] != nil {
+			typmod = args[1].(int)
+		}
+		return formatType(uint32(args[0].(int)), typmod), nil
+	}})
+
+	// pg_get_expr(expr, relation) decompiles a stored expression; they are
+	// kept as their SQL text
+	scalar("pg_get_expr", FunctionSignature{Args: []storage.ColumnType{storage.TypeString, integer}, Result: storage.TypeString, Impl: func(args []interface{}) (interface{}, error) {
+		return args[0], nil
+	}})
+
+	// Objects have no comments
+	noComment := func(args []interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	scalar("col_description", FunctionSignature{Args: []storage.ColumnType{integer, integer}, Result: storage.TypeString, Impl: noComment})
+	scalar("obj_description",
+		FunctionSignature{Args: []storage.ColumnType{integer}, Result: storage.TypeString, Impl: noComment},
+		FunctionSignature{Args: []storage.ColumnType{integer, storage.TypeString}, Result: storage.TypeString, Impl: noComment})
+
+	// Every table lives in the public schema, which is always on the path
+	scalar("pg_table_is_visible", FunctionSignature{Args: []storage.ColumnType{integer}, Result: storage.TypeBoolean, Impl: func(args []interface{}) (interface{}, error) {
+		return true, nil
+	}})
+}
+
+// formatType returns the SQL name of a type, with its modifiers
+func formatType(oid uint32, typmod int) string {
+	if elem, ok := arrayElementPgType(oid); ok {
+		return formatType(elem.oid, typmod) + "[]"
+	}
+	if enumType, ok := storage.LookupEnumOID(oid); ok {
+		return storage.EnumOf(enumType).Name
+	}
+	t, ok := pgTypeByOID(oid)
+	if !ok {
+		return "???"
+	}
+	if typmod < 4 {
+		return t.sqlName
+	}
+	switch t.name {
+	case "varchar", "bpchar":
+		return fmt.Sprintf("%s(%d)", t.sqlName, typmod-4)
+	case "numeric":
+		return fmt.Sprintf("numeric(%d,%d)", (typmod-4)>>16, (typmod-4)&0xffff)
+	}
+	return t.sqlName
+}
@@ -0,0 +1,650 @@
+package parser
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// truncateTime truncates t to the start of the named unit
+func truncateTime(unit string, t time.Time, typeName string) (time.Time, error) {
+	y, m, d := t.Date()
+	switch unit {
+	case "microseconds":
+		return t.Truncate(time.Microsecond), nil
+	case "milliseconds":
+		return t.Truncate(time.Millisecond), nil
+	case "second":
+		return t.Truncate(time.Second), nil
+	case "minute":
+		return t.Truncate(time.Minute), nil
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location()), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), nil
+	case "week":
+		// Weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location()), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location()), nil
+	case "quarter":
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, t.Location()), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	case "decade":
+		return time.Date(y-y%10, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	case "century":
+		return time.Date((y-1)/100*100+1, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	case "millennium":
+		return time.Date((y-1)/1000*1000+1, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, unitNotRecognized(unit, typeName)
+}
+
+// truncateInterval truncates an interval to the named unit
+func truncateInterval(unit string, iv storage.Interval) (storage.Interval, error) {
+	switch unit {
+	case "microseconds":
+		return iv, nil
+	case "milliseconds":
+		return storage.Interval{Months: iv.Months, Days: iv.Days, Micros: iv.Micros / 1000 * 1000}, nil
+	case "second":
+		return storage.Interval{Months: iv.Months, Days: iv.Days, Micros: iv.Micros / storage.MicrosPerSecond * storage.MicrosPerSecond}, nil
+	case "minute":
+		return storage.Interval{Months: iv.Months, Days: iv.Days, Micros: iv.Micros / storage.MicrosPerMinute * storage.MicrosPerMinute}, nil
+	case "hour":
+		return storage.Interval{Months: iv.Months, Days: iv.Days, Micros: iv.Micros / storage.MicrosPerHour * storage.MicrosPerHour}, nil
+	case "day":
+		return storage.Interval{Months: iv.Months, Days: iv.Days}, nil
+	case "month":
+		return storage.Interval{Months: iv.Months}, nil
+	case "quarter":
+		return storage.Interval{Months: iv.Months / 3 * 3}, nil
+	case "year":
+		return storage.Interval{Months: iv.Months / 12 * 12}, nil
+	case "decade":
+		return storage.Interval{Months: iv.Months / 120 * 120}, nil
+	case "century":
+		return storage.Interval{Months: iv.Months / 1200 * 1200}, nil
+	case "millennium":
+		return storage.Interval{Months: iv.Months / 12000 * 12000}, nil
+	}
+	return storage.Interval{}, unitNotRecognized(unit, "interval")
+}
+
+// normalizeUnit maps the spellings PostgreSQL accepts for a field to one name
+func normalizeUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	switch unit {
+	case "microsecond", "us", "usec", "usecs":
+		return "microseconds"
+	case "millisecond", "ms", "msec", "msecs":
+		return "milliseconds"
+	case "seconds", "sec", "secs", "s":
+		return "second"
+	case "minutes", "min", "mins", "m":
+		return "minute"
+	case "hours", "hr", "hrs", "h":
+		return "hour"
+	case "days", "d":
+		return "day"
+	case "weeks", "w":
+		return "week"
+	case "months", "mon", "mons":
+		return "month"
+	case "quarters", "qtr":
+		return "quarter"
+	case "years", "yr", "yrs", "y":
+		return "year"
+	case "decades":
+		return "decade"
+	case "centuries":
+		return "century"
+	case "millennia", "millenniums":
+		return "millennium"
+	}
+	return unit
+}
+
+func unitNotRecognized(unit, typeName string) error {
+	return newSQLError(SQLStateInvalidParameter, "unit \"%s\" not recognized for type %s", unit, typeName)
+}
+
+// extractField returns a field of a date/time value as the exact numeric
+// EXTRACT produces; date_part returns the same value as a double
+func extractField(field string, value interface{}) (storage.Numeric, error) {
+	unit := normalizeUnit(field)
+	switch v := value.(type) {
+	case storage.Timestamp:
+		return extractTimeField(unit, v.Time, sqlTypeName(storage.TypeTimestamp), false)
+	case storage.TimestampTZ:
+		return extractTimeField(unit, v.UTC(), sqlTypeName(storage.TypeTimestampTZ), true)
+	case storage.TimeOfDay:
+		switch unit {
+		case "hour", "minute", "second", "milliseconds", "microseconds":
+			return extractClockField(unit, v.Micros), nil
+		case "epoch":
+			return storage.NewNumeric(v.Micros, 6), nil
+		}
+		return storage.Numeric{}, unitNotRecognized(field, sqlTypeName(storage.TypeTime))
+	case storage.Interval:
+		years, months := int64(v.Months/12), int64(v.Months%12)
+		switch unit {
+		case "hour", "minute", "second", "milliseconds", "microseconds":
+			return extractClockField(unit, v.Micros), nil
+		case "day":
+			return storage.NumericFromInt(int64(v.Days)), nil
+		case "month":
+			return storage.NumericFromInt(months), nil
+		case "quarter":
+			return storage.NumericFromInt(months/3 + 1), nil
+		case "year":
+			return storage.NumericFromInt(years), nil
+		case "decade":
+			return storage.NumericFromInt(years / 10), nil
+		case "century":
+			return storage.NumericFromInt(years / 100), nil
+		case "millennium":
+			return storage.NumericFromInt(years / 1000), nil
+		case "epoch":
+			// A year counts 365.25 days and a month 30 days
+			const microsPerDay = 24 * storage.MicrosPerHour
+			micros := v.Micros + int64(v.Days)*microsPerDay + months*30*microsPerDay + years*microsPerDay*1461/4
+			return storage.NewNumeric(micros, 6), nil
+		}
+		return storage.Numeric{}, unitNotRecognized(field, "interval")
+	}
+	return storage.Numeric{}, unitNotRecognized(field, valueTypeName(value))
+}
+
+// extractClockField returns an hour, minute or second field of a time in
+// microseconds. Seconds keep their fraction, with six digits as in PostgreSQL.
+func extractClockField(unit string, micros int64) storage.Numeric {
+	seconds := micros % storage.MicrosPerMinute
+	switch unit {
+	case "hour":
+		return storage.NumericFromInt(micros / storage.MicrosPerHour)
+	case "minute":
+		return storage.NumericFromInt(micros / storage.MicrosPerMinute % 60)
+	case "second":
+		return storage.NewNumeric(seconds, 6)
+	case "milliseconds":
+		return storage.NewNumeric(seconds, 3)
+	default:
+		return storage.NumericFromInt(seconds)
+	}
+}
+
+func extractTimeField(unit string, t time.Time, typeName string, withZone bool) (storage.Numeric, error) {
+	year := int64(t.Year())
+	switch unit {
+	case "hour", "minute", "second", "milliseconds", "microseconds":
+		return extractClockField(unit, storage.TimeOf(t).Micros), nil
+	case "day":
+		return storage.NumericFromInt(int64(t.Day())), nil
+	case "month":
+		return storage.NumericFromInt(int64(t.Month())), nil
+	case "quarter":
+		return storage.NumericFromInt(int64((t.Month()-1)/3 + 1)), nil
+	case "year":
+		return storage.NumericFromInt(year), nil
+	case "decade":
+		return storage.NumericFromInt(year / 10), nil
+	case "century":
+		return storage.NumericFromInt((year + 99) / 100), nil
+	case "millennium":
+		return storage.NumericFromInt((year + 999) / 1000), nil
+	case "dow":
+		return storage.NumericFromInt(int64(t.Weekday())), nil
+	case "isodow":
+		return storage.NumericFromInt(int64((t.Weekday()+6)%7 + 1)), nil
+	case "doy":
+		return storage.NumericFromInt(int64(t.YearDay())), nil
+	case "week":
+		_, week := t.ISOWeek()
+		return storage.NumericFromInt(int64(week)), nil
+	case "isoyear":
+		isoYear, _ := t.ISOWeek()
+		return storage.NumericFromInt(int64(isoYear)), nil
+	case "epoch":
+		return storage.NewNumeric(t.UnixMicro(), 6), nil
+	case "timezone", "timezone_hour", "timezone_minute":
+		if withZone {
+			return storage.NumericFromInt(0), nil
+		}
+	}
+	return storage.Numeric{}, unitNotRecognized(unit, typeName)
+}
+
+// toCharPatterns are the template patterns to_char and to_timestamp
+// understand, longest first so that a prefix never shadows a longer pattern
+var toCharPatterns = []string{
+	"A.M.", "P.M.", "a.m.", "p.m.",
+	"HH24", "HH12", "YYYY", "SSSS",
+	"MONTH", "Month", "month",
+	"YYY", "MON", "Mon", "mon", "DAY", "Day", "day", "DDD",
+	"HH", "MI", "SS", "MS", "US", "AM", "PM", "am", "pm", "YY",
+	"MM", "DY", "Dy", "dy", "DD", "ID", "IW", "WW", "TZ", "tz",
+	"Y", "D", "Q",
+}
+
+// matchTemplatePattern returns the template pattern at the start of s, if any
+func matchTemplatePattern(s string) string {
+	for _, p := range toCharPatterns {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// casedName returns name in the letter case the pattern is written in
+func casedName(name, pattern string) string {
+	switch {
+	case pattern == strings.ToUpper(pattern):
+		return strings.ToUpper(name)
+	case pattern == strings.ToLower(pattern):
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// formatTimestamp renders t using a to_char template
+func formatTimestamp(t time.Time, format string, withZone bool) string {
+	var sb strings.Builder
+	fillMode := false
+	for i := 0; i < len(format); {
+		rest := format[i:]
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				sb.WriteString(rest[1:])
+				break
+			}
+			sb.WriteString(rest[1 : end+1])
+			i += end + 2
+			continue
+		}
+		if strings.HasPrefix(rest, "FM") || strings.HasPrefix(rest, "fm") {
+			fillMode = true
+			i += 2
+			continue
+		}
+		pattern := matchTemplatePattern(rest)
+		if pattern == "" {
+			sb.WriteByte(rest[0])
+			i++
+			continue
+		}
+		i += len(pattern)
+
+		number := func(n, width int) string {
+			s := strconv.Itoa(n)
+			if !fillMode && len(s) < width {
+				s = strings.Repeat("0", width-len(s)) + s
+			}
+			return s
+		}
+		name := func(n string, width int) string {
+			n = casedName(n, pattern)
+			if !fillMode && len(n) < width {
+				n += strings.Repeat(" ", width-len(n))
+			}
+			return n
+		}
+		hour12 := t.Hour() % 12
+		if hour12 == 0 {
+			hour12 = 12
+		}
+		_, isoWeek := t.ISOWeek()
+
+		switch pattern {
+		case "HH24":
+			sb.WriteString(number(t.Hour(), 2))
+		case "HH12", "HH":
+			sb.WriteString(number(hour12, 2))
+		case "MI":
+			sb.WriteString(number(t.Minute(), 2))
+		case "SS":
+			sb.WriteString(number(t.Second(), 2))
+		case "SSSS":
+			sb.WriteString(strconv.Itoa(t.Hour()*3600 + t.Minute()*60 + t.Second()))
+		case "MS":
+			sb.WriteString(number(t.Nanosecond()/1000000, 3))
+		case "US":
+			sb.WriteString(number(t.Nanosecond()/1000, 6))
+		case "AM", "PM", "am", "pm", "A.M.", "P.M.", "a.m.", "p.m.":
+			marker := "AM"
+			if t.Hour() >= 12 {
+				marker = "PM"
+			}
+			if strings.Contains(pattern, ".") {
+				marker = marker[:1] + "." + marker[1:] + "."
+			}
+			sb.WriteString(casedName(marker, pattern))
+		case "YYYY":
+			sb.WriteString(number(t.Year(), 4))
+		case "YYY":
+			sb.WriteString(number(t.Year()%1000, 3))
+		case "YY":
+			sb.WriteString(number(t.Year()%100, 2))
+		case "Y":
+			sb.WriteString(strconv.Itoa(t.Year() % 10))
+		case "MONTH", "Month", "month":
+			sb.WriteString(name(t.Month().String(), 9))
+		case "MON", "Mon", "mon":
+			sb.WriteString(casedName(t.Month().String()[:3], pattern))
+		case "MM":
+			sb.WriteString(number(int(t.Month()), 2))
+		case "DAY", "Day", "day":
+			sb.WriteString(name(t.Weekday().String(), 9))
+		case "DY", "Dy", "dy":
+			sb.WriteString(casedName(t.Weekday().String()[:3], pattern))
+		case "DDD":
+			sb.WriteString(number(t.YearDay(), 3))
+		case "DD":
+			sb.WriteString(number(t.Day(), 2))
+		case "D":
+			sb.WriteString(strconv.Itoa(int(t.Weekday()) + 1))
+		case "ID":
+			sb.WriteString(strconv.Itoa((int(t.Weekday())+6)%7 + 1))
+		case "IW":
+			sb.WriteString(number(isoWeek, 2))
+		case "WW":
+			sb.WriteString(number((t.YearDay()-1)/7+1, 2))
+		case "Q":
+			sb.WriteString(strconv.Itoa((int(t.Month())-1)/3 + 1))
+		case "TZ", "tz":
+			if withZone {
+				sb.WriteString(casedName("UTC", pattern))
+			}
+		}
+		fillMode = false
+	}
+	return sb.String()
+}
+
+// parseWithTemplate reads a date/time written in a to_char template, as
+// to_timestamp and to_date do
+func parseWithTemplate(input, format string) (time.Time, error) {
+	year, month, day := 1, 1, 1
+	hour, minute, second, micros := 0, 0, 0, 0
+	pm, hasMeridiem := false, false
+	pos := 0
+
+	invalidValue := func(pattern string) error {
+		value := input[pos:]
+		if len(value) > 10 {
+			value = value[:10]
+		}
+		return newSQLError(SQLStateDatetimeFormat, "invalid value \"%s\" for \"%s\"", value, pattern)
+	}
+	readNumber := func(pattern string, maxDigits int) (int, error) {
+		for pos < len(input) && input[pos] == ' ' {
+			pos++
+		}
+		start := pos
+		if pos < len(input) && (input[pos] == '-' || input[pos] == '+') {
+			pos++
+		}
+		for pos < len(input) && pos-start < maxDigits && input[pos] >= '0' && input[pos] <= '9' {
+			pos++
+		}
+		n, err := strconv.Atoi(input[start:pos])
+		if err != nil {
+			pos = start
+			return 0, invalidValue(pattern)
+		}
+		return n, nil
+	}
+	readName := func(pattern string, names []string, minLength int) (int, error) {
+		for pos < len(input) && input[pos] == ' ' {
+			pos++
+		}
+		rest := strings.ToLower(input[pos:])
+		for i, n := range names {
+			n = strings.ToLower(n)
+			if strings.HasPrefix(rest, n) {
+				pos += len(n)
+				return i, nil
+			}
+			if strings.HasPrefix(rest, n[:minLength]) {
+				pos += minLength
+				return i, nil
+			}
+		}
+		return 0, invalidValue(pattern)
+	}
+	monthNames := make([]string, 12)
+	for i := range monthNames {
+		monthNames[i] = time.Month(i + 1).String()
+	}
+	dayNames := make([]string, 7)
+	for i := range dayNames {
+		dayNames[i] = time.Weekday(i).String()
+	}
+
+	var err error
+	for i := 0; i < len(format); {
+		rest := format[i:]
+		if strings.HasPrefix(rest, "FM") || strings.HasPrefix(rest, "fm") {
+			i += 2
+			continue
+		}
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			pos += end
+			i += end + 2
+			continue
+		}
+		pattern := matchTemplatePattern(rest)
+		if pattern == "" {
+			// Any other template character skips one input character
+			if pos < len(input) {
+				pos++
+			}
+			i++
+			continue
+		}
+		i += len(pattern)
+
+		switch pattern {
+		case "YYYY":
+			year, err = readNumber(pattern, 4)
+		case "YYY", "YY", "Y":
+			year, err = readNumber(pattern, len(pattern))
+			if err == nil && len(pattern) < 4 {
+				year += 2000
+			}
+		case "MM":
+			month, err = readNumber(pattern, 2)
+		case "MONTH", "Month", "month":
+			var m int
+			m, err = readName(pattern, monthNames, 3)
+			month = m + 1
+		case "MON", "Mon", "mon":
+			var m int
+			m, err = readName(pattern, monthNames, 3)
+			month = m + 1
+		case "DD":
+			day, err = readNumber(pattern, 2)
+		case "DDD":
+			var yday int
+			yday, err = readNumber(pattern, 3)
+			month, day = 1, yday
+		case "DAY", "Day", "day", "DY", "Dy", "dy":
+			_, err = readName(pattern, dayNames, 3)
+		case "D", "ID":
+			_, err = readNumber(pattern, 1)
+		case "HH24":
+			hour, err = readNumber(pattern, 2)
+		case "HH12", "HH":
+			hour, err = readNumber(pattern, 2)
+		case "MI":
+			minute, err = readNumber(pattern, 2)
+		case "SS":
+			second, err = readNumber(pattern, 2)
+		case "MS":
+			var ms int
+			ms, err = readNumber(pattern, 3)
+			micros = ms * 1000
+		case "US":
+			micros, err = readNumber(pattern, 6)
+		case "AM", "PM", "am", "pm", "A.M.", "P.M.", "a.m.", "p.m.":
+			var marker int
+			markers := []string{"am", "pm"}
+			if strings.Contains(pattern, ".") {
+				markers = []string{"a.m.", "p.m."}
+			}
+			marker, err = readName(pattern, markers, len(markers[0]))
+			pm, hasMeridiem = marker == 1, true
+		case "TZ", "tz":
+			_, err = readName(pattern, []string{"utc", "gmt"}, 3)
+		default:
+			err = newSQLError(SQLStateInvalidParameter, "formatting field \"%s\" is only supported in to_char", pattern)
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if hasMeridiem {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, newSQLError(SQLStateDatetimeOverflow, "hour \"%d\" is invalid for the 12-hour clock", hour).
+				withHint("Use the 24-hour clock, or give an hour between 1 and 12.")
+		}
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, micros*1000, time.UTC)
+	if t.Month() != time.Month(month) && month != 1 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, newSQLError(SQLStateDatetimeOverflow, "date/time field value out of range: \"%s\"", input)
+	}
+	return t, nil
+}
+
+func init() {
+	text := storage.TypeString
+	integer := storage.TypeInteger
+	float := storage.TypeFloat
+	numeric := storage.TypeNumeric
+	date := storage.TypeDate
+	timeOfDay := storage.TypeTime
+	timestamp := storage.TypeTimestamp
+	timestamptz := storage.TypeTimestampTZ
+	interval := storage.TypeInterval
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+	str := func(args []interface{}, i int) string { return args[i].(string) }
+
+	// now() returns the current time; it is not fixed for the transaction
+	scalar("now", FunctionSignature{Args: nil, Result: timestamptz, Impl: func(args []interface{}) (interface{}, error) {
+		return storage.NewTimestampTZ(time.Now()), nil
+	}})
+
+	scalar("date_trunc",
+		FunctionSignature{Args: []storage.ColumnType{text, timestamp}, Result: timestamp, Impl: func(args []interface{}) (interface{}, error) {
+			t, err := truncateTime(normalizeUnit(str(args, 0)), args[1].(storage.Timestamp).Time, sqlTypeName(timestamp))
+			return storage.Timestamp{Time: t}, err
+		}},
+		FunctionSignature{Args: []storage.ColumnType{text, timestamptz}, Result: timestamptz, Impl: func(args []interface{}) (interface{}, error) {
+			t, err := truncateTime(normalizeUnit(str(args, 0)), args[1].(storage.TimestampTZ).Time, sqlTypeName(timestamptz))
+			return storage.TimestampTZ{Time: t}, err
+		}},
+		FunctionSignature{Args: []storage.ColumnType{text, interval}, Result: interval, Impl: func(args []interface{}) (interface{}, error) {
+			return truncateInterval(normalizeUnit(str(args, 0)), args[1].(storage.Interval))
+		}},
+	)
+
+	// EXTRACT(field FROM value) is parsed as extract('field', value). It
+	// returns an exact numeric, while date_part returns a double.
+	extract := func(args []interface{}) (interface{}, error) {
+		return extractField(str(args, 0), args[1])
+	}
+	datePart := func(args []interface{}) (interface{}, error) {
+		n, err := extractField(str(args, 0), args[1])
+		if err != nil {
+			return nil, err
+		}
+		return n.Float64(), nil
+	}
+	for name, impl := range map[string]func([]interface{}) (interface{}, error){"date_part": datePart, "extract": extract} {
+		result := float
+		if name == "extract" {
+			result = numeric
+		}
+		scalar(name,
+			FunctionSignature{Args: []storage.ColumnType{text, timestamp}, Result: result, Impl: impl},
+			FunctionSignature{Args: []storage.ColumnType{text, timestamptz}, Result: result, Impl: impl},
+			FunctionSignature{Args: []storage.ColumnType{text, interval}, Result: result, Impl: impl},
+			FunctionSignature{Args: []storage.ColumnType{text, timeOfDay}, Result: result, Impl: impl},
+		)
+	}
+
+	scalar("age",
+		FunctionSignature{Args: []storage.ColumnType{timestamp, timestamp}, Result: interval, Impl: func(args []interface{}) (interface{}, error) {
+			return storage.Age(args[0].(storage.Timestamp).Time, args[1].(storage.Timestamp).Time), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{timestamptz, timestamptz}, Result: interval, Impl: func(args []interface{}) (interface{}, error) {
+			return storage.Age(args[0].(storage.TimestampTZ).Time, args[1].(storage.TimestampTZ).Time), nil
+		}},
+		// With one argument, age is measured from midnight today
+		FunctionSignature{Args: []storage.ColumnType{timestamp}, Result: interval, Impl: func(args []interface{}) (interface{}, error) {
+			return storage.Age(storage.DateOf(time.Now().UTC()).Time, args[0].(storage.Timestamp).Time), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{timestamptz}, Result: interval, Impl: func(args []interface{}) (interface{}, error) {
+			return storage.Age(storage.DateOf(time.Now().UTC()).Time, args[0].(storage.TimestampTZ).Time), nil
+		}},
+	)
+
+	scalar("to_char",
+		FunctionSignature{Args: []storage.ColumnType{timestamp, text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+			return formatTimestamp(args[0].(storage.Timestamp).Time, str(args, 1), false), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{timestamptz, text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+			return formatTimestamp(args[0].(storage.TimestampTZ).UTC(), str(args, 1), true), nil
+		}},
+	)
+
+	scalar("to_timestamp",
+		FunctionSignature{Args: []storage.ColumnType{float}, Result: timestamptz, Impl: func(args []interface{}) (interface{}, error) {
+			seconds := args[0].(float64)
+			whole := math.Floor(seconds)
+			return storage.NewTimestampTZ(time.Unix(int64(whole), int64(math.Round((seconds-whole)*1e9)))), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{text, text}, Result: timestamptz, Impl: func(args []interface{}) (interface{}, error) {
+			t, err := parseWithTemplate(str(args, 0), str(args, 1))
+			if err != nil {
+				return nil, err
+			}
+			return storage.NewTimestampTZ(t), nil
+		}},
+	)
+	scalar("to_date", FunctionSignature{Args: []storage.ColumnType{text, text}, Result: date, Impl: func(args []interface{}) (interface{}, error) {
+		t, err := parseWithTemplate(str(args, 0), str(args, 1))
+		if err != nil {
+			return nil, err
+		}
+		return storage.DateOf(t), nil
+	}})
+
+	scalar("make_date", FunctionSignature{Args: []storage.ColumnType{integer, integer, integer}, Result: date, Impl: func(args []interface{}) (interface{}, error) {
+		year, month, day := args[0].(int), args[1].(int), args[2].(int)
+		d := storage.NewDate(year, time.Month(month), day)
+		if d.Year() != year || int(d.Month()) != month || d.Day() != day || year == 0 {
+			return nil, newSQLError(SQLStateDatetimeOverflow, "date field value out of range: %d-%02d-%02d", year, month, day)
+		}
+		return d, nil
+	}})
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"github.com/satetsu888/vsql/storage"
+)
+
+// jsonEach lists an object's fields as rows of key and value, as
+// jsonb_each does. With asText, values are given as ->> gives them.
+func jsonEach(name string, doc storage.JSON, asText bool) ([]storage.Row, error) {
+	object, ok := doc.Doc.(map[string]interface{})
+	if !ok {
+		return nil, newSQLError(SQLStateInvalidParameter, "cannot call %s on a non-object", name)
+	}
+	rows := make([]storage.Row, 0, len(object))
+	for _, key := range storage.JSONKeys(object) {
+		var value interface{} = storage.JSON{Doc: object[key]}
+		if asText {
+			value = jsonText(object[key])
+		}
+		rows = append(rows, storage.Row{"key": key, "value": value})
+	}
+	return rows, nil
+}
+
+func init() {
+	jsonb := storage.TypeJSON
+	text := storage.TypeString
+	boolean := storage.TypeBoolean
+	any := storage.TypeUnknown
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+	doc := func(args []interface{}, i int) storage.JSON { return args[i].(storage.JSON) }
+
+	// json values are read as jsonb, so each json_ function is
+	// registered under both names
+	both := func(name string, register func(name string)) {
+		register("json" + name)
+		register("jsonb" + name)
+	}
+
+	// build_object takes alternating keys and values
+	buildObject := func(name string) FunctionImpl {
+		return func(args []interface{}) (interface{}, error) {
+			if len(args)%2 != 0 {
+				return nil, newSQLError(SQLStateInvalidParameter, "argument list must have even number of elements").
+					withHint("The arguments of " + name + "() must consist of alternating keys and values.")
+			}
+			object := make(map[string]interface{}, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				if args[i] == nil {
+					return nil, newSQLError(SQLStateNullNotAllowed, "argument %d: key must not be null", i+1)
+				}
+				object[toString(args[i])] = toJSONDoc(args[i+1])
+			}
+			return storage.JSON{Doc: object}, nil
+		}
+	}
+	both("_build_object", func(name string) {
+		scalar(name,
+			FunctionSignature{Result: jsonb, Impl: buildObject(name)},
+			FunctionSignature{Args: []storage.ColumnType{any}, Variadic: true, Result: jsonb, CalledOnNull: true, Impl: buildObject(name)},
+		)
+	})
+
+	toJSON := FunctionSignature{Args: []storage.ColumnType{any}, Result: jsonb, Impl: func(args []interface{}) (interface{}, error) {
+		return storage.JSON{Doc: toJSONDoc(args[0])}, nil
+	}}
+	scalar("to_json", toJSON)
+	scalar("to_jsonb", toJSON)
+
+	// jsonb_set(target, path, new_value [, create_missing])
+	set := func(args []interface{}) (interface{}, error) {
+		path, err := jsonPath(args[1])
+		if err != nil {
+			return nil, err
+		}
+		create := true
+		if len(args) > 3 {
+			create = args[3].(bool)
+		}
+		result, err := jsonSet(doc(args, 0).Doc, path, doc(args, 2).Doc, create, 1)
+		if err != nil {
+			return nil, err
+		}
+		return storage.JSON{Doc: result}, nil
+	}
+	scalar("jsonb_set",
+		FunctionSignature{Args: []storage.ColumnType{jsonb, any, jsonb}, Result: jsonb, Impl: set},
+		FunctionSignature{Args: []storage.ColumnType{jsonb, any, jsonb, boolean}, Result: jsonb, Impl: set},
+	)
+
+	both("_typeof", func(name string) {
+		scalar(name, FunctionSignature{Args: []storage.ColumnType{jsonb}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+			return doc(args, 0).TypeName(), nil
+		}})
+	})
+
+	// json_each and json_each_text are set-returning functions used in FROM
+	for _, asText := range []bool{false, true} {
+		asText := asText
+		suffix := "_each"
+		if asText {
+			suffix = "_each_text"
+		}
+		both(suffix, func(name string) {
+			RegisterFunction(&Function{Name: name, Kind: SetReturningFunction, Columns: []string{"key", "value"}, Signatures: []FunctionSignature{
+				{Args: []storage.ColumnType{jsonb}, Impl: func(args []interface{}) (interface{}, error) {
+					return jsonEach(name, doc(args, 0), asText)
+				}},
+			}})
+		})
+	}
+
+	// json_agg is computed by evaluateAggregateFunction
+	both("_agg", func(name string) {
+		RegisterFunction(&Function{Name: name, Kind: AggregateFunction, Signatures: []FunctionSignature{
+			{Args: []storage.ColumnType{any}, Result: jsonb},
+		}})
+	})
+}
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"math"
+	"math/rand"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// evaluateMinMaxExpr computes GREATEST and LEAST, which ignore NULL
+// arguments and are NULL only when every argument is
+func evaluateMinMaxExpr(expr *pg_query.MinMaxExpr, row storage.Row, ctx *QueryContext) interface{} {
+	args := make([]interface{}, len(expr.Args))
+	for i, arg := range expr.Args {
+		args[i] = evaluateExpression(arg, row, ctx)
+	}
+	return extremeOf(args, expr.Op == pg_query.MinMaxOp_IS_GREATEST)
+}
+
+// extremeOf returns the largest (or smallest) non-NULL value
+func extremeOf(args []interface{}, greatest bool) interface{} {
+	var result interface{}
+	for _, arg := range args {
+		if arg == nil {
+			continue
+		}
+		if result == nil {
+			result = arg
+			continue
+		}
+		cmp := compareForSort(arg, result)
+		if (greatest && cmp > 0) || (!greatest && cmp < 0) {
+			result = arg
+		}
+	}
+	return result
+}
+
+func init() {
+	integer := storage.TypeInteger
+	numeric := storage.TypeNumeric
+	float := storage.TypeFloat
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+	num := func(args []interface{}, i int) int { return args[i].(int) }
+	dec := func(args []interface{}, i int) storage.Numeric { return args[i].(storage.Numeric) }
+	flt := func(args []interface{}, i int) float64 { return args[i].(float64) }
+
+	// Functions of one number take double precision for integers and floats,
+	// and keep numerics exact, as PostgreSQL's overload resolution does
+	unary := func(name string, exact func(storage.Numeric) interface{}, approx func(float64) interface{}) {
+		scalar(name,
+			FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+				return approx(flt(args, 0)), nil
+			}},
+			FunctionSignature{Args: []storage.ColumnType{numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+				return exact(dec(args, 0)), nil
+			}},
+		)
+	}
+
+	scalar("abs",
+		FunctionSignature{Args: []storage.ColumnType{integer}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+			return unaryArithmetic("@", num(args, 0))
+		}},
+		FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+			return math.Abs(flt(args, 0)), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return dec(args, 0).Abs(), nil
+		}},
+	)
+
+	unary("ceil", func(n storage.Numeric) interface{} { return n.Ceil() }, func(f float64) interface{} { return math.Ceil(f) })
+	unary("ceiling", func(n storage.Numeric) interface{} { return n.Ceil() }, func(f float64) interface{} { return math.Ceil(f) })
+	unary("floor", func(n storage.Numeric) interface{} { return n.Floor() }, func(f float64) interface{} { return math.Floor(f) })
+	unary("sign", func(n storage.Numeric) interface{} { return storage.NumericFromInt(int64(n.Sign())) }, func(f float64) interface{} {
+		switch {
+		case f > 0:
+			return 1.0
+		case f < 0:
+			return -1.0
+		}
+		return 0.0
+	})
+
+	// round and trunc take an optional number of decimal places, which may
+	// be negative to round to the left of the decimal point
+	scalar("round",
+		FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+			// Halves round to even, as the C library's rint does
+			return math.RoundToEven(flt(args, 0)), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return dec(args, 0).Round(0), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric, integer}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return dec(args, 0).Round(num(args, 1)), nil
+		}},
+	)
+	scalar("trunc",
+		FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+			return math.Trunc(flt(args, 0)), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return dec(args, 0).Trunc(0), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric, integer}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return dec(args, 0).Trunc(num(args, 1)), nil
+		}},
+	)
+
+	scalar("sqrt",
+		FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+			return unaryArithmetic("|/", flt(args, 0))
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			if dec(args, 0).Sign() < 0 {
+				return nil, newSQLError(SQLStateInvalidArgumentForPower, "cannot take square root of a negative number")
+			}
+			return dec(args, 0).Sqrt(), nil
+		}},
+	)
+	scalar("cbrt", FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+		return math.Cbrt(flt(args, 0)), nil
+	}})
+
+	power := []FunctionSignature{
+		{Args: []storage.ColumnType{float, float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+			return floatPower(flt(args, 0), flt(args, 1))
+		}},
+		{Args: []storage.ColumnType{numeric, numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return numericPower(dec(args, 0), dec(args, 1))
+		}},
+	}
+	scalar("power", power...)
+	scalar("pow", power...)
+
+	scalar("exp", FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+		return math.Exp(flt(args, 0)), nil
+	}})
+	logarithm := func(f func(float64) float64) FunctionImpl {
+		return func(args []interface{}) (interface{}, error) {
+			x := flt(args, 0)
+			if x == 0 {
+				return nil, newSQLError(SQLStateInvalidArgumentForLogarithm, "cannot take logarithm of zero")
+			}
+			if x < 0 {
+				return nil, newSQLError(SQLStateInvalidArgumentForLogarithm, "cannot take logarithm of a negative number")
+			}
+			return f(x), nil
+		}
+	}
+	scalar("ln", FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: logarithm(math.Log)})
+	scalar("log10", FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: logarithm(math.Log10)})
+	scalar("log",
+		FunctionSignature{Args: []storage.ColumnType{float}, Result: float, Impl: logarithm(math.Log10)},
+		// log(b, x) is the logarithm of x to base b
+		FunctionSignature{Args: []storage.ColumnType{float, float}, Result: float, Impl: func(args []interface{}) (interface{}, error) {
+			base, err := logarithm(math.Log)(args[:1])
+			if err != nil {
+				return nil, err
+			}
+			x, err := logarithm(math.Log)(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			if base.(float64) == 0 {
+				return nil, newSQLError(SQLStateDivisionByZero, "division by zero")
+			}
+			return x.(float64) / base.(float64), nil
+		}},
+	)
+	scalar("pi", FunctionSignature{Result: float, Impl: func(args []interface{}) (interface{}, error) {
+		return math.Pi, nil
+	}})
+
+	scalar("mod",
+		FunctionSignature{Args: []storage.ColumnType{integer, integer}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+			return numericArithmetic("%", args[0], args[1], false)
+		}},
+		FunctionSignature{Args: []storage.ColumnType{numeric, numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+			return numericArithmetic("%", args[0], args[1], false)
+		}},
+	)
+	// div is integer division of numerics, truncating toward zero
+	scalar("div", FunctionSignature{Args: []storage.ColumnType{numeric, numeric}, Result: numeric, Impl: func(args []interface{}) (interface{}, error) {
+		if dec(args, 1).Sign() == 0 {
+			return nil, newSQLError(SQLStateDivisionByZero, "division by zero")
+		}
+		a, b := dec(args, 0), dec(args, 1)
+		return a.Sub(a.Mod(b)).QuoScale(b, 0), nil
+	}})
+
+	scalar("random", FunctionSignature{Result: float, Impl: func(args []interface{}) (interface{}, error) {
+		return rand.Float64(), nil
+	}})
+}
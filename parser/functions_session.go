@@ -0,0 +1,350 @@
+package parser
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Functions that report on the server and the current session
+
+// serverVersion is the PostgreSQL version the server claims to be
+var serverVersion = "12.0"
+
+// SetServerVersion changes the PostgreSQL version the server reports, such
+// as "16.4" or "9.6.24"
+func SetServerVersion(version string) error {
+	if _, err := versionNum(version); err != nil {
+		return err
+	}
+	serverVersion = version
+	return nil
+}
+
+// ServerVersion returns the PostgreSQL version the server reports
+func ServerVersion() string {
+	return serverVersion
+}
+
+// ServerVersionNum returns the server version as a number, such as 160004
+// for 16.4
+func ServerVersionNum() int {
+	num, _ := versionNum(serverVersion)
+	return num
+}
+
+// versionNum converts a version to the form server_version_num reports.
+// Versions before 10 have three parts, later ones two.
+func versionNum(version string) (int, error) {
+	parts := strings.Split(version, ".")
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || i >= len(nums) {
+			return 0, fmt.Errorf("invalid server version %q", version)
+		}
+		nums[i] = n
+	}
+	if nums[0] >= 10 {
+		if len(parts) > 2 || nums[1] > 9999 {
+			return 0, fmt.Errorf("invalid server version %q", version)
+		}
+		return nums[0]*10000 + nums[1], nil
+	}
+	if nums[1] > 99 || nums[2] > 99 {
+		return 0, fmt.Errorf("invalid server version %q", version)
+	}
+	return nums[0]*10000 + nums[1]*100 + nums[2], nil
+}
+
+// versionString is the text version() returns
+func versionString() string {
+	return fmt.Sprintf("PostgreSQL %s (vsql) on %s-%s, compiled by %s, 64-bit", serverVersion, runtime.GOARCH, runtime.GOOS, runtime.Version())
+}
+
+// sessionFunction computes a function whose result depends on the session
+// that calls it
+type sessionFunction func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error)
+
+// sessionFunctions are bound to the calling session's values by
+// bindSessionFunctions before a statement runs
+var sessionFunctions = map[string]sessionFunction{
+	"current_database": func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error) {
+		return session.Database(), nil
+	},
+	"current_schema": func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error) {
+		return session.currentSchema(dataStore), nil
+	},
+	"current_user": func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error) {
+		return session.User(), nil
+	},
+	"session_user": func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error) {
+		return session.User(), nil
+	},
+	"pg_backend_pid": func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error) {
+		return int(session.ProcessID()), nil
+	},
+	// current_setting(name [, missing_ok]) returns NULL for an unknown
+	// parameter when missing_ok is true
+	"current_setting": func(session *Session, dataStore *storage.DataStore, args []interface{}) (interface{}, error) {
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		if value, exists := session.Setting(name); exists {
+			return value, nil
+		}
+		if len(args) > 1 {
+			if missingOK, _ := coerceValue(args[1], storage.TypeBoolean); missingOK == true {
+				return nil, nil
+			}
+		}
+		return nil, newSQLError(SQLStateUndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+	},
+}
+
+func init() {
+	text := storage.TypeString
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+
+	scalar("version", FunctionSignature{Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		return versionString(), nil
+	}})
+
+	// Session functions are registered for their types and argument counts;
+	// calls are replaced with their values before evaluation, which leaves
+	// only calls whose arguments are not constants
+	unbound := func(name string) FunctionImpl {
+		return func(args []interface{}) (interface{}, error) {
+			return nil, newSQLError(SQLStateFeatureNotSupported, "%s() only accepts constant arguments", name)
+		}
+	}
+	for _, name := range []string{"current_database", "current_schema", "current_user", "session_user"} {
+		scalar(name, FunctionSignature{Result: text, Impl: unbound(name)})
+	}
+	scalar("pg_backend_pid", FunctionSignature{Result: storage.TypeInteger, Impl: unbound("pg_backend_pid")})
+	scalar("current_setting",
+		FunctionSignature{Args: []storage.ColumnType{text}, Result: text, Impl: unbound("current_setting")},
+		FunctionSignature{Args: []storage.ColumnType{text, storage.TypeBoolean}, Result: text, Impl: unbound("current_setting")})
+}
+
+// bindSessionFunctions replaces calls to session functions, and keywords
+// such as CURRENT_USER, with the values they have in session, and relation
+// names cast to regclass with the relations they find on its search path.
+// Calls whose arguments are not constants are left in place.
+func bindSessionFunctions(stmt *pg_query.Node, session *Session, dataStore *storage.DataStore) error {
+	var err error
+	bind := func(node *pg_query.Node) {
+		walkExpr(node, func(n *pg_query.Node) bool {
+			if err != nil {
+				return false
+			}
+			switch expr := n.Node.(type) {
+			case *pg_query.Node_ResTarget:
+				// Keep the column name the call would have had
+				if expr.ResTarget.Name == "" && expr.ResTarget.Val != nil {
+					if name := sessionValueName(expr.ResTarget.Val); name != "" {
+						expr.ResTarget.Name = name
+					}
+				}
+			case *pg_query.Node_FuncCall:
+				var value interface{}
+				var bound bool
+				if value, bound, err = callSessionFunction(expr.FuncCall, session, dataStore); bound {
+					n.Node = constNode(value).Node
+					return false
+				}
+			case *pg_query.Node_SqlvalueFunction:
+				if name := sessionValueFunctionName(expr.SqlvalueFunction); name != "" {
+					value, _ := sessionFunctions[name](session, dataStore, nil)
+					n.Node = constNode(value).Node
+					return false
+				}
+			case *pg_query.Node_TypeCast:
+				if regTypeName(expr.TypeCast.TypeName) == "regclass" {
+					err = session.bindRegclass(expr.TypeCast, dataStore)
+				}
+			case *pg_query.Node_SubLink:
+				err = bindSessionFunctions(expr.SubLink.Subselect, session, dataStore)
+			}
+			return err == nil
+		})
+	}
+	walkStatementExprs(stmt, bind)
+	return err
+}
+
+// walkStatementExprs calls visit for the top-level expressions of a
+// statement, including those of its CTEs, set operations and subqueries in
+// FROM. Subqueries within expressions are left to visit.
+func walkStatementExprs(stmt *pg_query.Node, visit func(*pg_query.Node)) {
+	if stmt == nil {
+		return
+	}
+	visitAll := func(nodes []*pg_query.Node) {
+		for _, node := range nodes {
+			visit(node)
+		}
+	}
+	var walkFrom func(node *pg_query.Node)
+	walkFrom = func(node *pg_query.Node) {
+		if node == nil {
+			return
+		}
+		switch n := node.Node.(type) {
+		case *pg_query.Node_JoinExpr:
+			walkFrom(n.JoinExpr.Larg)
+			walkFrom(n.JoinExpr.Rarg)
+			visit(n.JoinExpr.Quals)
+		case *pg_query.Node_RangeSubselect:
+			walkStatementExprs(n.RangeSubselect.Subquery, visit)
+		case *pg_query.Node_RangeFunction:
+			visitAll(n.RangeFunction.Functions)
+		}
+	}
+	walkWith := func(with *pg_query.WithClause) {
+		if with == nil {
+			return
+		}
+		for _, cte := range with.Ctes {
+			if c, ok := cte.Node.(*pg_query.Node_CommonTableExpr); ok {
+				walkStatementExprs(c.CommonTableExpr.Ctequery, visit)
+			}
+		}
+	}
+
+	switch n := stmt.Node.(type) {
+	case *pg_query.Node_SelectStmt:
+		s := n.SelectStmt
+		if s.Larg != nil || s.Rarg != nil {
+			walkStatementExprs(&pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: s.Larg}}, visit)
+			walkStatementExprs(&pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: s.Rarg}}, visit)
+		}
+		walkWith(s.WithClause)
+		for _, from := range s.FromClause {
+			walkFrom(from)
+		}
+		visitAll(s.TargetList)
+		visitAll(s.ValuesLists)
+		visitAll(s.GroupClause)
+		visitAll(s.SortClause)
+		visit(s.WhereClause)
+		visit(s.HavingClause)
+		visit(s.LimitCount)
+		visit(s.LimitOffset)
+	case *pg_query.Node_InsertStmt:
+		walkWith(n.InsertStmt.WithClause)
+		walkStatementExprs(n.InsertStmt.SelectStmt, visit)
+		visitAll(n.InsertStmt.ReturningList)
+	case *pg_query.Node_UpdateStmt:
+		walkWith(n.UpdateStmt.WithClause)
+		for _, from := range n.UpdateStmt.FromClause {
+			walkFrom(from)
+		}
+		visitAll(n.UpdateStmt.TargetList)
+		visit(n.UpdateStmt.WhereClause)
+		visitAll(n.UpdateStmt.ReturningList)
+	case *pg_query.Node_DeleteStmt:
+		walkWith(n.DeleteStmt.WithClause)
+		visit(n.DeleteStmt.WhereClause)
+		visitAll(n.DeleteStmt.ReturningList)
+	case *pg_query.Node_DeclareCursorStmt:
+		walkStatementExprs(n.DeclareCursorStmt.Query, visit)
+	case *pg_query.Node_ExplainStmt:
+		walkStatementExprs(n.ExplainStmt.Query, visit)
+	}
+}
+
+// callSessionFunction computes a call to a session function whose
+// arguments are all constants. bound reports whether it was computed.
+func callSessionFunction(funcCall *pg_query.FuncCall, session *Session, dataStore *storage.DataStore) (value interface{}, bound bool, err error) {
+	name := strings.ToLower(getFunctionName(funcCall))
+	impl, ok := sessionFunctions[name]
+	if !ok {
+		return nil, false, nil
+	}
+	fn, _ := LookupFunction(name)
+	if fn == nil || !fn.acceptsCount(len(funcCall.Args)) {
+		// Leave the call for checkFunctionCalls to report
+		return nil, false, nil
+	}
+	args := make([]interface{}, len(funcCall.Args))
+	for i, arg := range funcCall.Args {
+		if !isConstantExpr(arg) {
+			return nil, false, nil
+		}
+		args[i] = evaluateParam(arg)
+	}
+	value, err = impl(session, dataStore, args)
+	return value, err == nil, err
+}
+
+// isConstantExpr reports whether an expression is a literal, possibly cast
+func isConstantExpr(node *pg_query.Node) bool {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_AConst:
+		return true
+	case *pg_query.Node_TypeCast:
+		return isConstantExpr(n.TypeCast.Arg)
+	}
+	return false
+}
+
+// sessionValueFunctionName returns the session function a keyword such as
+// CURRENT_USER stands for, or "" for keywords that do not depend on the
+// session
+func sessionValueFunctionName(fn *pg_query.SQLValueFunction) string {
+	switch fn.Op {
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_USER, pg_query.SQLValueFunctionOp_SVFOP_USER,
+		pg_query.SQLValueFunctionOp_SVFOP_CURRENT_ROLE:
+		return "current_user"
+	case pg_query.SQLValueFunctionOp_SVFOP_SESSION_USER:
+		return "session_user"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_CATALOG:
+		return "current_database"
+	case pg_query.SQLValueFunctionOp_SVFOP_CURRENT_SCHEMA:
+		return "current_schema"
+	}
+	return ""
+}
+
+// sessionValueName returns the column name PostgreSQL gives a session
+// function call or keyword, or "" if the expression is neither
+func sessionValueName(node *pg_query.Node) string {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_FuncCall:
+		name := strings.ToLower(getFunctionName(n.FuncCall))
+		if _, ok := sessionFunctions[name]; ok {
+			return name
+		}
+	case *pg_query.Node_SqlvalueFunction:
+		if sessionValueFunctionName(n.SqlvalueFunction) != "" {
+			return SQLValueFunctionName(n.SqlvalueFunction)
+		}
+	}
+	return ""
+}
+
+// constNode makes a literal holding value, which is a string, an integer,
+// a boolean or nil
+func constNode(value interface{}) *pg_query.Node {
+	aConst := &pg_query.A_Const{}
+	switch v := value.(type) {
+	case string:
+		aConst.Val = &pg_query.A_Const_Sval{Sval: &pg_query.String{Sval: v}}
+	case int:
+		aConst.Val = &pg_query.A_Const_Ival{Ival: &pg_query.Integer{Ival: int32(v)}}
+	case bool:
+		aConst.Val = &pg_query.A_Const_Boolval{Boolval: &pg_query.Boolean{Boolval: v}}
+	default:
+		aConst.Isnull = true
+	}
+	return &pg_query.Node{Node: &pg_query.Node_AConst{AConst: aConst}}
+}
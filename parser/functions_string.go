@@ -0,0 +1,413 @@
+package parser
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// String functions operate on characters rather than bytes, so lengths and
+// positions are counted in runes.
+
+// textOf renders a value as text, as a cast to text does
+func textOf(value interface{}) string {
+	text, _ := coerceValue(value, storage.TypeString)
+	s, _ := text.(string)
+	return s
+}
+
+// substring returns count characters of s starting at the 1-based position
+// start; a negative count means the rest of the string
+func substring(s string, start, count int) string {
+	runes := []rune(s)
+	end := len(runes) + 1
+	if count >= 0 {
+		end = start + count
+	}
+	if start < 1 {
+		start = 1
+	}
+	if end > len(runes)+1 {
+		end = len(runes) + 1
+	}
+	if start > len(runes) || end <= start {
+		return ""
+	}
+	return string(runes[start-1 : end-1])
+}
+
+// position returns the 1-based character position of substr in s, or 0
+func position(s, substr string) int {
+	i := strings.Index(s, substr)
+	if i < 0 {
+		return 0
+	}
+	return len([]rune(s[:i])) + 1
+}
+
+// trimChars removes any of the characters in chars from the ends of s
+func trimChars(s, chars string, leading, trailing bool) string {
+	cut := func(r rune) bool { return strings.ContainsRune(chars, r) }
+	if leading {
+		s = strings.TrimLeftFunc(s, cut)
+	}
+	if trailing {
+		s = strings.TrimRightFunc(s, cut)
+	}
+	return s
+}
+
+// splitPart returns the n-th field of s; negative n counts from the end
+func splitPart(s, delimiter string, n int) (string, error) {
+	if n == 0 {
+		return "", newSQLError(SQLStateInvalidParameter, "field position must not be zero")
+	}
+	fields := []string{s}
+	if delimiter != "" {
+		fields = strings.Split(s, delimiter)
+	}
+	if s == "" {
+		fields = nil
+	}
+	if n < 0 {
+		n = len(fields) + n + 1
+	}
+	if n < 1 || n > len(fields) {
+		return "", nil
+	}
+	return fields[n-1], nil
+}
+
+// leftChars returns the first n characters of s; negative n drops the last |n|
+func leftChars(s string, n int) string {
+	runes := []rune(s)
+	if n < 0 {
+		n = len(runes) + n
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > len(runes) {
+		n = len(runes)
+	}
+	return string(runes[:n])
+}
+
+// rightChars returns the last n characters of s; negative n drops the first |n|
+func rightChars(s string, n int) string {
+	runes := []rune(s)
+	if n < 0 {
+		n = len(runes) + n
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > len(runes) {
+		n = len(runes)
+	}
+	return string(runes[len(runes)-n:])
+}
+
+// pad fills s to length characters with fill, on the left or right. A
+// string already longer than length is truncated on the right.
+func pad(s string, length int, fill string, left bool) string {
+	runes := []rune(s)
+	if length <= 0 {
+		return ""
+	}
+	if len(runes) >= length {
+		return string(runes[:length])
+	}
+	fillRunes := []rune(fill)
+	if len(fillRunes) == 0 {
+		return s
+	}
+	padding := make([]rune, length-len(runes))
+	for i := range padding {
+		padding[i] = fillRunes[i%len(fillRunes)]
+	}
+	if left {
+		return string(padding) + s
+	}
+	return s + string(padding)
+}
+
+// initcap upper-cases the first letter of each word and lower-cases the rest
+func initcap(s string) string {
+	var sb strings.Builder
+	inWord := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if inWord {
+				sb.WriteRune(unicode.ToLower(r))
+			} else {
+				sb.WriteRune(unicode.ToUpper(r))
+			}
+			inWord = true
+		} else {
+			sb.WriteRune(r)
+			inWord = false
+		}
+	}
+	return sb.String()
+}
+
+// reverse reverses the characters of s
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// quoteIdent quotes an identifier if it is not a plain lower-case name
+func quoteIdent(s string) string {
+	plain := s != ""
+	for i, r := range s {
+		if !(r >= 'a' && r <= 'z' || r == '_' || i > 0 && (r >= '0' && r <= '9' || r == '$')) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a value as an SQL literal, or NULL
+func quoteLiteral(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(textOf(value), "'", "''") + "'"
+}
+
+// formatString implements format(): %s, %I and %L conversions with optional
+// n$ argument positions, a - flag and a width, and %% for a literal percent
+func formatString(format string, args []interface{}) (string, error) {
+	var sb strings.Builder
+	next := 0
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		i++
+		if i >= len(runes) {
+			return "", newSQLError(SQLStateInvalidParameter, "unterminated format() type specifier")
+		}
+		if runes[i] == '%' {
+			sb.WriteRune('%')
+			continue
+		}
+
+		// Optional argument position, flags and width
+		readNumber := func() (int, bool) {
+			start := i
+			n := 0
+			for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+				n = n*10 + int(runes[i]-'0')
+				i++
+			}
+			return n, i > start
+		}
+		argIndex := next
+		start := i
+		if n, ok := readNumber(); ok {
+			if i < len(runes) && runes[i] == '$' {
+				if n == 0 {
+					return "", newSQLError(SQLStateInvalidParameter, "format specifies argument 0, but arguments are numbered from 1")
+				}
+				argIndex = n - 1
+				i++
+			} else {
+				// The number was a width
+				i = start
+			}
+		}
+		leftAlign := false
+		if i < len(runes) && runes[i] == '-' {
+			leftAlign = true
+			i++
+		}
+		width, _ := readNumber()
+		if i >= len(runes) {
+			return "", newSQLError(SQLStateInvalidParameter, "unterminated format() type specifier")
+		}
+
+		if argIndex >= len(args) {
+			return "", newSQLError(SQLStateInvalidParameter, "too few arguments for format()")
+		}
+		arg := args[argIndex]
+		next = argIndex + 1
+
+		var text string
+		switch runes[i] {
+		case 's':
+			if arg != nil {
+				text = textOf(arg)
+			}
+		case 'I':
+			if arg == nil {
+				return "", newSQLError(SQLStateNullNotAllowed, "null values cannot be formatted as an SQL identifier")
+			}
+			text = quoteIdent(textOf(arg))
+		case 'L':
+			text = quoteLiteral(arg)
+		default:
+			return "", newSQLError(SQLStateInvalidParameter, "unrecognized format() type specifier \"%c\"", runes[i]).
+				withHint("For a single \"%\" use \"%%\".")
+		}
+
+		if padding := width - len([]rune(text)); padding > 0 {
+			if leftAlign {
+				text += strings.Repeat(" ", padding)
+			} else {
+				text = strings.Repeat(" ", padding) + text
+			}
+		}
+		sb.WriteString(text)
+	}
+	return sb.String(), nil
+}
+
+func init() {
+	text := storage.TypeString
+	integer := storage.TypeInteger
+	any := storage.TypeUnknown
+
+	scalar := func(name string, signatures ...FunctionSignature) {
+		RegisterFunction(&Function{Name: name, Signatures: signatures})
+	}
+	str := func(args []interface{}, i int) string { return args[i].(string) }
+	num := func(args []interface{}, i int) int { return args[i].(int) }
+
+	length := FunctionSignature{Args: []storage.ColumnType{text}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+		return len([]rune(str(args, 0))), nil
+	}}
+	scalar("length", length)
+	scalar("char_length", length)
+	scalar("character_length", length)
+
+	scalar("substring",
+		FunctionSignature{Args: []storage.ColumnType{text, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+			return substring(str(args, 0), num(args, 1), -1), nil
+		}},
+		FunctionSignature{Args: []storage.ColumnType{text, integer, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+			if num(args, 2) < 0 {
+				return nil, newSQLError(SQLStateSubstringError, "negative substring length not allowed")
+			}
+			return substring(str(args, 0), num(args, 1), num(args, 2)), nil
+		}},
+	)
+	// position(substr IN s) is parsed as position(s, substr)
+	scalar("position", FunctionSignature{Args: []storage.ColumnType{text, text}, Result: integer, Impl: func(args []interface{}) (interface{}, error) {
+		return position(str(args, 0), str(args, 1)), nil
+	}})
+
+	// TRIM([LEADING|TRAILING|BOTH] [chars] FROM s) is parsed as ltrim, rtrim or btrim
+	for _, trim := range []struct {
+		name              string
+		leading, trailing bool
+	}{{"btrim", true, true}, {"ltrim", true, false}, {"rtrim", false, true}} {
+		trim := trim
+		scalar(trim.name,
+			FunctionSignature{Args: []storage.ColumnType{text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+				return trimChars(str(args, 0), " ", trim.leading, trim.trailing), nil
+			}},
+			FunctionSignature{Args: []storage.ColumnType{text, text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+				return trimChars(str(args, 0), str(args, 1), trim.leading, trim.trailing), nil
+			}},
+		)
+	}
+
+	scalar("replace", FunctionSignature{Args: []storage.ColumnType{text, text, text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		if str(args, 1) == "" {
+			return str(args, 0), nil
+		}
+		return strings.ReplaceAll(str(args, 0), str(args, 1), str(args, 2)), nil
+	}})
+	scalar("split_part", FunctionSignature{Args: []storage.ColumnType{text, text, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		return splitPart(str(args, 0), str(args, 1), num(args, 2))
+	}})
+
+	// concat and concat_ws skip NULL arguments instead of returning NULL
+	scalar("concat", FunctionSignature{Args: []storage.ColumnType{any}, Variadic: true, Result: text, CalledOnNull: true, Impl: func(args []interface{}) (interface{}, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			if arg != nil {
+				sb.WriteString(textOf(arg))
+			}
+		}
+		return sb.String(), nil
+	}})
+	scalar("concat_ws", FunctionSignature{Args: []storage.ColumnType{text, any}, Variadic: true, Result: text, CalledOnNull: true, Impl: func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		var parts []string
+		for _, arg := range args[1:] {
+			if arg != nil {
+				parts = append(parts, textOf(arg))
+			}
+		}
+		return strings.Join(parts, str(args, 0)), nil
+	}})
+
+	scalar("left", FunctionSignature{Args: []storage.ColumnType{text, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		return leftChars(str(args, 0), num(args, 1)), nil
+	}})
+	scalar("right", FunctionSignature{Args: []storage.ColumnType{text, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		return rightChars(str(args, 0), num(args, 1)), nil
+	}})
+	for _, name := range []string{"lpad", "rpad"} {
+		left := name == "lpad"
+		scalar(name,
+			FunctionSignature{Args: []storage.ColumnType{text, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+				return pad(str(args, 0), num(args, 1), " ", left), nil
+			}},
+			FunctionSignature{Args: []storage.ColumnType{text, integer, text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+				return pad(str(args, 0), num(args, 1), str(args, 2), left), nil
+			}},
+		)
+	}
+
+	scalar("initcap", FunctionSignature{Args: []storage.ColumnType{text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		return initcap(str(args, 0)), nil
+	}})
+	scalar("repeat", FunctionSignature{Args: []storage.ColumnType{text, integer}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		if num(args, 1) <= 0 {
+			return "", nil
+		}
+		return strings.Repeat(str(args, 0), num(args, 1)), nil
+	}})
+	scalar("reverse", FunctionSignature{Args: []storage.ColumnType{text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		return reverse(str(args, 0)), nil
+	}})
+	scalar("md5", FunctionSignature{Args: []storage.ColumnType{text}, Result: text, Impl: func(args []interface{}) (interface{}, error) {
+		sum := md5.Sum([]byte(str(args, 0)))
+		return hex.EncodeToString(sum[:]), nil
+	}})
+
+	formatImpl := func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		return formatString(str(args, 0), args[1:])
+	}
+	scalar("format",
+		FunctionSignature{Args: []storage.ColumnType{text}, Result: text, Impl: formatImpl},
+		FunctionSignature{Args: []storage.ColumnType{text, any}, Variadic: true, Result: text, CalledOnNull: true, Impl: formatImpl},
+	)
+
+	// string_agg is computed by evaluateAggregateFunction
+	RegisterFunction(&Function{Name: "string_agg", Kind: AggregateFunction, Signatures: []FunctionSignature{
+		{Args: []storage.ColumnType{text, text}, Result: text},
+	}})
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// TestFunctionRegistryCall checks overload resolution, NULL handling and SQLSTATEs
+func TestFunctionRegistryCall(t *testing.T) {
+	registry := NewFunctionRegistry()
+	registry.Register(&Function{
+		Name: "double",
+		Kind: ScalarFunction,
+		Signatures: []FunctionSignature{{
+			Args:   []storage.ColumnType{storage.TypeInteger},
+			Result: storage.TypeInteger,
+			Impl: func(args []interface{}) (interface{}, error) {
+				return args[0].(int) * 2, nil
+			},
+		}},
+	})
+
+	fn, ok := registry.Lookup("DOUBLE")
+	if !ok {
+		t.Fatal("Expected lookup to ignore case")
+	}
+	if result, err := fn.Call([]interface{}{21}); err != nil || result != 42 {
+		t.Errorf("Expected 42, got %v (%v)", result, err)
+	}
+	if result, err := fn.Call([]interface{}{nil}); err != nil || result != nil {
+		t.Errorf("Expected NULL for a NULL argument, got %v (%v)", result, err)
+	}
+
+	_, err := fn.Call([]interface{}{1, 2})
+	sqlErr, ok := err.(*SQLError)
+	if !ok || sqlErr.SQLState() != SQLStateUndefinedFunction {
+		t.Fatalf("Expected a 42883 error for the wrong argument count, got %v", err)
+	}
+	if want := "function double(integer, integer) does not exist"; sqlErr.Error() != want {
+		t.Errorf("Expected %q, got %q", want, sqlErr.Error())
+	}
+}
+
+// TestFunctionResultType checks return types read from the built-in registry
+func TestFunctionResultType(t *testing.T) {
+	tests := []struct {
+		name string
+		args []storage.ColumnType
+		want storage.ColumnType
+		ok   bool
+	}{
+		{"upper", []storage.ColumnType{storage.TypeString}, storage.TypeString, true},
+		{"count", []storage.ColumnType{storage.TypeUnknown}, storage.TypeInteger, true},
+		{"max", []storage.ColumnType{storage.TypeFloat}, storage.TypeFloat, true},
+		{"upper", []storage.ColumnType{storage.TypeString, storage.TypeString}, storage.TypeUnknown, false},
+		{"no_such_function", nil, storage.TypeUnknown, false},
+	}
+	for _, tt := range tests {
+		got, ok := FunctionResultType(tt.name, tt.args)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FunctionResultType(%s, %v) = %v, %v; want %v, %v", tt.name, tt.args, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// isJSONOperator reports whether op only applies to JSON documents
+func isJSONOperator(op string) bool {
+	switch op {
+	case "->", "->>", "#>", "#>>", "@>", "<@", "?", "?|", "?&":
+		return true
+	}
+	return false
+}
+
+// jsonOperand reads an operand as a JSON document. Text is an untyped
+// literal and is parsed, as is the text of a json value.
+func jsonOperand(value interface{}) (storage.JSON, error) {
+	switch v := value.(type) {
+	case storage.JSON:
+		return v.Jsonb(), nil
+	case string:
+		return storage.ParseJSON(v)
+	}
+	return storage.JSON{}, newSQLError(SQLStateUndefinedFunction, "operator does not exist: %s", valueTypeName(value))
+}
+
+// jsonOperator applies a JSON operator. The ||, - and comparison operators
+// apply when an operand is a document. The second return value is false
+// when the operator does not apply.
+func jsonOperator(op string, left, right interface{}) (interface{}, bool, error) {
+	_, leftIsJSON := left.(storage.JSON)
+	_, rightIsJSON := right.(storage.JSON)
+	switch {
+	case isJSONOperator(op):
+	case op == "||" && (leftIsJSON || rightIsJSON):
+	case op == "-" && leftIsJSON:
+	case (op == "=" || op == "<>" || op == "!=") && (leftIsJSON || rightIsJSON):
+	default:
+		return nil, false, nil
+	}
+	if left == nil || right == nil {
+		return nil, true, nil
+	}
+	doc, err := jsonOperand(left)
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch op {
+	case "->", "->>":
+		elem, ok := jsonElement(doc.Doc, right)
+		if !ok {
+			return nil, true, nil
+		}
+		if op == "->>" {
+			return jsonText(elem), true, nil
+		}
+		return storage.JSON{Doc: elem}, true, nil
+	case "#>", "#>>":
+		path, err := jsonPath(right)
+		if err != nil {
+			return nil, true, err
+		}
+		elem, ok := doc.Doc, true
+		for _, key := range path {
+			if elem, ok = jsonElement(elem, key); !ok {
+				return nil, true, nil
+			}
+		}
+		if op == "#>>" {
+			return jsonText(elem), true, nil
+		}
+		return storage.JSON{Doc: elem}, true, nil
+	case "?":
+		return doc.HasKey(toString(right)), true, nil
+	case "?|", "?&":
+		keys, err := jsonPath(right)
+		if err != nil {
+			return nil, true, err
+		}
+		for _, key := range keys {
+			if doc.HasKey(key) == (op == "?|") {
+				return op == "?|", true, nil
+			}
+		}
+		return op == "?&", true, nil
+	case "-":
+		return jsonDelete(doc, right), true, nil
+	}
+
+	other, err := jsonOperand(right)
+	if err != nil {
+		return nil, true, err
+	}
+	switch op {
+	case "@>":
+		return doc.Contains(other), true, nil
+	case "<@":
+		return other.Contains(doc), true, nil
+	case "||":
+		return jsonConcat(doc, other), true, nil
+	case "=":
+		return doc.Equal(other), true, nil
+	default:
+		return !doc.Equal(other), true, nil
+	}
+}
+
+// jsonElement returns an object's field by key, or an array's element by
+// index; negative indexes count from the end
+func jsonElement(doc interface{}, key interface{}) (interface{}, bool) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		name, ok := key.(string)
+		if !ok {
+			return nil, false
+		}
+		elem, ok := v[name]
+		return elem, ok
+	case []interface{}:
+		var index int
+		switch k := key.(type) {
+		case int:
+			index = k
+		case string:
+			n, err := strconv.Atoi(k)
+			if err != nil {
+				return nil, false
+			}
+			index = n
+		default:
+			return nil, false
+		}
+		if index < 0 {
+			index += len(v)
+		}
+		if index < 0 || index >= len(v) {
+			return nil, false
+		}
+		return v[index], true
+	}
+	return nil, false
+}
+
+// jsonText returns an element as ->> does: strings without quotes, JSON
+// null as SQL NULL, and anything else as JSON text
+func jsonText(elem interface{}) interface{} {
+	switch v := elem.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	}
+	return storage.JSON{Doc: elem}.String()
+}
+
+// jsonPath reads a path such as '{a,b,0}' given to #>, #>> or jsonb_set
+func jsonPath(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case storage.Array:
+		path := make([]string, len(v.Elems))
+		for i, elem := range v.Elems {
+			path[i] = toString(elem)
+		}
+		return path, nil
+	case []interface{}:
+		path := make([]string, len(v))
+		for i, elem := range v {
+			path[i] = toString(elem)
+		}
+		return path, nil
+	case string:
+		s := strings.TrimSpace(v)
+		if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+			return nil, newSQLError(SQLStateInvalidTextRepresentation, "malformed array literal: \"%s\"", v).
+				withDetail("Array value must start with \"{\" or dimension information.")
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+		if s == "" {
+			return nil, nil
+		}
+		var path []string
+		for _, elem := range strings.Split(s, ",") {
+			path = append(path, strings.Trim(strings.TrimSpace(elem), `"`))
+		}
+		return path, nil
+	}
+	return nil, newSQLError(SQLStateDatatypeMismatch, "path must be a text array, not %s", valueTypeName(value))
+}
+
+// toString formats a value for use as a key
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return storage.JSON{Doc: toJSONDoc(value)}.String()
+}
+
+// jsonConcat merges two objects, with the right one's fields winning, and
+// otherwise concatenates the two as arrays
+func jsonConcat(left, right storage.JSON) storage.JSON {
+	leftObject, leftIsObject := left.Doc.(map[string]interface{})
+	rightObject, rightIsObject := right.Doc.(map[string]interface{})
+	if leftIsObject && rightIsObject {
+		merged := make(map[string]interface{}, len(leftObject)+len(rightObject))
+		for key, value := range leftObject {
+			merged[key] = value
+		}
+		for key, value := range rightObject {
+			merged[key] = value
+		}
+		return storage.JSON{Doc: merged}
+	}
+	return storage.JSON{Doc: append(append([]interface{}(nil), asJSONArray(left.Doc)...), asJSONArray(right.Doc)...)}
+}
+
+func asJSONArray(doc interface{}) []interface{} {
+	if array, ok := doc.([]interface{}); ok {
+		return array
+	}
+	return []interface{}{doc}
+}
+
+// jsonDelete removes a key from an object, or an element from an array by
+// index or by string value
+func jsonDelete(doc storage.JSON, key interface{}) storage.JSON {
+	switch v := doc.Doc.(type) {
+	case map[string]interface{}:
+		name := toString(key)
+		result := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			if k != name {
+				result[k] = value
+			}
+		}
+		return storage.JSON{Doc: result}
+	case []interface{}:
+		result := []interface{}{}
+		index, byIndex := key.(int)
+		if byIndex && index < 0 {
+			index += len(v)
+		}
+		for i, elem := range v {
+			if byIndex && i == index {
+				continue
+			}
+			if s, ok := elem.(string); !byIndex && ok && s == toString(key) {
+				continue
+			}
+			result = append(result, elem)
+		}
+		return storage.JSON{Doc: result}
+	}
+	return doc
+}
+
+// jsonSet replaces the element at path with value, as jsonb_set does. A
+// missing final object key, or an array index past either end, is added
+// when create is true; a path through missing elements leaves the document
+// unchanged.
+func jsonSet(doc interface{}, path []string, value interface{}, create bool, position int) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	key := path[0]
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		elem, exists := v[key]
+		if !exists && (len(path) > 1 || !create) {
+			return doc, nil
+		}
+		replaced, err := jsonSet(elem, path[1:], value, create, position+1)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]interface{}, len(v)+1)
+		for k, existing := range v {
+			result[k] = existing
+		}
+		result[key] = replaced
+		return result, nil
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, newSQLError(SQLStateInvalidTextRepresentation, "path element at position %d is not an integer: \"%s\"", position, key)
+		}
+		if index < 0 {
+			index += len(v)
+		}
+		result := append([]interface{}(nil), v...)
+		switch {
+		case index >= 0 && index < len(v):
+			replaced, err := jsonSet(v[index], path[1:], value, create, position+1)
+			if err != nil {
+				return nil, err
+			}
+			result[index] = replaced
+		case len(path) > 1 || !create:
+			return doc, nil
+		case index < 0:
+			result = append([]interface{}{value}, result...)
+		default:
+			result = append(result, value)
+		}
+		return result, nil
+	}
+	return doc, nil
+}
+
+// toJSONDoc converts an SQL value to the JSON value to_jsonb produces.
+// Dates and times become ISO 8601 strings.
+func toJSONDoc(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case storage.JSON:
+		return v.Jsonb().Doc
+	case int:
+		return storage.NumericFromInt(int64(v))
+	case int64:
+		return storage.NumericFromInt(v)
+	case float64:
+		if n, err := storage.ParseNumeric(strconv.FormatFloat(v, 'g', -1, 64)); err == nil {
+			return n
+		}
+		// NaN and infinities have no JSON number form
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case storage.Numeric, bool, string:
+		return v
+	case storage.Array:
+		elems := make([]interface{}, len(v.Elems))
+		for i, elem := range v.Elems {
+			elems[i] = toJSONDoc(elem)
+		}
+		return elems
+	case storage.Timestamp:
+		return strings.Replace(v.String(), " ", "T", 1)
+	case storage.TimestampTZ:
+		s := strings.Replace(v.String(), " ", "T", 1)
+		if strings.HasSuffix(s, "+00") {
+			s += ":00"
+		}
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// compareJSON compares two values when either is a document. Documents
+// only have equality here; text on the other side is parsed.
+func compareJSON(left, right interface{}) (int, bool) {
+	_, leftIsJSON := left.(storage.JSON)
+	_, rightIsJSON := right.(storage.JSON)
+	if !leftIsJSON && !rightIsJSON {
+		return 0, false
+	}
+	a, err := jsonOperand(left)
+	if err != nil {
+		return 0, false
+	}
+	b, err := jsonOperand(right)
+	if err != nil {
+		return 0, false
+	}
+	return strings.Compare(a.String(), b.String()), true
+}
+
+// declaredTypeName returns the name a column declared with typeName keeps
+// in the meta store: its character type, or json, whose values keep their
+// text unlike jsonb's
+func declaredTypeName(typeName *pg_query.TypeName) string {
+	if typeName != nil && len(typeName.ArrayBounds) == 0 && baseTypeName(typeName) == "json" {
+		return "json"
+	}
+	return characterTypeName(typeName)
+}
+
+// coerceJSONText converts a value to json, which keeps the text it is
+// given. A jsonb document becomes its output text.
+func coerceJSONText(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case storage.JSON:
+		if v.Text != "" {
+			return v, nil
+		}
+		return storage.JSON{Text: v.String()}, nil
+	case string:
+		return storage.ParseJSONText(v)
+	}
+	return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to json", valueTypeName(value))
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"github.com/satetsu888/vsql/storage"
+)
+
+// A careless join can produce far more rows than its inputs hold. Each row
+// the executor builds while running a statement, whether by a join, GROUP
+// BY, the select list, a sort or a subquery, is counted against the
+// session's max_intermediate_rows and statement_memory_limit, and the
+// statement fails once either is exceeded instead of exhausting the
+// server's memory.
+
+// rowEntryOverhead approximates the memory of one column of a row besides
+// its name and value: the map entry and the interface holding the value
+const rowEntryOverhead = 48
+
+// exceedsLimits reports whether building row took the statement over its
+// limits, recording the error. row is a storage.Row or a result row.
+func (ctx *QueryContext) exceedsLimits(row interface{}) bool {
+	if err := ctx.guard.account(row); err != nil {
+		ctx.fail(err)
+		return true
+	}
+	return false
+}
+
+// account adds a row the statement built to its totals. A nil guard has no
+// limits.
+func (g *statementGuard) account(row interface{}) error {
+	if g == nil {
+		return nil
+	}
+	g.rows++
+	if g.maxRows > 0 && g.rows > g.maxRows {
+		return newSQLError(SQLStateProgramLimitExceeded, "intermediate results exceed max_intermediate_rows (%d)", g.maxRows).
+			withHint("Check the join conditions, or raise max_intermediate_rows.")
+	}
+	if g.memoryLimit > 0 {
+		g.memory += rowSize(row)
+		if g.memory > g.memoryLimit {
+			return newSQLError(SQLStateOutOfMemory, "intermediate results exceed statement_memory_limit (%s)", formatKilobytes(g.memoryLimit/1024)).
+				withHint("Check the join conditions, or raise statement_memory_limit.")
+		}
+	}
+	return nil
+}
+
+// rowSize estimates the memory a storage.Row or result row uses
+func rowSize(row interface{}) int64 {
+	var size int64
+	switch r := row.(type) {
+	case storage.Row:
+		for name, value := range r {
+			size += rowEntryOverhead + int64(len(name)) + valueSize(value)
+		}
+	case []interface{}:
+		for _, value := range r {
+			size += rowEntryOverhead + valueSize(value)
+		}
+	}
+	return size
+}
+
+// valueSize estimates the memory a column value holds besides its entry
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	return 0
+}
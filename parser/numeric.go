@@ -0,0 +1,512 @@
+package parser
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// numberKind orders the numeric types the way PostgreSQL resolves mixed
+// arithmetic: integer op numeric is numeric, and anything op float is float
+type numberKind int
+
+const (
+	kindInteger numberKind = iota
+	kindNumeric
+	kindFloat
+)
+
+// numericOperand reads a value as a number. Text is read as an integer or a
+// numeric, the way an untyped literal would be.
+func numericOperand(value interface{}) (interface{}, numberKind, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, kindInteger, true
+	case int64:
+		return int(v), kindInteger, true
+	case storage.Numeric:
+		return v, kindNumeric, true
+	case float64:
+		return v, kindFloat, true
+	case string:
+		s := strings.TrimSpace(v)
+		if n, err := strconv.Atoi(s); err == nil {
+			return n, kindInteger, true
+		}
+		if n, err := storage.ParseNumeric(s); err == nil {
+			return n, kindNumeric, true
+		}
+	}
+	return nil, kindInteger, false
+}
+
+// asNumeric converts an integer or numeric operand to a numeric
+func asNumeric(value interface{}) storage.Numeric {
+	switch v := value.(type) {
+	case int:
+		return storage.NumericFromInt(int64(v))
+	case storage.Numeric:
+		return v
+	case float64:
+		n, _ := storage.NumericFromFloat(v)
+		return n
+	}
+	return storage.Numeric{}
+}
+
+// asFloat converts a numeric operand to a float
+func asFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case storage.Numeric:
+		return v.Float64()
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// isArithmeticOperator reports whether op is computed by numericArithmetic
+func isArithmeticOperator(op string) bool {
+	switch op {
+	case "+", "-", "*", "/", "%", "^", "&", "|", "#", "<<", ">>", "|/", "||/", "@", "~":
+		return true
+	}
+	return false
+}
+
+// numericArithmetic applies an arithmetic or bitwise operator. A nil left
+// operand means a prefix operator such as -x or |/x.
+func numericArithmetic(op string, left, right interface{}, unary bool) (interface{}, error) {
+	if unary {
+		if right == nil {
+			return nil, nil
+		}
+		return unaryArithmetic(op, right)
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	l, lkind, lok := numericOperand(left)
+	r, rkind, rok := numericOperand(right)
+	if !lok || !rok {
+		if _, isText := left.(string); isText && !lok {
+			return nil, invalidNumberError(left, rkind)
+		}
+		if _, isText := right.(string); isText && !rok {
+			return nil, invalidNumberError(right, lkind)
+		}
+		return nil, operatorError(op, left, right)
+	}
+	kind := lkind
+	if rkind > kind {
+		kind = rkind
+	}
+
+	switch op {
+	case "&", "|", "#", "<<", ">>":
+		if kind != kindInteger {
+			return nil, operatorError(op, left, right)
+		}
+		a, b := l.(int), r.(int)
+		switch op {
+		case "&":
+			return a & b, nil
+		case "|":
+			return a | b, nil
+		case "#":
+			return a ^ b, nil
+		case "<<":
+			return a << uint(b&63), nil
+		default:
+			return a >> uint(b&63), nil
+		}
+	case "^":
+		if kind == kindNumeric {
+			return numericPower(asNumeric(l), asNumeric(r))
+		}
+		return floatPower(asFloat(l), asFloat(r))
+	case "/", "%":
+		if isZero(r) {
+			return nil, newSQLError(SQLStateDivisionByZero, "division by zero")
+		}
+	}
+
+	switch kind {
+	case kindInteger:
+		return integerArithmetic(op, l.(int), r.(int))
+	case kindNumeric:
+		a, b := asNumeric(l), asNumeric(r)
+		switch op {
+		case "+":
+			return a.Add(b), nil
+		case "-":
+			return a.Sub(b), nil
+		case "*":
+			return a.Mul(b), nil
+		case "/":
+			return a.Quo(b), nil
+		case "%":
+			return a.Mod(b), nil
+		}
+	default:
+		a, b := asFloat(l), asFloat(r)
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			return a / b, nil
+		}
+	}
+	return nil, operatorError(op, left, right)
+}
+
+// integerArithmetic applies +, -, *, / or % to integers. Division truncates
+// toward zero, and results that overflow are errors.
+func integerArithmetic(op string, a, b int) (interface{}, error) {
+	var result int
+	overflow := false
+	switch op {
+	case "+":
+		result = a + b
+		overflow = (a > 0 && b > 0 && result < 0) || (a < 0 && b < 0 && result >= 0)
+	case "-":
+		result = a - b
+		overflow = (a >= 0 && b < 0 && result < 0) || (a < 0 && b > 0 && result >= 0)
+	case "*":
+		result = a * b
+		overflow = a != 0 && (result/a != b || (a == -1 && b == math.MinInt64))
+	case "/":
+		overflow = a == math.MinInt64 && b == -1
+		if !overflow {
+			result = a / b
+		}
+	case "%":
+		if b == -1 {
+			return 0, nil
+		}
+		result = a % b
+	default:
+		return nil, operatorError(op, a, b)
+	}
+	if overflow {
+		return nil, newSQLError(SQLStateNumericOutOfRange, "bigint out of range")
+	}
+	return result, nil
+}
+
+// integerWidth returns the width in bytes of the integer type an expression
+// has: 4 for a literal that fits in an integer, the declared width of a column or cast, and
+// the wider operand's width for arithmetic, as int2 + int4 is int4. Other
+// expressions are taken to be bigints.
+func integerWidth(node *pg_query.Node, ctx *QueryContext) int {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_AConst:
+		if _, isInt := n.AConst.Val.(*pg_query.A_Const_Ival); isInt {
+			return 4
+		}
+		// -2147483648 is read as a decimal literal but is still an integer
+		if fval := n.AConst.GetFval(); fval != nil {
+			if v, ok := bigintLiteral(fval.Fval); ok && v >= math.MinInt32 && v <= math.MaxInt32 {
+				return 4
+			}
+		}
+	case *pg_query.Node_TypeCast:
+		if size := integerSize(n.TypeCast.TypeName); size > 0 {
+			return size
+		}
+	case *pg_query.Node_ColumnRef:
+		qualifier, column := extractTableAndColumnFromRef(n.ColumnRef)
+		width := 0
+		for name, table := range ctx.tables {
+			if qualifier != "" && name != qualifier {
+				continue
+			}
+			if size := ctx.metaStore.GetColumnIntegerSize(table.name, column); size > width {
+				width = size
+			}
+		}
+		if width > 0 {
+			return width
+		}
+	case *pg_query.Node_AExpr:
+		if n.AExpr.Kind == pg_query.A_Expr_Kind_AEXPR_OP && isArithmeticOperator(operatorName(n.AExpr)) {
+			if n.AExpr.Lexpr == nil {
+				return integerWidth(n.AExpr.Rexpr, ctx)
+			}
+			left, right := integerWidth(n.AExpr.Lexpr, ctx), integerWidth(n.AExpr.Rexpr, ctx)
+			if left > right {
+				return left
+			}
+			return right
+		}
+	}
+	return 8
+}
+
+// checkIntegerResult rejects an integer sum, difference, product or
+// quotient that overflows the width of its operands, as smallint and
+// integer arithmetic do
+func checkIntegerResult(op string, result interface{}, size int) (interface{}, error) {
+	if _, isInt := result.(int); !isInt {
+		return result, nil
+	}
+	switch op {
+	case "+", "-", "*", "/":
+		return checkIntegerRange(result, size)
+	}
+	return result, nil
+}
+
+// unaryArithmetic applies a prefix operator
+func unaryArithmetic(op string, value interface{}) (interface{}, error) {
+	v, _, ok := numericOperand(value)
+	if !ok {
+		return nil, newSQLError(SQLStateUndefinedFunction, "operator does not exist: %s %s", op, valueTypeName(value)).
+			withHint("No operator matches the given name and argument type. You might need to add an explicit type cast.")
+	}
+	switch op {
+	case "+":
+		return v, nil
+	case "-", "@":
+		switch n := v.(type) {
+		case int:
+			if op == "@" && n >= 0 {
+				return n, nil
+			}
+			if n == math.MinInt64 {
+				return nil, newSQLError(SQLStateNumericOutOfRange, "bigint out of range")
+			}
+			return -n, nil
+		case storage.Numeric:
+			if op == "@" {
+				return n.Abs(), nil
+			}
+			return n.Neg(), nil
+		case float64:
+			if op == "@" {
+				return math.Abs(n), nil
+			}
+			return -n, nil
+		}
+	case "~":
+		if n, isInt := v.(int); isInt {
+			return ^n, nil
+		}
+	case "|/":
+		f := asFloat(v)
+		if f < 0 {
+			return nil, newSQLError(SQLStateInvalidArgumentForPower, "cannot take square root of a negative number")
+		}
+		return math.Sqrt(f), nil
+	case "||/":
+		return math.Cbrt(asFloat(v)), nil
+	}
+	return nil, newSQLError(SQLStateUndefinedFunction, "operator does not exist: %s %s", op, valueTypeName(value)).
+		withHint("No operator matches the given name and argument type. You might need to add an explicit type cast.")
+}
+
+// floatPower computes a ^ b in double precision
+func floatPower(a, b float64) (interface{}, error) {
+	if a == 0 && b < 0 {
+		return nil, newSQLError(SQLStateInvalidArgumentForPower, "zero raised to a negative power is undefined")
+	}
+	if a < 0 && b != math.Trunc(b) {
+		return nil, newSQLError(SQLStateInvalidArgumentForPower, "a negative number raised to a non-integer power yields a complex result")
+	}
+	return math.Pow(a, b), nil
+}
+
+// numericPower computes a ^ b exactly when b is an integer
+func numericPower(a, b storage.Numeric) (interface{}, error) {
+	if a.Sign() == 0 && b.Sign() < 0 {
+		return nil, newSQLError(SQLStateInvalidArgumentForPower, "zero raised to a negative power is undefined")
+	}
+	if b.IsInteger() {
+		if exp, ok := b.Int64(); ok && exp >= -1000 && exp <= 1000 {
+			return a.Pow(exp), nil
+		}
+	}
+	if a.Sign() < 0 {
+		return nil, newSQLError(SQLStateInvalidArgumentForPower, "a negative number raised to a non-integer power yields a complex result")
+	}
+	result, err := a.PowFloat(b.Float64())
+	if err != nil {
+		return nil, newSQLError(SQLStateNumericOutOfRange, "value overflows numeric format")
+	}
+	return result, nil
+}
+
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case int:
+		return v == 0
+	case storage.Numeric:
+		return v.Sign() == 0
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+// invalidNumberError reports text that cannot be read as the number type of
+// the other operand
+func invalidNumberError(text interface{}, other numberKind) error {
+	typeName := "integer"
+	switch other {
+	case kindNumeric:
+		typeName = "numeric"
+	case kindFloat:
+		typeName = "double precision"
+	}
+	return newSQLError(SQLStateInvalidTextRepresentation, "invalid input syntax for type %s: \"%v\"", typeName, text)
+}
+
+func operatorError(op string, left, right interface{}) error {
+	return newSQLError(SQLStateUndefinedFunction, "operator does not exist: %s %s %s", valueTypeName(left), op, valueTypeName(right)).
+		withHint("No operator matches the given name and argument types. You might need to add explicit type casts.")
+}
+
+// compareNumeric orders two values exactly when either is a numeric and the
+// other is an integer, a numeric or text holding a number. The second return
+// value is false when the values are not compared this way.
+func compareNumeric(left, right interface{}) (int, bool) {
+	_, leftIsNumeric := left.(storage.Numeric)
+	_, rightIsNumeric := right.(storage.Numeric)
+	if !leftIsNumeric && !rightIsNumeric {
+		return 0, false
+	}
+	l, lkind, lok := numericOperand(left)
+	r, rkind, rok := numericOperand(right)
+	if !lok || !rok || lkind == kindFloat || rkind == kindFloat {
+		return 0, false
+	}
+	return asNumeric(l).Cmp(asNumeric(r)), true
+}
+
+// applyTypeModifiers fits a value to a type's declared modifiers, such as
+// rounding to the scale of numeric(10,2)
+func applyTypeModifiers(value interface{}, colType storage.ColumnType, modifiers []int) (interface{}, error) {
+	n, isNumeric := value.(storage.Numeric)
+	if !isNumeric || colType != storage.TypeNumeric || len(modifiers) == 0 {
+		return value, nil
+	}
+	precision, scale := modifiers[0], 0
+	if len(modifiers) > 1 {
+		scale = modifiers[1]
+	}
+	return n.WithTypmod(precision, scale)
+}
+
+var integerTypeNames = map[int]string{2: "smallint", 4: "integer", 8: "bigint"}
+
+// checkIntegerRange rejects an integer that does not fit in a smallint (2),
+// integer (4) or bigint (8) of the given width in bytes
+func checkIntegerRange(value interface{}, size int) (interface{}, error) {
+	var n int
+	switch v := value.(type) {
+	case int:
+		n = v
+	case storage.Numeric:
+		// Numerics round to the nearest integer
+		i, ok := v.Int64()
+		if !ok {
+			if size > 0 {
+				return nil, newSQLError(SQLStateNumericOutOfRange, "%s out of range", integerTypeNames[size])
+			}
+			return value, nil
+		}
+		n = int(i)
+	default:
+		return value, nil
+	}
+	if (size == 2 && (n < math.MinInt16 || n > math.MaxInt16)) || (size == 4 && (n < math.MinInt32 || n > math.MaxInt32)) {
+		return nil, newSQLError(SQLStateNumericOutOfRange, "%s out of range", integerTypeNames[size])
+	}
+	return n, nil
+}
+
+// checkTypeModifiers validates declared modifiers, as CREATE TABLE and casts do
+func checkTypeModifiers(colType storage.ColumnType, modifiers []int) error {
+	if colType != storage.TypeNumeric || len(modifiers) == 0 {
+		return nil
+	}
+	precision := modifiers[0]
+	if precision < 1 || precision > storage.NumericMaxPrecision {
+		return newSQLError(SQLStateInvalidParameter, "NUMERIC precision %d must be between 1 and %d", precision, storage.NumericMaxPrecision)
+	}
+	if len(modifiers) > 1 && (modifiers[1] < 0 || modifiers[1] > precision) {
+		return newSQLError(SQLStateInvalidParameter, "NUMERIC scale %d must be between 0 and precision %d", modifiers[1], precision)
+	}
+	return nil
+}
+
+// numericSum accumulates SUM and AVG. Integers and numerics are added
+// exactly; a float anywhere makes the result a float.
+type numericSum struct {
+	exact   storage.Numeric
+	float   float64
+	isFloat bool
+	allInts bool
+	count   int
+}
+
+func newNumericSum() *numericSum {
+	return &numericSum{exact: storage.NumericFromInt(0), allInts: true}
+}
+
+// add adds a value, ignoring NULLs and values that are not numbers
+func (s *numericSum) add(value interface{}) {
+	v, kind, ok := numericOperand(value)
+	if value == nil || !ok {
+		return
+	}
+	s.count++
+	switch kind {
+	case kindFloat:
+		s.isFloat = true
+		s.allInts = false
+		s.float += v.(float64)
+	case kindNumeric:
+		s.allInts = false
+		s.exact = s.exact.Add(v.(storage.Numeric))
+	default:
+		s.exact = s.exact.Add(storage.NumericFromInt(int64(v.(int))))
+	}
+}
+
+// sum returns the total: an integer when only integers were added, or NULL
+// when nothing was
+func (s *numericSum) sum() interface{} {
+	switch {
+	case s.count == 0:
+		return nil
+	case s.isFloat:
+		return s.float + s.exact.Float64()
+	case s.allInts:
+		if n, ok := s.exact.Int64(); ok {
+			return int(n)
+		}
+	}
+	return s.exact
+}
+
+// avg returns the mean, which is a numeric unless a float was added
+func (s *numericSum) avg() interface{} {
+	switch {
+	case s.count == 0:
+		return nil
+	case s.isFloat:
+		return (s.float + s.exact.Float64()) / float64(s.count)
+	}
+	return s.exact.Quo(storage.NumericFromInt(int64(s.count)))
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// TestCreateTableInvalidModifiersKeepsTable checks that a CREATE TABLE
+// rejected for its column types leaves a table of the same name alone
+func TestCreateTableInvalidModifiersKeepsTable(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	for _, query := range []string{
+		"CREATE TABLE prices (id int, amount numeric(5, 2))",
+		"INSERT INTO prices VALUES (1, 1.50), (2, 2.25)",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	for _, query := range []string{
+		"CREATE TABLE prices (amount numeric(2, 5))",
+		"CREATE TABLE prices (name varchar(0))",
+		"CREATE TABLE fresh (id int, amount numeric(2, 5))",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err == nil {
+			t.Fatalf("%s: expected an error", query)
+		}
+	}
+
+	_, rows, _, err := ExecutePgQuery("SELECT id FROM prices", session, dataStore, metaStore)
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("prices has %d rows, want 2", len(rows))
+	}
+	if _, exists := dataStore.GetTable("fresh"); exists {
+		t.Error("a rejected CREATE TABLE should not create the table")
+	}
+}
+
+// TestIntegerArithmeticWidth checks that smallint and integer arithmetic
+// overflows at the width of its operands, while bigint arithmetic does not
+func TestIntegerArithmeticWidth(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	for _, query := range []string{
+		"CREATE TABLE widths (s smallint, i int, b bigint)",
+		"INSERT INTO widths VALUES (32767, 2147483647, 2147483647)",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		wantErr string
+		want    interface{}
+	}{
+		{query: "SELECT 2147483647 + 1", wantErr: "integer out of range"},
+		{query: "SELECT -2147483647 - 2", wantErr: "integer out of range"},
+		{query: "SELECT 46341 * 46341", wantErr: "integer out of range"},
+		{query: "SELECT i + 1 FROM widths", wantErr: "integer out of range"},
+		{query: "SELECT s + s FROM widths", wantErr: "smallint out of range"},
+		{query: "SELECT 32767::smallint + 1::smallint", wantErr: "smallint out of range"},
+		{query: "SELECT (-2147483648) / -1", wantErr: "integer out of range"},
+		{query: "SELECT -2147483648 * -1", wantErr: "integer out of range"},
+		{query: "SELECT (-32768)::smallint / (-1)::smallint", wantErr: "smallint out of range"},
+		{query: "SELECT (-2147483648)::bigint / -1", want: 2147483648},
+		{query: "SELECT -2147483648 / 2", want: -1073741824},
+		{query: "SELECT s + 1 FROM widths", want: 32768},
+		{query: "SELECT b + 1 FROM widths", want: 2147483648},
+		{query: "SELECT 2147483647::bigint + 1", want: 2147483648},
+		{query: "SELECT i - 1 FROM widths", want: 2147483646},
+	}
+	for _, tt := range tests {
+		_, rows, _, err := ExecutePgQuery(tt.query, session, dataStore, metaStore)
+		if tt.wantErr != "" {
+			sqlErr, ok := err.(*SQLError)
+			if !ok || sqlErr.SQLState() != SQLStateNumericOutOfRange || sqlErr.Error() != tt.wantErr {
+				t.Errorf("%s: error = %v, want %q (22003)", tt.query, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s failed: %v", tt.query, err)
+			continue
+		}
+		if len(rows) != 1 || rows[0][0] != tt.want {
+			t.Errorf("%s = %v, want %v", tt.query, rows, tt.want)
+		}
+	}
+}
+
+// TestBigintBoundary checks that whole number literals beyond integer are
+// bigints, and that numerics written to an integer column are stored rounded
+func TestBigintBoundary(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	for _, query := range []string{"SELECT 9223372036854775807 + 1", "SELECT -9223372036854775807 - 2"} {
+		_, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore)
+		sqlErr, ok := err.(*SQLError)
+		if !ok || sqlErr.SQLState() != SQLStateNumericOutOfRange || sqlErr.Error() != "bigint out of range" {
+			t.Errorf("%s: error = %v, want bigint out of range (22003)", query, err)
+		}
+	}
+
+	for _, query := range []string{
+		"CREATE TABLE big (v bigint, i int)",
+		"INSERT INTO big VALUES (9223372036854775807, 2.6)",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+	_, rows, _, err := ExecutePgQuery("SELECT v, i FROM big", session, dataStore, metaStore)
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0][0] != 9223372036854775807 || rows[0][1] != 3 {
+		t.Errorf("rows = %v, want [[9223372036854775807 3]]", rows)
+	}
+
+	if _, _, _, err := ExecutePgQuery("INSERT INTO big VALUES (9223372036854775808, 0)", session, dataStore, metaStore); err == nil {
+		t.Error("a value beyond bigint should not be stored in a bigint column")
+	}
+}
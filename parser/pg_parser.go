@@ -14,7 +14,13 @@ func ParsePostgreSQL(query string) (*pg_query.ParseResult, error) {
 	return pg_query.Parse(query)
 }
 
-func ExecutePgQuery(query string, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+// ExecutePgQuery runs the first statement of query in session. A nil session
+// runs it in a fresh session of its own.
+func ExecutePgQuery(query string, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	if session == nil {
+		session = NewSession()
+	}
+
 	result, err := ParsePostgreSQL(query)
 	if err != nil {
 		return nil, nil, "", err
@@ -25,9 +31,27 @@ func ExecutePgQuery(query string, dataStore *storage.DataStore, metaStore *stora
 	}
 
 	stmt := result.Stmts[0].Stmt
+	var columns []string
+	var rows [][]interface{}
+	var tag string
+	err = session.run(stmt, dataStore, metaStore, func() (err error) {
+		columns, rows, tag, err = executePgStatement(stmt, session, dataStore, metaStore)
+		return err
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	for i, row := range rows {
+		rows[i] = session.localizeRow(row)
+	}
+	return columns, rows, tag, nil
+}
+
+// executePgStatement runs a single parsed statement to completion
+func executePgStatement(stmt *pg_query.Node, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
 	switch node := stmt.Node.(type) {
 	case *pg_query.Node_SelectStmt:
-		return executePgSelect(node.SelectStmt, dataStore, metaStore)
+		return executePgSelect(node.SelectStmt, session, dataStore, metaStore)
 	case *pg_query.Node_InsertStmt:
 		return executePgInsert(node.InsertStmt, dataStore, metaStore)
 	case *pg_query.Node_UpdateStmt:
@@ -35,15 +59,39 @@ func ExecutePgQuery(query string, dataStore *storage.DataStore, metaStore *stora
 	case *pg_query.Node_DeleteStmt:
 		return executePgDelete(node.DeleteStmt, dataStore)
 	case *pg_query.Node_CreateStmt:
-		return executePgCreateTable(node.CreateStmt, dataStore, metaStore)
+		return executePgCreateTable(node.CreateStmt, session, dataStore, metaStore)
+	case *pg_query.Node_CreateEnumStmt:
+		return executePgCreateEnum(node.CreateEnumStmt, metaStore)
 	case *pg_query.Node_DropStmt:
-		return executePgDropTable(node.DropStmt, dataStore, metaStore)
+		return executePgDrop(node.DropStmt, session, dataStore, metaStore)
+	case *pg_query.Node_IndexStmt:
+		return executePgCreateIndex(node.IndexStmt, dataStore, metaStore)
+	case *pg_query.Node_ExplainStmt:
+		return executePgExplain(node.ExplainStmt, session, dataStore, metaStore)
+	case *pg_query.Node_DeclareCursorStmt:
+		return executePgDeclareCursor(node.DeclareCursorStmt, session, dataStore, metaStore)
+	case *pg_query.Node_FetchStmt:
+		return executePgFetch(node.FetchStmt, session.cursors)
+	case *pg_query.Node_ClosePortalStmt:
+		return executePgClosePortal(node.ClosePortalStmt, session.cursors)
 	case *pg_query.Node_PrepareStmt:
-		return executePgPrepare(node.PrepareStmt, dataStore, metaStore)
+		return executePgPrepare(node.PrepareStmt, session)
 	case *pg_query.Node_ExecuteStmt:
-		return executePgExecute(node.ExecuteStmt, dataStore, metaStore)
+		return executePgExecute(node.ExecuteStmt, session, dataStore, metaStore)
 	case *pg_query.Node_DeallocateStmt:
-		return executePgDeallocate(node.DeallocateStmt)
+		return executePgDeallocate(node.DeallocateStmt, session)
+	case *pg_query.Node_TransactionStmt:
+		return executePgTransaction(node.TransactionStmt, session, dataStore, metaStore)
+	case *pg_query.Node_VariableSetStmt:
+		return executePgVariableSet(node.VariableSetStmt, session)
+	case *pg_query.Node_VariableShowStmt:
+		return executePgVariableShow(node.VariableShowStmt, session)
+	case *pg_query.Node_CreateSchemaStmt:
+		return executePgCreateSchema(node.CreateSchemaStmt, session, dataStore, metaStore)
+	case *pg_query.Node_CreatedbStmt:
+		return executePgCreateDatabase(node.CreatedbStmt, session)
+	case *pg_query.Node_DropdbStmt:
+		return executePgDropDatabase(node.DropdbStmt, session)
 	default:
 		// Log warning for unsupported statement types but return empty result
 		log.Printf("WARNING: Unsupported SQL statement type: %T. Query will be ignored.\n", node)
@@ -51,26 +99,58 @@ func ExecutePgQuery(query string, dataStore *storage.DataStore, metaStore *stora
 	}
 }
 
-func executePgSelect(stmt *pg_query.SelectStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+func executePgSelect(stmt *pg_query.SelectStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	cursor, err := openPgSelect(stmt, session, dataStore, metaStore)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	resultRows, err := cursor.Fetch(0)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return cursor.Columns(), resultRows, cursor.Tag(len(resultRows)), nil
+}
+
+// openPgSelect starts a SELECT. Queries that neither sort, group nor remove
+// duplicates are evaluated lazily, one row per fetch; anything else is
+// executed up front.
+func openPgSelect(stmt *pg_query.SelectStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) (*Cursor, error) {
+	dataStore, metaStore = WithSystemCatalog(stmt, dataStore, metaStore)
+	if err := checkFunctionCalls(stmt, metaStore); err != nil {
+		return nil, err
+	}
+
 	// Check if this is a complex query that needs advanced processing
 	if needsAdvancedProcessing(stmt) {
-		return executePgSelectAdvanced(stmt, dataStore, metaStore)
+		if streamableSelect(stmt) {
+			return openStreamingSelect(stmt, session, dataStore, metaStore)
+		}
+		columns, rows, tag, err := executePgSelectAdvanced(stmt, session.statementGuard(), dataStore, metaStore)
+		if err != nil {
+			return nil, err
+		}
+		return newMaterializedCursor(columns, rows, tag), nil
 	}
 
 	// Simple single-table query - use optimized path
 	if len(stmt.FromClause) != 1 {
-		return nil, nil, "", fmt.Errorf("only single table SELECT is supported in simple mode")
+		return nil, fmt.Errorf("only single table SELECT is supported in simple mode")
 	}
 
 	tableName := extractTableName(stmt.FromClause[0])
 	if tableName == "" {
-		return nil, nil, "", fmt.Errorf("could not extract table name")
+		return nil, fmt.Errorf("could not extract table name")
 	}
 
 	var rows []storage.Row
 	table, exists := dataStore.GetTable(tableName)
 	if exists {
-		rows = table.GetRows()
+		qualifiers := []string{tableName, extractTableAlias(stmt.FromClause[0])}
+		if scan := chooseIndexScan(table, stmt.WhereClause, qualifiers, true); scan != nil {
+			rows = scan.rows(table)
+		} else {
+			rows = table.GetRows()
+		}
 	} else {
 		// Table doesn't exist - return empty result set
 		rows = []storage.Row{}
@@ -78,30 +158,35 @@ func executePgSelect(stmt *pg_query.SelectStmt, dataStore *storage.DataStore, me
 	
 	columns := extractSelectColumns(stmt, tableName, metaStore, rows)
 
-	var resultRows [][]interface{}
-	for _, row := range rows {
-		if stmt.WhereClause != nil && !evaluatePgWhere(row, stmt.WhereClause) {
-			continue
-		}
-
-		resultRow := make([]interface{}, len(columns))
-		for i, col := range columns {
-			// Try the column name as-is first
-			if val, exists := row[col]; exists {
-				resultRow[i] = val
-			} else if strings.Contains(col, ".") {
-				// If it's a qualified name and not found, try the unqualified part
-				parts := strings.Split(col, ".")
-				unqualified := parts[len(parts)-1]
-				resultRow[i] = row[unqualified]
-			} else {
-				resultRow[i] = row[col]
+	pos := 0
+	next := func() ([]interface{}, bool, error) {
+		for pos < len(rows) {
+			row := rows[pos]
+			pos++
+			if stmt.WhereClause != nil && !evaluatePgWhere(row, stmt.WhereClause) {
+				continue
+			}
+
+			resultRow := make([]interface{}, len(columns))
+			for i, col := range columns {
+				// Try the column name as-is first
+				if val, exists := row[col]; exists {
+					resultRow[i] = val
+				} else if strings.Contains(col, ".") {
+					// If it's a qualified name and not found, try the unqualified part
+					parts := strings.Split(col, ".")
+					unqualified := parts[len(parts)-1]
+					resultRow[i] = row[unqualified]
+				} else {
+					resultRow[i] = row[col]
+				}
 			}
+			return resultRow, true, nil
 		}
-		resultRows = append(resultRows, resultRow)
+		return nil, false, nil
 	}
 
-	return columns, resultRows, fmt.Sprintf("SELECT %d", len(resultRows)), nil
+	return newCursor(columns, next), nil
 }
 
 func needsAdvancedProcessing(stmt *pg_query.SelectStmt) bool {
@@ -110,6 +195,11 @@ func needsAdvancedProcessing(stmt *pg_query.SelectStmt) bool {
 		return true
 	}
 	
+	// A SELECT without FROM only computes expressions
+	if len(stmt.FromClause) == 0 {
+		return true
+	}
+	
 	// Check for UNION/INTERSECT/EXCEPT (SetOp)
 	if stmt.Op != pg_query.SetOperation_SETOP_NONE {
 		return true
@@ -120,8 +210,9 @@ func needsAdvancedProcessing(stmt *pg_query.SelectStmt) bool {
 		if _, ok := stmt.FromClause[0].Node.(*pg_query.Node_JoinExpr); ok {
 			return true
 		}
-		// Check for subquery in FROM
-		if _, ok := stmt.FromClause[0].Node.(*pg_query.Node_RangeSubselect); ok {
+		// Check for subquery or function in FROM
+		switch stmt.FromClause[0].Node.(type) {
+		case *pg_query.Node_RangeSubselect, *pg_query.Node_RangeFunction:
 			return true
 		}
 	}
@@ -154,12 +245,18 @@ func needsAdvancedProcessing(stmt *pg_query.SelectStmt) bool {
 			if _, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_AExpr); ok {
 				return true
 			}
+			// Check for casts, SQL value functions such as CURRENT_DATE, and GREATEST/LEAST
+			switch resTarget.ResTarget.Val.Node.(type) {
+			case *pg_query.Node_TypeCast, *pg_query.Node_SqlvalueFunction, *pg_query.Node_MinMaxExpr,
+				*pg_query.Node_AArrayExpr, *pg_query.Node_AIndirection:
+				return true
+			}
 		}
 	}
 	
-	// Check for subquery in WHERE
+	// Check for subquery or computed value in WHERE
 	if stmt.WhereClause != nil {
-		if hasSubquery(stmt.WhereClause) {
+		if hasSubquery(stmt.WhereClause) || containsComputedValue(stmt.WhereClause) {
 			return true
 		}
 	}
@@ -226,7 +323,8 @@ func executePgInsert(stmt *pg_query.InsertStmt, dataStore *storage.DataStore, me
 		columns = metaStore.GetTableColumns(tableName)
 	}
 
-	rowsInserted := 0
+	// Rows are written together so a unique violation leaves the table unchanged
+	var rows []storage.Row
 	if selectStmt, ok := stmt.SelectStmt.Node.(*pg_query.Node_SelectStmt); ok {
 		if len(selectStmt.SelectStmt.ValuesLists) > 0 {
 			for _, valuesList := range selectStmt.SelectStmt.ValuesLists {
@@ -235,7 +333,10 @@ func executePgInsert(stmt *pg_query.InsertStmt, dataStore *storage.DataStore, me
 					values := list.List.Items
 					for i, val := range values {
 						if i < len(columns) {
-							extractedValue := extractPgValue(val)
+							extractedValue, err := evaluateColumnValue(val, storage.Row{}, tableName, columns[i], metaStore)
+							if err != nil {
+								return nil, nil, "", err
+							}
 							// Validate type before inserting
 							if err := metaStore.ValidateValueType(tableName, columns[i], extractedValue); err != nil {
 								return nil, nil, "", err
@@ -247,15 +348,20 @@ func executePgInsert(stmt *pg_query.InsertStmt, dataStore *storage.DataStore, me
 							}
 						}
 					}
-					table.Insert(row)
-					metaStore.UpdateFromRow(tableName, row)
-					rowsInserted++
+					rows = append(rows, row)
 				}
 			}
 		}
 	}
 
-	return nil, nil, fmt.Sprintf("INSERT 0 %d", rowsInserted), nil
+	if err := table.InsertRows(rows); err != nil {
+		return nil, nil, "", err
+	}
+	for _, row := range rows {
+		metaStore.UpdateFromRow(tableName, row)
+	}
+
+	return nil, nil, fmt.Sprintf("INSERT 0 %d", len(rows)), nil
 }
 
 func executePgUpdate(stmt *pg_query.UpdateStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
@@ -270,31 +376,35 @@ func executePgUpdate(stmt *pg_query.UpdateStmt, dataStore *storage.DataStore, me
 		return nil, nil, "UPDATE 0", nil
 	}
 
-	rows := table.GetRows()
-	updatedCount := 0
-
-	for _, row := range rows {
-		if stmt.WhereClause != nil && !evaluatePgWhere(row, stmt.WhereClause) {
-			continue
-		}
-
+	match := func(row storage.Row) bool {
+		return stmt.WhereClause == nil || evaluatePgWhere(row, stmt.WhereClause)
+	}
+	apply := func(row storage.Row) (storage.Row, error) {
 		for _, target := range stmt.TargetList {
 			if resTarget, ok := target.Node.(*pg_query.Node_ResTarget); ok {
 				colName := resTarget.ResTarget.Name
-				value := extractPgValue(resTarget.ResTarget.Val)
+				value, err := evaluateColumnValue(resTarget.ResTarget.Val, row, tableName, colName, metaStore)
+				if err != nil {
+					return nil, err
+				}
 				// Validate type before updating
 				if err := metaStore.ValidateValueType(tableName, colName, value); err != nil {
-					return nil, nil, "", err
+					return nil, err
 				}
 				row[colName] = value
 				// Set type information
 				if err := metaStore.SetColumnType(tableName, colName, value); err != nil {
-					return nil, nil, "", err
+					return nil, err
 				}
 				metaStore.AddColumn(tableName, colName)
 			}
 		}
-		updatedCount++
+		return row, nil
+	}
+
+	updatedCount, err := table.UpdateScan(chooseModifyScan(table, stmt.Relation, stmt.WhereClause).candidates(), match, apply)
+	if err != nil {
+		return nil, nil, "", err
 	}
 
 	return nil, nil, fmt.Sprintf("UPDATE %d", updatedCount), nil
@@ -312,36 +422,25 @@ func executePgDelete(stmt *pg_query.DeleteStmt, dataStore *storage.DataStore) ([
 		return nil, nil, "DELETE 0", nil
 	}
 
-	rows := table.GetRows()
-	var newRows []storage.Row
-	deletedCount := 0
-
-	for _, row := range rows {
-		if stmt.WhereClause != nil && evaluatePgWhere(row, stmt.WhereClause) {
-			deletedCount++
-		} else {
-			newRows = append(newRows, row)
-		}
-	}
-
-	table.Rows = newRows
+	deletedCount := table.DeleteScan(chooseModifyScan(table, stmt.Relation, stmt.WhereClause).candidates(), func(row storage.Row) bool {
+		return stmt.WhereClause != nil && evaluatePgWhere(row, stmt.WhereClause)
+	})
 
 	return nil, nil, fmt.Sprintf("DELETE %d", deletedCount), nil
 }
 
-func executePgCreateTable(stmt *pg_query.CreateStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+func executePgCreateTable(stmt *pg_query.CreateStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
 	tableName := extractTableNameFromRangeVar(stmt.Relation)
 	if tableName == "" {
 		return nil, nil, "", fmt.Errorf("could not extract table name")
 	}
 
-	if err := dataStore.CreateTable(tableName); err != nil {
-		return nil, nil, "", err
-	}
-
 	// Extract column names and types from table elements
 	var columns []string
 	var columnTypes []storage.ColumnType
+	var columnModifiers [][]int
+	var columnIntegerSizes []int
+	var columnTypeNames []string
 	
 	// Collect column names and types
 	for _, elem := range stmt.TableElts {
@@ -353,14 +452,38 @@ func executePgCreateTable(stmt *pg_query.CreateStmt, dataStore *storage.DataStor
 			// Extract column type if specified
 			if colDef.ColumnDef.TypeName != nil {
 				colType := getColumnTypeFromTypeName(colDef.ColumnDef.TypeName)
+				modifiers := typeModifiers(colDef.ColumnDef.TypeName)
+				characterType := characterTypeName(colDef.ColumnDef.TypeName)
+				if err := checkTypeModifiers(colType, modifiers); err != nil {
+					return nil, nil, "", err
+				}
+				if err := checkCharacterModifiers(characterType, modifiers); err != nil {
+					return nil, nil, "", err
+				}
 				columnTypes = append(columnTypes, colType)
+				columnModifiers = append(columnModifiers, modifiers)
+				columnIntegerSizes = append(columnIntegerSizes, integerSize(colDef.ColumnDef.TypeName))
+				columnTypeNames = append(columnTypeNames, declaredTypeName(colDef.ColumnDef.TypeName))
 			} else {
 				// Default to unknown if no type specified
 				columnTypes = append(columnTypes, storage.TypeUnknown)
+				columnModifiers = append(columnModifiers, nil)
+				columnIntegerSizes = append(columnIntegerSizes, 0)
+				columnTypeNames = append(columnTypeNames, "")
 			}
 		}
 	}
 
+	// Every column is valid, so the table can be created
+	if err := dataStore.CreateTable(tableName); err != nil {
+		return nil, nil, "", err
+	}
+
+	// Temporary tables are dropped when the session ends
+	if stmt.Relation.Relpersistence == "t" {
+		session.addTempTable(tableName, stmt.Oncommit, dataStore)
+	}
+
 	// Store column names in metastore
 	if len(columns) > 0 {
 		metaStore.AddColumns(tableName, columns)
@@ -369,6 +492,15 @@ func executePgCreateTable(stmt *pg_query.CreateStmt, dataStore *storage.DataStor
 		for i, colName := range columns {
 			if i < len(columnTypes) && columnTypes[i] != storage.TypeUnknown {
 				metaStore.SetColumnTypeFromSchema(tableName, colName, columnTypes[i])
+				if len(columnModifiers[i]) > 0 {
+					metaStore.SetColumnTypeModifiers(tableName, colName, columnModifiers[i])
+				}
+				if columnIntegerSizes[i] > 0 {
+					metaStore.SetColumnIntegerSize(tableName, colName, columnIntegerSizes[i])
+				}
+				if columnTypeNames[i] != "" {
+					metaStore.SetColumnTypeName(tableName, colName, columnTypeNames[i])
+				}
 			}
 		}
 	}
@@ -376,7 +508,21 @@ func executePgCreateTable(stmt *pg_query.CreateStmt, dataStore *storage.DataStor
 	return nil, nil, "CREATE TABLE", nil
 }
 
-func executePgDropTable(stmt *pg_query.DropStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+// executePgDrop dispatches DROP statements by object type
+func executePgDrop(stmt *pg_query.DropStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	switch stmt.RemoveType {
+	case pg_query.ObjectType_OBJECT_INDEX:
+		return executePgDropIndex(stmt, dataStore)
+	case pg_query.ObjectType_OBJECT_TYPE:
+		return executePgDropType(stmt, metaStore)
+	case pg_query.ObjectType_OBJECT_SCHEMA:
+		return executePgDropSchema(stmt, session, dataStore, metaStore)
+	default:
+		return executePgDropTable(stmt, session, dataStore, metaStore)
+	}
+}
+
+func executePgDropTable(stmt *pg_query.DropStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
 	for _, obj := range stmt.Objects {
 		if list, ok := obj.Node.(*pg_query.Node_List); ok && len(list.List.Items) > 0 {
 			if str, ok := list.List.Items[0].Node.(*pg_query.Node_String_); ok {
@@ -384,6 +530,7 @@ func executePgDropTable(stmt *pg_query.DropStmt, dataStore *storage.DataStore, m
 				tableName := strings.Trim(str.String_.Sval, `"`)
 				dataStore.DropTable(tableName)
 				metaStore.DropTable(tableName)
+				session.dropTempTable(tableName)
 			}
 		}
 	}
@@ -413,6 +560,10 @@ func getColumnTypeFromTypeName(typeName *pg_query.TypeName) storage.ColumnType {
 	if typeName == nil || len(typeName.Names) == 0 {
 		return storage.TypeString
 	}
+	if len(typeName.ArrayBounds) > 0 {
+		// integer[] and integer ARRAY are arrays of the base type
+		return storage.ArrayOf(getColumnTypeFromTypeName(&pg_query.TypeName{Names: typeName.Names, TypeOid: typeName.TypeOid}))
+	}
 	
 	// Get the type name - it might be schema-qualified (e.g., pg_catalog.integer)
 	var typeStr string
@@ -429,19 +580,73 @@ func getColumnTypeFromTypeName(typeName *pg_query.TypeName) storage.ColumnType {
 	switch typeStr {
 	case "bool", "boolean":
 		return storage.TypeBoolean
-	case "int", "int2", "int4", "int8", "integer", "smallint", "bigint":
+	case "int", "int2", "int4", "int8", "integer", "smallint", "bigint", "oid":
 		return storage.TypeInteger
-	case "float", "float4", "float8", "real", "double", "numeric", "decimal":
+	case "float", "float4", "float8", "real", "double":
 		return storage.TypeFloat
-	case "timestamp", "timestamptz", "date", "time", "timetz":
+	case "numeric", "decimal":
+		return storage.TypeNumeric
+	case "timestamp":
 		return storage.TypeTimestamp
+	case "timestamptz":
+		return storage.TypeTimestampTZ
+	case "date":
+		return storage.TypeDate
+	case "time", "timetz":
+		return storage.TypeTime
+	case "interval":
+		return storage.TypeInterval
+	case "json", "jsonb":
+		return storage.TypeJSON
+	case "uuid":
+		return storage.TypeUUID
+	case "bytea":
+		return storage.TypeBytea
 	case "text", "varchar", "char", "bpchar":
 		return storage.TypeString
 	default:
+		// Enum types were found in the database when the statement was bound
+		if enumType, ok := storage.LookupEnumOID(typeName.TypeOid); ok {
+			return enumType
+		}
 		return storage.TypeString
 	}
 }
 
+// typeModifiers returns the integer modifiers written after a type name,
+// such as the precision and scale of numeric(10,2)
+func typeModifiers(typeName *pg_query.TypeName) []int {
+	if typeName == nil {
+		return nil
+	}
+	var modifiers []int
+	for _, mod := range typeName.Typmods {
+		if aConst, ok := mod.Node.(*pg_query.Node_AConst); ok {
+			if ival, ok := aConst.AConst.Val.(*pg_query.A_Const_Ival); ok {
+				modifiers = append(modifiers, int(ival.Ival.Ival))
+			}
+		}
+	}
+	return modifiers
+}
+
+// integerSize returns the width in bytes of an integer type name: 2 for
+// smallint, 8 for bigint and 4 for integer. Other types have no width.
+func integerSize(typeName *pg_query.TypeName) int {
+	if typeName == nil || len(typeName.ArrayBounds) > 0 {
+		return 0
+	}
+	switch baseTypeName(typeName) {
+	case "int2", "smallint":
+		return 2
+	case "int", "int4", "integer":
+		return 4
+	case "int8", "bigint":
+		return 8
+	}
+	return 0
+}
+
 func extractSelectColumns(stmt *pg_query.SelectStmt, tableName string, metaStore *storage.MetaStore, rows []storage.Row) []string {
 	var columns []string
 
@@ -803,6 +1008,14 @@ func extractValueFromExpr(row storage.Row, node *pg_query.Node) interface{} {
 	case *pg_query.Node_AExpr:
 		// Handle arithmetic expressions
 		return evaluateArithmeticExpr(row, n.AExpr)
+	case *pg_query.Node_TypeCast:
+		value, err := castValue(extractValueFromExpr(row, n.TypeCast.Arg), n.TypeCast.TypeName)
+		if err != nil {
+			return nil
+		}
+		return value
+	case *pg_query.Node_SqlvalueFunction:
+		return evaluateSQLValueFunction(n.SqlvalueFunction)
 	}
 	
 	return nil
@@ -814,58 +1027,102 @@ func evaluateArithmeticExpr(row storage.Row, expr *pg_query.A_Expr) interface{}
 		return nil
 	}
 	
-	// Extract operator
-	var op string
-	if len(expr.Name) > 0 {
-		if str, ok := expr.Name[0].Node.(*pg_query.Node_String_); ok {
-			op = str.String_.Sval
-		}
+	op := operatorName(expr)
+	if !isArithmeticOperator(op) {
+		return nil
 	}
 	
 	// Extract left and right values
 	leftVal := extractValueFromExpr(row, expr.Lexpr)
 	rightVal := extractValueFromExpr(row, expr.Rexpr)
-	
-	// Convert to float64 for arithmetic
-	leftNum, err1 := toFloat64(leftVal)
-	rightNum, err2 := toFloat64(rightVal)
-	
-	if err1 != nil || err2 != nil {
-		return nil
+
+	if result, ok, err := temporalArithmetic(op, leftVal, rightVal); ok {
+		if err != nil {
+			return nil
+		}
+		return result
 	}
 	
-	// Perform arithmetic operation
-	switch op {
-	case "+":
-		return leftNum + rightNum
-	case "-":
-		return leftNum - rightNum
-	case "*":
-		return leftNum * rightNum
-	case "/":
-		if rightNum == 0 {
-			return nil // Division by zero
-		}
-		return leftNum / rightNum
-	default:
+	// Errors such as division by zero are raised by the advanced path,
+	// which takes every WHERE clause with arithmetic in it
+	result, err := numericArithmetic(op, leftVal, rightVal, expr.Lexpr == nil)
+	if err != nil {
 		return nil
 	}
+	return result
 }
 
-func extractPgValue(node *pg_query.Node) interface{} {
-	if node == nil {
-		return nil
+// evaluateColumnValue computes a value written by INSERT or UPDATE, which may
+// be an expression over the row being updated. Values written to a column
+// declared with a date/time, numeric, JSON, array, uuid, bytea or enum type
+// are converted to that type (json keeping the text it is given), numerics are fitted to the column's precision
+// and scale, integers must fit the column's width and strings the length of
+// varchar(n) or char(n).
+func evaluateColumnValue(node *pg_query.Node, row storage.Row, tableName, columnName string, metaStore *storage.MetaStore) (interface{}, error) {
+	ctx := &QueryContext{tables: make(map[string]*TableContext), currentRow: row}
+	value := evaluateExpression(node, row, ctx)
+	if ctx.err != nil {
+		return nil, ctx.err
+	}
+	declared, ok := metaStore.GetDeclaredType(tableName, columnName)
+	if !ok || value == nil {
+		return value, nil
+	}
+	switch {
+	case declared == storage.TypeJSON && metaStore.GetColumnTypeName(tableName, columnName) == "json":
+		return coerceJSONText(value)
+	case storage.IsTemporalType(declared), declared == storage.TypeJSON, storage.IsArrayType(declared),
+		declared == storage.TypeUUID, declared == storage.TypeBytea, storage.IsEnumType(declared):
+		return coerceValue(value, declared)
+	case declared == storage.TypeNumeric:
+		converted, err := coerceValue(value, declared)
+		if err != nil {
+			return nil, err
+		}
+		return applyTypeModifiers(converted, declared, metaStore.GetColumnTypeModifiers(tableName, columnName))
+	case declared == storage.TypeFloat:
+		if _, isText := value.(string); !isText {
+			return coerceValue(value, declared)
+		}
+	case declared == storage.TypeInteger:
+		return checkIntegerRange(value, metaStore.GetColumnIntegerSize(tableName, columnName))
+	case declared == storage.TypeString:
+		return fitCharacterLength(value, metaStore.GetColumnTypeName(tableName, columnName), metaStore.GetColumnTypeModifiers(tableName, columnName), false)
 	}
+	return value, nil
+}
 
-	switch val := node.Node.(type) {
-	case *pg_query.Node_AConst:
-		return extractAConstValue(val.AConst)
-	case *pg_query.Node_String_:
-		return val.String_.Sval
-	case *pg_query.Node_Integer:
-		return val.Integer.Ival
+// castValue converts a value to the type named in a cast, applying the
+// type's modifiers
+func castValue(value interface{}, typeName *pg_query.TypeName) (interface{}, error) {
+	colType := getColumnTypeFromTypeName(typeName)
+	modifiers := typeModifiers(typeName)
+	if err := checkTypeModifiers(colType, modifiers); err != nil {
+		return nil, err
 	}
-	return nil
+	size := integerSize(typeName)
+	if size > 0 {
+		if _, err := checkIntegerRange(value, size); err != nil {
+			return nil, err
+		}
+	}
+	if declaredTypeName(typeName) == "json" {
+		return coerceJSONText(value)
+	}
+	result, err := coerceValue(value, colType)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		return checkIntegerRange(result, size)
+	}
+	if characterType := characterTypeName(typeName); characterType != "" {
+		if err := checkCharacterModifiers(characterType, modifiers); err != nil {
+			return nil, err
+		}
+		return fitCharacterLength(result, characterType, modifiers, true)
+	}
+	return applyTypeModifiers(result, colType, modifiers)
 }
 
 // extractAConstValue is now in pg_parser_utils.go
@@ -875,22 +1132,3 @@ func extractPgValue(node *pg_query.Node) interface{} {
 // toNumber is now in pg_parser_utils.go
 
 // matchPattern is now in pg_parser_utils.go
-
-// executePgPrepare handles PREPARE statements
-func executePgPrepare(stmt *pg_query.PrepareStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
-	// For now, we'll return an error saying PREPARE is not fully implemented
-	// A complete implementation would need to store the query and handle parameter types
-	return nil, nil, "", fmt.Errorf("PREPARE statement is not yet implemented")
-}
-
-// executePgExecute handles EXECUTE statements
-func executePgExecute(stmt *pg_query.ExecuteStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
-	// For now, we'll return an error saying EXECUTE is not fully implemented
-	return nil, nil, "", fmt.Errorf("EXECUTE statement is not yet implemented")
-}
-
-// executePgDeallocate handles DEALLOCATE statements
-func executePgDeallocate(stmt *pg_query.DeallocateStmt) ([]string, [][]interface{}, string, error) {
-	// For now, we'll return an error saying DEALLOCATE is not fully implemented
-	return nil, nil, "", fmt.Errorf("DEALLOCATE statement is not yet implemented")
-}
\ No newline at end of file
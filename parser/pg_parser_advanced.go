@@ -5,6 +5,7 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"time"
 
 	pg_query "github.com/pganalyze/pg_query_go/v5"
 	"github.com/satetsu888/vsql/storage"
@@ -20,6 +21,16 @@ type QueryContext struct {
 	currentJoinContext *JoinContext  // Track current join context
 	currentRow   storage.Row          // Current row for correlated subqueries
 	outerRows    []storage.Row        // Stack of rows from outer queries
+	stats        *planStats           // Actual row counts for EXPLAIN ANALYZE, nil otherwise
+	guard        *statementGuard      // Cancellation of the running statement, nil if it cannot be canceled
+	err          error                // First error raised while evaluating expressions
+}
+
+// fail records an expression evaluation error; the query reports the first one
+func (ctx *QueryContext) fail(err error) {
+	if ctx.err == nil {
+		ctx.err = err
+	}
 }
 
 type TableContext struct {
@@ -51,8 +62,8 @@ func hasAggregateFunctions(targetList []*pg_query.Node) bool {
 	return false
 }
 
-func executePgSelectAdvanced(stmt *pg_query.SelectStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
-	ctx := &QueryContext{
+func newQueryContext(dataStore *storage.DataStore, metaStore *storage.MetaStore) *QueryContext {
+	return &QueryContext{
 		dataStore:    dataStore,
 		metaStore:    metaStore,
 		tables:       make(map[string]*TableContext),
@@ -61,25 +72,49 @@ func executePgSelectAdvanced(stmt *pg_query.SelectStmt, dataStore *storage.DataS
 		aggregations: make(map[string]interface{}),
 		outerRows:    []storage.Row{},
 	}
+}
 
+func executePgSelectAdvanced(stmt *pg_query.SelectStmt, guard *statementGuard, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	ctx := newQueryContext(dataStore, metaStore)
+	ctx.guard = guard
 	return executePgSelectWithContext(stmt, ctx)
 }
 
 func executePgSelectWithContext(stmt *pg_query.SelectStmt, ctx *QueryContext) ([]string, [][]interface{}, string, error) {
 	// Handle UNION/INTERSECT/EXCEPT queries
 	if stmt.Op != pg_query.SetOperation_SETOP_NONE {
-		return executeSetOperation(stmt, ctx.dataStore, ctx.metaStore)
+		return executeSetOperation(stmt, ctx)
 	}
 
+	start := time.Now()
+
 	// Handle FROM clause (including JOINs and subqueries)
-	rows, err := processFromClause(ctx, stmt.FromClause)
-	if err != nil {
-		return nil, nil, "", err
+	var rows []storage.Row
+	whereApplied := false
+	if rv := singleRangeVar(stmt.FromClause); rv != nil {
+		// Single table: an index may narrow the rows read
+		rows, whereApplied = scanSingleTable(ctx, stmt, rv)
+	} else {
+		var err error
+		rows, err = processFromClause(ctx, stmt.FromClause)
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	if ctx.err != nil {
+		return nil, nil, "", ctx.err
 	}
+	ctx.stats.record(stmt, planStepFrom, len(rows), start)
 
 	// Apply WHERE clause
 	if stmt.WhereClause != nil {
-		rows = filterRows(rows, stmt.WhereClause, ctx)
+		if !whereApplied {
+			rows = filterRows(rows, stmt.WhereClause, ctx)
+		}
+		if ctx.err != nil {
+			return nil, nil, "", ctx.err
+		}
+		ctx.stats.record(stmt, planStepWhere, len(rows), start)
 	}
 
 	// Check if we have aggregate functions
@@ -88,7 +123,10 @@ func executePgSelectWithContext(stmt *pg_query.SelectStmt, ctx *QueryContext) ([
 	// Handle GROUP BY
 	var groupedRows map[string][]storage.Row
 	if len(stmt.GroupClause) > 0 {
-		groupedRows = groupRows(rows, stmt.GroupClause)
+		groupedRows = groupRows(ctx, rows, stmt.GroupClause)
+		if ctx.err != nil {
+			return nil, nil, "", ctx.err
+		}
 	} else if hasAggregates {
 		// If we have aggregates but no GROUP BY, treat all rows as one group
 		groupedRows = map[string][]storage.Row{
@@ -101,10 +139,17 @@ func executePgSelectWithContext(stmt *pg_query.SelectStmt, ctx *QueryContext) ([
 	if err != nil {
 		return nil, nil, "", err
 	}
+	if ctx.err != nil {
+		return nil, nil, "", ctx.err
+	}
+	if groupedRows != nil {
+		ctx.stats.record(stmt, planStepAggregate, len(resultRows), start)
+	}
 
 	// Apply DISTINCT
 	if stmt.DistinctClause != nil && len(stmt.DistinctClause) > 0 {
 		resultRows = applyDistinct(resultRows)
+		ctx.stats.record(stmt, planStepDistinct, len(resultRows), start)
 	}
 
 	// Apply HAVING clause
@@ -120,16 +165,22 @@ func executePgSelectWithContext(stmt *pg_query.SelectStmt, ctx *QueryContext) ([
 			}
 		}
 		resultRows = filterResultRowsWithGroups(resultRows, columns, stmt.HavingClause, orderedGroups)
+		ctx.stats.record(stmt, planStepHaving, len(resultRows), start)
 	}
 
 	// Apply ORDER BY
 	if len(stmt.SortClause) > 0 {
-		resultRows = sortRows(resultRows, columns, stmt.SortClause)
+		resultRows = sortRows(resultRows, columns, stmt.SortClause, ctx)
+		if ctx.err != nil {
+			return nil, nil, "", ctx.err
+		}
+		ctx.stats.record(stmt, planStepSort, len(resultRows), start)
 	}
 
 	// Apply LIMIT and OFFSET
 	if stmt.LimitCount != nil || stmt.LimitOffset != nil {
 		resultRows = applyLimitOffset(resultRows, stmt.LimitCount, stmt.LimitOffset)
+		ctx.stats.record(stmt, planStepLimit, len(resultRows), start)
 	}
 
 	return columns, resultRows, fmt.Sprintf("SELECT %d", len(resultRows)), nil
@@ -147,8 +198,39 @@ func processFromClause(ctx *QueryContext, fromClause []*pg_query.Node) ([]storag
 	}
 
 	// Multiple items in FROM clause - handle as CROSS JOIN
+	start := time.Now()
 	var result []storage.Row
 	for i, fromNode := range fromClause {
+		if rf, ok := fromNode.Node.(*pg_query.Node_RangeFunction); ok && i > 0 {
+			// Functions see the columns of the FROM items before them
+			var newResult []storage.Row
+			for _, r1 := range result {
+				rows, err := evaluateRangeFunction(ctx, rf.RangeFunction, r1)
+				if err != nil {
+					return nil, err
+				}
+				for _, r2 := range rows {
+					if ctx.interrupted() {
+						return nil, ctx.err
+					}
+					merged := make(storage.Row, len(r1)+len(r2))
+					for k, v := range r1 {
+						merged[k] = v
+					}
+					for k, v := range r2 {
+						merged[k] = v
+					}
+					newResult = append(newResult, merged)
+					if ctx.exceedsLimits(merged) {
+						return nil, ctx.err
+					}
+				}
+			}
+			result = newResult
+			ctx.stats.record(fromNode, planStepCrossJoin, len(result), start)
+			continue
+		}
+
 		rows, err := processFromNode(ctx, fromNode)
 		if err != nil {
 			return nil, err
@@ -161,6 +243,9 @@ func processFromClause(ctx *QueryContext, fromClause []*pg_query.Node) ([]storag
 			var newResult []storage.Row
 			for _, r1 := range result {
 				for _, r2 := range rows {
+					if ctx.interrupted() {
+						return nil, ctx.err
+					}
 					merged := make(storage.Row)
 					for k, v := range r1 {
 						merged[k] = v
@@ -169,9 +254,13 @@ func processFromClause(ctx *QueryContext, fromClause []*pg_query.Node) ([]storag
 						merged[k] = v
 					}
 					newResult = append(newResult, merged)
+					if ctx.exceedsLimits(merged) {
+						return nil, ctx.err
+					}
 				}
 			}
 			result = newResult
+			ctx.stats.record(fromNode, planStepCrossJoin, len(result), start)
 		}
 	}
 
@@ -179,50 +268,21 @@ func processFromClause(ctx *QueryContext, fromClause []*pg_query.Node) ([]storag
 }
 
 func processFromNode(ctx *QueryContext, node *pg_query.Node) ([]storage.Row, error) {
+	start := time.Now()
 	switch n := node.Node.(type) {
 	case *pg_query.Node_RangeVar:
-		realTableName := n.RangeVar.Relname
-		aliasName := realTableName
-		if n.RangeVar.Alias != nil && n.RangeVar.Alias.Aliasname != "" {
-			aliasName = n.RangeVar.Alias.Aliasname
-		}
-
 		var rows []storage.Row
-		table, exists := ctx.dataStore.GetTable(realTableName)
+		table, exists := ctx.dataStore.GetTable(n.RangeVar.Relname)
 		if exists {
 			rows = table.GetRows()
 		} else {
 			// Table doesn't exist - return empty row set
 			rows = []storage.Row{}
 		}
-		
-		// Store table context with both real name and alias
-		ctx.tables[aliasName] = &TableContext{
-			name:  realTableName,
-			alias: aliasName,
-			rows:  rows,
-		}
-		if aliasName != realTableName {
-			ctx.tables[realTableName] = ctx.tables[aliasName]
-		}
-		
-		// If we have an alias, enrich rows with qualified column names
-		if aliasName != "" {
-			enrichedRows := make([]storage.Row, len(rows))
-			for i, row := range rows {
-				enrichedRow := make(storage.Row)
-				for k, v := range row {
-					// Add unqualified name
-					enrichedRow[k] = v
-					// Add qualified name
-					enrichedRow[aliasName+"."+k] = v
-				}
-				enrichedRows[i] = enrichedRow
-			}
-			return enrichedRows, nil
-		}
-		
-		return rows, nil
+		ctx.stats.record(n.RangeVar, planStepScan, len(rows), start)
+
+		aliasName := registerRangeVar(ctx, n.RangeVar, rows)
+		return enrichRows(rows, aliasName), nil
 	case *pg_query.Node_JoinExpr:
 		// Handle JOIN
 		// fmt.Printf("DEBUG processFromNode: Processing JoinExpr\n")
@@ -244,13 +304,19 @@ func processFromNode(ctx *QueryContext, node *pg_query.Node) ([]storage.Row, err
 		}
 		// fmt.Printf("DEBUG processFromNode: Right rows count: %d\n", len(rightRows))
 		
-		return performJoinWithContext(leftRows, rightRows, n.JoinExpr, leftAlias, rightAlias, ctx), nil
+		joined := performJoinWithContext(leftRows, rightRows, n.JoinExpr, leftAlias, rightAlias, ctx)
+		if ctx.err != nil {
+			return nil, ctx.err
+		}
+		ctx.stats.record(n.JoinExpr, planStepJoin, len(joined), start)
+		return joined, nil
 	case *pg_query.Node_RangeSubselect:
 		// Execute the subquery
 		rows, err := executeSubquery(n.RangeSubselect.Subquery, ctx)
 		if err != nil {
 			return nil, err
 		}
+		ctx.stats.record(n.RangeSubselect, planStepScan, len(rows), start)
 		
 		// Handle alias for the subquery result
 		if n.RangeSubselect.Alias != nil && n.RangeSubselect.Alias.Aliasname != "" {
@@ -264,12 +330,229 @@ func processFromNode(ctx *QueryContext, node *pg_query.Node) ([]storage.Row, err
 			}
 		}
 		
+		return rows, nil
+	case *pg_query.Node_RangeFunction:
+		rows, err := evaluateRangeFunction(ctx, n.RangeFunction, storage.Row{})
+		if err != nil {
+			return nil, err
+		}
+		ctx.stats.record(n.RangeFunction, planStepScan, len(rows), start)
 		return rows, nil
 	}
 	log.Printf("WARNING: Unsupported FROM node type in query. Returning empty result.\n")
 	return []storage.Row{}, nil
 }
 
+// rangeFunctionCall returns the function called by a FROM item such as
+// jsonb_each(doc)
+func rangeFunctionCall(rf *pg_query.RangeFunction) *pg_query.FuncCall {
+	if len(rf.Functions) == 0 {
+		return nil
+	}
+	list, ok := rf.Functions[0].Node.(*pg_query.Node_List)
+	if !ok || len(list.List.Items) == 0 {
+		return nil
+	}
+	if call, ok := list.List.Items[0].Node.(*pg_query.Node_FuncCall); ok {
+		return call.FuncCall
+	}
+	return nil
+}
+
+// evaluateRangeFunction computes the rows of a function in FROM. As in
+// PostgreSQL, functions in FROM are implicitly LATERAL, so their arguments
+// may refer to the columns of row. A scalar function gives a single row
+// with one column named after the function or its alias.
+func evaluateRangeFunction(ctx *QueryContext, rf *pg_query.RangeFunction, row storage.Row) ([]storage.Row, error) {
+	funcCall := rangeFunctionCall(rf)
+	if funcCall == nil {
+		return nil, newSQLError(SQLStateFeatureNotSupported, "only function calls are supported in FROM")
+	}
+	name := getFunctionName(funcCall)
+	args := make([]interface{}, len(funcCall.Args))
+	for i, arg := range funcCall.Args {
+		args[i] = evaluateExpression(arg, row, ctx)
+	}
+	if ctx.err != nil {
+		return nil, ctx.err
+	}
+	fn, ok := LookupFunction(name)
+	if !ok {
+		argTypes := make([]string, len(args))
+		for i, arg := range args {
+			argTypes[i] = valueTypeName(arg)
+		}
+		return nil, undefinedFunctionError(name, argTypes)
+	}
+	if fn.Kind == AggregateFunction {
+		return nil, newSQLError(SQLStateGroupingError, "aggregate functions are not allowed in functions in FROM")
+	}
+	result, err := fn.Call(args)
+	if err != nil {
+		return nil, err
+	}
+
+	alias := fn.Name
+	if rf.Alias != nil && rf.Alias.Aliasname != "" {
+		alias = rf.Alias.Aliasname
+	}
+	columns := fn.Columns
+	var rows []storage.Row
+	if fn.Kind == SetReturningFunction {
+		rows, _ = result.([]storage.Row)
+	} else {
+		columns = []string{alias}
+		rows = []storage.Row{{alias: result}}
+	}
+
+	// Column aliases rename the function's columns in order. A function
+	// with a single column, such as unnest, names it after its alias.
+	var colnames []string
+	if rf.Alias != nil {
+		for _, colName := range rf.Alias.Colnames {
+			if str, ok := colName.Node.(*pg_query.Node_String_); ok {
+				colnames = append(colnames, str.String_.Sval)
+			}
+		}
+		if len(colnames) == 0 && len(columns) == 1 && alias != fn.Name {
+			colnames = []string{alias}
+		}
+	}
+	if len(colnames) > 0 {
+		renames := make(map[string]string)
+		for i, colName := range colnames {
+			if i < len(columns) {
+				renames[columns[i]] = colName
+			}
+		}
+		for i, r := range rows {
+			renamed := make(storage.Row, len(r))
+			for k, v := range r {
+				if to, ok := renames[k]; ok {
+					k = to
+				}
+				renamed[k] = v
+			}
+			rows[i] = renamed
+		}
+	}
+
+	ctx.tables[alias] = &TableContext{
+		name:  alias,
+		alias: alias,
+		rows:  rows,
+	}
+	return enrichRows(rows, alias), nil
+}
+
+// registerRangeVar stores the table context for a table reference under both
+// its real name and its alias, and returns the alias
+func registerRangeVar(ctx *QueryContext, rv *pg_query.RangeVar, rows []storage.Row) string {
+	realTableName := rv.Relname
+	aliasName := realTableName
+	if rv.Alias != nil && rv.Alias.Aliasname != "" {
+		aliasName = rv.Alias.Aliasname
+	}
+
+	// Store table context with both real name and alias
+	ctx.tables[aliasName] = &TableContext{
+		name:  realTableName,
+		alias: aliasName,
+		rows:  rows,
+	}
+	if aliasName != realTableName {
+		ctx.tables[realTableName] = ctx.tables[aliasName]
+	}
+	return aliasName
+}
+
+// enrichRows copies rows adding alias-qualified column names
+func enrichRows(rows []storage.Row, aliasName string) []storage.Row {
+	if aliasName == "" {
+		return rows
+	}
+	enrichedRows := make([]storage.Row, len(rows))
+	for i, row := range rows {
+		enrichedRows[i] = enrichRow(row, aliasName)
+	}
+	return enrichedRows
+}
+
+func enrichRow(row storage.Row, aliasName string) storage.Row {
+	enrichedRow := make(storage.Row, len(row)*2)
+	for k, v := range row {
+		// Add unqualified name
+		enrichedRow[k] = v
+		// Add qualified name
+		enrichedRow[aliasName+"."+k] = v
+	}
+	return enrichedRow
+}
+
+// singleRangeVar returns the table reference if the FROM clause is a single table
+func singleRangeVar(fromClause []*pg_query.Node) *pg_query.RangeVar {
+	if len(fromClause) != 1 {
+		return nil
+	}
+	if rv, ok := fromClause[0].Node.(*pg_query.Node_RangeVar); ok {
+		return rv.RangeVar
+	}
+	return nil
+}
+
+// scanSingleTable reads the rows of a SELECT whose FROM clause is a single
+// table, using an index to narrow the rows read when one applies.
+// The second return value reports whether the WHERE clause has already been
+// applied to the returned rows.
+func scanSingleTable(ctx *QueryContext, stmt *pg_query.SelectStmt, rv *pg_query.RangeVar) ([]storage.Row, bool) {
+	start := time.Now()
+	table, exists := ctx.dataStore.GetTable(rv.Relname)
+	if !exists {
+		// Table doesn't exist - return empty row set
+		registerRangeVar(ctx, rv, []storage.Row{})
+		ctx.stats.record(rv, planStepScan, 0, start)
+		return []storage.Row{}, true
+	}
+
+	qualifiers := []string{rv.Relname}
+	if rv.Alias != nil && rv.Alias.Aliasname != "" {
+		qualifiers = append(qualifiers, rv.Alias.Aliasname)
+	}
+
+	// ORDER BY col LIMIT n: walk the index and stop once enough rows match
+	if ordered := chooseIndexOrderedScan(stmt, table, qualifiers); ordered != nil {
+		if cursor, ok := table.IndexOrderedScan(ordered.index, ordered.desc); ok {
+			aliasName := registerRangeVar(ctx, rv, nil)
+			result := []storage.Row{}
+			scanned := 0
+			for len(result) < ordered.limit {
+				row, ok := cursor.Next()
+				if !ok {
+					break
+				}
+				scanned++
+				row = enrichRow(row, aliasName)
+				if stmt.WhereClause == nil || evaluateWhereWithSubqueries(row, stmt.WhereClause, ctx) {
+					result = append(result, row)
+				}
+			}
+			ctx.tables[aliasName].rows = result
+			ctx.stats.record(rv, planStepScan, scanned, start)
+			return result, true
+		}
+	}
+
+	var rows []storage.Row
+	if scan := chooseIndexScan(table, stmt.WhereClause, qualifiers, true); scan != nil {
+		rows = scan.rows(table)
+	} else {
+		rows = table.GetRows()
+	}
+	ctx.stats.record(rv, planStepScan, len(rows), start)
+	aliasName := registerRangeVar(ctx, rv, rows)
+	return enrichRows(rows, aliasName), false
+}
+
 func extractTableAlias(node *pg_query.Node) string {
 	if node == nil {
 		return ""
@@ -332,11 +615,17 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 		// INNER JOIN
 		for _, leftRow := range leftRows {
 			for _, rightRow := range rightRows {
+				if ctx.interrupted() {
+					return result
+				}
 				if joinExpr.Quals == nil || evaluateJoinCondition(leftRow, rightRow, joinExpr.Quals, ctx) {
 					mergedRow := mergeRowsWithAliases(leftRow, rightRow, leftAlias, rightAlias)
 					// DEBUG: Print merged row
 					// // fmt.Printf("DEBUG performJoinWithAliases: Merged row: %v\n", mergedRow)
 					result = append(result, mergedRow)
+					if ctx.exceedsLimits(mergedRow) {
+						return result
+					}
 				}
 			}
 		}
@@ -345,9 +634,15 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 		for _, leftRow := range leftRows {
 			matched := false
 			for _, rightRow := range rightRows {
+				if ctx.interrupted() {
+					return result
+				}
 				if joinExpr.Quals == nil || evaluateJoinCondition(leftRow, rightRow, joinExpr.Quals, ctx) {
 					mergedRow := mergeRowsWithAliases(leftRow, rightRow, leftAlias, rightAlias)
 					result = append(result, mergedRow)
+					if ctx.exceedsLimits(mergedRow) {
+						return result
+					}
 					matched = true
 				}
 			}
@@ -373,6 +668,9 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 				
 				mergedRow := mergeRowsWithAliases(leftRow, nullRightRow, leftAlias, rightAlias)
 				result = append(result, mergedRow)
+				if ctx.exceedsLimits(mergedRow) {
+					return result
+				}
 			}
 		}
 	case pg_query.JoinType_JOIN_RIGHT:
@@ -380,9 +678,15 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 		for _, rightRow := range rightRows {
 			matched := false
 			for _, leftRow := range leftRows {
+				if ctx.interrupted() {
+					return result
+				}
 				if joinExpr.Quals == nil || evaluateJoinCondition(leftRow, rightRow, joinExpr.Quals, ctx) {
 					mergedRow := mergeRowsWithAliases(leftRow, rightRow, leftAlias, rightAlias)
 					result = append(result, mergedRow)
+					if ctx.exceedsLimits(mergedRow) {
+						return result
+					}
 					matched = true
 				}
 			}
@@ -408,6 +712,9 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 				
 				mergedRow := mergeRowsWithAliases(nullLeftRow, rightRow, leftAlias, rightAlias)
 				result = append(result, mergedRow)
+				if ctx.exceedsLimits(mergedRow) {
+					return result
+				}
 			}
 		}
 	case pg_query.JoinType_JOIN_FULL:
@@ -418,9 +725,15 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 		// First, do inner join part
 		for i, leftRow := range leftRows {
 			for j, rightRow := range rightRows {
+				if ctx.interrupted() {
+					return result
+				}
 				if joinExpr.Quals == nil || evaluateJoinCondition(leftRow, rightRow, joinExpr.Quals, ctx) {
 					mergedRow := mergeRowsWithAliases(leftRow, rightRow, leftAlias, rightAlias)
 					result = append(result, mergedRow)
+					if ctx.exceedsLimits(mergedRow) {
+						return result
+					}
 					leftMatched[i] = true
 					rightMatched[j] = true
 				}
@@ -445,6 +758,9 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 				}
 				mergedRow := mergeRowsWithAliases(leftRow, nullRightRow, leftAlias, rightAlias)
 				result = append(result, mergedRow)
+				if ctx.exceedsLimits(mergedRow) {
+					return result
+				}
 			}
 		}
 
@@ -466,6 +782,9 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 				}
 				mergedRow := mergeRowsWithAliases(nullLeftRow, rightRow, leftAlias, rightAlias)
 				result = append(result, mergedRow)
+				if ctx.exceedsLimits(mergedRow) {
+					return result
+				}
 			}
 		}
 	default:
@@ -476,6 +795,9 @@ func performJoinWithAliases(leftRows, rightRows []storage.Row, joinExpr *pg_quer
 			for _, rightRow := range rightRows {
 				mergedRow := mergeRowsWithAliases(leftRow, rightRow, leftAlias, rightAlias)
 				result = append(result, mergedRow)
+				if ctx.exceedsLimits(mergedRow) {
+					return result
+				}
 			}
 		}
 	}
@@ -722,6 +1044,8 @@ func executeSubquery(subquery *pg_query.Node, ctx *QueryContext) ([]storage.Row,
 			aggregations: make(map[string]interface{}),
 			currentRow:   ctx.currentRow, // Pass the outer query's row
 			outerRows:    make([]storage.Row, len(ctx.outerRows)),
+			stats:        ctx.stats,
+			guard:        ctx.guard,
 		}
 		
 		// Copy outer rows stack
@@ -765,6 +1089,9 @@ func executeSubquery(subquery *pg_query.Node, ctx *QueryContext) ([]storage.Row,
 					storageRow[fmt.Sprintf("col%d", i)] = val
 				}
 			}
+			if ctx.exceedsLimits(storageRow) {
+				return nil, ctx.err
+			}
 			result = append(result, storageRow)
 		}
 		return result, nil
@@ -776,6 +1103,9 @@ func executeSubquery(subquery *pg_query.Node, ctx *QueryContext) ([]storage.Row,
 func filterRows(rows []storage.Row, whereClause *pg_query.Node, ctx *QueryContext) []storage.Row {
 	var filtered []storage.Row
 	for _, row := range rows {
+		if ctx.interrupted() {
+			return nil
+		}
 		if evaluateWhereWithSubqueries(row, whereClause, ctx) {
 			filtered = append(filtered, row)
 		}
@@ -930,27 +1260,33 @@ func evaluateSubqueryExpression(row storage.Row, sublink *pg_query.SubLink, ctx
 	return false
 }
 
-func groupRows(rows []storage.Row, groupClause []*pg_query.Node) map[string][]storage.Row {
+func groupRows(ctx *QueryContext, rows []storage.Row, groupClause []*pg_query.Node) map[string][]storage.Row {
 	groups := make(map[string][]storage.Row)
 
 	for _, row := range rows {
-		groupKey := buildGroupKey(row, groupClause)
+		if ctx.interrupted() {
+			return nil
+		}
+		if ctx.exceedsLimits(row) {
+			return nil
+		}
+		groupKey := buildGroupKey(ctx, row, groupClause)
 		groups[groupKey] = append(groups[groupKey], row)
 	}
 
 	return groups
 }
 
-func buildGroupKey(row storage.Row, groupClause []*pg_query.Node) string {
+func buildGroupKey(ctx *QueryContext, row storage.Row, groupClause []*pg_query.Node) string {
 	var keyParts []string
 	for _, groupNode := range groupClause {
-		value := extractGroupValue(row, groupNode)
+		value := extractGroupValue(ctx, row, groupNode)
 		keyParts = append(keyParts, fmt.Sprintf("%v", value))
 	}
 	return strings.Join(keyParts, "|")
 }
 
-func extractGroupValue(row storage.Row, node *pg_query.Node) interface{} {
+func extractGroupValue(ctx *QueryContext, row storage.Row, node *pg_query.Node) interface{} {
 	// Extract column reference from GROUP BY expression
 	if colRef, ok := node.Node.(*pg_query.Node_ColumnRef); ok {
 		fields := colRef.ColumnRef.Fields
@@ -965,8 +1301,10 @@ func extractGroupValue(row storage.Row, node *pg_query.Node) interface{} {
 				return row[str.String_.Sval]
 			}
 		}
+		return nil
 	}
-	return nil
+	// Expressions such as date_trunc('month', created_at) group by their value
+	return evaluateExpression(node, row, ctx)
 }
 
 func processSelectList(ctx *QueryContext, targetList []*pg_query.Node, allRows []storage.Row, groupedRows map[string][]storage.Row, groupClause []*pg_query.Node) ([]string, [][]interface{}, error) {
@@ -982,6 +1320,9 @@ func processSelectList(ctx *QueryContext, targetList []*pg_query.Node, allRows [
 		ctx.aggregations["__groupOrder__"] = [][]storage.Row{}
 		
 		for _, groupRows := range groupedRows {
+			if ctx.interrupted() {
+				return nil, nil, ctx.err
+			}
 			// Handle empty groups (e.g., COUNT on empty table)
 			var sampleRow storage.Row
 			if len(groupRows) > 0 {
@@ -991,6 +1332,9 @@ func processSelectList(ctx *QueryContext, targetList []*pg_query.Node, allRows [
 			}
 			
 			resultRow := processSelectTargetsWithColumns(ctx, targetList, sampleRow, groupRows, true, columns)
+			if ctx.exceedsLimits(resultRow) {
+				return nil, nil, ctx.err
+			}
 			resultRows = append(resultRows, resultRow)
 			
 			// Store group rows in order
@@ -1001,7 +1345,13 @@ func processSelectList(ctx *QueryContext, targetList []*pg_query.Node, allRows [
 	} else {
 		// Process non-grouped results
 		for _, row := range allRows {
+			if ctx.interrupted() {
+				return nil, nil, ctx.err
+			}
 			resultRow := processSelectTargetsWithColumns(ctx, targetList, row, allRows, false, columns)
+			if ctx.exceedsLimits(resultRow) {
+				return nil, nil, ctx.err
+			}
 			resultRows = append(resultRows, resultRow)
 		}
 	}
@@ -1052,6 +1402,9 @@ func determineAllColumns(ctx *QueryContext, targetList []*pg_query.Node, allRows
 		
 		for _, row := range rows {
 			for col := range row {
+				if isQualifiedCopy(row, col) {
+					continue
+				}
 				colMap[col] = true
 			}
 		}
@@ -1093,6 +1446,26 @@ func determineAllColumns(ctx *QueryContext, targetList []*pg_query.Node, allRows
 	return columns
 }
 
+// isQualifiedCopy reports whether col is an alias-qualified copy of a column
+// (see enrichRow) that SELECT * already lists under its own name. Copies
+// stay listed when several tables share the column name.
+func isQualifiedCopy(row storage.Row, col string) bool {
+	dot := strings.LastIndexByte(col, '.')
+	if dot < 0 {
+		return false
+	}
+	name := col[dot+1:]
+	if _, ok := row[name]; !ok {
+		return false
+	}
+	for other := range row {
+		if other != col && strings.HasSuffix(other, "."+name) {
+			return false
+		}
+	}
+	return true
+}
+
 func processSelectTargetsWithColumns(ctx *QueryContext, targetList []*pg_query.Node, currentRow storage.Row, groupRows []storage.Row, isGrouped bool, columns []string) []interface{} {
 	values := make([]interface{}, len(columns))
 	
@@ -1253,51 +1626,6 @@ func evaluateSelectExpression(resTarget *pg_query.ResTarget, currentRow storage.
 
 // isAggregateFunction is now in pg_parser_utils.go
 
-func evaluateScalarFunction(funcCall *pg_query.FuncCall, row storage.Row, ctx *QueryContext) interface{} {
-	if len(funcCall.Funcname) == 0 {
-		return nil
-	}
-	
-	funcName := getFunctionName(funcCall)
-	
-	switch funcName {
-	case "COALESCE":
-		// COALESCE returns the first non-NULL argument
-		for _, arg := range funcCall.Args {
-			val := evaluateExpression(arg, row, ctx)
-			if val != nil {
-				return val
-			}
-		}
-		return nil
-	case "UPPER":
-		// UPPER converts string to uppercase
-		if len(funcCall.Args) > 0 {
-			val := evaluateExpression(funcCall.Args[0], row, ctx)
-			if val != nil {
-				if str, ok := val.(string); ok {
-					return strings.ToUpper(str)
-				}
-			}
-		}
-		return nil
-	case "LOWER":
-		// LOWER converts string to lowercase
-		if len(funcCall.Args) > 0 {
-			val := evaluateExpression(funcCall.Args[0], row, ctx)
-			if val != nil {
-				if str, ok := val.(string); ok {
-					return strings.ToLower(str)
-				}
-			}
-		}
-		return nil
-	default:
-		// Unknown function, return nil
-		return nil
-	}
-}
-
 func evaluateExpression(node *pg_query.Node, row storage.Row, ctx *QueryContext) interface{} {
 	if node == nil {
 		return nil
@@ -1358,10 +1686,36 @@ func evaluateExpression(node *pg_query.Node, row storage.Row, ctx *QueryContext)
 	case *pg_query.Node_NullTest:
 		// Handle IS NULL / IS NOT NULL
 		return evaluateNullTestWithContext(row, n.NullTest, ctx)
+	case *pg_query.Node_TypeCast:
+		return evaluateTypeCast(n.TypeCast, evaluateExpression(n.TypeCast.Arg, row, ctx), ctx)
+	case *pg_query.Node_SqlvalueFunction:
+		return evaluateSQLValueFunction(n.SqlvalueFunction)
+	case *pg_query.Node_MinMaxExpr:
+		return evaluateMinMaxExpr(n.MinMaxExpr, row, ctx)
+	case *pg_query.Node_AArrayExpr:
+		return evaluateArrayExpr(n.AArrayExpr, row, ctx)
+	case *pg_query.Node_AIndirection:
+		return evaluateIndirection(n.AIndirection, row, ctx)
 	}
 	return nil
 }
 
+// evaluateTypeCast converts an evaluated value to the cast's target type
+func evaluateTypeCast(typeCast *pg_query.TypeCast, value interface{}, ctx *QueryContext) interface{} {
+	var result interface{}
+	var err error
+	if typ := regTypeName(typeCast.TypeName); typ != "" {
+		result, err = castRegObject(value, typ, ctx.dataStore, ctx.metaStore)
+	} else {
+		result, err = castValue(value, typeCast.TypeName)
+	}
+	if err != nil {
+		ctx.fail(err)
+		return nil
+	}
+	return result
+}
+
 func evaluateAggregateFunction(funcCall *pg_query.FuncCall, rows []storage.Row) interface{} {
 	if len(funcCall.Funcname) == 0 {
 		return nil
@@ -1447,9 +1801,8 @@ func evaluateAggregateFunction(funcCall *pg_query.FuncCall, rows []storage.Row)
 			return count
 		}
 
-	case "SUM":
-		var sum float64
-		hasNonNullValue := false
+	case "SUM", "AVG":
+		sum := newNumericSum()
 		
 		if isExpression && argExpr != nil {
 			// Create a temporary context for expression evaluation
@@ -1462,70 +1815,20 @@ func evaluateAggregateFunction(funcCall *pg_query.FuncCall, rows []storage.Row)
 			// Evaluate the expression for each row
 			for _, row := range rows {
 				tmpCtx.currentRow = row
-				val := evaluateExpression(argExpr, row, tmpCtx)
-				if val != nil {
-					if num, err := toFloat64(val); err == nil {
-						sum += num
-						hasNonNullValue = true
-					}
-				}
+				sum.add(evaluateExpression(argExpr, row, tmpCtx))
 			}
 		} else {
 			// Simple column reference
 			for _, row := range rows {
-				if val := row[colName]; val != nil {
-					if num, err := toFloat64(val); err == nil {
-						sum += num
-						hasNonNullValue = true
-					}
-				}
+				sum.add(row[colName])
 			}
 		}
 		
-		// SQL standard: SUM returns NULL if no non-NULL values
-		if !hasNonNullValue {
-			return nil
+		// SQL standard: SUM and AVG return NULL if no non-NULL values
+		if funcName == "AVG" {
+			return sum.avg()
 		}
-		return sum
-
-	case "AVG":
-		var sum float64
-		count := 0
-		
-		if isExpression && argExpr != nil {
-			// Create a temporary context for expression evaluation
-			tmpCtx := &QueryContext{
-				dataStore: nil,
-				metaStore: nil,
-				tables:    make(map[string]*TableContext),
-			}
-			
-			for _, row := range rows {
-				tmpCtx.currentRow = row
-				val := evaluateExpression(argExpr, row, tmpCtx)
-				if val != nil {
-					if num, err := toFloat64(val); err == nil {
-						sum += num
-						count++
-					}
-				}
-			}
-		} else {
-			// Simple column reference
-			for _, row := range rows {
-				if val := row[colName]; val != nil {
-					if num, err := toFloat64(val); err == nil {
-						sum += num
-						count++
-					}
-				}
-			}
-		}
-		
-		if count == 0 {
-			return nil
-		}
-		return sum / float64(count)
+		return sum.sum()
 
 	case "MAX":
 		var max interface{}
@@ -1590,11 +1893,152 @@ func evaluateAggregateFunction(funcCall *pg_query.FuncCall, rows []storage.Row)
 			}
 		}
 		return min
+
+	case "STRING_AGG":
+		return evaluateStringAgg(funcCall, rows)
+	case "JSONB_AGG", "JSON_AGG":
+		return evaluateJSONAgg(funcCall, rows)
+	case "ARRAY_AGG":
+		return evaluateArrayAgg(funcCall, rows)
 	}
 
 	return nil
 }
 
+// evaluateStringAgg concatenates the non-NULL values of a group, in the
+// aggregate's ORDER BY order when one is given
+func evaluateStringAgg(funcCall *pg_query.FuncCall, rows []storage.Row) interface{} {
+	if len(funcCall.Args) != 2 {
+		return nil
+	}
+	tmpCtx := &QueryContext{
+		tables: make(map[string]*TableContext),
+	}
+	rows = sortAggregateRows(funcCall, rows, tmpCtx)
+
+	var sb strings.Builder
+	seen := make(map[string]bool)
+	found := false
+	for _, row := range rows {
+		tmpCtx.currentRow = row
+		val := evaluateExpression(funcCall.Args[0], row, tmpCtx)
+		if val == nil {
+			continue
+		}
+		text := textOf(val)
+		if funcCall.AggDistinct {
+			if seen[text] {
+				continue
+			}
+			seen[text] = true
+		}
+		if found {
+			if delimiter := evaluateExpression(funcCall.Args[1], row, tmpCtx); delimiter != nil {
+				sb.WriteString(textOf(delimiter))
+			}
+		}
+		sb.WriteString(text)
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return sb.String()
+}
+
+// sortAggregateRows orders a group's rows by the aggregate's ORDER BY, if any
+func sortAggregateRows(funcCall *pg_query.FuncCall, rows []storage.Row, ctx *QueryContext) []storage.Row {
+	if len(funcCall.AggOrder) == 0 {
+		return rows
+	}
+	rows = append([]storage.Row(nil), rows...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, node := range funcCall.AggOrder {
+			sortBy, ok := node.Node.(*pg_query.Node_SortBy)
+			if !ok {
+				continue
+			}
+			left := evaluateExpression(sortBy.SortBy.Node, rows[i], ctx)
+			right := evaluateExpression(sortBy.SortBy.Node, rows[j], ctx)
+			if left == nil || right == nil {
+				if left == right {
+					continue
+				}
+				// NULLs sort last in ascending order
+				return (right == nil) != (sortBy.SortBy.SortbyDir == pg_query.SortByDir_SORTBY_DESC)
+			}
+			if cmp := compareForSort(left, right); cmp != 0 {
+				if sortBy.SortBy.SortbyDir == pg_query.SortByDir_SORTBY_DESC {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return rows
+}
+
+// evaluateJSONAgg collects the values of a group, NULLs included, into a
+// JSON array in the aggregate's ORDER BY order
+func evaluateJSONAgg(funcCall *pg_query.FuncCall, rows []storage.Row) interface{} {
+	if len(funcCall.Args) != 1 || len(rows) == 0 {
+		return nil
+	}
+	tmpCtx := &QueryContext{
+		tables: make(map[string]*TableContext),
+	}
+	rows = sortAggregateRows(funcCall, rows, tmpCtx)
+
+	elems := []interface{}{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		tmpCtx.currentRow = row
+		elem := toJSONDoc(evaluateExpression(funcCall.Args[0], row, tmpCtx))
+		if funcCall.AggDistinct {
+			key := storage.JSON{Doc: elem}.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		elems = append(elems, elem)
+	}
+	return storage.JSON{Doc: elems}
+}
+
+// evaluateArrayAgg collects the values of a group, NULLs included, into an
+// array in the aggregate's ORDER BY order
+func evaluateArrayAgg(funcCall *pg_query.FuncCall, rows []storage.Row) interface{} {
+	if len(funcCall.Args) != 1 || len(rows) == 0 {
+		return nil
+	}
+	tmpCtx := &QueryContext{
+		tables: make(map[string]*TableContext),
+	}
+	rows = sortAggregateRows(funcCall, rows, tmpCtx)
+
+	values := []interface{}{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		tmpCtx.currentRow = row
+		value := evaluateExpression(funcCall.Args[0], row, tmpCtx)
+		if funcCall.AggDistinct {
+			key := arrayElementKey(value)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		values = append(values, value)
+	}
+	array, err := makeArray(values)
+	if err != nil {
+		return nil
+	}
+	return array
+}
+
 func isStarExpr(node *pg_query.Node) bool {
 	if colRef, ok := node.Node.(*pg_query.Node_ColumnRef); ok {
 		if len(colRef.ColumnRef.Fields) > 0 {
@@ -1628,6 +2072,24 @@ func extractColumnName(node *pg_query.Node) string {
 		if funcName != "" {
 			return strings.ToLower(funcName)
 		}
+	case *pg_query.Node_TypeCast:
+		// A cast keeps the name of what it casts, or takes the type's name
+		if name := extractColumnName(n.TypeCast.Arg); name != "?column?" {
+			return name
+		}
+		return typeNameString(n.TypeCast.TypeName)
+	case *pg_query.Node_SqlvalueFunction:
+		return SQLValueFunctionName(n.SqlvalueFunction)
+	case *pg_query.Node_MinMaxExpr:
+		if n.MinMaxExpr.Op == pg_query.MinMaxOp_IS_GREATEST {
+			return "greatest"
+		}
+		return "least"
+	case *pg_query.Node_AArrayExpr:
+		return "array"
+	case *pg_query.Node_AIndirection:
+		// A subscript keeps the name of what it subscripts
+		return extractColumnName(n.AIndirection.Arg)
 	}
 	return "?column?"
 }
@@ -1674,7 +2136,7 @@ func filterResultRowsWithGroups(rows [][]interface{}, columns []string, havingCl
 				// e.g., if column is "total_spent" and value is numeric, also map "sum" -> value
 				// This is a heuristic but helps with HAVING SUM(x) > n when SELECT has SUM(x) AS total_spent
 				switch v := row[i].(type) {
-				case int, int64, float64:
+				case int, int64, float64, storage.Numeric:
 					// For numeric columns, also map common aggregate function names
 					if col != "sum" && col != "count" && col != "avg" && col != "max" && col != "min" {
 						// This might be an aliased aggregate
@@ -1821,17 +2283,27 @@ func evaluateHavingBoolExpr(rowMap storage.Row, expr *pg_query.BoolExpr, groupRo
 	return true
 }
 
-func sortRows(rows [][]interface{}, columns []string, sortClause []*pg_query.Node) [][]interface{} {
+func sortRows(rows [][]interface{}, columns []string, sortClause []*pg_query.Node, ctx *QueryContext) [][]interface{} {
 	if len(sortClause) == 0 || len(rows) == 0 {
 		return rows
 	}
 	
-	// Create a copy of rows to avoid modifying the original
-	result := make([][]interface{}, len(rows))
-	copy(result, rows)
+	// Create a copy of rows to avoid modifying the original; the rows a
+	// sort holds count towards the statement's limits
+	result := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if ctx.exceedsLimits(row) {
+			return nil
+		}
+		result = append(result, row)
+	}
 	
 	// Sort using all sort clauses
 	sort.Slice(result, func(i, j int) bool {
+		// A canceled sort stops ordering; its result is discarded
+		if ctx.interrupted() {
+			return false
+		}
 		// Compare using each sort clause in order
 		for _, sortNode := range sortClause {
 			if sortBy, ok := sortNode.Node.(*pg_query.Node_SortBy); ok {
@@ -1927,6 +2399,21 @@ func sortRows(rows [][]interface{}, columns []string, sortClause []*pg_query.Nod
 // compareForSort compares two values for sorting purposes
 // Returns -1 if val1 < val2, 0 if equal, 1 if val1 > val2
 func compareForSort(val1, val2 interface{}) int {
+	if a, ok := val1.(storage.Array); ok {
+		if b, ok := val2.(storage.Array); ok {
+			return compareArrays(a, b)
+		}
+	}
+	if cmp, ok := compareTemporal(val1, val2); ok {
+		return cmp
+	}
+	if cmp, ok := compareOpaque(val1, val2); ok {
+		return cmp
+	}
+	if cmp, ok := compareNumeric(val1, val2); ok {
+		return cmp
+	}
+
 	// Try to compare as numbers first
 	num1, err1 := toFloat64(val1)
 	num2, err2 := toFloat64(val2)
@@ -2020,7 +2507,39 @@ func evaluateAExprWithContext(row storage.Row, expr *pg_query.A_Expr, ctx *Query
 
 	// Handle different expression kinds
 	switch expr.Kind {
+	case pg_query.A_Expr_Kind_AEXPR_OP, pg_query.A_Expr_Kind_AEXPR_LIKE, pg_query.A_Expr_Kind_AEXPR_ILIKE:
+		if (expr.Lexpr != nil && leftVal == nil) || rightVal == nil {
+			// A comparison with NULL is unknown, which WHERE treats as false
+			return false
+		}
+	}
+	switch expr.Kind {
+	case pg_query.A_Expr_Kind_AEXPR_OP_ANY, pg_query.A_Expr_Kind_AEXPR_OP_ALL:
+		result, err := evaluateAnyAll(op, leftVal, rightVal, expr.Kind == pg_query.A_Expr_Kind_AEXPR_OP_ALL)
+		if err != nil {
+			ctx.fail(err)
+		}
+		matched, _ := result.(bool)
+		return matched
 	case pg_query.A_Expr_Kind_AEXPR_OP:
+		if result, ok, err := arrayOperator(op, leftVal, rightVal); ok {
+			if err != nil {
+				ctx.fail(err)
+			}
+			matched, _ := result.(bool)
+			return matched
+		}
+		if result, ok, err := jsonOperator(op, leftVal, rightVal); ok {
+			if err != nil {
+				ctx.fail(err)
+			}
+			matched, _ := result.(bool)
+			return matched
+		}
+		if _, ok := compareOpaque(leftVal, rightVal); ok {
+			// uuids, byteas and enums have their own order, which text loses
+			return compareValuesPg(leftVal, op, rightVal)
+		}
 		return compareValuesPg(fmt.Sprintf("%v", leftVal), op, rightVal)
 	case pg_query.A_Expr_Kind_AEXPR_IN:
 		// IN expression is handled by evaluatePgWhere
@@ -2094,34 +2613,55 @@ func evaluateAExprValue(row storage.Row, expr *pg_query.A_Expr, ctx *QueryContex
 		}
 	}
 	
+	switch expr.Kind {
+	case pg_query.A_Expr_Kind_AEXPR_OP_ANY, pg_query.A_Expr_Kind_AEXPR_OP_ALL:
+		result, err := evaluateAnyAll(op, leftVal, rightVal, expr.Kind == pg_query.A_Expr_Kind_AEXPR_OP_ALL)
+		if err != nil {
+			ctx.fail(err)
+		}
+		return result
+	}
 	
-	// Perform arithmetic operation
-	switch op {
-	case "+":
-		left, _ := toFloat64(leftVal)
-		right, _ := toFloat64(rightVal)
-		return left + right
-	case "-":
-		left, _ := toFloat64(leftVal)
-		right, _ := toFloat64(rightVal)
-		return left - right
-	case "*":
-		left, _ := toFloat64(leftVal)
-		right, _ := toFloat64(rightVal)
-		return left * right
-	case "/":
-		left, _ := toFloat64(leftVal)
-		right, _ := toFloat64(rightVal)
-		if right != 0 {
-			return left / right
+	if result, ok, err := arrayOperator(op, leftVal, rightVal); ok {
+		if err != nil {
+			ctx.fail(err)
 		}
-		return nil
+		return result
+	}
+	
+	if result, ok, err := jsonOperator(op, leftVal, rightVal); ok {
+		if err != nil {
+			ctx.fail(err)
+		}
+		return result
+	}
+	
+	if result, ok, err := temporalArithmetic(op, leftVal, rightVal); ok {
+		if err != nil {
+			ctx.fail(err)
+		}
+		return result
+	}
+	
+	if isArithmeticOperator(op) {
+		result, err := numericArithmetic(op, leftVal, rightVal, expr.Lexpr == nil)
+		if err == nil {
+			result, err = checkIntegerResult(op, result, integerWidth(&pg_query.Node{Node: &pg_query.Node_AExpr{AExpr: expr}}, ctx))
+		}
+		if err != nil {
+			ctx.fail(err)
+			return nil
+		}
+		return result
+	}
+	
+	switch op {
 	case "||":
 		// String concatenation - if either operand is NULL, result is NULL
 		if leftVal == nil || rightVal == nil {
 			return nil
 		}
-		return fmt.Sprintf("%v%v", leftVal, rightVal)
+		return textOf(leftVal) + textOf(rightVal)
 	default:
 		// For comparison operators, return boolean result
 		return compareValuesPg(leftVal, op, rightVal)
@@ -2237,7 +2777,11 @@ func extractValueFromNodeWithContext(row storage.Row, node *pg_query.Node, ctx *
 		ctx.currentRow = oldRow
 		ctx.outerRows = oldOuterRows
 		
-		if err != nil || len(subRows) != 1 || len(subRows[0]) == 0 {
+		if err != nil {
+			ctx.fail(err)
+			return nil
+		}
+		if len(subRows) != 1 || len(subRows[0]) == 0 {
 			return nil
 		}
 		// Return the first column of the first row
@@ -2247,6 +2791,21 @@ func extractValueFromNodeWithContext(row storage.Row, node *pg_query.Node, ctx *
 	case *pg_query.Node_NullTest:
 		// Handle IS NULL / IS NOT NULL
 		return evaluateNullTestWithContext(row, n.NullTest, ctx)
+	case *pg_query.Node_FuncCall:
+		if isAggregateFunction(getFunctionName(n.FuncCall)) {
+			return nil
+		}
+		return evaluateScalarFunction(n.FuncCall, row, ctx)
+	case *pg_query.Node_TypeCast:
+		return evaluateTypeCast(n.TypeCast, extractValueFromNodeWithContext(row, n.TypeCast.Arg, ctx), ctx)
+	case *pg_query.Node_SqlvalueFunction:
+		return evaluateSQLValueFunction(n.SqlvalueFunction)
+	case *pg_query.Node_MinMaxExpr:
+		return evaluateMinMaxExpr(n.MinMaxExpr, row, ctx)
+	case *pg_query.Node_AArrayExpr:
+		return evaluateArrayExpr(n.AArrayExpr, row, ctx)
+	case *pg_query.Node_AIndirection:
+		return evaluateIndirection(n.AIndirection, row, ctx)
 	}
 	return nil
 }
@@ -2285,14 +2844,24 @@ func rowToKey(row []interface{}) string {
 	return strings.Join(parts, "\x01")
 }
 
-func executeSetOperation(stmt *pg_query.SelectStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+func executeSetOperation(stmt *pg_query.SelectStmt, ctx *QueryContext) ([]string, [][]interface{}, string, error) {
+	start := time.Now()
+
+	// Each side runs in its own context, sharing only the EXPLAIN ANALYZE statistics
+	branchContext := func() *QueryContext {
+		branchCtx := newQueryContext(ctx.dataStore, ctx.metaStore)
+		branchCtx.stats = ctx.stats
+		branchCtx.guard = ctx.guard
+		return branchCtx
+	}
+
 	// Execute left side query
 	var leftColumns []string
 	var leftRows [][]interface{}
 	var err error
 	
 	if stmt.Larg != nil {
-		leftColumns, leftRows, _, err = executePgSelectAdvanced(stmt.Larg, dataStore, metaStore)
+		leftColumns, leftRows, _, err = executePgSelectWithContext(stmt.Larg, branchContext())
 		if err != nil {
 			return nil, nil, "", err
 		}
@@ -2303,7 +2872,7 @@ func executeSetOperation(stmt *pg_query.SelectStmt, dataStore *storage.DataStore
 	var rightRows [][]interface{}
 	
 	if stmt.Rarg != nil {
-		rightColumns, rightRows, _, err = executePgSelectAdvanced(stmt.Rarg, dataStore, metaStore)
+		rightColumns, rightRows, _, err = executePgSelectWithContext(stmt.Rarg, branchContext())
 		if err != nil {
 			return nil, nil, "", err
 		}
@@ -2386,15 +2955,21 @@ func executeSetOperation(stmt *pg_query.SelectStmt, dataStore *storage.DataStore
 		log.Printf("WARNING: Unsupported set operation type. Returning empty result.\n")
 		return []string{}, [][]interface{}{}, "SELECT 0", nil
 	}
+	ctx.stats.record(stmt, planStepSetOp, len(resultRows), start)
 	
 	// Apply ORDER BY if present
 	if len(stmt.SortClause) > 0 {
-		resultRows = sortRows(resultRows, columns, stmt.SortClause)
+		resultRows = sortRows(resultRows, columns, stmt.SortClause, ctx)
+		if ctx.err != nil {
+			return nil, nil, "", ctx.err
+		}
+		ctx.stats.record(stmt, planStepSort, len(resultRows), start)
 	}
 	
 	// Apply LIMIT and OFFSET if present
 	if stmt.LimitCount != nil || stmt.LimitOffset != nil {
 		resultRows = applyLimitOffset(resultRows, stmt.LimitCount, stmt.LimitOffset)
+		ctx.stats.record(stmt, planStepLimit, len(resultRows), start)
 	}
 	
 	return columns, resultRows, fmt.Sprintf("SELECT %d", len(resultRows)), nil
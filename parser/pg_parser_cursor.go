@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// DECLARE options, from PostgreSQL's CURSOR_OPT_* flags
+const (
+	cursorOptScroll   = 0x0002
+	cursorOptNoScroll = 0x0004
+	cursorOptHold     = 0x0020
+)
+
+// executePgDeclareCursor opens a named cursor over a SELECT
+func executePgDeclareCursor(stmt *pg_query.DeclareCursorStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	hold := stmt.Options&cursorOptHold != 0
+	inTransaction := session.InTransaction()
+	if !hold && !inTransaction {
+		return nil, nil, "", fmt.Errorf("DECLARE CURSOR can only be used in transaction blocks")
+	}
+	if stmt.Options&cursorOptScroll != 0 && stmt.Options&cursorOptNoScroll != 0 {
+		return nil, nil, "", fmt.Errorf("cannot specify both SCROLL and NO SCROLL")
+	}
+
+	selectStmt, ok := stmt.Query.GetNode().(*pg_query.Node_SelectStmt)
+	if !ok {
+		return nil, nil, "", fmt.Errorf("cursor query must be a SELECT statement")
+	}
+
+	cursor, err := openPgSelect(selectStmt.SelectStmt, session, dataStore, metaStore)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	declared := newDeclaredCursor(cursor, stmt.Options&cursorOptScroll != 0, hold)
+	declared.uncommitted = inTransaction
+	if err := session.cursors.add(stmt.Portalname, declared); err != nil {
+		return nil, nil, "", err
+	}
+	return nil, nil, "DECLARE CURSOR", nil
+}
+
+// executePgFetch handles FETCH and MOVE on a declared cursor
+func executePgFetch(stmt *pg_query.FetchStmt, cursors *CursorSet) ([]string, [][]interface{}, string, error) {
+	declared, err := cursors.get(stmt.Portalname)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	rows, n, err := declared.move(stmt.Direction, stmt.HowMany, !stmt.Ismove)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if stmt.Ismove {
+		return nil, nil, fmt.Sprintf("MOVE %d", n), nil
+	}
+	return declared.cursor.Columns(), rows, fmt.Sprintf("FETCH %d", n), nil
+}
+
+// executePgClosePortal closes one declared cursor, or all of them for CLOSE ALL
+func executePgClosePortal(stmt *pg_query.ClosePortalStmt, cursors *CursorSet) ([]string, [][]interface{}, string, error) {
+	if stmt.Portalname == "" {
+		cursors.Clear()
+		return nil, nil, "CLOSE CURSOR ALL", nil
+	}
+	if err := cursors.remove(stmt.Portalname); err != nil {
+		return nil, nil, "", err
+	}
+	return nil, nil, "CLOSE CURSOR", nil
+}
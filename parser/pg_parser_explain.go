@@ -0,0 +1,1181 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Execution steps recorded for EXPLAIN ANALYZE. Each step is recorded against
+// the parse tree node it executes, so plan nodes can find their statistics.
+const (
+	planStepScan      = "scan"
+	planStepJoin      = "join"
+	planStepCrossJoin = "cross join"
+	planStepFrom      = "from"
+	planStepWhere     = "where"
+	planStepAggregate = "aggregate"
+	planStepHaving    = "having"
+	planStepDistinct  = "distinct"
+	planStepSort      = "sort"
+	planStepLimit     = "limit"
+	planStepSetOp     = "setop"
+	planStepModify    = "modify"
+	planStepInput     = "input"
+)
+
+type planStatsKey struct {
+	node interface{}
+	step string
+}
+
+// planActual holds the actual results of one execution step, summed over loops
+type planActual struct {
+	rows  int
+	loops int
+	time  time.Duration
+}
+
+// planStats collects actual row counts and timings while a statement runs
+// under EXPLAIN ANALYZE. A nil *planStats records nothing.
+type planStats struct {
+	steps map[planStatsKey]*planActual
+}
+
+func newPlanStats() *planStats {
+	return &planStats{steps: make(map[planStatsKey]*planActual)}
+}
+
+// record adds one execution of a step. The time is measured from start, which
+// callers take before executing the step's inputs, so it is inclusive of them.
+func (s *planStats) record(node interface{}, step string, rows int, start time.Time) {
+	if s == nil {
+		return
+	}
+	key := planStatsKey{node: node, step: step}
+	actual, exists := s.steps[key]
+	if !exists {
+		actual = &planActual{}
+		s.steps[key] = actual
+	}
+	actual.rows += rows
+	actual.loops++
+	actual.time += time.Since(start)
+}
+
+func (s *planStats) get(key planStatsKey) (*planActual, bool) {
+	if s == nil || key.node == nil {
+		return nil, false
+	}
+	actual, exists := s.steps[key]
+	return actual, exists
+}
+
+// explainOptions holds the options of an EXPLAIN statement
+type explainOptions struct {
+	analyze bool
+	costs   bool
+	format  string // "text" or "json"
+	timing  bool
+	summary bool
+}
+
+func parseExplainOptions(options []*pg_query.Node) (explainOptions, error) {
+	opts := explainOptions{format: "text", costs: true, timing: true}
+	summarySet := false
+
+	for _, option := range options {
+		defElem, ok := option.Node.(*pg_query.Node_DefElem)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(defElem.DefElem.Defname)
+		switch name {
+		case "analyze", "timing", "summary", "verbose", "costs", "buffers", "settings", "wal", "generic_plan":
+			value, err := defElemBool(defElem.DefElem)
+			if err != nil {
+				return opts, err
+			}
+			switch name {
+			case "analyze":
+				opts.analyze = value
+			case "costs":
+				opts.costs = value
+			case "timing":
+				opts.timing = value
+			case "summary":
+				opts.summary = value
+				summarySet = true
+			}
+			// Other options are accepted but don't change the output
+		case "format":
+			format := ""
+			if str, ok := defElem.DefElem.Arg.GetNode().(*pg_query.Node_String_); ok {
+				format = strings.ToLower(str.String_.Sval)
+			}
+			switch format {
+			case "text", "json":
+				opts.format = format
+			case "xml", "yaml":
+				return opts, fmt.Errorf("EXPLAIN format %s is not supported", strings.ToUpper(format))
+			default:
+				return opts, fmt.Errorf("unrecognized value for EXPLAIN option \"format\": \"%s\"", format)
+			}
+		default:
+			return opts, fmt.Errorf("unrecognized EXPLAIN option \"%s\"", defElem.DefElem.Defname)
+		}
+	}
+
+	if !summarySet {
+		opts.summary = opts.analyze
+	}
+	return opts, nil
+}
+
+// defElemBool reads the boolean argument of an option; a missing argument means true
+func defElemBool(defElem *pg_query.DefElem) (bool, error) {
+	if defElem.Arg == nil {
+		return true, nil
+	}
+	switch arg := defElem.Arg.Node.(type) {
+	case *pg_query.Node_String_:
+		switch strings.ToLower(arg.String_.Sval) {
+		case "true", "on", "yes", "1":
+			return true, nil
+		case "false", "off", "no", "0":
+			return false, nil
+		}
+	case *pg_query.Node_Integer:
+		return arg.Integer.Ival != 0, nil
+	case *pg_query.Node_Boolean:
+		return arg.Boolean.Boolval, nil
+	}
+	return false, fmt.Errorf("%s requires a Boolean value", defElem.Defname)
+}
+
+// executePgExplain handles EXPLAIN [ANALYZE] statements.
+// The plan describes how the executor runs the statement: it uses the same
+// index choices as the executor, and under ANALYZE the statement is executed
+// and each plan node reports the rows and time of the step it stands for.
+func executePgExplain(stmt *pg_query.ExplainStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	opts, err := parseExplainOptions(stmt.Options)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if sel, ok := stmt.Query.Node.(*pg_query.Node_SelectStmt); ok {
+		if err := checkFunctionCalls(sel.SelectStmt, metaStore); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	planStart := time.Now()
+	builder := &planBuilder{dataStore: dataStore}
+	plan, err := builder.statementPlan(stmt.Query)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	builder.estimate(plan)
+	planningTime := time.Since(planStart)
+
+	var stats *planStats
+	var executionTime time.Duration
+	if opts.analyze {
+		stats = newPlanStats()
+		execStart := time.Now()
+		if err := analyzeStatement(stmt.Query, stats, session.statementGuard(), dataStore, metaStore); err != nil {
+			return nil, nil, "", err
+		}
+		executionTime = time.Since(execStart)
+	}
+
+	out := &planOutput{opts: opts, stats: stats}
+	var rows [][]interface{}
+	if opts.format == "json" {
+		rows = [][]interface{}{{out.json(plan, planningTime, executionTime)}}
+	} else {
+		for _, line := range out.text(plan, planningTime, executionTime) {
+			rows = append(rows, []interface{}{line})
+		}
+	}
+	return []string{"QUERY PLAN"}, rows, "EXPLAIN", nil
+}
+
+// analyzeStatement executes the explained statement, recording statistics
+func analyzeStatement(query *pg_query.Node, stats *planStats, guard *statementGuard, dataStore *storage.DataStore, metaStore *storage.MetaStore) error {
+	start := time.Now()
+	switch node := query.Node.(type) {
+	case *pg_query.Node_SelectStmt:
+		// Always use the advanced executor, which records every step
+		ctx := newQueryContext(dataStore, metaStore)
+		ctx.stats = stats
+		ctx.guard = guard
+		_, _, _, err := executePgSelectWithContext(node.SelectStmt, ctx)
+		return err
+	case *pg_query.Node_InsertStmt:
+		_, _, tag, err := executePgInsert(node.InsertStmt, dataStore, metaStore)
+		if err != nil {
+			return err
+		}
+		stats.record(node.InsertStmt, planStepInput, commandTagCount(tag), start)
+		stats.record(node.InsertStmt, planStepModify, 0, start)
+	case *pg_query.Node_UpdateStmt:
+		scanned := scannedRowCount(dataStore, node.UpdateStmt.Relation, node.UpdateStmt.WhereClause)
+		_, _, tag, err := executePgUpdate(node.UpdateStmt, dataStore, metaStore)
+		if err != nil {
+			return err
+		}
+		stats.record(node.UpdateStmt, planStepScan, scanned, start)
+		stats.record(node.UpdateStmt, planStepInput, commandTagCount(tag), start)
+		stats.record(node.UpdateStmt, planStepModify, 0, start)
+	case *pg_query.Node_DeleteStmt:
+		scanned := scannedRowCount(dataStore, node.DeleteStmt.Relation, node.DeleteStmt.WhereClause)
+		_, _, tag, err := executePgDelete(node.DeleteStmt, dataStore)
+		if err != nil {
+			return err
+		}
+		stats.record(node.DeleteStmt, planStepScan, scanned, start)
+		stats.record(node.DeleteStmt, planStepInput, commandTagCount(tag), start)
+		stats.record(node.DeleteStmt, planStepModify, 0, start)
+	}
+	return nil
+}
+
+// scannedRowCount returns how many rows an UPDATE or DELETE reads from its
+// table: the index scan's candidates when it uses one, and every row otherwise
+func scannedRowCount(dataStore *storage.DataStore, rv *pg_query.RangeVar, where *pg_query.Node) int {
+	table, exists := dataStore.GetTable(extractTableNameFromRangeVar(rv))
+	if !exists {
+		return 0
+	}
+	if scan := chooseModifyScan(table, rv, where); scan != nil {
+		return len(scan.rows(table))
+	}
+	return len(table.GetRows())
+}
+
+// commandTagCount returns the row count at the end of a command tag such as "UPDATE 3"
+func commandTagCount(tag string) int {
+	fields := strings.Fields(tag)
+	if len(fields) == 0 {
+		return 0
+	}
+	var count int
+	fmt.Sscanf(fields[len(fields)-1], "%d", &count)
+	return count
+}
+
+// planNode is one operator of an EXPLAIN plan
+type planNode struct {
+	nodeType    string // "Node Type" in JSON output
+	strategy    string // Aggregate and SetOp strategy: "Plain", "Hashed"
+	command     string // SetOp command: "Intersect", "Except", ...
+	operation   string // ModifyTable operation: "Insert", "Update", "Delete"
+	joinType    string // Join type: "Inner", "Left", "Right", "Full"
+	scanDir     string // Index scan direction: "Forward", "Backward"
+	indexName   string
+	relation    string
+	alias       string
+	parentRel   string // Relationship to the parent: "Outer", "Inner", "SubPlan", ...
+	subplanName string
+	sortKey     []string
+	groupKey    []string
+	indexCond   string
+	joinFilter  string
+	filter      string
+	limitCount  int // Constant LIMIT of a Limit node, or -1
+	children    []*planNode
+
+	// Planner estimates shown unless COSTS OFF
+	startupCost float64
+	totalCost   float64
+	planRows    int
+	planWidth   int
+
+	key       planStatsKey  // Step that produces the node's output
+	filterKey *planStatsKey // Step that applies the node's Filter, if any
+}
+
+// title returns the node's heading in text format
+func (n *planNode) title() string {
+	var title string
+	switch n.nodeType {
+	case "Aggregate":
+		title = "Aggregate"
+		if n.strategy == "Hashed" {
+			title = "HashAggregate"
+		}
+	case "SetOp":
+		title = "HashSetOp " + n.command
+	case "Nested Loop":
+		title = "Nested Loop"
+		if n.joinType != "" && n.joinType != "Inner" {
+			title += " " + n.joinType + " Join"
+		}
+	case "Index Scan":
+		title = "Index Scan"
+		if n.scanDir == "Backward" {
+			title += " Backward"
+		}
+		title += " using " + n.indexName
+	case "ModifyTable":
+		title = n.operation
+	default:
+		title = n.nodeType
+	}
+
+	if n.relation != "" {
+		title += " on " + n.relation
+		if n.alias != "" && n.alias != n.relation {
+			title += " " + n.alias
+		}
+	} else if n.alias != "" {
+		title += " on " + n.alias
+	}
+	return title
+}
+
+// planBuilder turns statements into plan trees mirroring the executor
+type planBuilder struct {
+	dataStore *storage.DataStore
+	subplans  int
+}
+
+func (b *planBuilder) statementPlan(query *pg_query.Node) (*planNode, error) {
+	if query == nil {
+		return nil, fmt.Errorf("EXPLAIN requires a statement")
+	}
+	switch node := query.Node.(type) {
+	case *pg_query.Node_SelectStmt:
+		return b.selectPlan(node.SelectStmt), nil
+	case *pg_query.Node_InsertStmt:
+		return b.insertPlan(node.InsertStmt), nil
+	case *pg_query.Node_UpdateStmt:
+		stmt := node.UpdateStmt
+		return b.modifyPlan(stmt, "Update", stmt.Relation, stmt.WhereClause), nil
+	case *pg_query.Node_DeleteStmt:
+		stmt := node.DeleteStmt
+		return b.modifyPlan(stmt, "Delete", stmt.Relation, stmt.WhereClause), nil
+	case *pg_query.Node_ExecuteStmt:
+		return nil, fmt.Errorf("EXPLAIN EXECUTE is not supported")
+	default:
+		return nil, fmt.Errorf("EXPLAIN is not supported for %T", node)
+	}
+}
+
+func (b *planBuilder) selectPlan(stmt *pg_query.SelectStmt) *planNode {
+	var node *planNode
+	orderedScan := false
+
+	switch {
+	case stmt.Op != pg_query.SetOperation_SETOP_NONE:
+		node = b.setOpPlan(stmt)
+	case len(stmt.ValuesLists) > 0:
+		node = &planNode{nodeType: "Values Scan", alias: `"*VALUES*"`, planRows: len(stmt.ValuesLists)}
+	case len(stmt.FromClause) == 0:
+		node = &planNode{nodeType: "Result", key: planStatsKey{stmt, planStepFrom}}
+		b.attachFilter(node, stmt.WhereClause, stmt)
+	case singleRangeVar(stmt.FromClause) != nil:
+		node, orderedScan = b.singleTablePlan(stmt, singleRangeVar(stmt.FromClause))
+	default:
+		node = b.fromPlan(stmt.FromClause)
+		b.attachFilter(node, stmt.WhereClause, stmt)
+	}
+
+	if stmt.Op == pg_query.SetOperation_SETOP_NONE {
+		if len(stmt.GroupClause) > 0 || hasAggregateFunctions(stmt.TargetList) {
+			agg := &planNode{
+				nodeType: "Aggregate",
+				strategy: "Plain",
+				key:      planStatsKey{stmt, planStepAggregate},
+				children: []*planNode{node},
+			}
+			if len(stmt.GroupClause) > 0 {
+				agg.strategy = "Hashed"
+				for _, group := range stmt.GroupClause {
+					agg.groupKey = append(agg.groupKey, deparseExpr(group))
+				}
+			}
+			if stmt.HavingClause != nil {
+				agg.filter = deparseCondition(stmt.HavingClause)
+				agg.filterKey = &planStatsKey{stmt, planStepHaving}
+				agg.children = append(agg.children, b.subPlans(stmt.HavingClause)...)
+			}
+			node = agg
+		}
+		node.children = append(node.children, b.subPlans(stmt.TargetList...)...)
+
+		if len(stmt.DistinctClause) > 0 {
+			node = &planNode{nodeType: "Unique", key: planStatsKey{stmt, planStepDistinct}, children: []*planNode{node}}
+		}
+	}
+
+	if len(stmt.SortClause) > 0 && !orderedScan {
+		sortNode := &planNode{nodeType: "Sort", key: planStatsKey{stmt, planStepSort}, children: []*planNode{node}}
+		for _, sortItem := range stmt.SortClause {
+			sortNode.sortKey = append(sortNode.sortKey, deparseSortKey(sortItem))
+		}
+		node = sortNode
+	}
+	if stmt.LimitCount != nil || stmt.LimitOffset != nil {
+		node = &planNode{nodeType: "Limit", key: planStatsKey{stmt, planStepLimit}, limitCount: -1, children: []*planNode{node}}
+		if count, ok := constantInt(stmt.LimitCount); ok {
+			node.limitCount = count
+		}
+	}
+	return node
+}
+
+// singleTablePlan plans a scan of the only table in the FROM clause, making
+// the same index choices as scanSingleTable. The second return value reports
+// whether the scan returns rows in ORDER BY order.
+func (b *planBuilder) singleTablePlan(stmt *pg_query.SelectStmt, rv *pg_query.RangeVar) (*planNode, bool) {
+	node := b.relationScan(rv)
+	where := stmt.WhereClause
+	ordered := false
+
+	if table, exists := b.dataStore.GetTable(rv.Relname); exists {
+		qualifiers := []string{rv.Relname}
+		if rv.Alias != nil && rv.Alias.Aliasname != "" {
+			qualifiers = append(qualifiers, rv.Alias.Aliasname)
+		}
+		if orderedScan := chooseIndexOrderedScan(stmt, table, qualifiers); orderedScan != nil && table.IndexOrderable(orderedScan.index) {
+			node.nodeType = "Index Scan"
+			node.indexName = orderedScan.index.Name
+			node.scanDir = "Forward"
+			if orderedScan.desc {
+				node.scanDir = "Backward"
+			}
+			ordered = true
+		} else if scan := chooseIndexScan(table, where, qualifiers, true); scan != nil {
+			node.nodeType = "Index Scan"
+			node.indexName = scan.index.Name
+			node.scanDir = "Forward"
+			node.indexCond = deparseCondition(scan.cond)
+			where = removeConjunct(where, scan.cond)
+		}
+	}
+
+	if where != nil {
+		node.filter = deparseCondition(where)
+	}
+	if stmt.WhereClause != nil {
+		node.filterKey = &planStatsKey{stmt, planStepWhere}
+		node.children = append(node.children, b.subPlans(stmt.WhereClause)...)
+	}
+	return node, ordered
+}
+
+func (b *planBuilder) relationScan(rv *pg_query.RangeVar) *planNode {
+	node := &planNode{
+		nodeType: "Seq Scan",
+		relation: rv.Relname,
+		alias:    rv.Relname,
+		key:      planStatsKey{rv, planStepScan},
+	}
+	if rv.Alias != nil && rv.Alias.Aliasname != "" {
+		node.alias = rv.Alias.Aliasname
+	}
+	return node
+}
+
+// fromPlan plans a FROM clause with several items, which the executor cross joins
+func (b *planBuilder) fromPlan(fromClause []*pg_query.Node) *planNode {
+	node := b.fromItemPlan(fromClause[0])
+	for _, item := range fromClause[1:] {
+		outer := node
+		outer.parentRel = "Outer"
+		inner := b.fromItemPlan(item)
+		inner.parentRel = "Inner"
+		node = &planNode{
+			nodeType: "Nested Loop",
+			joinType: "Inner",
+			key:      planStatsKey{item, planStepCrossJoin},
+			children: []*planNode{outer, inner},
+		}
+	}
+	return node
+}
+
+func (b *planBuilder) fromItemPlan(item *pg_query.Node) *planNode {
+	switch n := item.Node.(type) {
+	case *pg_query.Node_RangeVar:
+		return b.relationScan(n.RangeVar)
+	case *pg_query.Node_JoinExpr:
+		join := n.JoinExpr
+		node := &planNode{
+			nodeType: "Nested Loop",
+			joinType: "Inner",
+			key:      planStatsKey{join, planStepJoin},
+		}
+		switch join.Jointype {
+		case pg_query.JoinType_JOIN_LEFT:
+			node.joinType = "Left"
+		case pg_query.JoinType_JOIN_RIGHT:
+			node.joinType = "Right"
+		case pg_query.JoinType_JOIN_FULL:
+			node.joinType = "Full"
+		}
+		if join.Quals != nil {
+			node.joinFilter = deparseCondition(join.Quals)
+		}
+		outer := b.fromItemPlan(join.Larg)
+		outer.parentRel = "Outer"
+		inner := b.fromItemPlan(join.Rarg)
+		inner.parentRel = "Inner"
+		node.children = []*planNode{outer, inner}
+		return node
+	case *pg_query.Node_RangeSubselect:
+		node := &planNode{nodeType: "Subquery Scan", key: planStatsKey{n.RangeSubselect, planStepScan}}
+		if n.RangeSubselect.Alias != nil {
+			node.alias = n.RangeSubselect.Alias.Aliasname
+		}
+		if sel, ok := n.RangeSubselect.Subquery.Node.(*pg_query.Node_SelectStmt); ok {
+			child := b.selectPlan(sel.SelectStmt)
+			child.parentRel = "Subquery"
+			node.children = []*planNode{child}
+		}
+		return node
+	}
+	return &planNode{nodeType: "Result"}
+}
+
+func (b *planBuilder) setOpPlan(stmt *pg_query.SelectStmt) *planNode {
+	appendNode := &planNode{nodeType: "Append"}
+	for _, arg := range []*pg_query.SelectStmt{stmt.Larg, stmt.Rarg} {
+		if arg != nil {
+			child := b.selectPlan(arg)
+			child.parentRel = "Member"
+			appendNode.children = append(appendNode.children, child)
+		}
+	}
+
+	key := planStatsKey{stmt, planStepSetOp}
+	switch stmt.Op {
+	case pg_query.SetOperation_SETOP_UNION:
+		if stmt.All {
+			appendNode.key = key
+			return appendNode
+		}
+		appendNode.parentRel = "Outer"
+		return &planNode{nodeType: "Unique", key: key, children: []*planNode{appendNode}}
+	case pg_query.SetOperation_SETOP_INTERSECT, pg_query.SetOperation_SETOP_EXCEPT:
+		command := "Intersect"
+		if stmt.Op == pg_query.SetOperation_SETOP_EXCEPT {
+			command = "Except"
+		}
+		if stmt.All {
+			command += " All"
+		}
+		appendNode.parentRel = "Outer"
+		return &planNode{nodeType: "SetOp", strategy: "Hashed", command: command, key: key, children: []*planNode{appendNode}}
+	}
+	return appendNode
+}
+
+func (b *planBuilder) insertPlan(stmt *pg_query.InsertStmt) *planNode {
+	node := &planNode{
+		nodeType:  "ModifyTable",
+		operation: "Insert",
+		key:       planStatsKey{stmt, planStepModify},
+	}
+	node.relation, node.alias = relationAndAlias(stmt.Relation)
+
+	var input *planNode
+	if sel, ok := stmt.SelectStmt.GetNode().(*pg_query.Node_SelectStmt); ok {
+		if len(sel.SelectStmt.ValuesLists) == 1 {
+			input = &planNode{nodeType: "Result"}
+		} else if len(sel.SelectStmt.ValuesLists) > 1 {
+			input = &planNode{nodeType: "Values Scan", alias: `"*VALUES*"`, planRows: len(sel.SelectStmt.ValuesLists)}
+		} else {
+			input = b.selectPlan(sel.SelectStmt)
+		}
+	} else {
+		input = &planNode{nodeType: "Result"}
+	}
+	input.key = planStatsKey{stmt, planStepInput}
+	input.parentRel = "Outer"
+	node.children = []*planNode{input}
+	return node
+}
+
+// modifyPlan plans UPDATE and DELETE, which read their table through the
+// index the executor picks for the WHERE clause, or sequentially
+func (b *planBuilder) modifyPlan(stmt interface{}, operation string, rv *pg_query.RangeVar, where *pg_query.Node) *planNode {
+	node := &planNode{
+		nodeType:  "ModifyTable",
+		operation: operation,
+		key:       planStatsKey{stmt, planStepModify},
+	}
+	node.relation, node.alias = relationAndAlias(rv)
+
+	scan := &planNode{
+		nodeType:  "Seq Scan",
+		relation:  node.relation,
+		alias:     node.alias,
+		parentRel: "Outer",
+		key:       planStatsKey{stmt, planStepScan},
+		filterKey: &planStatsKey{stmt, planStepInput},
+	}
+	filter := where
+	if table, exists := b.dataStore.GetTable(node.relation); exists {
+		if index := chooseModifyScan(table, rv, where); index != nil {
+			scan.nodeType = "Index Scan"
+			scan.indexName = index.index.Name
+			scan.scanDir = "Forward"
+			scan.indexCond = deparseCondition(index.cond)
+			filter = removeConjunct(where, index.cond)
+		}
+	}
+	if filter != nil {
+		scan.filter = deparseCondition(filter)
+	}
+	if where != nil {
+		scan.children = b.subPlans(where)
+	}
+	node.children = []*planNode{scan}
+	return node
+}
+
+// Planner cost constants, with PostgreSQL's default values
+const (
+	seqPageCost       = 1.0
+	randomPageCost    = 4.0
+	cpuTupleCost      = 0.01
+	cpuIndexTupleCost = 0.005
+	cpuOperatorCost   = 0.0025
+	planPageSize      = 8192
+)
+
+// Default selectivities used without column statistics, as in PostgreSQL
+const (
+	defaultEqSel     = 0.005
+	defaultIneqSel   = 1.0 / 3.0
+	defaultNumGroups = 200
+)
+
+// estimate fills in the estimated costs, rows and widths of a plan, bottom
+// up. The figures follow PostgreSQL's cost model closely enough to read the
+// same way, using the table's current row count in place of statistics.
+func (b *planBuilder) estimate(n *planNode) {
+	var inputs, subplans []*planNode
+	for _, child := range n.children {
+		b.estimate(child)
+		if child.parentRel == "SubPlan" {
+			subplans = append(subplans, child)
+		} else {
+			inputs = append(inputs, child)
+		}
+	}
+	var input *planNode
+	if len(inputs) > 0 {
+		input = inputs[0]
+	}
+
+	switch n.nodeType {
+	case "Seq Scan":
+		rows, width := b.tableEstimate(n.relation)
+		pages := math.Max(1, math.Ceil(float64(rows*width)/planPageSize))
+		n.totalCost = pages*seqPageCost + float64(rows)*cpuTupleCost
+		if n.filter != "" {
+			n.totalCost += float64(rows) * cpuOperatorCost
+			rows = selectRows(rows, defaultIneqSel)
+		}
+		n.planRows, n.planWidth = rows, width
+	case "Index Scan":
+		rows, width := b.tableEstimate(n.relation)
+		if n.indexCond != "" {
+			rows = selectRows(rows, defaultEqSel)
+		}
+		n.startupCost = 0.29
+		n.totalCost = n.startupCost + float64(rows)*(randomPageCost+cpuIndexTupleCost+cpuTupleCost)
+		if n.filter != "" {
+			n.totalCost += float64(rows) * cpuOperatorCost
+			rows = selectRows(rows, defaultIneqSel)
+		}
+		n.planRows, n.planWidth = rows, width
+	case "Values Scan":
+		n.totalCost = float64(n.planRows) * cpuTupleCost * 1.25
+		n.planWidth = 32
+	case "Result":
+		n.totalCost = cpuTupleCost
+		n.planRows, n.planWidth = 1, 4
+	case "Sort":
+		rows := float64(input.planRows)
+		n.startupCost = input.totalCost + 2*cpuOperatorCost*rows*math.Log2(math.Max(rows, 2))
+		n.totalCost = n.startupCost + rows*cpuOperatorCost
+		n.planRows, n.planWidth = input.planRows, input.planWidth
+	case "Limit":
+		n.startupCost = input.startupCost
+		n.totalCost = input.totalCost
+		n.planRows, n.planWidth = input.planRows, input.planWidth
+		if n.limitCount >= 0 && n.limitCount < input.planRows {
+			n.planRows = n.limitCount
+			n.totalCost = input.startupCost + (input.totalCost-input.startupCost)*float64(n.limitCount)/float64(input.planRows)
+		}
+	case "Aggregate":
+		rows := float64(input.planRows)
+		n.startupCost = input.totalCost + rows*cpuOperatorCost*float64(max(1, len(n.groupKey)))
+		n.planRows, n.planWidth = 1, 8*(1+len(n.groupKey))
+		if n.strategy == "Hashed" {
+			n.planRows = max(1, min(defaultNumGroups, input.planRows))
+		}
+		n.totalCost = n.startupCost + float64(n.planRows)*cpuTupleCost
+		if n.filter != "" {
+			n.totalCost += float64(n.planRows) * cpuOperatorCost
+			n.planRows = selectRows(n.planRows, defaultIneqSel)
+		}
+	case "Unique", "SetOp":
+		n.startupCost = input.startupCost
+		n.totalCost = input.totalCost + float64(input.planRows)*cpuOperatorCost
+		n.planRows, n.planWidth = input.planRows, input.planWidth
+	case "Append":
+		for _, child := range inputs {
+			n.totalCost += child.totalCost
+			n.planRows += child.planRows
+			n.planWidth = max(n.planWidth, child.planWidth)
+		}
+	case "Subquery Scan":
+		n.startupCost = input.startupCost
+		n.totalCost = input.totalCost + float64(input.planRows)*cpuTupleCost
+		n.planRows, n.planWidth = input.planRows, input.planWidth
+	case "Nested Loop":
+		outer, inner := inputs[0], inputs[1]
+		pairs := float64(outer.planRows) * float64(inner.planRows)
+		n.startupCost = outer.startupCost + inner.startupCost
+		n.totalCost = outer.totalCost + float64(outer.planRows)*inner.totalCost + pairs*cpuTupleCost
+		n.planRows = outer.planRows * inner.planRows
+		if n.joinFilter != "" {
+			// A join condition usually matches each row of the larger side once
+			n.totalCost += pairs * cpuOperatorCost
+			n.planRows = max(outer.planRows, inner.planRows)
+		}
+		if n.joinType == "Left" || n.joinType == "Full" {
+			n.planRows = max(n.planRows, outer.planRows)
+		}
+		n.planWidth = outer.planWidth + inner.planWidth
+	case "ModifyTable":
+		n.totalCost = input.totalCost + float64(input.planRows)*cpuTupleCost
+	default:
+		if input != nil {
+			n.startupCost, n.totalCost = input.startupCost, input.totalCost
+			n.planRows, n.planWidth = input.planRows, input.planWidth
+		}
+	}
+
+	for _, sub := range subplans {
+		n.totalCost += sub.totalCost
+	}
+}
+
+// tableEstimate returns the number of rows in a table and their average
+// width in bytes, estimated from a sample of the rows
+func (b *planBuilder) tableEstimate(relation string) (int, int) {
+	table, exists := b.dataStore.GetTable(relation)
+	if !exists {
+		return 0, 32
+	}
+	rows := table.GetRows()
+	sample := rows
+	if len(sample) > 100 {
+		sample = sample[:100]
+	}
+	if len(sample) == 0 {
+		return 0, 32
+	}
+	total := 0
+	for _, row := range sample {
+		for _, value := range row {
+			total += valueWidth(value)
+		}
+	}
+	return len(rows), (total + len(sample) - 1) / len(sample)
+}
+
+// valueWidth returns the stored width of a value the way PostgreSQL counts
+// it: fixed-width types by their size, others by their length plus a header
+func valueWidth(value interface{}) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int16:
+		return 2
+	case int32:
+		return 4
+	case int, int64, float64:
+		return 8
+	case string:
+		return len(v) + 1
+	}
+	return len(fmt.Sprintf("%v", value)) + 1
+}
+
+// selectRows returns the estimated rows left after a condition of the given
+// selectivity, never less than one as in PostgreSQL
+func selectRows(rows int, selectivity float64) int {
+	return max(1, int(math.Round(float64(rows)*selectivity)))
+}
+
+func relationAndAlias(rv *pg_query.RangeVar) (string, string) {
+	if rv == nil {
+		return "", ""
+	}
+	relation := extractTableNameFromRangeVar(rv)
+	if rv.Alias != nil && rv.Alias.Aliasname != "" {
+		return relation, rv.Alias.Aliasname
+	}
+	return relation, relation
+}
+
+// attachFilter sets a WHERE clause as the Filter of the node that produces it
+func (b *planBuilder) attachFilter(node *planNode, where *pg_query.Node, stmt *pg_query.SelectStmt) {
+	if where == nil {
+		return
+	}
+	node.filter = deparseCondition(where)
+	node.filterKey = &planStatsKey{stmt, planStepWhere}
+	node.children = append(node.children, b.subPlans(where)...)
+}
+
+// subPlans plans every subquery found in the given expressions
+func (b *planBuilder) subPlans(exprs ...*pg_query.Node) []*planNode {
+	var plans []*planNode
+	for _, expr := range exprs {
+		walkSubLinks(expr, func(sublink *pg_query.SubLink) {
+			sel, ok := sublink.Subselect.GetNode().(*pg_query.Node_SelectStmt)
+			if !ok {
+				return
+			}
+			b.subplans++
+			plan := b.selectPlan(sel.SelectStmt)
+			plan.parentRel = "SubPlan"
+			plan.subplanName = fmt.Sprintf("SubPlan %d", b.subplans)
+			plans = append(plans, plan)
+		})
+	}
+	return plans
+}
+
+// walkSubLinks calls fn for each subquery in an expression, without
+// descending into the subqueries themselves
+func walkSubLinks(node *pg_query.Node, fn func(*pg_query.SubLink)) {
+	walkExpr(node, func(n *pg_query.Node) bool {
+		if sublink, ok := n.Node.(*pg_query.Node_SubLink); ok {
+			walkSubLinks(sublink.SubLink.Testexpr, fn)
+			fn(sublink.SubLink)
+			return false
+		}
+		return true
+	})
+}
+
+// removeConjunct returns where without the top-level AND conjunct cond
+func removeConjunct(where, cond *pg_query.Node) *pg_query.Node {
+	if where == cond {
+		return nil
+	}
+	boolExpr, ok := where.Node.(*pg_query.Node_BoolExpr)
+	if !ok || boolExpr.BoolExpr.Boolop != pg_query.BoolExprType_AND_EXPR {
+		return where
+	}
+	var rest []*pg_query.Node
+	for _, arg := range boolExpr.BoolExpr.Args {
+		if arg != cond {
+			rest = append(rest, arg)
+		}
+	}
+	switch len(rest) {
+	case 0:
+		return nil
+	case 1:
+		return rest[0]
+	}
+	return pg_query.MakeBoolExprNode(pg_query.BoolExprType_AND_EXPR, rest, -1)
+}
+
+// deparseExpr turns an expression back into SQL text
+func deparseExpr(node *pg_query.Node) string {
+	if node == nil {
+		return ""
+	}
+	if constNode, ok := node.Node.(*pg_query.Node_AConst); ok {
+		// A bare integer in GROUP BY refers to an output column
+		if val, ok := constNode.AConst.Val.(*pg_query.A_Const_Ival); ok {
+			return fmt.Sprintf("%d", val.Ival.Ival)
+		}
+	}
+	stmt := &pg_query.SelectStmt{WhereClause: node}
+	result := &pg_query.ParseResult{Stmts: []*pg_query.RawStmt{{Stmt: &pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: stmt}}}}}
+	sql, err := pg_query.Deparse(result)
+	if err != nil {
+		return "?"
+	}
+	return strings.TrimPrefix(sql, "SELECT WHERE ")
+}
+
+// deparseCondition formats a condition the way PostgreSQL shows filters
+func deparseCondition(node *pg_query.Node) string {
+	return "(" + deparseExpr(node) + ")"
+}
+
+func deparseSortKey(node *pg_query.Node) string {
+	sortBy, ok := node.Node.(*pg_query.Node_SortBy)
+	if !ok {
+		return deparseExpr(node)
+	}
+	key := deparseExpr(sortBy.SortBy.Node)
+	if sortBy.SortBy.SortbyDir == pg_query.SortByDir_SORTBY_DESC {
+		key += " DESC"
+	}
+	switch sortBy.SortBy.SortbyNulls {
+	case pg_query.SortByNulls_SORTBY_NULLS_FIRST:
+		key += " NULLS FIRST"
+	case pg_query.SortByNulls_SORTBY_NULLS_LAST:
+		key += " NULLS LAST"
+	}
+	return key
+}
+
+// planOutput renders a plan in text or JSON format
+type planOutput struct {
+	opts  explainOptions
+	stats *planStats
+}
+
+// actual returns the per-loop statistics of a node and the number of rows
+// removed by its filter. ok is false if the node was never executed.
+func (o *planOutput) actual(n *planNode) (rows, loops, removed int, total time.Duration, ok bool) {
+	input, exists := o.stats.get(n.key)
+	output := input
+	if n.filterKey != nil {
+		if filtered, filterExists := o.stats.get(*n.filterKey); filterExists {
+			output = filtered
+			if !exists {
+				input = filtered
+			}
+			exists = true
+		}
+	}
+	if !exists {
+		return 0, 0, 0, 0, false
+	}
+	loops = output.loops
+	rows = output.rows / loops
+	total = output.time / time.Duration(loops)
+	if n.filterKey != nil && input != output && input.loops > 0 {
+		removed = (input.rows - output.rows) / loops
+	}
+	return rows, loops, removed, total, true
+}
+
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d.Nanoseconds())/1e6)
+}
+
+func (o *planOutput) text(plan *planNode, planningTime, executionTime time.Duration) []string {
+	var lines []string
+	o.textNode(&lines, plan, 0, true)
+	if o.opts.summary {
+		lines = append(lines, fmt.Sprintf("Planning Time: %s ms", formatMillis(planningTime)))
+		if o.opts.analyze {
+			lines = append(lines, fmt.Sprintf("Execution Time: %s ms", formatMillis(executionTime)))
+		}
+	}
+	return lines
+}
+
+// textNode writes a node whose heading starts at column indent
+func (o *planOutput) textNode(lines *[]string, n *planNode, indent int, top bool) {
+	heading := n.title()
+	sep := "  "
+	if o.opts.costs {
+		heading += fmt.Sprintf("  (cost=%.2f..%.2f rows=%d width=%d)", n.startupCost, n.totalCost, n.planRows, n.planWidth)
+		sep = " "
+	}
+	if o.opts.analyze {
+		rows, loops, _, total, ok := o.actual(n)
+		switch {
+		case !ok:
+			heading += sep + "(never executed)"
+		case o.opts.timing:
+			heading += sep + fmt.Sprintf("(actual time=%s..%s rows=%d loops=%d)", formatMillis(total), formatMillis(total), rows, loops)
+		default:
+			heading += sep + fmt.Sprintf("(actual rows=%d loops=%d)", rows, loops)
+		}
+	}
+	if top {
+		*lines = append(*lines, heading)
+	} else {
+		*lines = append(*lines, strings.Repeat(" ", indent-4)+"->  "+heading)
+	}
+
+	pad := strings.Repeat(" ", indent+2)
+	detail := func(label, value string) {
+		*lines = append(*lines, pad+label+": "+value)
+	}
+	if len(n.sortKey) > 0 {
+		detail("Sort Key", strings.Join(n.sortKey, ", "))
+	}
+	if len(n.groupKey) > 0 {
+		detail("Group Key", strings.Join(n.groupKey, ", "))
+	}
+	if n.indexCond != "" {
+		detail("Index Cond", n.indexCond)
+	}
+	if n.joinFilter != "" {
+		detail("Join Filter", n.joinFilter)
+	}
+	if n.filter != "" {
+		detail("Filter", n.filter)
+		if o.opts.analyze {
+			if _, _, removed, _, ok := o.actual(n); ok {
+				detail("Rows Removed by Filter", fmt.Sprintf("%d", removed))
+			}
+		}
+	}
+
+	for _, child := range n.children {
+		if child.parentRel == "SubPlan" {
+			*lines = append(*lines, pad+child.subplanName)
+			o.textNode(lines, child, indent+8, false)
+		} else {
+			o.textNode(lines, child, indent+6, false)
+		}
+	}
+}
+
+// jsonField is one key of a JSON object; fields keep PostgreSQL's key order
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+func (o *planOutput) json(plan *planNode, planningTime, executionTime time.Duration) string {
+	top := []jsonField{{"Plan", o.jsonNode(plan)}}
+	if o.opts.summary {
+		top = append(top, jsonField{"Planning Time", jsonNumber(formatMillis(planningTime))})
+		if o.opts.analyze {
+			top = append(top, jsonField{"Execution Time", jsonNumber(formatMillis(executionTime))})
+		}
+	}
+	var sb strings.Builder
+	writeJSON(&sb, []interface{}{top}, 0)
+	return sb.String()
+}
+
+// jsonNumber is a number already formatted for output
+type jsonNumber string
+
+func (o *planOutput) jsonNode(n *planNode) []jsonField {
+	fields := []jsonField{{"Node Type", n.nodeType}}
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, jsonField{key, value})
+		}
+	}
+	add("Strategy", n.strategy)
+	add("Command", n.command)
+	add("Operation", n.operation)
+	add("Parent Relationship", n.parentRel)
+	add("Subplan Name", n.subplanName)
+	add("Join Type", n.joinType)
+	add("Scan Direction", n.scanDir)
+	add("Index Name", n.indexName)
+	add("Relation Name", n.relation)
+	add("Alias", strings.Trim(n.alias, `"`))
+
+	if o.opts.costs {
+		fields = append(fields,
+			jsonField{"Startup Cost", jsonNumber(fmt.Sprintf("%.2f", n.startupCost))},
+			jsonField{"Total Cost", jsonNumber(fmt.Sprintf("%.2f", n.totalCost))},
+			jsonField{"Plan Rows", jsonNumber(fmt.Sprintf("%d", n.planRows))},
+			jsonField{"Plan Width", jsonNumber(fmt.Sprintf("%d", n.planWidth))})
+	}
+
+	if o.opts.analyze {
+		rows, loops, _, total, _ := o.actual(n)
+		if o.opts.timing {
+			fields = append(fields,
+				jsonField{"Actual Startup Time", jsonNumber(formatMillis(total))},
+				jsonField{"Actual Total Time", jsonNumber(formatMillis(total))})
+		}
+		fields = append(fields,
+			jsonField{"Actual Rows", jsonNumber(fmt.Sprintf("%d", rows))},
+			jsonField{"Actual Loops", jsonNumber(fmt.Sprintf("%d", loops))})
+	}
+
+	if len(n.sortKey) > 0 {
+		fields = append(fields, jsonField{"Sort Key", n.sortKey})
+	}
+	if len(n.groupKey) > 0 {
+		fields = append(fields, jsonField{"Group Key", n.groupKey})
+	}
+	add("Index Cond", n.indexCond)
+	add("Join Filter", n.joinFilter)
+	add("Filter", n.filter)
+	if o.opts.analyze && n.filter != "" {
+		if _, _, removed, _, ok := o.actual(n); ok {
+			fields = append(fields, jsonField{"Rows Removed by Filter", jsonNumber(fmt.Sprintf("%d", removed))})
+		}
+	}
+
+	if len(n.children) > 0 {
+		var children []interface{}
+		for _, child := range n.children {
+			children = append(children, o.jsonNode(child))
+		}
+		fields = append(fields, jsonField{"Plans", children})
+	}
+	return fields
+}
+
+// writeJSON writes a value with two-space indentation like PostgreSQL does
+func writeJSON(sb *strings.Builder, value interface{}, indent int) {
+	pad := strings.Repeat("  ", indent+1)
+	closing := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case []jsonField:
+		sb.WriteString("{\n")
+		for i, field := range v {
+			key, _ := json.Marshal(field.key)
+			sb.WriteString(pad)
+			sb.Write(key)
+			sb.WriteString(": ")
+			writeJSON(sb, field.value, indent+1)
+			if i < len(v)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString(closing + "}")
+	case []interface{}:
+		sb.WriteString("[\n")
+		for i, item := range v {
+			sb.WriteString(pad)
+			writeJSON(sb, item, indent+1)
+			if i < len(v)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString(closing + "]")
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		writeJSON(sb, items, indent)
+	case jsonNumber:
+		sb.WriteString(string(v))
+	default:
+		encoded, _ := json.Marshal(v)
+		sb.Write(encoded)
+	}
+}
@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// executePgCreateIndex handles CREATE [UNIQUE] INDEX statements
+func executePgCreateIndex(stmt *pg_query.IndexStmt, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	tableName := extractTableNameFromRangeVar(stmt.Relation)
+	if tableName == "" {
+		return nil, nil, "", fmt.Errorf("could not extract table name")
+	}
+
+	var method storage.IndexMethod
+	switch strings.ToLower(stmt.AccessMethod) {
+	case "", "btree":
+		method = storage.IndexMethodBTree
+	case "hash":
+		method = storage.IndexMethodHash
+	default:
+		log.Printf("WARNING: Unsupported index access method %q. Index will be ignored.\n", stmt.AccessMethod)
+		return nil, nil, "CREATE INDEX", nil
+	}
+
+	if stmt.WhereClause != nil {
+		log.Printf("WARNING: Partial indexes are not supported. Index will be ignored.\n")
+		return nil, nil, "CREATE INDEX", nil
+	}
+
+	var columns []string
+	for _, param := range stmt.IndexParams {
+		elem, ok := param.Node.(*pg_query.Node_IndexElem)
+		if !ok || elem.IndexElem.Name == "" {
+			log.Printf("WARNING: Expression indexes are not supported. Index will be ignored.\n")
+			return nil, nil, "CREATE INDEX", nil
+		}
+		columns = append(columns, strings.Trim(elem.IndexElem.Name, `"`))
+	}
+	if len(columns) == 0 {
+		return nil, nil, "", fmt.Errorf("index must have at least one column")
+	}
+	if method == storage.IndexMethodHash && len(columns) > 1 {
+		return nil, nil, "", fmt.Errorf("access method \"hash\" does not support multicolumn indexes")
+	}
+
+	indexName := stmt.Idxname
+	if indexName == "" {
+		// Same naming convention as PostgreSQL: table_col1_col2_idx / _key
+		suffix := "idx"
+		if stmt.Unique {
+			suffix = "key"
+		}
+		indexName = fmt.Sprintf("%s_%s_%s", tableName, strings.Join(columns, "_"), suffix)
+	}
+
+	if _, exists := dataStore.GetIndex(indexName); exists && stmt.IfNotExists {
+		return nil, nil, "CREATE INDEX", nil
+	}
+
+	// Indexes may be created before any data arrives, like everything else in vsql
+	if err := dataStore.CreateTable(tableName); err != nil {
+		return nil, nil, "", err
+	}
+	for _, col := range columns {
+		metaStore.AddColumn(tableName, col)
+	}
+
+	idx := storage.NewIndex(indexName, tableName, columns, method, stmt.Unique)
+	if err := dataStore.CreateIndex(idx); err != nil {
+		return nil, nil, "", err
+	}
+
+	return nil, nil, "CREATE INDEX", nil
+}
+
+// executePgDropIndex handles DROP INDEX statements
+func executePgDropIndex(stmt *pg_query.DropStmt, dataStore *storage.DataStore) ([]string, [][]interface{}, string, error) {
+	for _, obj := range stmt.Objects {
+		list, ok := obj.Node.(*pg_query.Node_List)
+		if !ok || len(list.List.Items) == 0 {
+			continue
+		}
+		// The last item is the index name (earlier items are the schema)
+		str, ok := list.List.Items[len(list.List.Items)-1].Node.(*pg_query.Node_String_)
+		if !ok {
+			continue
+		}
+		indexName := strings.Trim(str.String_.Sval, `"`)
+		if err := dataStore.DropIndex(indexName); err != nil && !stmt.MissingOk {
+			return nil, nil, "", err
+		}
+	}
+
+	return nil, nil, "DROP INDEX", nil
+}
+
+// indexScan describes how an index narrows the rows read from a table.
+// The rows it returns are candidates only: the WHERE clause is still applied
+// to them afterwards, so an index scan never changes a query's result.
+type indexScan struct {
+	index  *storage.Index
+	column string
+	keys   [][]interface{}     // Equality keys (= and IN)
+	lower  *storage.IndexBound // Range bounds (<, <=, >, >=, BETWEEN)
+	upper  *storage.IndexBound
+	cond   *pg_query.Node // The WHERE conjunct answered by the index
+}
+
+// rows fetches the candidate rows from the table
+func (s *indexScan) rows(table *storage.Table) []storage.Row {
+	if len(s.keys) > 0 {
+		return table.IndexLookup(s.index, s.keys...)
+	}
+	return table.IndexRange(s.index, s.lower, s.upper)
+}
+
+// candidates returns the scan for the table to run itself, as UPDATE and
+// DELETE do; nil stands for reading every row
+func (s *indexScan) candidates() *storage.IndexScan {
+	if s == nil {
+		return nil
+	}
+	return &storage.IndexScan{Index: s.index, Keys: s.keys, Lower: s.lower, Upper: s.upper}
+}
+
+// chooseModifyScan picks the index an UPDATE or DELETE finds its target
+// rows through, the same way a SELECT of the table would
+func chooseModifyScan(table *storage.Table, rv *pg_query.RangeVar, where *pg_query.Node) *indexScan {
+	relation, alias := relationAndAlias(rv)
+	return chooseIndexScan(table, where, []string{relation, alias}, true)
+}
+
+// indexPredicate is a top-level WHERE conjunct comparing a column with constants
+type indexPredicate struct {
+	column string
+	op     string
+	values []interface{}
+	node   *pg_query.Node
+}
+
+// chooseIndexScan picks an index that can answer one of the top-level AND
+// conjuncts of where. Column references must be qualified with one of
+// qualifiers, or unqualified if allowUnqualified is set. Returns nil if no
+// index applies and the table has to be scanned sequentially.
+func chooseIndexScan(table *storage.Table, where *pg_query.Node, qualifiers []string, allowUnqualified bool) *indexScan {
+	if table == nil || where == nil {
+		return nil
+	}
+	indexes := table.Indexes()
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	var best *indexScan
+	bestScore := 0
+	for _, pred := range collectIndexPredicates(where, qualifiers, allowUnqualified) {
+		for _, idx := range indexes {
+			if idx.Columns[0] != pred.column {
+				continue
+			}
+			score := 0
+			scan := &indexScan{index: idx, column: pred.column, cond: pred.node}
+			switch pred.op {
+			case "=", "in":
+				if idx.Method == storage.IndexMethodHash && len(idx.Columns) > 1 {
+					continue
+				}
+				for _, v := range pred.values {
+					scan.keys = append(scan.keys, []interface{}{v})
+				}
+				score = 2
+				if idx.Unique && len(idx.Columns) == 1 && pred.op == "=" {
+					score = 3
+				}
+			case "<", "<=":
+				if !idx.SupportsRange() {
+					continue
+				}
+				scan.upper = &storage.IndexBound{Value: pred.values[0], Inclusive: pred.op == "<="}
+				score = 1
+			case ">", ">=":
+				if !idx.SupportsRange() {
+					continue
+				}
+				scan.lower = &storage.IndexBound{Value: pred.values[0], Inclusive: pred.op == ">="}
+				score = 1
+			case "between":
+				if !idx.SupportsRange() {
+					continue
+				}
+				scan.lower = &storage.IndexBound{Value: pred.values[0], Inclusive: true}
+				scan.upper = &storage.IndexBound{Value: pred.values[1], Inclusive: true}
+				score = 1
+			}
+			if score > bestScore {
+				best = scan
+				bestScore = score
+			}
+		}
+	}
+	return best
+}
+
+// collectIndexPredicates extracts column-versus-constant comparisons from the
+// top-level AND conjuncts of a WHERE clause
+func collectIndexPredicates(node *pg_query.Node, qualifiers []string, allowUnqualified bool) []indexPredicate {
+	if node == nil {
+		return nil
+	}
+
+	switch n := node.Node.(type) {
+	case *pg_query.Node_BoolExpr:
+		if n.BoolExpr.Boolop != pg_query.BoolExprType_AND_EXPR {
+			return nil
+		}
+		var preds []indexPredicate
+		for _, arg := range n.BoolExpr.Args {
+			preds = append(preds, collectIndexPredicates(arg, qualifiers, allowUnqualified)...)
+		}
+		return preds
+	case *pg_query.Node_AExpr:
+		expr := n.AExpr
+		op := ""
+		if len(expr.Name) > 0 {
+			if str, ok := expr.Name[0].Node.(*pg_query.Node_String_); ok {
+				op = str.String_.Sval
+			}
+		}
+
+		switch expr.Kind {
+		case pg_query.A_Expr_Kind_AEXPR_OP:
+			column, ok := indexableColumn(expr.Lexpr, qualifiers, allowUnqualified)
+			value, isConst := indexableConstant(expr.Rexpr)
+			if !ok || !isConst {
+				// Try the mirrored form: constant op column
+				column, ok = indexableColumn(expr.Rexpr, qualifiers, allowUnqualified)
+				value, isConst = indexableConstant(expr.Lexpr)
+				if !ok || !isConst {
+					return nil
+				}
+				switch op {
+				case "<":
+					op = ">"
+				case "<=":
+					op = ">="
+				case ">":
+					op = "<"
+				case ">=":
+					op = "<="
+				}
+			}
+			switch op {
+			case "=", "<", "<=", ">", ">=":
+				return []indexPredicate{{column: column, op: op, values: []interface{}{value}, node: node}}
+			}
+		case pg_query.A_Expr_Kind_AEXPR_IN:
+			if op != "=" {
+				return nil // NOT IN
+			}
+			column, ok := indexableColumn(expr.Lexpr, qualifiers, allowUnqualified)
+			list, isList := expr.Rexpr.Node.(*pg_query.Node_List)
+			if !ok || !isList {
+				return nil
+			}
+			var values []interface{}
+			for _, item := range list.List.Items {
+				if value, isConst := indexableConstant(item); isConst {
+					values = append(values, value)
+				} else if !isNullConstant(item) {
+					return nil
+				}
+			}
+			return []indexPredicate{{column: column, op: "in", values: values, node: node}}
+		case pg_query.A_Expr_Kind_AEXPR_BETWEEN:
+			column, ok := indexableColumn(expr.Lexpr, qualifiers, allowUnqualified)
+			list, isList := expr.Rexpr.Node.(*pg_query.Node_List)
+			if !ok || !isList || len(list.List.Items) != 2 {
+				return nil
+			}
+			lower, lowerConst := indexableConstant(list.List.Items[0])
+			upper, upperConst := indexableConstant(list.List.Items[1])
+			if !lowerConst || !upperConst {
+				return nil
+			}
+			return []indexPredicate{{column: column, op: "between", values: []interface{}{lower, upper}, node: node}}
+		}
+	}
+	return nil
+}
+
+// indexableColumn returns the column name of a column reference that belongs
+// to the table being scanned
+func indexableColumn(node *pg_query.Node, qualifiers []string, allowUnqualified bool) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+	colRef, ok := node.Node.(*pg_query.Node_ColumnRef)
+	if !ok {
+		return "", false
+	}
+	qualifier, column := extractTableAndColumnFromRef(colRef.ColumnRef)
+	if column == "" {
+		return "", false
+	}
+	if len(colRef.ColumnRef.Fields) > 2 {
+		return "", false
+	}
+	if qualifier == "" {
+		return column, allowUnqualified
+	}
+	for _, q := range qualifiers {
+		if q != "" && q == qualifier {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// indexableConstant returns the value of a non-NULL constant
+func indexableConstant(node *pg_query.Node) (interface{}, bool) {
+	if node == nil {
+		return nil, false
+	}
+	if aConst, ok := node.Node.(*pg_query.Node_AConst); ok {
+		value := extractAConstValue(aConst.AConst)
+		return value, value != nil
+	}
+	return nil, false
+}
+
+func isNullConstant(node *pg_query.Node) bool {
+	aConst, ok := node.Node.(*pg_query.Node_AConst)
+	return ok && aConst.AConst.Isnull
+}
+
+// indexOrderedScan describes reading a table in index order to answer
+// ORDER BY ... LIMIT without sorting the whole table
+type indexOrderedScan struct {
+	index *storage.Index
+	desc  bool
+	limit int // Number of rows needed, including OFFSET
+}
+
+// chooseIndexOrderedScan checks whether a single-table SELECT with ORDER BY
+// on one column and a constant LIMIT can be answered by walking a B-tree index
+func chooseIndexOrderedScan(stmt *pg_query.SelectStmt, table *storage.Table, qualifiers []string) *indexOrderedScan {
+	if table == nil || len(stmt.SortClause) != 1 || stmt.LimitCount == nil {
+		return nil
+	}
+	if len(stmt.GroupClause) > 0 || stmt.HavingClause != nil || len(stmt.DistinctClause) > 0 || hasAggregateFunctions(stmt.TargetList) {
+		return nil
+	}
+
+	limit, ok := constantInt(stmt.LimitCount)
+	if !ok {
+		return nil
+	}
+	offset := 0
+	if stmt.LimitOffset != nil {
+		if offset, ok = constantInt(stmt.LimitOffset); !ok {
+			return nil
+		}
+	}
+
+	sortBy, ok := stmt.SortClause[0].Node.(*pg_query.Node_SortBy)
+	if !ok || sortBy.SortBy.Node == nil {
+		return nil
+	}
+	desc := sortBy.SortBy.SortbyDir == pg_query.SortByDir_SORTBY_DESC
+	// The index keeps NULLs last, so only the default NULLS placement matches
+	if sortBy.SortBy.SortbyNulls != pg_query.SortByNulls_SORTBY_NULLS_DEFAULT {
+		return nil
+	}
+	column, ok := indexableColumn(sortBy.SortBy.Node, qualifiers, true)
+	if !ok {
+		return nil
+	}
+	// ORDER BY prefers output column names, so an alias shadowing the column
+	// means we would be ordering by something else
+	for _, target := range stmt.TargetList {
+		if resTarget, ok := target.Node.(*pg_query.Node_ResTarget); ok && resTarget.ResTarget.Name == column {
+			return nil
+		}
+	}
+
+	for _, idx := range table.Indexes() {
+		if idx.SupportsRange() && idx.Columns[0] == column {
+			return &indexOrderedScan{index: idx, desc: desc, limit: limit + offset}
+		}
+	}
+	return nil
+}
+
+// constantInt returns the value of an integer constant, such as a LIMIT
+// given as one. A missing node is not a constant.
+func constantInt(node *pg_query.Node) (int, bool) {
+	if constNode, ok := node.GetNode().(*pg_query.Node_AConst); ok {
+		if val, ok := constNode.AConst.Val.(*pg_query.A_Const_Ival); ok {
+			return int(val.Ival.Ival), true
+		}
+	}
+	return 0, false
+}
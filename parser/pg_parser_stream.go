@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// A SELECT that the advanced executor would run without sorting, grouping or
+// removing duplicates produces each output row from one row of its FROM
+// clause, so it can be evaluated as rows are fetched. The FROM clause is
+// walked as nested loops: the leftmost table is read one row at a time, and
+// only the tables joined to it are held in memory, never the joined result.
+
+// rowSource returns the next row of a FROM clause, or false when there are
+// no more rows
+type rowSource func() (storage.Row, bool)
+
+// streamableSelect reports whether openStreamingSelect can evaluate stmt
+func streamableSelect(stmt *pg_query.SelectStmt) bool {
+	if stmt.Op != pg_query.SetOperation_SETOP_NONE || len(stmt.FromClause) == 0 {
+		return false
+	}
+	if len(stmt.GroupClause) > 0 || stmt.HavingClause != nil || hasAggregateFunctions(stmt.TargetList) {
+		return false
+	}
+	if len(stmt.DistinctClause) > 0 || len(stmt.SortClause) > 0 || len(stmt.WindowClause) > 0 {
+		return false
+	}
+	for _, item := range stmt.FromClause {
+		if !streamableFromItem(item) {
+			return false
+		}
+	}
+	// SELECT * lists the columns found in the rows, which for a join are only
+	// known once every joined row has been built
+	if hasStarTarget(stmt.TargetList) && singleRangeVar(stmt.FromClause) == nil {
+		return false
+	}
+	return true
+}
+
+// streamableFromItem reports whether a FROM item is a table or an inner or
+// cross join of tables
+func streamableFromItem(item *pg_query.Node) bool {
+	switch n := item.Node.(type) {
+	case *pg_query.Node_RangeVar:
+		return true
+	case *pg_query.Node_JoinExpr:
+		return n.JoinExpr.Jointype == pg_query.JoinType_JOIN_INNER &&
+			streamableFromItem(n.JoinExpr.Larg) && streamableFromItem(n.JoinExpr.Rarg)
+	}
+	return false
+}
+
+func hasStarTarget(targetList []*pg_query.Node) bool {
+	for _, target := range targetList {
+		if resTarget, ok := target.Node.(*pg_query.Node_ResTarget); ok && isStarExpr(resTarget.ResTarget.Val) {
+			return true
+		}
+	}
+	return false
+}
+
+// openStreamingSelect starts a SELECT accepted by streamableSelect, returning
+// a cursor that evaluates WHERE, the select list and LIMIT/OFFSET per fetch
+func openStreamingSelect(stmt *pg_query.SelectStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) (*Cursor, error) {
+	ctx := newQueryContext(dataStore, metaStore)
+
+	var source rowSource
+	var sourceRows []storage.Row // Rows of a single table, for SELECT *
+	if rv := singleRangeVar(stmt.FromClause); rv != nil {
+		source, sourceRows = streamSingleTable(ctx, stmt, rv)
+	} else {
+		var err error
+		if source, err = streamFromClause(ctx, stmt.FromClause); err != nil {
+			return nil, err
+		}
+	}
+	if ctx.err != nil {
+		return nil, ctx.err
+	}
+	columns := determineAllColumns(ctx, stmt.TargetList, sourceRows, nil)
+
+	offset, limit := 0, -1
+	if count, ok := constantInt(stmt.LimitOffset); ok {
+		offset = count
+	}
+	if count, ok := constantInt(stmt.LimitCount); ok {
+		limit = count
+	}
+
+	returned := 0
+	next := func() ([]interface{}, bool, error) {
+		// Each fetch runs as part of the statement that reads the cursor
+		ctx.guard = session.statementGuard()
+		for limit < 0 || returned < limit {
+			row, ok := source()
+			if !ok {
+				break
+			}
+			if ctx.interrupted() {
+				return nil, false, ctx.err
+			}
+			if stmt.WhereClause != nil && !evaluateWhereWithSubqueries(row, stmt.WhereClause, ctx) {
+				if ctx.err != nil {
+					return nil, false, ctx.err
+				}
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			result := processSelectTargetsWithColumns(ctx, stmt.TargetList, row, nil, false, columns)
+			if ctx.err != nil || ctx.exceedsLimits(result) {
+				return nil, false, ctx.err
+			}
+			returned++
+			return result, true, nil
+		}
+		return nil, false, ctx.err
+	}
+	return newCursor(columns, next), nil
+}
+
+// streamSingleTable reads the only table of a FROM clause, using an index to
+// narrow the rows read when one applies. It also returns the table's rows.
+func streamSingleTable(ctx *QueryContext, stmt *pg_query.SelectStmt, rv *pg_query.RangeVar) (rowSource, []storage.Row) {
+	var rows []storage.Row
+	if table, exists := ctx.dataStore.GetTable(rv.Relname); exists {
+		qualifiers := []string{rv.Relname}
+		if rv.Alias != nil && rv.Alias.Aliasname != "" {
+			qualifiers = append(qualifiers, rv.Alias.Aliasname)
+		}
+		if scan := chooseIndexScan(table, stmt.WhereClause, qualifiers, true); scan != nil {
+			rows = scan.rows(table)
+		} else {
+			rows = table.GetRows()
+		}
+	}
+	aliasName := registerRangeVar(ctx, rv, rows)
+	return streamRows(rows, aliasName), rows
+}
+
+// streamRows returns the rows one at a time, adding alias-qualified column
+// names as enrichRows does
+func streamRows(rows []storage.Row, aliasName string) rowSource {
+	pos := 0
+	return func() (storage.Row, bool) {
+		if pos >= len(rows) {
+			return nil, false
+		}
+		pos++
+		if aliasName == "" {
+			return rows[pos-1], true
+		}
+		return enrichRow(rows[pos-1], aliasName), true
+	}
+}
+
+// streamFromClause cross joins the FROM items as processFromClause does
+func streamFromClause(ctx *QueryContext, fromClause []*pg_query.Node) (rowSource, error) {
+	source, err := streamFromNode(ctx, fromClause[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range fromClause[1:] {
+		inner, err := processFromNode(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		source = nestedLoop(ctx, source, inner, func(outer, inner storage.Row) (storage.Row, bool) {
+			return mergeRows(outer, inner), true
+		})
+	}
+	return source, nil
+}
+
+// streamFromNode reads a table or inner join as processFromNode does
+func streamFromNode(ctx *QueryContext, node *pg_query.Node) (rowSource, error) {
+	switch n := node.Node.(type) {
+	case *pg_query.Node_RangeVar:
+		var rows []storage.Row
+		if table, exists := ctx.dataStore.GetTable(n.RangeVar.Relname); exists {
+			rows = table.GetRows()
+		}
+		return streamRows(rows, registerRangeVar(ctx, n.RangeVar, rows)), nil
+	case *pg_query.Node_JoinExpr:
+		join := n.JoinExpr
+		leftAlias := extractTableAlias(join.Larg)
+		outer, err := streamFromNode(ctx, join.Larg)
+		if err != nil {
+			return nil, err
+		}
+		rightAlias := extractTableAlias(join.Rarg)
+		inner, err := processFromNode(ctx, join.Rarg)
+		if err != nil {
+			return nil, err
+		}
+		joinCtx := &JoinContext{leftAlias: leftAlias, rightAlias: rightAlias}
+		return nestedLoop(ctx, outer, inner, func(left, right storage.Row) (storage.Row, bool) {
+			if join.Quals != nil {
+				ctx.currentJoinContext = joinCtx
+				matched := evaluateJoinCondition(left, right, join.Quals, ctx)
+				ctx.currentJoinContext = nil
+				if !matched {
+					return nil, false
+				}
+			}
+			return mergeRowsWithAliases(left, right, leftAlias, rightAlias), true
+		}), nil
+	}
+	return nil, fmt.Errorf("FROM item %T can't be streamed", node.Node)
+}
+
+// nestedLoop pairs each row of outer with every row of inner, returning the
+// rows join builds for the pairs it accepts. It stops early when the
+// statement is canceled or goes over its limits, leaving the error in
+// ctx.err.
+func nestedLoop(ctx *QueryContext, outer rowSource, inner []storage.Row, join func(outer, inner storage.Row) (storage.Row, bool)) rowSource {
+	var current storage.Row
+	pos := len(inner)
+	return func() (storage.Row, bool) {
+		for {
+			if ctx.interrupted() {
+				return nil, false
+			}
+			if pos >= len(inner) {
+				row, ok := outer()
+				if !ok || len(inner) == 0 {
+					return nil, false
+				}
+				current, pos = row, 0
+			}
+			pos++
+			if row, ok := join(current, inner[pos-1]); ok {
+				if ctx.exceedsLimits(row) {
+					return nil, false
+				}
+				return row, true
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
 )
 
 // toFloat64 converts various types to float64
@@ -18,6 +19,8 @@ func toFloat64(val interface{}) (float64, error) {
 		return float64(v), nil
 	case int64:
 		return float64(v), nil
+	case storage.Numeric:
+		return v.Float64(), nil
 	case string:
 		return strconv.ParseFloat(v, 64)
 	default:
@@ -46,9 +49,12 @@ func extractAConstValue(aConst *pg_query.A_Const) interface{} {
 		return int(val.Ival.Ival)
 	case *pg_query.A_Const_Fval:
 		if val.Fval != nil {
-			// Parse string to float64
-			if floatVal, err := strconv.ParseFloat(val.Fval.Fval, 64); err == nil {
-				return floatVal
+			if n, ok := bigintLiteral(val.Fval.Fval); ok {
+				return n
+			}
+			// Decimal literals are exact numerics, as in PostgreSQL
+			if n, err := storage.ParseNumeric(val.Fval.Fval); err == nil {
+				return n
 			}
 			return val.Fval.Fval
 		}
@@ -58,6 +64,13 @@ func extractAConstValue(aConst *pg_query.A_Const) interface{} {
 	return nil
 }
 
+// bigintLiteral reads a whole number literal too large for an integer as a
+// bigint, as PostgreSQL does while it fits in eight bytes
+func bigintLiteral(literal string) (int, bool) {
+	n, err := strconv.ParseInt(literal, 10, 64)
+	return int(n), err == nil
+}
+
 // compareValuesPg compares two values using the given operator
 // Implements SQL three-valued logic for NULL handling
 func compareValuesPg(left interface{}, operator string, right interface{}) bool {
@@ -67,6 +80,71 @@ func compareValuesPg(left interface{}, operator string, right interface{}) bool
 		return false
 	}
 
+	// Compare date/time values on their time line rather than as text
+	if cmp, ok := compareTemporal(left, right); ok {
+		switch operator {
+		case "=":
+			return cmp == 0
+		case "!=", "<>":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case ">":
+			return cmp > 0
+		case "<=":
+			return cmp <= 0
+		case ">=":
+			return cmp >= 0
+		}
+	}
+
+	// Compare uuids, byteas and enums in their own order
+	if cmp, ok := compareOpaque(left, right); ok {
+		switch operator {
+		case "=":
+			return cmp == 0
+		case "!=", "<>":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case ">":
+			return cmp > 0
+		case "<=":
+			return cmp <= 0
+		case ">=":
+			return cmp >= 0
+		}
+	}
+
+	// Compare JSON documents by value rather than by their text
+	if cmp, ok := compareJSON(left, right); ok {
+		switch operator {
+		case "=":
+			return cmp == 0
+		case "!=", "<>":
+			return cmp != 0
+		}
+		return false
+	}
+
+	// Compare numerics exactly rather than as floats
+	if cmp, ok := compareNumeric(left, right); ok {
+		switch operator {
+		case "=":
+			return cmp == 0
+		case "!=", "<>":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case ">":
+			return cmp > 0
+		case "<=":
+			return cmp <= 0
+		case ">=":
+			return cmp >= 0
+		}
+	}
+
 	// Try to compare as booleans first
 	leftBool, leftIsBool := left.(bool)
 	rightBool, rightIsBool := right.(bool)
@@ -215,21 +293,228 @@ func extractTableAndColumnFromRef(colRef *pg_query.ColumnRef) (string, string) {
 
 // isAggregateFunction checks if a function name is an aggregate function
 func isAggregateFunction(funcName string) bool {
-	switch strings.ToUpper(funcName) {
-	case "COUNT", "SUM", "AVG", "MAX", "MIN":
-		return true
-	default:
-		return false
-	}
+	fn, ok := LookupFunction(funcName)
+	return ok && fn.Kind == AggregateFunction
 }
 
-// getFunctionName extracts function name from FuncCall
+// getFunctionName extracts function name from FuncCall, without any schema qualification
 func getFunctionName(funcCall *pg_query.FuncCall) string {
 	if funcCall == nil || len(funcCall.Funcname) == 0 {
 		return ""
 	}
-	if str, ok := funcCall.Funcname[0].Node.(*pg_query.Node_String_); ok {
+	if str, ok := funcCall.Funcname[len(funcCall.Funcname)-1].Node.(*pg_query.Node_String_); ok {
 		return strings.ToUpper(str.String_.Sval)
 	}
 	return ""
+}
+
+// operatorName returns the operator of an expression, such as "+" or "="
+func operatorName(expr *pg_query.A_Expr) string {
+	if len(expr.Name) > 0 {
+		if str, ok := expr.Name[len(expr.Name)-1].Node.(*pg_query.Node_String_); ok {
+			return str.String_.Sval
+		}
+	}
+	return ""
+}
+
+// coerceValue converts a value to a column type, as a cast or a typed parameter does
+func coerceValue(value interface{}, colType storage.ColumnType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if b, isBpchar := value.(storage.Bpchar); isBpchar {
+		// char(n) loses its padding when cast to another type
+		value = b.Text()
+	}
+	if r, isRegObject := value.(regObject); isRegObject {
+		// Object identifiers are cast to integers as their OIDs and to text
+		// as their names
+		if colType == storage.TypeString {
+			return r.name, nil
+		}
+		value = r.oid
+	}
+	if storage.IsArrayType(colType) {
+		return coerceArray(value, colType)
+	}
+	if storage.IsEnumType(colType) {
+		switch v := value.(type) {
+		case storage.Enum:
+			if v.Type == colType {
+				return v, nil
+			}
+		case string:
+			return storage.ParseEnum(colType, v)
+		}
+		return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to %s", valueTypeName(value), sqlTypeName(colType))
+	}
+
+	switch colType {
+	case storage.TypeInteger:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case float64:
+			if v == float64(int(v)) {
+				return int(v), nil
+			}
+		case storage.Numeric:
+			// Numerics round to the nearest integer, half away from zero
+			if n, ok := v.Int64(); ok {
+				return int(n), nil
+			}
+			return nil, newSQLError(SQLStateNumericOutOfRange, "integer out of range")
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid input syntax for type integer: \"%v\"", value)
+	case storage.TypeFloat:
+		if f, err := toFloat64(value); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("invalid input syntax for type double precision: \"%v\"", value)
+	case storage.TypeNumeric:
+		switch v := value.(type) {
+		case storage.Numeric:
+			return v, nil
+		case int:
+			return storage.NumericFromInt(int64(v)), nil
+		case float64:
+			return storage.NumericFromFloat(v)
+		case string:
+			return storage.ParseNumeric(v)
+		}
+		return nil, newSQLError(SQLStateInvalidTextRepresentation, "invalid input syntax for type numeric: \"%v\"", value)
+	case storage.TypeBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", value))) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid input syntax for type boolean: \"%v\"", value)
+	case storage.TypeDate, storage.TypeTime, storage.TypeTimestamp, storage.TypeTimestampTZ, storage.TypeInterval:
+		return coerceTemporal(value, colType)
+	case storage.TypeJSON:
+		switch v := value.(type) {
+		case storage.JSON:
+			return v.Jsonb(), nil
+		case string:
+			return storage.ParseJSON(v)
+		}
+		return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to jsonb", valueTypeName(value))
+	case storage.TypeUUID:
+		switch v := value.(type) {
+		case storage.UUID:
+			return v, nil
+		case string:
+			return storage.ParseUUID(v)
+		}
+		return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to uuid", valueTypeName(value))
+	case storage.TypeBytea:
+		switch v := value.(type) {
+		case storage.Bytea:
+			return v, nil
+		case string:
+			return storage.ParseBytea(v)
+		}
+		return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to bytea", valueTypeName(value))
+	default:
+		return fmt.Sprintf("%v", value), nil
+	}
+}
+
+// walkExpr calls visit for node and each expression nested in it, descending
+// into a node's children only while visit returns true. Subqueries are
+// visited as SubLink nodes but not entered.
+func walkExpr(node *pg_query.Node, visit func(*pg_query.Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+	switch n := node.Node.(type) {
+	case *pg_query.Node_SubLink:
+		walkExpr(n.SubLink.Testexpr, visit)
+	case *pg_query.Node_BoolExpr:
+		for _, arg := range n.BoolExpr.Args {
+			walkExpr(arg, visit)
+		}
+	case *pg_query.Node_AExpr:
+		walkExpr(n.AExpr.Lexpr, visit)
+		walkExpr(n.AExpr.Rexpr, visit)
+	case *pg_query.Node_NullTest:
+		walkExpr(n.NullTest.Arg, visit)
+	case *pg_query.Node_TypeCast:
+		walkExpr(n.TypeCast.Arg, visit)
+	case *pg_query.Node_FuncCall:
+		for _, arg := range n.FuncCall.Args {
+			walkExpr(arg, visit)
+		}
+		for _, order := range n.FuncCall.AggOrder {
+			walkExpr(order, visit)
+		}
+		walkExpr(n.FuncCall.AggFilter, visit)
+	case *pg_query.Node_CoalesceExpr:
+		for _, arg := range n.CoalesceExpr.Args {
+			walkExpr(arg, visit)
+		}
+	case *pg_query.Node_MinMaxExpr:
+		for _, arg := range n.MinMaxExpr.Args {
+			walkExpr(arg, visit)
+		}
+	case *pg_query.Node_AArrayExpr:
+		for _, elem := range n.AArrayExpr.Elements {
+			walkExpr(elem, visit)
+		}
+	case *pg_query.Node_AIndirection:
+		walkExpr(n.AIndirection.Arg, visit)
+	case *pg_query.Node_CaseExpr:
+		walkExpr(n.CaseExpr.Arg, visit)
+		for _, when := range n.CaseExpr.Args {
+			walkExpr(when, visit)
+		}
+		walkExpr(n.CaseExpr.Defresult, visit)
+	case *pg_query.Node_CaseWhen:
+		walkExpr(n.CaseWhen.Expr, visit)
+		walkExpr(n.CaseWhen.Result, visit)
+	case *pg_query.Node_ResTarget:
+		walkExpr(n.ResTarget.Val, visit)
+	case *pg_query.Node_SortBy:
+		walkExpr(n.SortBy.Node, visit)
+	case *pg_query.Node_List:
+		for _, item := range n.List.Items {
+			walkExpr(item, visit)
+		}
+	}
+}
+
+// containsComputedValue reports whether an expression calls a function
+// (including GREATEST and LEAST), casts a value, applies an arithmetic
+// operator or reads a SQL value function such as CURRENT_DATE
+func containsComputedValue(node *pg_query.Node) bool {
+	found := false
+	walkExpr(node, func(n *pg_query.Node) bool {
+		switch expr := n.Node.(type) {
+		case *pg_query.Node_FuncCall, *pg_query.Node_TypeCast, *pg_query.Node_SqlvalueFunction, *pg_query.Node_MinMaxExpr,
+			*pg_query.Node_AArrayExpr, *pg_query.Node_AIndirection:
+			found = true
+		case *pg_query.Node_AExpr:
+			op := operatorName(expr.AExpr)
+			switch expr.AExpr.Kind {
+			case pg_query.A_Expr_Kind_AEXPR_OP:
+				if isArithmeticOperator(op) || isJSONOperator(op) || op == "&&" || op == "||" {
+					found = true
+				}
+			case pg_query.A_Expr_Kind_AEXPR_OP_ANY, pg_query.A_Expr_Kind_AEXPR_OP_ALL:
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
 }
\ No newline at end of file
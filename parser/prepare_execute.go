@@ -2,76 +2,141 @@ package parser
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
-	"sync"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
 )
 
-// SimplePreparedStatement represents a prepared statement for SQL PREPARE/EXECUTE
-type SimplePreparedStatement struct {
-	Name       string
-	Query      string   // The original query with $1, $2, etc.
-	ParamTypes []string // Parameter type names from PREPARE statement
-}
+var paramRefPattern = regexp.MustCompile(`\$(\d+)`)
 
-// Global store for SQL PREPARE statements
-var (
-	simplePreparedStatements = make(map[string]*SimplePreparedStatement)
-	simplePreparedMutex      sync.RWMutex
-)
+// executePgPrepare handles PREPARE statements
+func executePgPrepare(stmt *pg_query.PrepareStmt, session *Session) ([]string, [][]interface{}, string, error) {
+	query, err := pg_query.Deparse(&pg_query.ParseResult{Stmts: []*pg_query.RawStmt{{Stmt: stmt.Query}}})
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("could not prepare statement: %v", err)
+	}
 
-// StoreSimplePreparedStatement stores a prepared statement from PREPARE command
-func StoreSimplePreparedStatement(name, query string, paramTypes []string) {
-	simplePreparedMutex.Lock()
-	defer simplePreparedMutex.Unlock()
-	
-	simplePreparedStatements[name] = &SimplePreparedStatement{
-		Name:       name,
+	var paramTypes []string
+	var argTypes []storage.ColumnType
+	for _, arg := range stmt.Argtypes {
+		if typeName, ok := arg.Node.(*pg_query.Node_TypeName); ok {
+			paramTypes = append(paramTypes, typeNameString(typeName.TypeName))
+			argTypes = append(argTypes, getColumnTypeFromTypeName(typeName.TypeName))
+		}
+	}
+
+	prepared := &SQLPreparedStatement{
+		Name:       stmt.Name,
+		QueryNode:  stmt.Query,
 		Query:      query,
 		ParamTypes: paramTypes,
+		argTypes:   argTypes,
+	}
+	if err := session.StorePreparedStatement(prepared); err != nil {
+		return nil, nil, "", err
+	}
+	return nil, nil, "PREPARE", nil
+}
+
+// executePgExecute handles EXECUTE statements
+func executePgExecute(stmt *pg_query.ExecuteStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	prepared, err := session.GetPreparedStatement(stmt.Name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	expected := len(prepared.argTypes)
+	for _, match := range paramRefPattern.FindAllStringSubmatch(prepared.Query, -1) {
+		if n, _ := strconv.Atoi(match[1]); n > expected {
+			expected = n
+		}
+	}
+	if len(stmt.Params) != expected {
+		return nil, nil, "", fmt.Errorf("wrong number of parameters for prepared statement \"%s\": expected %d parameters but got %d", stmt.Name, expected, len(stmt.Params))
+	}
+
+	values := make([]interface{}, len(stmt.Params))
+	for i, param := range stmt.Params {
+		value := evaluateParam(param)
+		if i < len(prepared.argTypes) {
+			if value, err = coerceValue(value, prepared.argTypes[i]); err != nil {
+				return nil, nil, "", err
+			}
+		}
+		values[i] = value
+	}
+
+	result, err := ParsePostgreSQL(SubstituteParameters(prepared.Query, values))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(result.Stmts) == 0 {
+		return nil, nil, "", fmt.Errorf("no statements found")
 	}
+	if err := session.bind(result.Stmts[0].Stmt, dataStore, metaStore); err != nil {
+		return nil, nil, "", err
+	}
+	return executePgStatement(result.Stmts[0].Stmt, session, dataStore, metaStore)
 }
 
-// GetSimplePreparedStatement retrieves a prepared statement by name
-func GetSimplePreparedStatement(name string) (*SimplePreparedStatement, error) {
-	simplePreparedMutex.RLock()
-	defer simplePreparedMutex.RUnlock()
-	
-	stmt, exists := simplePreparedStatements[name]
-	if !exists {
-		return nil, fmt.Errorf("prepared statement \"%s\" does not exist", name)
-	}
-	
-	return stmt, nil
+// executePgDeallocate handles DEALLOCATE statements
+func executePgDeallocate(stmt *pg_query.DeallocateStmt, session *Session) ([]string, [][]interface{}, string, error) {
+	if stmt.Name == "" {
+		session.ClearPreparedStatements()
+		return nil, nil, "DEALLOCATE ALL", nil
+	}
+	if err := session.DropPreparedStatement(stmt.Name); err != nil {
+		return nil, nil, "", err
+	}
+	return nil, nil, "DEALLOCATE", nil
 }
 
-// DropSimplePreparedStatement removes a prepared statement
-func DropSimplePreparedStatement(name string) {
-	simplePreparedMutex.Lock()
-	defer simplePreparedMutex.Unlock()
-	delete(simplePreparedStatements, name)
+// evaluateParam computes the value of an EXECUTE argument
+func evaluateParam(node *pg_query.Node) interface{} {
+	if typeCast, ok := node.Node.(*pg_query.Node_TypeCast); ok {
+		value := evaluateParam(typeCast.TypeCast.Arg)
+		if coerced, err := coerceValue(value, getColumnTypeFromTypeName(typeCast.TypeCast.TypeName)); err == nil {
+			return coerced
+		}
+		return value
+	}
+	return extractValueFromExpr(storage.Row{}, node)
 }
 
-// ClearAllSimplePreparedStatements removes all prepared statements
-func ClearAllSimplePreparedStatements() {
-	simplePreparedMutex.Lock()
-	defer simplePreparedMutex.Unlock()
-	simplePreparedStatements = make(map[string]*SimplePreparedStatement)
+// typeNameString returns the unqualified name of a type
+func typeNameString(typeName *pg_query.TypeName) string {
+	if typeName == nil || len(typeName.Names) == 0 {
+		return ""
+	}
+	if str, ok := typeName.Names[len(typeName.Names)-1].Node.(*pg_query.Node_String_); ok {
+		return str.String_.Sval
+	}
+	return ""
 }
 
 // SubstituteParameters replaces $1, $2, etc. with actual parameter values
 func SubstituteParameters(query string, paramValues []interface{}) string {
 	result := query
-	
-	for i, value := range paramValues {
+
+	// Replace from the highest number down so $1 does not match inside $10
+	for i := len(paramValues) - 1; i >= 0; i-- {
+		value := paramValues[i]
 		placeholder := fmt.Sprintf("$%d", i+1)
 		var replacement string
-		
+
 		if value == nil {
 			replacement = "NULL"
 		} else {
 			switch v := value.(type) {
-			case int, int32, int64, float32, float64:
+			case int, int32, int64, float32, float64, storage.Numeric:
 				replacement = fmt.Sprintf("%v", v)
+				if strings.HasPrefix(replacement, "-") {
+					// Keep "$1-$2" from turning into a comment
+					replacement = "(" + replacement + ")"
+				}
 			case bool:
 				replacement = fmt.Sprintf("%v", v)
 			default:
@@ -81,10 +146,10 @@ func SubstituteParameters(query string, paramValues []interface{}) string {
 				replacement = fmt.Sprintf("'%s'", escaped)
 			}
 		}
-		
+
 		// Replace all occurrences of the placeholder
 		result = strings.ReplaceAll(result, placeholder, replacement)
 	}
-	
+
 	return result
-}
\ No newline at end of file
+}
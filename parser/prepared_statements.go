@@ -2,55 +2,59 @@ package parser
 
 import (
 	"fmt"
-	"sync"
-	
+
 	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
 )
 
 // SQLPreparedStatement represents a prepared statement created via SQL PREPARE command
 type SQLPreparedStatement struct {
 	Name       string
 	QueryNode  *pg_query.Node // The parsed query node from PREPARE
-	ParamTypes []string // Parameter type names from PREPARE statement
-}
+	Query      string         // The query text with $1, $2, etc.
+	ParamTypes []string       // Parameter type names from PREPARE statement
 
-// Global store for SQL-level prepared statements
-// This is separate from the Extended Protocol prepared statements
-var (
-	sqlPreparedStatements = make(map[string]*SQLPreparedStatement)
-	sqlPreparedMutex      sync.RWMutex
-)
+	argTypes []storage.ColumnType // Declared parameter types, used to coerce EXECUTE arguments
+}
 
-// StoreSQLPreparedStatement stores a prepared statement from PREPARE command
-func StoreSQLPreparedStatement(stmt *SQLPreparedStatement) {
-	sqlPreparedMutex.Lock()
-	defer sqlPreparedMutex.Unlock()
-	sqlPreparedStatements[stmt.Name] = stmt
+// StorePreparedStatement stores a prepared statement from PREPARE command
+func (s *Session) StorePreparedStatement(stmt *SQLPreparedStatement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.preparedStatements[stmt.Name]; exists {
+		return fmt.Errorf("prepared statement \"%s\" already exists", stmt.Name)
+	}
+	s.preparedStatements[stmt.Name] = stmt
+	return nil
 }
 
-// GetSQLPreparedStatement retrieves a prepared statement by name
-func GetSQLPreparedStatement(name string) (*SQLPreparedStatement, error) {
-	sqlPreparedMutex.RLock()
-	defer sqlPreparedMutex.RUnlock()
-	
-	stmt, exists := sqlPreparedStatements[name]
+// GetPreparedStatement retrieves a prepared statement by name
+func (s *Session) GetPreparedStatement(name string) (*SQLPreparedStatement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, exists := s.preparedStatements[name]
 	if !exists {
 		return nil, fmt.Errorf("prepared statement \"%s\" does not exist", name)
 	}
-	
+
 	return stmt, nil
 }
 
-// DropSQLPreparedStatement removes a prepared statement
-func DropSQLPreparedStatement(name string) {
-	sqlPreparedMutex.Lock()
-	defer sqlPreparedMutex.Unlock()
-	delete(sqlPreparedStatements, name)
+// DropPreparedStatement removes a prepared statement
+func (s *Session) DropPreparedStatement(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.preparedStatements[name]; !exists {
+		return fmt.Errorf("prepared statement \"%s\" does not exist", name)
+	}
+	delete(s.preparedStatements, name)
+	return nil
 }
 
-// ClearAllSQLPreparedStatements removes all prepared statements
-func ClearAllSQLPreparedStatements() {
-	sqlPreparedMutex.Lock()
-	defer sqlPreparedMutex.Unlock()
-	sqlPreparedStatements = make(map[string]*SQLPreparedStatement)
-}
\ No newline at end of file
+// ClearPreparedStatements removes all prepared statements
+func (s *Session) ClearPreparedStatements() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preparedStatements = make(map[string]*SQLPreparedStatement)
+}
@@ -0,0 +1,300 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// The object identifier types regclass, regtype and regnamespace hold the
+// OID of a relation, type or schema. A cast from text looks the object up by
+// name and a cast from an integer by OID, and the value prints as the
+// object's name, which is how catalog queries such as
+// attrelid = 'users'::regclass and indexrelid::regclass are written.
+
+// regObject is a value of one of the object identifier types
+type regObject struct {
+	typ  string // regclass, regtype or regnamespace
+	oid  int
+	name string
+}
+
+// String returns the name of the object, as the type's output does
+func (r regObject) String() string {
+	return r.name
+}
+
+// regTypeName returns the object identifier type a cast names, or ""
+func regTypeName(typeName *pg_query.TypeName) string {
+	if typeName == nil || len(typeName.ArrayBounds) > 0 {
+		return ""
+	}
+	switch name := baseTypeName(typeName); name {
+	case "regclass", "regtype", "regnamespace":
+		return name
+	}
+	return ""
+}
+
+// castRegObject converts text or an OID to a value of an object identifier
+// type. Names that match nothing are errors, so a lookup never silently
+// compares with no object.
+func castRegObject(value interface{}, typ string, dataStore *storage.DataStore, metaStore *storage.MetaStore) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case regObject:
+		return castRegObject(v.oid, typ, dataStore, metaStore)
+	case int:
+		return regObject{typ: typ, oid: v, name: regObjectName(typ, uint32(v), dataStore)}, nil
+	case string:
+		s := strings.TrimSpace(v)
+		if oid, err := strconv.Atoi(s); err == nil {
+			return castRegObject(oid, typ, dataStore, metaStore)
+		}
+		switch typ {
+		case "regclass":
+			return lookupRegclass(splitQualifiedName(s), dataStore)
+		case "regtype":
+			return lookupRegtype(s, metaStore)
+		default:
+			return lookupRegnamespace(strings.Join(splitQualifiedName(s), "."), dataStore)
+		}
+	}
+	return nil, newSQLError(SQLStateCannotCoerce, "cannot cast type %s to %s", valueTypeName(value), typ)
+}
+
+// splitQualifiedName splits a possibly schema-qualified name such as
+// public."Users" into its parts. Unquoted parts are folded to lower case.
+func splitQualifiedName(s string) []string {
+	var parts []string
+	var part strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' && quoted && i+1 < len(s) && s[i+1] == '"':
+			part.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		case quoted:
+			part.WriteByte(c)
+		default:
+			part.WriteString(strings.ToLower(string(c)))
+		}
+	}
+	return append(parts, part.String())
+}
+
+// lookupRegclass finds the table, index or catalog relation stored under
+// the key a name resolves to. Names written in a query are resolved on the
+// session's search path by bindRegclass; others are read as keys.
+func lookupRegclass(names []string, dataStore *storage.DataStore) (interface{}, error) {
+	name := names[len(names)-1]
+	key := name
+	if len(names) > 1 {
+		if rel := lookupCatalogRelation(&pg_query.RangeVar{Schemaname: names[len(names)-2], Relname: name}); rel != nil {
+			return regObject{typ: "regclass", oid: int(rel.oid), name: regclassName(rel.name, rel.namespace)}, nil
+		}
+		key = storage.TableKey(names[len(names)-2], name)
+	} else if rel := lookupCatalogRelation(&pg_query.RangeVar{Relname: name}); rel != nil {
+		return regObject{typ: "regclass", oid: int(rel.oid), name: rel.name}, nil
+	}
+	if table, exists := dataStore.GetTable(key); exists {
+		return regObject{typ: "regclass", oid: int(table.OID), name: regclassKeyName(key)}, nil
+	}
+	if idx, exists := dataStore.GetIndex(key); exists {
+		return regObject{typ: "regclass", oid: int(idx.OID), name: regclassKeyName(key)}, nil
+	}
+	return nil, newSQLError(SQLStateUndefinedTable, "relation \"%s\" does not exist", strings.Join(names, "."))
+}
+
+// regclassName returns how regclass prints a relation: by its own name when
+// its schema is on the search path, and qualified otherwise
+func regclassName(name string, namespace uint32) string {
+	if namespace == informationSchemaNamespaceOID {
+		return "information_schema." + quoteIdent(name)
+	}
+	return quoteIdent(name)
+}
+
+// regclassKeyName returns how regclass prints the table or index stored
+// under key. Tables in public and temporary tables are on the search path.
+func regclassKeyName(key string) string {
+	schema, name := storage.SplitTableKey(key)
+	if schema == storage.PublicSchema || storage.IsTempSchema(schema) {
+		return quoteIdent(name)
+	}
+	return quoteIdent(schema) + "." + quoteIdent(name)
+}
+
+// regtypeAliases maps the names types are also known by to their pg_type
+// names
+var regtypeAliases = map[string]string{
+	"int": "int4", "decimal": "numeric", "float": "float8", "double": "float8",
+	"char": "bpchar", "timestamp with time zone": "timestamptz",
+}
+
+// lookupRegtype finds a built-in type or an enum type of the database by
+// any of its names, such as int4, integer or integer[]
+func lookupRegtype(s string, metaStore *storage.MetaStore) (interface{}, error) {
+	name := strings.ToLower(s)
+	array := strings.HasSuffix(name, "[]")
+	name = strings.TrimSpace(strings.TrimSuffix(name, "[]"))
+	if alias, ok := regtypeAliases[name]; ok {
+		name = alias
+	}
+	var oid uint32
+	for _, t := range pgTypes {
+		if t.name == name || t.sqlName == name {
+			oid = t.oid
+			if array {
+				oid = t.array
+			}
+		} else if "_"+t.name == name {
+			oid = t.array
+		}
+	}
+	if oid == 0 && !array {
+		if enumType, ok := metaStore.LookupEnum(name); ok {
+			oid = storage.EnumOf(enumType).OID
+		}
+	}
+	if oid == 0 {
+		return nil, newSQLError(SQLStateUndefinedObject, "type \"%s\" does not exist", s)
+	}
+	return regObject{typ: "regtype", oid: int(oid), name: formatType(oid, -1)}, nil
+}
+
+// lookupRegnamespace finds a schema by name
+func lookupRegnamespace(name string, dataStore *storage.DataStore) (interface{}, error) {
+	for _, oid := range []uint32{pgCatalogNamespaceOID, informationSchemaNamespaceOID} {
+		if namespaceName(oid) == name {
+			return regObject{typ: "regnamespace", oid: int(oid), name: name}, nil
+		}
+	}
+	if oid, exists := dataStore.GetSchema(name); exists {
+		return regObject{typ: "regnamespace", oid: int(oid), name: quoteIdent(name)}, nil
+	}
+	return nil, newSQLError(SQLStateInvalidSchemaName, "schema \"%s\" does not exist", name)
+}
+
+// regObjectName returns the name of the object with the given OID. An OID
+// that names nothing prints as the number, as in PostgreSQL.
+func regObjectName(typ string, oid uint32, dataStore *storage.DataStore) string {
+	switch typ {
+	case "regclass":
+		for _, rel := range catalogRelations {
+			if rel.oid == oid {
+				return regclassName(rel.name, rel.namespace)
+			}
+		}
+		for _, key := range dataStore.ListTables() {
+			if table, _ := dataStore.GetTable(key); table != nil && table.OID == oid {
+				return regclassKeyName(key)
+			}
+		}
+		for _, idx := range dataStore.ListIndexes() {
+			if idx.OID == oid {
+				return regclassKeyName(idx.Name)
+			}
+		}
+	case "regtype":
+		if name := formatType(oid, -1); name != "???" {
+			return name
+		}
+	case "regnamespace":
+		if oid == pgCatalogNamespaceOID || oid == informationSchemaNamespaceOID {
+			return namespaceName(oid)
+		}
+		for _, name := range dataStore.ListSchemas() {
+			if schemaOID, _ := dataStore.GetSchema(name); schemaOID == oid {
+				return quoteIdent(name)
+			}
+		}
+	}
+	return strconv.Itoa(int(oid))
+}
+
+// compareRegObjects compares object identifiers by OID. Integers on the
+// other side are OIDs, and text is the object's name.
+func compareRegObjects(left regObject, right interface{}) (int, bool) {
+	switch r := right.(type) {
+	case regObject:
+		return left.oid - r.oid, true
+	case int:
+		return left.oid - r, true
+	case string:
+		if oid, err := strconv.Atoi(strings.TrimSpace(r)); err == nil {
+			return left.oid - oid, true
+		}
+		return strings.Compare(left.name, r), true
+	}
+	return 0, false
+}
+
+// bindRegclass resolves the relation name a regclass cast is given on the
+// session's search path, replacing it with the key the relation is stored
+// under. A name that matches no relation is an error.
+func (s *Session) bindRegclass(typeCast *pg_query.TypeCast, dataStore *storage.DataStore) error {
+	aConst, ok := typeCast.Arg.Node.(*pg_query.Node_AConst)
+	if !ok {
+		return nil
+	}
+	name, ok := extractAConstValue(aConst.AConst).(string)
+	if !ok {
+		return nil
+	}
+	key, err := s.resolveRegclass(name, dataStore)
+	if err == nil {
+		typeCast.Arg = constNode(key)
+	}
+	return err
+}
+
+// resolveRegclass returns the key of the relation a regclass name such as
+// users, public.users or "Users" refers to for the session. Catalog
+// relations keep their qualified names.
+func (s *Session) resolveRegclass(name string, dataStore *storage.DataStore) (string, error) {
+	if _, err := strconv.Atoi(strings.TrimSpace(name)); err == nil {
+		return name, nil
+	}
+	names := splitQualifiedName(strings.TrimSpace(name))
+	rv := &pg_query.RangeVar{Relname: names[len(names)-1]}
+	if len(names) > 1 {
+		rv.Schemaname = names[len(names)-2]
+	}
+	if lookupCatalogRelation(rv) != nil {
+		return name, nil
+	}
+	key, err := s.resolveTable(rv, dataStore, false)
+	if err != nil {
+		return "", err
+	}
+	if _, exists := dataStore.GetTable(key); exists {
+		return quoteKey(key), nil
+	}
+	if key := s.resolveIndex(names, dataStore); key != "" {
+		if _, exists := dataStore.GetIndex(key); exists {
+			return quoteKey(key), nil
+		}
+	}
+	return "", newSQLError(SQLStateUndefinedTable, "relation \"%s\" does not exist", name)
+}
+
+// quoteKey writes a table key as a qualified name that splitQualifiedName
+// reads back as the same key
+func quoteKey(key string) string {
+	schema, name := storage.SplitTableKey(key)
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+	if key == name {
+		return quote(name)
+	}
+	return quote(schema) + "." + quote(name)
+}
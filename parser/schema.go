@@ -0,0 +1,418 @@
+package parser
+
+import (
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Schemas, and how the tables a statement names are found through the
+// session's search_path
+
+// systemSchemas hold the system catalog; they cannot be created, dropped or
+// hold tables
+var systemSchemas = []string{"pg_catalog", "information_schema"}
+
+func isSystemSchema(name string) bool {
+	for _, schema := range systemSchemas {
+		if name == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaExists reports whether a schema exists, counting the system ones
+func schemaExists(dataStore *storage.DataStore, name string) bool {
+	if isSystemSchema(name) {
+		return true
+	}
+	_, exists := dataStore.GetSchema(name)
+	return exists
+}
+
+// searchPath returns the schemas of the session's search_path that exist,
+// in order. "$user" stands for a schema named after the session user.
+func (s *Session) searchPath(dataStore *storage.DataStore) []string {
+	value, _ := s.Setting("search_path")
+	var schemas []string
+	for _, schema := range splitSearchPath(value) {
+		if schema == "$user" {
+			schema = s.User()
+		}
+		if schemaExists(dataStore, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+// currentSchema returns the first schema on the search path that exists,
+// or nil if there is none
+func (s *Session) currentSchema(dataStore *storage.DataStore) interface{} {
+	if schemas := s.searchPath(dataStore); len(schemas) > 0 {
+		return schemas[0]
+	}
+	return nil
+}
+
+// creationSchema returns the schema tables named without one are created
+// in: the first schema on the search path that can hold tables
+func (s *Session) creationSchema(dataStore *storage.DataStore) (string, bool) {
+	for _, schema := range s.searchPath(dataStore) {
+		if !isSystemSchema(schema) {
+			return schema, true
+		}
+	}
+	return "", false
+}
+
+// ResolveTableName returns the name the table a FROM item names is stored
+// under, looking unqualified names up on the session's search_path.
+// System catalog relations keep their names.
+func ResolveTableName(rv *pg_query.RangeVar, session *Session, dataStore *storage.DataStore) string {
+	key, _ := session.resolveTable(rv, dataStore, false)
+	return key
+}
+
+// resolveTable returns the name a table is stored under. An unqualified
+// name is the first table of that name on the search path; one that does
+// not exist belongs to the creation schema. create reports an error when
+// there is no schema to create it in.
+func (s *Session) resolveTable(rv *pg_query.RangeVar, dataStore *storage.DataStore, create bool) (string, error) {
+	name := strings.Trim(rv.Relname, `"`)
+	if rv.Catalogname != "" && rv.Catalogname != s.Database() {
+		return "", newSQLError(SQLStateFeatureNotSupported, "cross-database references are not implemented: %s.%s.%s", rv.Catalogname, rv.Schemaname, name)
+	}
+	if rv.Schemaname != "" {
+		if isSystemSchema(rv.Schemaname) {
+			return name, nil
+		}
+		if storage.IsTempSchema(rv.Schemaname) {
+			if rv.Schemaname != "pg_temp" && rv.Schemaname != s.tempSchema {
+				return "", newSQLError(SQLStateFeatureNotSupported, "cannot access temporary tables of other sessions")
+			}
+			return s.tempTableKey(name), nil
+		}
+		if !schemaExists(dataStore, rv.Schemaname) {
+			return "", newSQLError(SQLStateInvalidSchemaName, "schema \"%s\" does not exist", rv.Schemaname)
+		}
+		return storage.TableKey(rv.Schemaname, name), nil
+	}
+
+	// Temporary tables and the system catalog come before the search path
+	if s.hasTempTable(name) {
+		return s.tempTableKey(name), nil
+	}
+	if lookupCatalogRelation(rv) != nil {
+		return name, nil
+	}
+	for _, schema := range s.searchPath(dataStore) {
+		if isSystemSchema(schema) {
+			continue
+		}
+		key := storage.TableKey(schema, name)
+		if _, exists := dataStore.GetTable(key); exists {
+			return key, nil
+		}
+	}
+	if schema, ok := s.creationSchema(dataStore); ok {
+		return storage.TableKey(schema, name), nil
+	}
+	if create {
+		return "", newSQLError(SQLStateInvalidSchemaName, "no schema has been selected to create in")
+	}
+	return name, nil
+}
+
+// resolveTempTable rewrites the table a CREATE TEMPORARY TABLE names to the
+// session's own pg_temp_N schema, so it shadows a permanent table of the same
+// name instead of taking it over. A table created in pg_temp is temporary
+// too, as in PostgreSQL.
+func (s *Session) resolveTempTable(rv *pg_query.RangeVar) error {
+	if rv.Schemaname != "" && !storage.IsTempSchema(rv.Schemaname) {
+		return newSQLError(SQLStateInvalidTableDefinition, "cannot create temporary relation in non-temporary schema")
+	}
+	if rv.Schemaname != "" && rv.Schemaname != "pg_temp" && rv.Schemaname != s.tempSchema {
+		return newSQLError(SQLStateFeatureNotSupported, "cannot create relations in temporary schemas of other sessions")
+	}
+	rv.Relpersistence = "t"
+	rv.Catalogname, rv.Schemaname, rv.Relname = "", "", s.tempTableKey(strings.Trim(rv.Relname, `"`))
+	return nil
+}
+
+// resolveIndex returns the name an index is stored under: in the schema it
+// is qualified with, or the first schema on the search path holding it
+func (s *Session) resolveIndex(names []string, dataStore *storage.DataStore) string {
+	name := names[len(names)-1]
+	if len(names) > 1 {
+		return storage.TableKey(names[len(names)-2], name)
+	}
+	for _, schema := range s.searchPath(dataStore) {
+		key := storage.TableKey(schema, name)
+		if _, exists := dataStore.GetIndex(key); exists {
+			return key
+		}
+	}
+	return name
+}
+
+// resolveTableNames rewrites the tables and indexes a statement names to
+// the names they are stored under. A table from a schema other than public
+// keeps its own name as an alias, so columns qualified with it still match.
+func resolveTableNames(stmt *pg_query.Node, session *Session, dataStore *storage.DataStore) error {
+	r := &tableResolver{session: session, dataStore: dataStore, ctes: make(map[string]bool)}
+	r.statement(stmt)
+	return r.err
+}
+
+// tableResolver carries the state of resolveTableNames
+type tableResolver struct {
+	session   *Session
+	dataStore *storage.DataStore
+	ctes      map[string]bool // Names of CTEs, which are not tables
+	err       error
+}
+
+func (r *tableResolver) statement(stmt *pg_query.Node) {
+	if stmt == nil {
+		return
+	}
+	switch n := stmt.Node.(type) {
+	case *pg_query.Node_SelectStmt:
+		r.selectStmt(n.SelectStmt)
+	case *pg_query.Node_InsertStmt:
+		r.withClause(n.InsertStmt.WithClause)
+		r.relation(n.InsertStmt.Relation, true)
+		r.statement(n.InsertStmt.SelectStmt)
+	case *pg_query.Node_UpdateStmt:
+		r.withClause(n.UpdateStmt.WithClause)
+		r.relation(n.UpdateStmt.Relation, false)
+		r.selectStmt(&pg_query.SelectStmt{FromClause: n.UpdateStmt.FromClause, TargetList: n.UpdateStmt.TargetList, WhereClause: n.UpdateStmt.WhereClause})
+	case *pg_query.Node_DeleteStmt:
+		r.withClause(n.DeleteStmt.WithClause)
+		r.relation(n.DeleteStmt.Relation, false)
+		r.selectStmt(&pg_query.SelectStmt{FromClause: n.DeleteStmt.UsingClause, WhereClause: n.DeleteStmt.WhereClause})
+	case *pg_query.Node_CreateStmt:
+		if rv := n.CreateStmt.Relation; rv.Relpersistence == "t" || storage.IsTempSchema(rv.Schemaname) {
+			if err := r.session.resolveTempTable(rv); err != nil && r.err == nil {
+				r.err = err
+			}
+			return
+		}
+		r.relation(n.CreateStmt.Relation, true)
+	case *pg_query.Node_IndexStmt:
+		r.relation(n.IndexStmt.Relation, true)
+		if n.IndexStmt.Idxname != "" && r.err == nil {
+			schema, _ := storage.SplitTableKey(n.IndexStmt.Relation.Relname)
+			n.IndexStmt.Idxname = storage.TableKey(schema, n.IndexStmt.Idxname)
+		}
+	case *pg_query.Node_DropStmt:
+		r.dropObjects(n.DropStmt)
+	case *pg_query.Node_DeclareCursorStmt:
+		r.statement(n.DeclareCursorStmt.Query)
+	case *pg_query.Node_ExplainStmt:
+		r.statement(n.ExplainStmt.Query)
+	}
+}
+
+func (r *tableResolver) withClause(with *pg_query.WithClause) {
+	if with == nil {
+		return
+	}
+	for _, cte := range with.Ctes {
+		if c, ok := cte.Node.(*pg_query.Node_CommonTableExpr); ok {
+			r.ctes[c.CommonTableExpr.Ctename] = true
+		}
+	}
+}
+
+// selectStmt resolves the tables of a SELECT, its CTEs and subqueries, and
+// drops the schema from column references such as s.t.col
+func (r *tableResolver) selectStmt(stmt *pg_query.SelectStmt) {
+	var collectCTEs func(stmt *pg_query.SelectStmt)
+	collectCTEs = func(stmt *pg_query.SelectStmt) {
+		if stmt == nil {
+			return
+		}
+		r.withClause(stmt.WithClause)
+		collectCTEs(stmt.Larg)
+		collectCTEs(stmt.Rarg)
+	}
+	collectCTEs(stmt)
+
+	walkSelectRangeVars(stmt, func(rv *pg_query.RangeVar) {
+		if rv.Schemaname == "" && r.ctes[rv.Relname] {
+			return
+		}
+		r.relation(rv, false)
+	})
+	r.columnRefs(&pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: stmt}})
+}
+
+// relation rewrites a table reference to the name the table is stored under
+func (r *tableResolver) relation(rv *pg_query.RangeVar, create bool) {
+	if rv == nil || r.err != nil || isSystemSchema(rv.Schemaname) {
+		return
+	}
+	key, err := r.session.resolveTable(rv, r.dataStore, create)
+	if err != nil {
+		r.err = err
+		return
+	}
+	name := strings.Trim(rv.Relname, `"`)
+	if key != name && rv.Alias == nil && !create {
+		rv.Alias = &pg_query.Alias{Aliasname: name}
+	}
+	rv.Catalogname, rv.Schemaname, rv.Relname = "", "", key
+}
+
+// columnRefs drops the schema, and database, from column references that
+// name them, leaving the table and column
+func (r *tableResolver) columnRefs(stmt *pg_query.Node) {
+	walkStatementExprs(stmt, func(node *pg_query.Node) {
+		walkExpr(node, func(n *pg_query.Node) bool {
+			switch expr := n.Node.(type) {
+			case *pg_query.Node_ColumnRef:
+				if fields := expr.ColumnRef.Fields; len(fields) > 2 {
+					expr.ColumnRef.Fields = fields[len(fields)-2:]
+				}
+			case *pg_query.Node_SubLink:
+				r.columnRefs(expr.SubLink.Subselect)
+			}
+			return true
+		})
+	})
+}
+
+// dropObjects rewrites the tables and indexes a DROP names to the single
+// names they are stored under
+func (r *tableResolver) dropObjects(stmt *pg_query.DropStmt) {
+	switch stmt.RemoveType {
+	case pg_query.ObjectType_OBJECT_TABLE, pg_query.ObjectType_OBJECT_INDEX:
+	default:
+		return
+	}
+	for _, obj := range stmt.Objects {
+		list, ok := obj.Node.(*pg_query.Node_List)
+		if !ok || len(list.List.Items) == 0 {
+			continue
+		}
+		var names []string
+		for _, item := range list.List.Items {
+			if str, ok := item.Node.(*pg_query.Node_String_); ok {
+				names = append(names, strings.Trim(str.String_.Sval, `"`))
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		var key string
+		if stmt.RemoveType == pg_query.ObjectType_OBJECT_INDEX {
+			key = r.session.resolveIndex(names, r.dataStore)
+		} else {
+			rv := &pg_query.RangeVar{Relname: names[len(names)-1]}
+			if len(names) > 1 {
+				rv.Schemaname = names[len(names)-2]
+			}
+			var err error
+			if key, err = r.session.resolveTable(rv, r.dataStore, false); err != nil {
+				if stmt.MissingOk {
+					continue
+				}
+				r.err = err
+				return
+			}
+		}
+		list.List.Items = []*pg_query.Node{pg_query.MakeStrNode(key)}
+	}
+}
+
+// executePgCreateSchema handles CREATE SCHEMA, creating the tables and
+// indexes it lists in the new schema
+func executePgCreateSchema(stmt *pg_query.CreateSchemaStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	name := stmt.Schemaname
+	if name == "" && stmt.Authrole != nil {
+		// CREATE SCHEMA AUTHORIZATION role names the schema after the role
+		name = stmt.Authrole.Rolename
+		if name == "" {
+			name = session.User()
+		}
+	}
+	if strings.HasPrefix(name, "pg_") {
+		return nil, nil, "", newSQLError(SQLStateReservedName, "unacceptable schema name \"%s\"", name).
+			withDetail("The prefix \"pg_\" is reserved for system schemas.")
+	}
+	if schemaExists(dataStore, name) {
+		if stmt.IfNotExists {
+			return nil, nil, "CREATE SCHEMA", nil
+		}
+		return nil, nil, "", newSQLError(SQLStateDuplicateSchema, "schema \"%s\" already exists", name)
+	}
+	if err := dataStore.CreateSchema(name); err != nil {
+		return nil, nil, "", err
+	}
+
+	for _, elt := range stmt.SchemaElts {
+		var relation *pg_query.RangeVar
+		switch n := elt.Node.(type) {
+		case *pg_query.Node_CreateStmt:
+			relation = n.CreateStmt.Relation
+		case *pg_query.Node_IndexStmt:
+			relation = n.IndexStmt.Relation
+		}
+		if relation != nil {
+			if relation.Schemaname != "" && relation.Schemaname != name {
+				return nil, nil, "", newSQLError(SQLStateInvalidSchemaDefinition, "CREATE specifies a schema (%s) different from the one being created (%s)", relation.Schemaname, name)
+			}
+			relation.Schemaname = name
+		}
+		if err := resolveTableNames(elt, session, dataStore); err != nil {
+			return nil, nil, "", err
+		}
+		if _, _, _, err := executePgStatement(elt, session, dataStore, metaStore); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	return nil, nil, "CREATE SCHEMA", nil
+}
+
+// executePgDropSchema handles DROP SCHEMA. A schema that holds tables can
+// only be dropped with CASCADE, which drops them too.
+func executePgDropSchema(stmt *pg_query.DropStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	var names []string
+	for _, obj := range stmt.Objects {
+		str, ok := obj.Node.(*pg_query.Node_String_)
+		if !ok {
+			continue
+		}
+		name := str.String_.Sval
+		if isSystemSchema(name) {
+			return nil, nil, "", newSQLError(SQLStateDependentObjectsStillExist, "cannot drop schema %s because it is required by the database system", name)
+		}
+		if _, exists := dataStore.GetSchema(name); !exists {
+			if stmt.MissingOk {
+				continue
+			}
+			return nil, nil, "", newSQLError(SQLStateInvalidSchemaName, "schema \"%s\" does not exist", name)
+		}
+		if tables := dataStore.SchemaTables(name); len(tables) > 0 && stmt.Behavior != pg_query.DropBehavior_DROP_CASCADE {
+			_, table := storage.SplitTableKey(tables[0])
+			return nil, nil, "", newSQLError(SQLStateDependentObjectsStillExist, "cannot drop schema %s because other objects depend on it", name).
+				withDetail("table " + name + "." + table + " depends on schema " + name).
+				withHint("Use DROP ... CASCADE to drop the dependent objects too.")
+		}
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		for _, table := range dataStore.SchemaTables(name) {
+			dataStore.DropTable(table)
+			metaStore.DropTable(table)
+			session.dropTempTable(table)
+		}
+		dataStore.DropSchema(name)
+	}
+	return nil, nil, "DROP SCHEMA", nil
+}
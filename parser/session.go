@@ -0,0 +1,398 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// TransactionStatus is the transaction state reported in ReadyForQuery
+type TransactionStatus byte
+
+const (
+	TransactionIdle   TransactionStatus = 'I' // Not in a transaction block
+	TransactionActive TransactionStatus = 'T' // In a transaction block
+	TransactionFailed TransactionStatus = 'E' // In a failed transaction block
+)
+
+// defaultUser is the user of sessions that did not name one, such as the
+// one commands given on the command line run in
+const defaultUser = "postgres"
+
+// DefaultDatabase is the database of sessions that did not connect to one,
+// such as the one running commands from the command line. It is the
+// template new databases are copied from.
+const DefaultDatabase = "vsql"
+
+// tempNamespaces numbers the schemas that hold each session's temporary tables
+var tempNamespaces atomic.Int64
+
+// Session holds the state of one client connection: prepared statements,
+// settings, transaction state, temporary tables and declared cursors.
+// Writes are applied to the shared data store immediately; a transaction
+// block only tracks status, so ROLLBACK does not undo them.
+type Session struct {
+	mu                 sync.Mutex
+	preparedStatements map[string]*SQLPreparedStatement
+	settings           map[string]string
+	status             TransactionStatus
+	tempSchema         string                             // Schema of the session's temporary tables, pg_temp_N
+	tempTables         map[string]pg_query.OnCommitAction // ON COMMIT action of each temporary table, by name
+	cursors            *CursorSet
+	database           string
+	cluster            *storage.Cluster // Databases CREATE and DROP DATABASE manage
+	user               string
+	processID          int32
+	startupSettings    map[string]string       // Settings the client connected with
+	txnSettings        map[string]*string      // Values before the transaction block changed them
+	localSettings      map[string]*string      // Values before SET LOCAL changed them
+	reportedSettings   map[string]string       // Values last sent in ParameterStatus
+	guard              *statementGuard         // Checks of the running statement
+	cancel             context.CancelCauseFunc // Cancels the running statement
+}
+
+// NewSession creates a session with no state
+func NewSession() *Session {
+	return &Session{
+		preparedStatements: make(map[string]*SQLPreparedStatement),
+		settings:           make(map[string]string),
+		status:             TransactionIdle,
+		tempSchema:         fmt.Sprintf("pg_temp_%d", tempNamespaces.Add(1)),
+		tempTables:         make(map[string]pg_query.OnCommitAction),
+		cursors:            NewCursorSet(),
+		database:           DefaultDatabase,
+		user:               defaultUser,
+		processID:          int32(os.Getpid()),
+		startupSettings:    make(map[string]string),
+		txnSettings:        make(map[string]*string),
+		localSettings:      make(map[string]*string),
+		reportedSettings:   make(map[string]string),
+	}
+}
+
+// SetConnection records the database and user a client connected with and
+// the process ID reported to it
+func (s *Session) SetConnection(database, user string, processID int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.database = database
+	s.user = user
+	s.processID = processID
+}
+
+// SetCluster gives the session the cluster its database belongs to, for
+// CREATE DATABASE and DROP DATABASE
+func (s *Session) SetCluster(cluster *storage.Cluster) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cluster = cluster
+}
+
+// Cluster returns the cluster set with SetCluster, or nil
+func (s *Session) Cluster() *storage.Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cluster
+}
+
+// Database returns the name of the database the session is connected to
+func (s *Session) Database() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.database
+}
+
+// User returns the name of the user the session connected as
+func (s *Session) User() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user
+}
+
+// ProcessID returns the backend process ID reported to the session's client
+func (s *Session) ProcessID() int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.processID
+}
+
+// TransactionStatus returns the current transaction state
+func (s *Session) TransactionStatus() TransactionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// InTransaction reports whether a transaction block is open
+func (s *Session) InTransaction() bool {
+	return s.TransactionStatus() != TransactionIdle
+}
+
+// Close releases the session's temporary tables and cursors
+func (s *Session) Close(dataStore *storage.DataStore, metaStore *storage.MetaStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.tempTables {
+		key := storage.TableKey(s.tempSchema, name)
+		dataStore.DropTable(key)
+		metaStore.DropTable(key)
+	}
+	dataStore.DropSchema(s.tempSchema)
+	s.tempTables = make(map[string]pg_query.OnCommitAction)
+	s.preparedStatements = make(map[string]*SQLPreparedStatement)
+	s.cursors.Clear()
+}
+
+// tempTableKey returns the name the session's temporary table is stored
+// under, in its own pg_temp_N schema
+func (s *Session) tempTableKey(name string) string {
+	return storage.TableKey(s.tempSchema, name)
+}
+
+// addTempTable records a temporary table created under a key from
+// tempTableKey, creating the session's temporary schema on first use
+func (s *Session) addTempTable(key string, onCommit pg_query.OnCommitAction, dataStore *storage.DataStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := dataStore.GetSchema(s.tempSchema); !exists {
+		dataStore.CreateSchema(s.tempSchema)
+	}
+	_, name := storage.SplitTableKey(key)
+	s.tempTables[name] = onCommit
+}
+
+// hasTempTable reports whether the session owns a temporary table
+func (s *Session) hasTempTable(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.tempTables[name]
+	return exists
+}
+
+// dropTempTable forgets a table that was dropped explicitly if it is one of
+// the session's temporary tables
+func (s *Session) dropTempTable(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if schema, name := storage.SplitTableKey(key); schema == s.tempSchema {
+		delete(s.tempTables, name)
+	}
+}
+
+// run executes a statement within the session's transaction state. In a failed
+// transaction block only statements that end the block are accepted, and an
+// error inside a block marks it failed.
+func (s *Session) run(stmt *pg_query.Node, dataStore *storage.DataStore, metaStore *storage.MetaStore, execute func() error) error {
+	if s.TransactionStatus() == TransactionFailed && !endsFailedTransaction(stmt) {
+		return abortedTransactionError()
+	}
+	err := s.bind(stmt, dataStore, metaStore)
+	if err == nil {
+		err = s.execute(execute)
+	}
+	s.failTransaction(err)
+	return err
+}
+
+// RunStatement runs fn as a statement of the session, as the extended
+// protocol does when it fetches rows from a portal: statement_timeout and
+// Cancel stop it and the intermediate result limits apply. It is refused in
+// a failed transaction block, and an error inside a block marks it failed.
+func (s *Session) RunStatement(fn func() error) error {
+	if s.TransactionStatus() == TransactionFailed {
+		return abortedTransactionError()
+	}
+	err := s.execute(fn)
+	s.failTransaction(err)
+	return err
+}
+
+// failTransaction marks the open transaction block failed when err is set
+func (s *Session) failTransaction(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.status == TransactionActive {
+		s.status = TransactionFailed
+	}
+	s.mu.Unlock()
+}
+
+// abortedTransactionError is the error of a statement sent in a failed
+// transaction block
+func abortedTransactionError() error {
+	return fmt.Errorf("current transaction is aborted, commands ignored until end of transaction block")
+}
+
+// bind prepares a parsed statement to run in the session: calls to session
+// functions become their values, tables the names they are stored under,
+// and the names of the database's enum types are resolved
+func (s *Session) bind(stmt *pg_query.Node, dataStore *storage.DataStore, metaStore *storage.MetaStore) error {
+	if err := bindSessionFunctions(stmt, s, dataStore); err != nil {
+		return err
+	}
+	bindTypeNames(stmt, metaStore)
+	return resolveTableNames(stmt, s, dataStore)
+}
+
+// execute runs a statement under a context that Cancel and the session's
+// statement_timeout cancel, within the session's max_intermediate_rows and
+// statement_memory_limit. The executor stops at its next check with the
+// cancellation or exceeded limit as the statement's error.
+func (s *Session) execute(execute func() error) error {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	if timeout := s.statementTimeout(); timeout > 0 {
+		timer := time.AfterFunc(timeout, func() { cancel(statementTimeoutCancel()) })
+		defer timer.Stop()
+	}
+
+	guard := &statementGuard{ctx: ctx, maxRows: s.maxIntermediateRows(), memoryLimit: s.statementMemoryLimit()}
+	s.mu.Lock()
+	s.guard = guard
+	s.cancel = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.guard = nil
+		s.cancel = nil
+		s.mu.Unlock()
+	}()
+	return execute()
+}
+
+// Cancel stops the statement the session is running, which fails with a
+// query_canceled error. It does nothing when the session is idle.
+func (s *Session) Cancel() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel(userCancel())
+	}
+}
+
+// statementGuard returns the guard of the statement being run, nil when
+// there is none
+func (s *Session) statementGuard() *statementGuard {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.guard
+}
+
+// endsFailedTransaction reports whether stmt may run in a failed transaction block
+func endsFailedTransaction(stmt *pg_query.Node) bool {
+	txn, ok := stmt.GetNode().(*pg_query.Node_TransactionStmt)
+	if !ok {
+		return false
+	}
+	switch txn.TransactionStmt.Kind {
+	case pg_query.TransactionStmtKind_TRANS_STMT_COMMIT,
+		pg_query.TransactionStmtKind_TRANS_STMT_ROLLBACK,
+		pg_query.TransactionStmtKind_TRANS_STMT_ROLLBACK_TO:
+		return true
+	}
+	return false
+}
+
+// executePgTransaction handles BEGIN, COMMIT, ROLLBACK and savepoints
+func executePgTransaction(stmt *pg_query.TransactionStmt, session *Session, dataStore *storage.DataStore, metaStore *storage.MetaStore) ([]string, [][]interface{}, string, error) {
+	switch stmt.Kind {
+	case pg_query.TransactionStmtKind_TRANS_STMT_BEGIN, pg_query.TransactionStmtKind_TRANS_STMT_START:
+		session.mu.Lock()
+		if session.status == TransactionIdle {
+			session.status = TransactionActive
+		}
+		session.mu.Unlock()
+		if stmt.Kind == pg_query.TransactionStmtKind_TRANS_STMT_START {
+			return nil, nil, "START TRANSACTION", nil
+		}
+		return nil, nil, "BEGIN", nil
+	case pg_query.TransactionStmtKind_TRANS_STMT_COMMIT:
+		failed := session.TransactionStatus() == TransactionFailed
+		session.endTransaction(failed, dataStore, metaStore)
+		if failed {
+			return nil, nil, "ROLLBACK", nil
+		}
+		return nil, nil, "COMMIT", nil
+	case pg_query.TransactionStmtKind_TRANS_STMT_ROLLBACK:
+		session.endTransaction(true, dataStore, metaStore)
+		return nil, nil, "ROLLBACK", nil
+	case pg_query.TransactionStmtKind_TRANS_STMT_SAVEPOINT,
+		pg_query.TransactionStmtKind_TRANS_STMT_RELEASE,
+		pg_query.TransactionStmtKind_TRANS_STMT_ROLLBACK_TO:
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if session.status == TransactionIdle {
+			return nil, nil, "", fmt.Errorf("%s can only be used in transaction blocks", savepointCommand(stmt.Kind))
+		}
+		switch stmt.Kind {
+		case pg_query.TransactionStmtKind_TRANS_STMT_SAVEPOINT:
+			return nil, nil, "SAVEPOINT", nil
+		case pg_query.TransactionStmtKind_TRANS_STMT_RELEASE:
+			return nil, nil, "RELEASE", nil
+		default:
+			// Rolling back to a savepoint recovers a failed block
+			session.status = TransactionActive
+			return nil, nil, "ROLLBACK", nil
+		}
+	default:
+		return nil, nil, "", fmt.Errorf("prepared transactions are not supported")
+	}
+}
+
+func savepointCommand(kind pg_query.TransactionStmtKind) string {
+	switch kind {
+	case pg_query.TransactionStmtKind_TRANS_STMT_SAVEPOINT:
+		return "SAVEPOINT"
+	case pg_query.TransactionStmtKind_TRANS_STMT_RELEASE:
+		return "RELEASE SAVEPOINT"
+	default:
+		return "ROLLBACK TO SAVEPOINT"
+	}
+}
+
+// endTransaction closes the transaction block, its cursors and ON COMMIT temporary tables
+func (s *Session) endTransaction(rollback bool, dataStore *storage.DataStore, metaStore *storage.MetaStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == TransactionIdle {
+		return
+	}
+	s.status = TransactionIdle
+	s.cursors.closeAtTransactionEnd(rollback)
+
+	// Settings changed in the block revert on rollback; SET LOCAL ones
+	// revert either way
+	if rollback {
+		s.restoreSettings(s.txnSettings)
+	} else {
+		s.restoreSettings(s.localSettings)
+	}
+	s.txnSettings = make(map[string]*string)
+	s.localSettings = make(map[string]*string)
+
+	for name, onCommit := range s.tempTables {
+		key := storage.TableKey(s.tempSchema, name)
+		switch onCommit {
+		case pg_query.OnCommitAction_ONCOMMIT_DROP:
+			dataStore.DropTable(key)
+			metaStore.DropTable(key)
+			delete(s.tempTables, name)
+		case pg_query.OnCommitAction_ONCOMMIT_DELETE_ROWS:
+			if table, exists := dataStore.GetTable(key); exists {
+				table.Delete(func(storage.Row) bool { return true })
+			}
+		}
+	}
+}
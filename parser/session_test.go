@@ -0,0 +1,465 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// TestSessionPreparedStatements checks that prepared statements belong to one session
+func TestSessionPreparedStatements(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	first, second := NewSession(), NewSession()
+
+	if _, _, _, err := ExecutePgQuery("PREPARE q AS SELECT 1", first, dataStore, metaStore); err != nil {
+		t.Fatalf("PREPARE failed: %v", err)
+	}
+	if _, _, _, err := ExecutePgQuery("PREPARE q AS SELECT 2", second, dataStore, metaStore); err != nil {
+		t.Fatalf("PREPARE with the same name in another session failed: %v", err)
+	}
+	if _, _, _, err := ExecutePgQuery("DEALLOCATE q", first, dataStore, metaStore); err != nil {
+		t.Fatalf("DEALLOCATE failed: %v", err)
+	}
+	if _, err := second.GetPreparedStatement("q"); err != nil {
+		t.Errorf("DEALLOCATE in one session removed the other session's statement: %v", err)
+	}
+}
+
+// TestSessionTransactionStatus checks transaction status transitions, including failed blocks
+func TestSessionTransactionStatus(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	steps := []struct {
+		query   string
+		wantErr bool
+		tag     string
+		status  TransactionStatus
+	}{
+		{query: "BEGIN", tag: "BEGIN", status: TransactionActive},
+		{query: "PREPARE q AS SELECT 1", tag: "PREPARE", status: TransactionActive},
+		{query: "PREPARE q AS SELECT 1", wantErr: true, status: TransactionFailed},
+		{query: "SELECT 1", wantErr: true, status: TransactionFailed},
+		{query: "COMMIT", tag: "ROLLBACK", status: TransactionIdle},
+		{query: "SAVEPOINT s", wantErr: true, status: TransactionIdle},
+		{query: "START TRANSACTION", tag: "START TRANSACTION", status: TransactionActive},
+		{query: "SAVEPOINT s", tag: "SAVEPOINT", status: TransactionActive},
+		{query: "EXECUTE missing", wantErr: true, status: TransactionFailed},
+		{query: "ROLLBACK TO SAVEPOINT s", tag: "ROLLBACK", status: TransactionActive},
+		{query: "COMMIT", tag: "COMMIT", status: TransactionIdle},
+	}
+
+	for _, step := range steps {
+		_, _, tag, err := ExecutePgQuery(step.query, session, dataStore, metaStore)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: unexpected error state: %v", step.query, err)
+		}
+		if !step.wantErr && tag != step.tag {
+			t.Errorf("%s: expected tag %q, got %q", step.query, step.tag, tag)
+		}
+		if status := session.TransactionStatus(); status != step.status {
+			t.Errorf("%s: expected status %c, got %c", step.query, step.status, status)
+		}
+	}
+}
+
+// TestSessionTempTables checks that temporary tables are dropped with their session
+func TestSessionTempTables(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	queries := []string{
+		"CREATE TEMP TABLE scratch (id int)",
+		"BEGIN",
+		"CREATE TEMP TABLE txn_scratch (id int) ON COMMIT DROP",
+		"COMMIT",
+	}
+	for _, query := range queries {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	if _, exists := dataStore.GetTable(session.tempTableKey("txn_scratch")); exists {
+		t.Error("Expected ON COMMIT DROP table to be dropped at COMMIT")
+	}
+	if _, exists := dataStore.GetTable(session.tempTableKey("scratch")); !exists {
+		t.Fatal("Expected temporary table to outlive the transaction")
+	}
+
+	session.Close(dataStore, metaStore)
+	if _, exists := dataStore.GetTable(session.tempTableKey("scratch")); exists {
+		t.Error("Expected temporary table to be dropped when the session closes")
+	}
+}
+
+// TestSessionTempTableIsolation checks that a temporary table shadows a
+// permanent table of the same name in its own session only, and that
+// ending the session or transaction drops only the temporary table
+func TestSessionTempTableIsolation(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	owner, other := NewSession(), NewSession()
+
+	run := func(session *Session, query string) [][]interface{} {
+		t.Helper()
+		_, rows, _, err := ExecutePgQuery(query, session, dataStore, metaStore)
+		if err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+		return rows
+	}
+	count := func(session *Session, table string) interface{} {
+		t.Helper()
+		return run(session, "SELECT count(*) FROM "+table)[0][0]
+	}
+
+	run(other, "CREATE TABLE users (id int, name text)")
+	run(other, "INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob')")
+
+	// CREATE TEMP shadows the permanent table for its own session only
+	run(owner, "CREATE TEMP TABLE users (id int, name text)")
+	run(owner, "INSERT INTO users (id, name) VALUES (3, 'Carol')")
+	if got := count(owner, "users"); got != 1 {
+		t.Errorf("Expected the temporary table to shadow users, got %v rows", got)
+	}
+	if got := count(owner, "public.users"); got != 2 {
+		t.Errorf("Expected public.users to stay reachable, got %v rows", got)
+	}
+	if got := count(other, "users"); got != 2 {
+		t.Errorf("Expected another session to see the permanent table, got %v rows", got)
+	}
+	run(other, "INSERT INTO users (id, name) VALUES (4, 'Dave')")
+	if got := count(owner, "users"); got != 1 {
+		t.Errorf("Expected another session's insert to reach the permanent table, got %v temporary rows", got)
+	}
+	if _, _, _, err := ExecutePgQuery("SELECT * FROM "+owner.tempSchema+".users", other, dataStore, metaStore); err == nil {
+		t.Error("Expected another session's temporary schema to be inaccessible")
+	}
+
+	// ON COMMIT DROP drops only the temporary table
+	run(owner, "BEGIN")
+	run(owner, "CREATE TEMP TABLE users_txn (id int) ON COMMIT DROP")
+	run(other, "CREATE TABLE users_txn (id int)")
+	run(owner, "COMMIT")
+	if _, exists := dataStore.GetTable("users_txn"); !exists {
+		t.Error("Expected COMMIT to leave the permanent users_txn table")
+	}
+
+	// Closing the session drops only its temporary tables
+	owner.Close(dataStore, metaStore)
+	if got := count(other, "users"); got != 3 {
+		t.Errorf("Expected the permanent table to keep its 3 rows after the session closed, got %v", got)
+	}
+	if _, exists := dataStore.GetTable(owner.tempTableKey("users")); exists {
+		t.Error("Expected the temporary table to be dropped with its session")
+	}
+}
+
+// TestSessionInformationFunctions checks that session functions report the
+// values of the session that calls them
+func TestSessionInformationFunctions(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	first, second := NewSession(), NewSession()
+	first.SetConnection("shop", "alice", 101)
+	second.SetConnection("crm", "bob", 102)
+	second.SetSetting("application_name", "reports")
+
+	query := "SELECT current_database(), current_user, pg_backend_pid(), current_setting('Application_Name')"
+	for _, tt := range []struct {
+		session *Session
+		want    []interface{}
+	}{
+		{first, []interface{}{"shop", "alice", 101, ""}},
+		{second, []interface{}{"crm", "bob", 102, "reports"}},
+	} {
+		columns, rows, _, err := ExecutePgQuery(query, tt.session, dataStore, metaStore)
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if len(columns) != 4 || columns[1] != "current_user" {
+			t.Errorf("columns = %v", columns)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("got %d rows", len(rows))
+		}
+		for i, want := range tt.want {
+			if rows[0][i] != want {
+				t.Errorf("column %s = %v, want %v", columns[i], rows[0][i], want)
+			}
+		}
+	}
+}
+
+func TestVersionNum(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+	}{
+		{"16.4", 160004},
+		{"12.0", 120000},
+		{"10", 100000},
+		{"9.6.24", 90624},
+	}
+	for _, tt := range tests {
+		if got, err := versionNum(tt.version); err != nil || got != tt.want {
+			t.Errorf("versionNum(%q) = %d, %v, want %d", tt.version, got, err, tt.want)
+		}
+	}
+	for _, version := range []string{"", "16.x", "16.4.1", "9.6.100"} {
+		if _, err := versionNum(version); err == nil {
+			t.Errorf("versionNum(%q) should fail", version)
+		}
+	}
+}
+
+// TestSessionDisplaySettings checks that TimeZone and DateStyle change how
+// query results are shown
+func TestSessionDisplaySettings(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+	query := "SELECT '2024-03-05 00:00:00+00'::timestamptz AS t, '2024-03-05'::date AS d"
+
+	tests := []struct {
+		set   string
+		wantT string
+		wantD string
+	}{
+		{"SET TimeZone = 'UTC'", "2024-03-05 00:00:00+00", "2024-03-05"},
+		{"SET TimeZone = 'Asia/Kolkata'", "2024-03-05 05:30:00+05:30", "2024-03-05"},
+		{"SET TIME ZONE -3", "2024-03-04 21:00:00-03", "2024-03-05"},
+		{"SET DateStyle = 'SQL, DMY'", "04/03/2024 21:00:00 -03", "05/03/2024"},
+		{"SET DateStyle = 'German'", "04.03.2024 21:00:00 -03", "05.03.2024"},
+		{"RESET ALL", "2024-03-05 00:00:00+00", "2024-03-05"},
+	}
+	for _, tt := range tests {
+		if _, _, _, err := ExecutePgQuery(tt.set, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", tt.set, err)
+		}
+		_, rows, _, err := ExecutePgQuery(query, session, dataStore, metaStore)
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if got := fmt.Sprint(rows[0][0]); got != tt.wantT {
+			t.Errorf("after %s: timestamptz = %q, want %q", tt.set, got, tt.wantT)
+		}
+		if got := fmt.Sprint(rows[0][1]); got != tt.wantD {
+			t.Errorf("after %s: date = %q, want %q", tt.set, got, tt.wantD)
+		}
+	}
+}
+
+// TestParameterChanges checks that reported parameters are returned once
+// when they change
+func TestParameterChanges(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	if changes := session.ParameterChanges(); len(changes) != len(ReportedSettings) {
+		t.Errorf("first call returned %d parameters, want %d", len(changes), len(ReportedSettings))
+	}
+	if changes := session.ParameterChanges(); len(changes) != 0 {
+		t.Errorf("unchanged parameters were reported again: %v", changes)
+	}
+
+	for _, query := range []string{"SET TimeZone = 'Europe/Berlin'", "SET statement_timeout = 1000"} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+	changes := session.ParameterChanges()
+	if len(changes) != 1 || changes[0] != [2]string{"TimeZone", "Europe/Berlin"} {
+		t.Errorf("changes = %v, want only TimeZone", changes)
+	}
+}
+
+// TestSessionCancel checks that Cancel stops the statement a session is
+// running with a query_canceled error
+func TestSessionCancel(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, query := range []string{
+		"CREATE TABLE numbers (n integer)",
+		"INSERT INTO numbers (n) VALUES " + strings.Join(values, ", "),
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := ExecutePgQuery("SELECT count(*) FROM numbers a CROSS JOIN numbers b CROSS JOIN numbers c CROSS JOIN numbers d", session, dataStore, metaStore)
+		done <- err
+	}()
+
+	// Canceling an idle session does nothing, so keep trying until the
+	// statement has started
+	timeout := time.After(10 * time.Second)
+	for {
+		session.Cancel()
+		select {
+		case err := <-done:
+			sqlErr, ok := err.(*SQLError)
+			if !ok || sqlErr.SQLState() != SQLStateQueryCanceled {
+				t.Fatalf("error = %v, want query_canceled", err)
+			}
+			if session.statementGuard() != nil {
+				t.Error("the statement's guard should be cleared when it ends")
+			}
+			return
+		case <-timeout:
+			t.Fatal("the statement was not canceled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestSessionIntermediateRows checks that max_intermediate_rows counts the
+// rows every step of a statement builds, across all of its joins
+func TestSessionIntermediateRows(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	session := NewSession()
+
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, query := range []string{
+		"CREATE TABLE numbers (n integer)",
+		"INSERT INTO numbers (n) VALUES " + strings.Join(values, ", "),
+		"CREATE TABLE digits (d integer)",
+		"INSERT INTO digits (d) VALUES " + strings.Join(values[:10], ", "),
+		"SET max_intermediate_rows = 150",
+	} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		fails bool
+	}{
+		{"SELECT n FROM numbers", false},
+		{"SELECT count(*) FROM digits a CROSS JOIN digits b", false},
+		{"SELECT n % 10 AS d, count(*) FROM numbers GROUP BY n % 10", false},
+		{"SELECT n FROM numbers ORDER BY n", true},
+		{"SELECT n, count(*) FROM numbers GROUP BY n", true},
+		{"SELECT count(*) FROM (SELECT n FROM numbers) s", true},
+		{"SELECT (SELECT count(*) FROM digits a CROSS JOIN digits b) + (SELECT count(*) FROM digits c CROSS JOIN digits d)", true},
+	}
+	for _, tt := range tests {
+		_, _, _, err := ExecutePgQuery(tt.query, session, dataStore, metaStore)
+		if !tt.fails {
+			if err != nil {
+				t.Errorf("%s failed: %v", tt.query, err)
+			}
+			continue
+		}
+		if sqlErr, ok := err.(*SQLError); !ok || sqlErr.SQLState() != SQLStateProgramLimitExceeded {
+			t.Errorf("%s: error = %v, want max_intermediate_rows exceeded", tt.query, err)
+		}
+	}
+
+	// Cursors stream joins a fetch at a time, counting the rows they build
+	cursor, err := OpenPgQuery("SELECT a.d FROM digits a CROSS JOIN numbers b", session, dataStore, metaStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.RunStatement(func() error {
+		_, err := cursor.Fetch(0)
+		return err
+	})
+	if sqlErr, ok := err.(*SQLError); !ok || sqlErr.SQLState() != SQLStateProgramLimitExceeded {
+		t.Errorf("streamed join: error = %v, want max_intermediate_rows exceeded", err)
+	}
+}
+
+// TestSetDefaultSetting checks that a server-wide default applies to new
+// sessions and is what RESET returns to
+func TestSetDefaultSetting(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	defer SetDefaultSetting("max_intermediate_rows", "0")
+
+	if err := SetDefaultSetting("max_intermediate_rows", "1000"); err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession()
+	for _, query := range []string{"SET max_intermediate_rows = 5", "RESET max_intermediate_rows"} {
+		if _, _, _, err := ExecutePgQuery(query, session, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+	if value, _ := session.Setting("max_intermediate_rows"); value != "1000" {
+		t.Errorf("max_intermediate_rows = %q after RESET, want the default 1000", value)
+	}
+
+	for _, setting := range [][2]string{{"max_intermediate_rows", "-1"}, {"server_encoding", "LATIN1"}, {"no_such_setting", "1"}} {
+		if err := SetDefaultSetting(setting[0], setting[1]); err == nil {
+			t.Errorf("SetDefaultSetting(%q, %q) should fail", setting[0], setting[1])
+		}
+	}
+}
+
+// TestSessionRegclass checks that names cast to regclass are found on the
+// session's search path, and that a name matching no relation is an error
+// rather than an OID that matches nothing
+func TestSessionRegclass(t *testing.T) {
+	dataStore := storage.NewDataStore()
+	metaStore := storage.NewMetaStore()
+	first, second := NewSession(), NewSession()
+
+	for _, query := range []string{"CREATE TABLE scratch (id int, note text)", "CREATE TEMP TABLE scratch (id int)"} {
+		if _, _, _, err := ExecutePgQuery(query, first, dataStore, metaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	query := "SELECT count(*) FROM pg_attribute WHERE attrelid = 'scratch'::regclass AND attnum > 0"
+	for _, tt := range []struct {
+		session *Session
+		want    int
+	}{
+		{first, 1},
+		{second, 2},
+	} {
+		_, rows, _, err := ExecutePgQuery(query, tt.session, dataStore, metaStore)
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if len(rows) != 1 || fmt.Sprint(rows[0][0]) != fmt.Sprint(tt.want) {
+			t.Errorf("got %v, want %d columns", rows, tt.want)
+		}
+	}
+
+	_, rows, _, err := ExecutePgQuery("SELECT 'public.scratch'::regclass, 'int4'::regtype, 'public'::regnamespace", first, dataStore, metaStore)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if got := fmt.Sprint(rows[0]...); got != "scratch integer public" {
+		t.Errorf("got %q", got)
+	}
+
+	_, _, _, err = ExecutePgQuery("SELECT 'missing'::regclass", first, dataStore, metaStore)
+	if sqlErr, ok := err.(*SQLError); !ok || sqlErr.SQLState() != SQLStateUndefinedTable {
+		t.Errorf("expected undefined_table error, got %v", err)
+	}
+}
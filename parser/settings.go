@@ -0,0 +1,725 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zones are available even without system zoneinfo
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// settingDefinition describes a configuration parameter
+type settingDefinition struct {
+	name        string // Name as SHOW reports it
+	value       string // Value a session starts with
+	description string
+	reported    bool // Changes are sent to the client in ParameterStatus
+	readOnly    bool // Fixed for the server; SET is refused
+	// normalize checks a new value and returns it in the form SHOW reports.
+	// nil accepts any value as given.
+	normalize func(value, current string) (string, error)
+}
+
+// settingDefinitions are the configuration parameters sessions know,
+// by lower-case name
+var settingDefinitions = map[string]*settingDefinition{}
+
+// ReportedSettings are the configuration parameters whose values the
+// server reports to clients when they connect and whenever they change
+var ReportedSettings []string
+
+func init() {
+	for _, def := range []*settingDefinition{
+		{name: "application_name", description: "Sets the application name to be reported in statistics and logs.", reported: true},
+		{name: "client_encoding", value: "UTF8", description: "Sets the client's character set encoding.", reported: true, normalize: normalizeEncoding},
+		{name: "client_min_messages", value: "notice", description: "Sets the message levels that are sent to the client.",
+			normalize: enumSetting("debug5", "debug4", "debug3", "debug2", "debug1", "log", "notice", "warning", "error")},
+		{name: "DateStyle", value: "ISO, MDY", description: "Sets the display format for date and time values.", reported: true, normalize: normalizeDateStyle},
+		{name: "default_transaction_isolation", value: "read committed", description: "Sets the transaction isolation level of each new transaction.",
+			normalize: enumSetting("serializable", "repeatable read", "read committed", "read uncommitted")},
+		{name: "default_transaction_read_only", value: "off", description: "Sets the default read-only status of new transactions.", reported: true, normalize: normalizeBool},
+		{name: "extra_float_digits", value: "1", description: "Sets the number of digits displayed for floating-point values.", normalize: integerSetting(-15, 3)},
+		{name: "idle_in_transaction_session_timeout", value: "0", description: "Sets the maximum allowed idle time between queries, when in a transaction.", normalize: normalizeMilliseconds},
+		{name: "in_hot_standby", value: "off", description: "Shows whether hot standby is currently active.", reported: true, readOnly: true},
+		{name: "integer_datetimes", value: "on", description: "Shows whether datetimes are integer based.", reported: true, readOnly: true},
+		{name: "IntervalStyle", value: "postgres", description: "Sets the display format for interval values.", reported: true, normalize: enumSetting("postgres")},
+		{name: "is_superuser", value: "on", description: "Shows whether the current user is a superuser.", reported: true, readOnly: true},
+		{name: "lock_timeout", value: "0", description: "Sets the maximum allowed duration of any wait for a lock.", normalize: normalizeMilliseconds},
+		{name: "max_identifier_length", value: "63", description: "Shows the maximum identifier length.", readOnly: true},
+		{name: "max_intermediate_rows", value: "0", description: "Sets the maximum number of intermediate rows a statement may build.", normalize: integerSetting(0, 2147483647)},
+		{name: "search_path", value: `"$user", public`, description: "Sets the schema search order for names that are not schema-qualified.", normalize: normalizeSearchPath},
+		{name: "server_encoding", value: "UTF8", description: "Shows the server (database) character set encoding.", reported: true, readOnly: true},
+		{name: "server_version", description: "Shows the server version.", reported: true, readOnly: true},
+		{name: "server_version_num", description: "Shows the server version as an integer.", readOnly: true},
+		{name: "session_authorization", description: "Sets the session user name.", reported: true, readOnly: true},
+		{name: "standard_conforming_strings", value: "on", description: "Causes '...' strings to treat backslashes literally.", reported: true, normalize: enumSetting("on")},
+		{name: "statement_memory_limit", value: "0", description: "Sets the maximum memory the intermediate results of a statement may use.", normalize: normalizeKilobytes},
+		{name: "statement_timeout", value: "0", description: "Sets the maximum allowed duration of any statement.", normalize: normalizeMilliseconds},
+		{name: "TimeZone", value: "UTC", description: "Sets the time zone for displaying and interpreting time stamps.", reported: true, normalize: normalizeTimeZone},
+		{name: "transaction_isolation", value: "read committed", description: "Sets the current transaction's isolation level.",
+			normalize: enumSetting("serializable", "repeatable read", "read committed", "read uncommitted")},
+	} {
+		settingDefinitions[strings.ToLower(def.name)] = def
+		if def.reported {
+			ReportedSettings = append(ReportedSettings, def.name)
+		}
+	}
+}
+
+// invalidSettingValue reports a value a parameter does not accept
+func invalidSettingValue(name, value string) error {
+	return newSQLError(SQLStateInvalidParameter, "invalid value for parameter \"%s\": \"%s\"", name, value)
+}
+
+// enumSetting accepts one of a fixed list of values, ignoring case
+func enumSetting(values ...string) func(value, current string) (string, error) {
+	return func(value, current string) (string, error) {
+		for _, allowed := range values {
+			if strings.EqualFold(value, allowed) {
+				return allowed, nil
+			}
+		}
+		return "", fmt.Errorf("invalid value")
+	}
+}
+
+// integerSetting accepts integers between min and max
+func integerSetting(min, max int) func(value, current string) (string, error) {
+	return func(value, current string) (string, error) {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < min || n > max {
+			return "", fmt.Errorf("invalid value")
+		}
+		return strconv.Itoa(n), nil
+	}
+}
+
+func normalizeBool(value, current string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "yes", "1", "t", "y":
+		return "on", nil
+	case "off", "false", "no", "0", "f", "n":
+		return "off", nil
+	}
+	return "", fmt.Errorf("invalid value")
+}
+
+func normalizeEncoding(value, current string) (string, error) {
+	switch strings.ToUpper(strings.ReplaceAll(value, "-", "")) {
+	case "UTF8", "UNICODE":
+		return "UTF8", nil
+	}
+	return "", fmt.Errorf("invalid value")
+}
+
+// durationUnits are the units time-valued parameters accept, in milliseconds
+var durationUnits = map[string]float64{
+	"us": 0.001, "ms": 1, "s": 1000, "min": 60000, "h": 3600000, "d": 86400000,
+}
+
+var durationPattern = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*([a-z]*)$`)
+
+// parseMilliseconds reads a duration in milliseconds, or with one of the
+// units PostgreSQL accepts
+func parseMilliseconds(value string) (int64, error) {
+	m := durationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("invalid value")
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	unit := 1.0
+	if m[2] != "" {
+		var ok bool
+		if unit, ok = durationUnits[m[2]]; !ok {
+			return 0, fmt.Errorf("invalid value")
+		}
+	}
+	ms := int64(n*unit + 0.5)
+	if ms < 0 || ms > 2147483647 {
+		return 0, fmt.Errorf("invalid value")
+	}
+	return ms, nil
+}
+
+// normalizeMilliseconds accepts a duration and reports it the way SHOW does
+func normalizeMilliseconds(value, current string) (string, error) {
+	ms, err := parseMilliseconds(value)
+	if err != nil {
+		return "", err
+	}
+	return formatMilliseconds(ms), nil
+}
+
+// formatMilliseconds uses the largest unit that divides a duration evenly,
+// as SHOW does: "0", "500ms", "5s", "2min"
+func formatMilliseconds(ms int64) string {
+	if ms == 0 {
+		return "0"
+	}
+	for _, unit := range []struct {
+		name string
+		ms   int64
+	}{{"d", 86400000}, {"h", 3600000}, {"min", 60000}, {"s", 1000}} {
+		if ms%unit.ms == 0 {
+			return fmt.Sprintf("%d%s", ms/unit.ms, unit.name)
+		}
+	}
+	return fmt.Sprintf("%dms", ms)
+}
+
+// memoryUnits are the units memory-valued parameters accept, in kilobytes
+var memoryUnits = map[string]int64{
+	"kB": 1, "MB": 1024, "GB": 1024 * 1024, "TB": 1024 * 1024 * 1024,
+}
+
+var memoryPattern = regexp.MustCompile(`^(\d+)\s*([a-zA-Z]*)$`)
+
+// parseKilobytes reads an amount of memory in kilobytes, or with one of the
+// units PostgreSQL accepts
+func parseKilobytes(value string) (int64, error) {
+	m := memoryPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("invalid value")
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value")
+	}
+	unit := int64(1)
+	if m[2] != "" {
+		var ok bool
+		if unit, ok = memoryUnits[m[2]]; !ok {
+			return 0, fmt.Errorf("invalid value")
+		}
+	}
+	kb := n * unit
+	if n > 2147483647 || kb > 2147483647 {
+		return 0, fmt.Errorf("invalid value")
+	}
+	return kb, nil
+}
+
+// normalizeKilobytes accepts an amount of memory and reports it the way
+// SHOW does
+func normalizeKilobytes(value, current string) (string, error) {
+	kb, err := parseKilobytes(value)
+	if err != nil {
+		return "", err
+	}
+	return formatKilobytes(kb), nil
+}
+
+// formatKilobytes uses the largest unit that divides an amount of memory
+// evenly, as SHOW does: "0", "512kB", "64MB", "1GB"
+func formatKilobytes(kb int64) string {
+	if kb == 0 {
+		return "0"
+	}
+	for _, unit := range []string{"TB", "GB", "MB"} {
+		if kb%memoryUnits[unit] == 0 {
+			return fmt.Sprintf("%d%s", kb/memoryUnits[unit], unit)
+		}
+	}
+	return fmt.Sprintf("%dkB", kb)
+}
+
+// normalizeDateStyle accepts an output style, a field order or both, and
+// keeps the current value of whichever part is not given
+func normalizeDateStyle(value, current string) (string, error) {
+	style, order := parseDateStyle(current)
+	for _, part := range strings.Split(value, ",") {
+		switch strings.ToUpper(strings.TrimSpace(part)) {
+		case "ISO":
+			style = "ISO"
+		case "SQL":
+			style = "SQL"
+		case "POSTGRES":
+			style = "Postgres"
+		case "GERMAN":
+			style, order = "German", "DMY"
+		case "MDY", "US", "NONEURO", "NONEUROPEAN":
+			order = "MDY"
+		case "DMY", "EURO", "EUROPEAN":
+			order = "DMY"
+		case "YMD":
+			order = "YMD"
+		case "DEFAULT":
+			style, order = "ISO", "MDY"
+		default:
+			return "", fmt.Errorf("invalid value")
+		}
+	}
+	return style + ", " + order, nil
+}
+
+// parseDateStyle splits a DateStyle value into its output style and order
+func parseDateStyle(value string) (style, order string) {
+	style, order = "ISO", "MDY"
+	if parts := strings.SplitN(value, ", ", 2); len(parts) == 2 {
+		style, order = parts[0], parts[1]
+	}
+	return style, order
+}
+
+// normalizeSearchPath accepts a comma-separated list of schema names and
+// quotes the ones that need it, as SHOW does
+func normalizeSearchPath(value, current string) (string, error) {
+	var schemas []string
+	for _, schema := range splitSearchPath(value) {
+		schemas = append(schemas, quoteIdentifier(schema))
+	}
+	return strings.Join(schemas, ", "), nil
+}
+
+// splitSearchPath returns the schema names of a search_path value
+func splitSearchPath(value string) []string {
+	var schemas []string
+	for _, schema := range strings.Split(value, ",") {
+		schema = strings.TrimSpace(schema)
+		if unquoted := strings.Trim(schema, `"`); unquoted != schema {
+			schema = unquoted
+		} else {
+			schema = strings.ToLower(schema)
+		}
+		if schema != "" {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+var simpleIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// quoteIdentifier double-quotes a name unless it is a plain lower-case
+// identifier
+func quoteIdentifier(name string) string {
+	if simpleIdentifier.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// normalizeTimeZone accepts a time zone name such as "Europe/Paris" or a
+// fixed offset from UTC. Offsets are reported the way PostgreSQL reports
+// them, e.g. "<+09>-09" for nine hours east of UTC.
+func normalizeTimeZone(value, current string) (string, error) {
+	if _, err := timeZoneLocation(value); err != nil {
+		return "", err
+	}
+	if hours, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+		return offsetZoneName(int(hours * 3600)), nil
+	}
+	if strings.EqualFold(value, "utc") || strings.EqualFold(value, "gmt") || strings.EqualFold(value, "z") {
+		return strings.ToUpper(value), nil
+	}
+	return value, nil
+}
+
+var offsetZonePattern = regexp.MustCompile(`^<([+-]\d\d(?::?\d\d)?)>([+-]?\d+)(?::(\d\d))?$`)
+
+// timeZoneLocation returns the location a TimeZone value names
+func timeZoneLocation(value string) (*time.Location, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToUpper(value) {
+	case "UTC", "GMT", "Z":
+		return time.UTC, nil
+	}
+	if hours, err := strconv.ParseFloat(value, 64); err == nil {
+		if hours < -15 || hours > 15 {
+			return nil, fmt.Errorf("invalid value")
+		}
+		offset := int(hours * 3600)
+		return time.FixedZone(offsetAbbrev(offset), offset), nil
+	}
+	if m := offsetZonePattern.FindStringSubmatch(value); m != nil {
+		// POSIX offsets count hours west of UTC
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		if hours < 0 {
+			minutes = -minutes
+		}
+		return time.FixedZone(m[1], -(hours*3600 + minutes*60)), nil
+	}
+	if value != "" && value != "Local" {
+		if loc, err := time.LoadLocation(value); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value")
+}
+
+// offsetAbbrev names a fixed offset east of UTC, such as "+09" or "-03:30"
+func offsetAbbrev(offset int) string {
+	s := "+"
+	if offset < 0 {
+		s = "-"
+		offset = -offset
+	}
+	s += fmt.Sprintf("%02d", offset/3600)
+	if minutes := offset % 3600 / 60; minutes != 0 {
+		s += fmt.Sprintf(":%02d", minutes)
+	}
+	return s
+}
+
+// offsetZoneName is the POSIX-style name PostgreSQL reports for a fixed
+// offset east of UTC
+func offsetZoneName(offset int) string {
+	abbrev := offsetAbbrev(offset)
+	posix := offsetAbbrev(-offset)
+	if strings.HasPrefix(posix, "+") {
+		posix = posix[1:]
+	}
+	return "<" + abbrev + ">" + posix
+}
+
+// Setting returns the value of a configuration parameter in the session.
+// Parameter names are case-insensitive.
+func (s *Session) Setting(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch name {
+	case "server_version":
+		return ServerVersion(), true
+	case "server_version_num":
+		return strconv.Itoa(ServerVersionNum()), true
+	case "session_authorization":
+		return s.User(), true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, ok := s.settings[name]; ok {
+		return value, true
+	}
+	if def, ok := settingDefinitions[name]; ok {
+		return def.value, true
+	}
+	return "", false
+}
+
+// SetSetting sets a configuration parameter the client gave when it
+// connected; RESET returns the parameter to this value. Values are not
+// checked.
+func (s *Session) SetSetting(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[strings.ToLower(name)] = value
+	s.startupSettings[strings.ToLower(name)] = value
+}
+
+// changeSetting checks and applies a SET. Changes inside a transaction
+// block are undone if it rolls back, and SET LOCAL changes when it ends.
+func (s *Session) changeSetting(name, value string, isDefault, local bool) error {
+	key := strings.ToLower(name)
+	def, known := settingDefinitions[key]
+	if !known && !strings.Contains(key, ".") {
+		return newSQLError(SQLStateUndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+	}
+	if known && def.readOnly {
+		return newSQLError(SQLStateCantChangeRuntimeParam, "parameter \"%s\" cannot be changed", def.name)
+	}
+	if isDefault {
+		value = s.defaultSetting(key)
+	} else if known && def.normalize != nil {
+		current, _ := s.Setting(key)
+		normalized, err := def.normalize(value, current)
+		if err != nil {
+			return invalidSettingValue(def.name, value)
+		}
+		value = normalized
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != TransactionIdle {
+		s.saveSetting(s.txnSettings, key)
+		if local {
+			s.saveSetting(s.localSettings, key)
+		}
+	} else if local {
+		// SET LOCAL outside a transaction block has no effect
+		return nil
+	}
+	if isDefault && !known {
+		delete(s.settings, key)
+	} else {
+		s.settings[key] = value
+	}
+	return nil
+}
+
+// defaultSetting returns the value RESET gives a parameter: the one the
+// client connected with, or else the parameter's default
+func (s *Session) defaultSetting(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, ok := s.startupSettings[key]; ok {
+		return value
+	}
+	if def, ok := settingDefinitions[key]; ok {
+		return def.value
+	}
+	return ""
+}
+
+// saveSetting remembers a setting's value before its first change, so the
+// change can be undone. Callers must hold the lock.
+func (s *Session) saveSetting(saved map[string]*string, key string) {
+	if _, done := saved[key]; done {
+		return
+	}
+	if value, ok := s.settings[key]; ok {
+		saved[key] = &value
+	} else {
+		saved[key] = nil
+	}
+}
+
+// restoreSettings undoes the changes recorded in saved. Callers must hold
+// the lock.
+func (s *Session) restoreSettings(saved map[string]*string) {
+	for key, value := range saved {
+		if value == nil {
+			delete(s.settings, key)
+		} else {
+			s.settings[key] = *value
+		}
+	}
+}
+
+// resetSettings returns every setting the session changed to its default,
+// keeping those the client gave when it connected
+func (s *Session) resetSettings() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.settings {
+		if s.status != TransactionIdle {
+			s.saveSetting(s.txnSettings, key)
+		}
+		if value, ok := s.startupSettings[key]; ok {
+			s.settings[key] = value
+		} else {
+			delete(s.settings, key)
+		}
+	}
+}
+
+// ParameterChanges returns the reported parameters whose values changed
+// since they were last reported, as name and value pairs, and records them
+// as reported. The first call returns every reported parameter.
+func (s *Session) ParameterChanges() [][2]string {
+	var changes [][2]string
+	for _, name := range ReportedSettings {
+		value, _ := s.Setting(name)
+		s.mu.Lock()
+		last, reported := s.reportedSettings[name]
+		if !reported || last != value {
+			s.reportedSettings[name] = value
+			changes = append(changes, [2]string{name, value})
+		}
+		s.mu.Unlock()
+	}
+	return changes
+}
+
+// location returns the session's time zone
+func (s *Session) location() *time.Location {
+	value, _ := s.Setting("TimeZone")
+	if loc, err := timeZoneLocation(value); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// statementTimeout returns how long a statement may run, or 0 for no limit
+func (s *Session) statementTimeout() time.Duration {
+	value, _ := s.Setting("statement_timeout")
+	ms, err := parseMilliseconds(value)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// maxIntermediateRows returns how many intermediate rows a statement may
+// build, or 0 for no limit
+func (s *Session) maxIntermediateRows() int {
+	value, _ := s.Setting("max_intermediate_rows")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// statementMemoryLimit returns how many bytes a statement's intermediate
+// results may use, or 0 for no limit
+func (s *Session) statementMemoryLimit() int64 {
+	value, _ := s.Setting("statement_memory_limit")
+	kb, err := parseKilobytes(value)
+	if err != nil {
+		return 0
+	}
+	return kb * 1024
+}
+
+// SetDefaultSetting changes the value sessions start with and RESET
+// returns to, as a setting in postgresql.conf does. It is meant to be
+// called before any session starts.
+func SetDefaultSetting(name, value string) error {
+	def, ok := settingDefinitions[strings.ToLower(name)]
+	if !ok {
+		return newSQLError(SQLStateUndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+	}
+	if def.readOnly {
+		return newSQLError(SQLStateCantChangeRuntimeParam, "parameter \"%s\" cannot be changed", def.name)
+	}
+	if def.normalize != nil {
+		normalized, err := def.normalize(value, def.value)
+		if err != nil {
+			return invalidSettingValue(def.name, value)
+		}
+		value = normalized
+	}
+	def.value = value
+	return nil
+}
+
+// localizeRow converts date and time values in a result row for display in
+// the session: timestamps with time zone move to its time zone, and a
+// DateStyle other than ISO turns dates and timestamps into text
+func (s *Session) localizeRow(row []interface{}) []interface{} {
+	loc := s.location()
+	dateStyle, _ := s.Setting("DateStyle")
+	style, order := parseDateStyle(dateStyle)
+
+	var localized []interface{}
+	for i, value := range row {
+		converted, changed := value, false
+		if t, ok := value.(storage.TimestampTZ); ok && loc != time.UTC {
+			converted, changed = storage.TimestampTZ{Time: t.In(loc)}, true
+		}
+		if style != "ISO" {
+			if text, ok := storage.FormatDateStyle(converted, style, order); ok {
+				converted, changed = text, true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if localized == nil {
+			localized = append([]interface{}(nil), row...)
+		}
+		localized[i] = converted
+	}
+	if localized == nil {
+		return row
+	}
+	return localized
+}
+
+// executePgVariableSet handles SET and RESET
+func executePgVariableSet(stmt *pg_query.VariableSetStmt, session *Session) ([]string, [][]interface{}, string, error) {
+	switch stmt.Kind {
+	case pg_query.VariableSetKind_VAR_SET_VALUE:
+		value, err := settingValue(stmt.Name, stmt.Args)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if err := session.changeSetting(stmt.Name, value, false, stmt.IsLocal); err != nil {
+			return nil, nil, "", err
+		}
+		return nil, nil, "SET", nil
+	case pg_query.VariableSetKind_VAR_SET_DEFAULT:
+		if err := session.changeSetting(stmt.Name, "", true, stmt.IsLocal); err != nil {
+			return nil, nil, "", err
+		}
+		return nil, nil, "SET", nil
+	case pg_query.VariableSetKind_VAR_RESET:
+		if err := session.changeSetting(stmt.Name, "", true, false); err != nil {
+			return nil, nil, "", err
+		}
+		return nil, nil, "RESET", nil
+	case pg_query.VariableSetKind_VAR_RESET_ALL:
+		session.resetSettings()
+		return nil, nil, "RESET", nil
+	default:
+		// SET TRANSACTION and SET ... FROM CURRENT have nothing to change
+		return nil, nil, "SET", nil
+	}
+}
+
+// settingValue returns the text of the value a SET gives. A list, as in
+// SET search_path TO a, b, is joined with commas.
+func settingValue(name string, args []*pg_query.Node) (string, error) {
+	var parts []string
+	for _, arg := range args {
+		node := arg
+		var typeName string
+		if cast, ok := node.Node.(*pg_query.Node_TypeCast); ok {
+			node, typeName = cast.TypeCast.Arg, typeNameString(cast.TypeCast.TypeName)
+		}
+		aConst, ok := node.Node.(*pg_query.Node_AConst)
+		if !ok {
+			return "", newSQLError(SQLStateSyntaxError, "syntax error at or near \"%s\"", name)
+		}
+		var part string
+		switch val := aConst.AConst.Val.(type) {
+		case *pg_query.A_Const_Sval:
+			part = val.Sval.Sval
+		case *pg_query.A_Const_Ival:
+			part = strconv.Itoa(int(val.Ival.Ival))
+		case *pg_query.A_Const_Fval:
+			part = val.Fval.Fval
+		case *pg_query.A_Const_Boolval:
+			part = "off"
+			if val.Boolval.Boolval {
+				part = "on"
+			}
+		}
+		if typeName == "interval" {
+			// SET TIME ZONE INTERVAL '+05:30' HOUR TO MINUTE
+			iv, err := storage.ParseInterval(part)
+			if err != nil {
+				return "", err
+			}
+			part = strconv.FormatFloat(float64(iv.Micros)/float64(storage.MicrosPerHour), 'f', -1, 64)
+		}
+		if strings.EqualFold(name, "search_path") {
+			part = quoteIdentifier(part)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// executePgVariableShow handles SHOW name and SHOW ALL
+func executePgVariableShow(stmt *pg_query.VariableShowStmt, session *Session) ([]string, [][]interface{}, string, error) {
+	if strings.EqualFold(stmt.Name, "all") {
+		var names []string
+		for _, def := range settingDefinitions {
+			names = append(names, def.name)
+		}
+		sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+		rows := make([][]interface{}, len(names))
+		for i, name := range names {
+			value, _ := session.Setting(name)
+			rows[i] = []interface{}{name, value, settingDefinitions[strings.ToLower(name)].description}
+		}
+		return []string{"name", "setting", "description"}, rows, "SHOW", nil
+	}
+
+	value, ok := session.Setting(stmt.Name)
+	if !ok {
+		return nil, nil, "", newSQLError(SQLStateUndefinedObject, "unrecognized configuration parameter \"%s\"", stmt.Name)
+	}
+	column := strings.ToLower(stmt.Name)
+	if def, known := settingDefinitions[column]; known {
+		column = def.name
+	}
+	return []string{column}, [][]interface{}{{value}}, "SHOW", nil
+}
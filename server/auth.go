@@ -0,0 +1,264 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AuthMethod is how clients prove their identity at startup, named as in
+// pg_hba.conf
+type AuthMethod string
+
+const (
+	AuthTrust       AuthMethod = "trust"         // No password is asked for
+	AuthPassword    AuthMethod = "password"      // Cleartext password
+	AuthMD5         AuthMethod = "md5"           // Password hashed with the user name and a salt
+	AuthSCRAMSHA256 AuthMethod = "scram-sha-256" // SASL exchange that never sends the password
+)
+
+// ParseAuthMethod returns the authentication method with the given name
+func ParseAuthMethod(name string) (AuthMethod, error) {
+	switch method := AuthMethod(strings.ToLower(name)); method {
+	case AuthTrust, AuthPassword, AuthMD5, AuthSCRAMSHA256:
+		return method, nil
+	}
+	return "", fmt.Errorf("unknown authentication method \"%s\"", name)
+}
+
+// Auth configures the credentials clients must present. With any method
+// but trust, clients can only connect as User, with Password.
+type Auth struct {
+	Method   AuthMethod
+	User     string
+	Password string
+}
+
+// scramIterations is the PBKDF2 iteration count PostgreSQL uses by default
+const scramIterations = 4096
+
+// scramCredentials are the keys a SCRAM-SHA-256 exchange is checked with,
+// derived from the password once
+type scramCredentials struct {
+	salt      []byte
+	storedKey []byte
+	serverKey []byte
+}
+
+func newSCRAMCredentials(password string) (*scramCredentials, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	salted, err := pbkdf2.Key(sha256.New, password, salt, scramIterations, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	return &scramCredentials{
+		salt:      salt,
+		storedKey: storedKey[:],
+		serverKey: hmacSHA256(salted, "Server Key"),
+	}, nil
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// authError is an error ending the startup of a connection
+type authError struct {
+	code    string
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
+// SQLState returns the error's PostgreSQL error code
+func (e *authError) SQLState() string {
+	return e.code
+}
+
+// passwordFailed is the error for a wrong user or password. It names the
+// user either way, so clients cannot tell which was wrong.
+func passwordFailed(user string) error {
+	return &authError{"28P01", fmt.Sprintf("password authentication failed for user \"%s\"", user)}
+}
+
+// protocolViolation is the error for an unexpected authentication message
+func protocolViolation(format string, args ...interface{}) error {
+	return &authError{"08P01", fmt.Sprintf(format, args...)}
+}
+
+// SetAuth makes clients authenticate with the given method and credentials
+func (s *Server) SetAuth(auth Auth) error {
+	if auth.Method != AuthTrust && auth.Password == "" {
+		return fmt.Errorf("authentication method %s needs a password", auth.Method)
+	}
+	s.auth = auth
+	s.scram = nil
+	if auth.Method == AuthSCRAMSHA256 {
+		scram, err := newSCRAMCredentials(auth.Password)
+		if err != nil {
+			return err
+		}
+		s.scram = scram
+	}
+	return nil
+}
+
+// authenticate runs the exchange of the configured method with a client
+// connecting as user. A returned error is sent to the client as FATAL.
+func (s *Server) authenticate(reader io.Reader, writer io.Writer, flush func() error, user string) error {
+	// readPassword sends a request and reads the client's PasswordMessage
+	readPassword := func(request func() error) ([]byte, error) {
+		if err := request(); err != nil {
+			return nil, err
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		msg, err := ReadMessage(reader)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Type != PasswordMessage {
+			return nil, protocolViolation("expected password response, got message type %d", msg.Type)
+		}
+		return msg.Data, nil
+	}
+
+	switch s.auth.Method {
+	case AuthPassword:
+		data, err := readPassword(func() error { return WriteAuthenticationCleartextPassword(writer) })
+		if err != nil {
+			return err
+		}
+		password := string(bytes.TrimSuffix(data, []byte{0}))
+		if user != s.auth.User || subtle.ConstantTimeCompare([]byte(password), []byte(s.auth.Password)) != 1 {
+			return passwordFailed(user)
+		}
+	case AuthMD5:
+		var salt [4]byte
+		rand.Read(salt[:])
+		data, err := readPassword(func() error { return WriteAuthenticationMD5Password(writer, salt) })
+		if err != nil {
+			return err
+		}
+		response := bytes.TrimSuffix(data, []byte{0})
+		if user != s.auth.User || subtle.ConstantTimeCompare(response, []byte(md5Password(s.auth.User, s.auth.Password, salt))) != 1 {
+			return passwordFailed(user)
+		}
+	case AuthSCRAMSHA256:
+		if err := s.authenticateSCRAM(readPassword, writer, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// md5Password returns the response to an MD5 password request:
+// "md5" followed by md5(md5(password + user) + salt) in hex
+func md5Password(user, password string, salt [4]byte) string {
+	inner := md5.Sum([]byte(password + user))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt[:]...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// authenticateSCRAM runs a SCRAM-SHA-256 exchange (RFC 5802, RFC 7677).
+// Channel binding is not offered.
+func (s *Server) authenticateSCRAM(readPassword func(func() error) ([]byte, error), writer io.Writer, user string) error {
+	data, err := readPassword(func() error { return WriteAuthenticationSASL(writer, []string{"SCRAM-SHA-256"}) })
+	if err != nil {
+		return err
+	}
+
+	// SASLInitialResponse: mechanism, then the length-prefixed client-first-message
+	end := bytes.IndexByte(data, 0)
+	if end < 0 || len(data) < end+5 {
+		return protocolViolation("malformed SASL initial response")
+	}
+	if mechanism := string(data[:end]); mechanism != "SCRAM-SHA-256" {
+		return protocolViolation("client selected an invalid SASL authentication mechanism")
+	}
+	length := int32(binary.BigEndian.Uint32(data[end+1 : end+5]))
+	clientFirst := string(data[end+5:])
+	if length < 0 || int(length) != len(clientFirst) {
+		return protocolViolation("malformed SASL initial response")
+	}
+
+	// client-first-message: gs2-header "n,," or "y,,", then n=user,r=nonce
+	if !strings.HasPrefix(clientFirst, "n,,") && !strings.HasPrefix(clientFirst, "y,,") {
+		return protocolViolation("unsupported SCRAM channel binding in \"%s\"", clientFirst)
+	}
+	clientFirstBare := clientFirst[3:]
+	clientNonce := scramAttribute(clientFirstBare, 'r')
+	if clientNonce == "" {
+		return protocolViolation("malformed SCRAM message: missing nonce")
+	}
+
+	serverNonce := make([]byte, 18)
+	rand.Read(serverNonce)
+	nonce := clientNonce + base64.StdEncoding.EncodeToString(serverNonce)
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d", nonce, base64.StdEncoding.EncodeToString(s.scram.salt), scramIterations)
+	data, err = readPassword(func() error { return WriteAuthenticationSASLContinue(writer, []byte(serverFirst)) })
+	if err != nil {
+		return err
+	}
+
+	// client-final-message: c=binding,r=nonce,p=proof
+	clientFinal := string(data)
+	proofAt := strings.LastIndex(clientFinal, ",p=")
+	if proofAt < 0 {
+		return protocolViolation("malformed SCRAM message: missing proof")
+	}
+	if scramAttribute(clientFinal, 'c') != base64.StdEncoding.EncodeToString([]byte(clientFirst[:3])) {
+		return protocolViolation("SCRAM channel binding check failed")
+	}
+	if scramAttribute(clientFinal, 'r') != nonce {
+		return protocolViolation("SCRAM nonce does not match")
+	}
+	proof, err := base64.StdEncoding.DecodeString(clientFinal[proofAt+3:])
+	if err != nil || len(proof) != sha256.Size {
+		return protocolViolation("malformed SCRAM proof")
+	}
+
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal[:proofAt]
+	clientSignature := hmacSHA256(s.scram.storedKey, authMessage)
+	clientKey := make([]byte, sha256.Size)
+	for i := range clientKey {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+	storedKey := sha256.Sum256(clientKey)
+	if user != s.auth.User || subtle.ConstantTimeCompare(storedKey[:], s.scram.storedKey) != 1 {
+		return passwordFailed(user)
+	}
+
+	serverSignature := hmacSHA256(s.scram.serverKey, authMessage)
+	return WriteAuthenticationSASLFinal(writer, []byte("v="+base64.StdEncoding.EncodeToString(serverSignature)))
+}
+
+// scramAttribute returns the value of an attribute of a SCRAM message
+func scramAttribute(message string, name byte) string {
+	for _, attr := range strings.Split(message, ",") {
+		if len(attr) >= 2 && attr[0] == name && attr[1] == '=' {
+			return attr[2:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// authClient answers the authentication requests of authenticate the way
+// a client with the given password would, and returns the request codes
+// it received
+func authClient(t *testing.T, conn net.Conn, user, password string) []int32 {
+	t.Helper()
+	var codes []int32
+	var clientFirstBare, serverSignature string
+	for {
+		msg, err := ReadMessage(conn)
+		if err != nil {
+			return codes
+		}
+		if msg.Type != AuthenticationOk {
+			t.Errorf("unexpected message type %c", msg.Type)
+			return codes
+		}
+		code := int32(binary.BigEndian.Uint32(msg.Data[:4]))
+		codes = append(codes, code)
+		data := msg.Data[4:]
+
+		var response []byte
+		switch code {
+		case authCleartextPassword:
+			response = append([]byte(password), 0)
+		case authMD5Password:
+			var salt [4]byte
+			copy(salt[:], data)
+			response = append([]byte(md5Password(user, password, salt)), 0)
+		case authSASL:
+			clientFirstBare = "n=,r=clientnonce"
+			first := "n,," + clientFirstBare
+			var buf bytes.Buffer
+			buf.WriteString("SCRAM-SHA-256\x00")
+			binary.Write(&buf, binary.BigEndian, int32(len(first)))
+			buf.WriteString(first)
+			response = buf.Bytes()
+		case authSASLContinue:
+			serverFirst := string(data)
+			salt, _ := base64.StdEncoding.DecodeString(scramAttribute(serverFirst, 's'))
+			iterations, _ := strconv.Atoi(scramAttribute(serverFirst, 'i'))
+			salted, _ := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+			clientKey := hmacSHA256(salted, "Client Key")
+			storedKey := sha256.Sum256(clientKey)
+			withoutProof := "c=biws,r=" + scramAttribute(serverFirst, 'r')
+			authMessage := clientFirstBare + "," + serverFirst + "," + withoutProof
+			signature := hmacSHA256(storedKey[:], authMessage)
+			proof := make([]byte, len(clientKey))
+			for i := range proof {
+				proof[i] = clientKey[i] ^ signature[i]
+			}
+			serverSignature = "v=" + base64.StdEncoding.EncodeToString(hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage))
+			response = []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof))
+		case authSASLFinal:
+			if string(data) != serverSignature {
+				t.Errorf("server signature = %q, want %q", data, serverSignature)
+			}
+			continue
+		}
+		if err := WriteMessage(conn, PasswordMessage, response); err != nil {
+			return codes
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		method   AuthMethod
+		user     string
+		password string
+		codes    []int32
+		wantErr  bool
+	}{
+		{AuthTrust, "anyone", "", nil, false},
+		{AuthPassword, "app", "s3cret", []int32{authCleartextPassword}, false},
+		{AuthPassword, "app", "wrong", []int32{authCleartextPassword}, true},
+		{AuthMD5, "app", "s3cret", []int32{authMD5Password}, false},
+		{AuthMD5, "other", "s3cret", []int32{authMD5Password}, true},
+		{AuthSCRAMSHA256, "app", "s3cret", []int32{authSASL, authSASLContinue, authSASLFinal}, false},
+		{AuthSCRAMSHA256, "app", "wrong", []int32{authSASL, authSASLContinue}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method)+"/"+tt.user+"/"+tt.password, func(t *testing.T) {
+			s := New(0, nil)
+			if err := s.SetAuth(Auth{Method: tt.method, User: "app", Password: "s3cret"}); err != nil {
+				t.Fatal(err)
+			}
+
+			serverConn, clientConn := net.Pipe()
+			codes := make(chan []int32)
+			go func() {
+				codes <- authClient(t, clientConn, tt.user, tt.password)
+			}()
+
+			writer := bufio.NewWriter(serverConn)
+			err := s.authenticate(serverConn, writer, writer.Flush, tt.user)
+			writer.Flush()
+			serverConn.Close()
+			got := <-codes
+
+			if tt.wantErr {
+				var coded interface{ SQLState() string }
+				if !errors.As(err, &coded) || coded.SQLState() != "28P01" {
+					t.Errorf("error = %v, want 28P01", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.codes) {
+				t.Errorf("requests = %v, want %v", got, tt.codes)
+			}
+		})
+	}
+}
+
+func TestSetAuthNeedsPassword(t *testing.T) {
+	if err := New(0, nil).SetAuth(Auth{Method: AuthMD5, User: "app"}); err == nil {
+		t.Error("SetAuth should refuse a password method without a password")
+	}
+}
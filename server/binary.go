@@ -0,0 +1,464 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+// Binary format codes, as sent in Bind for parameters and results
+const (
+	FormatText   int16 = 0
+	FormatBinary int16 = 1
+)
+
+// postgresEpoch is the zero point of binary dates and timestamps
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// formatCode returns the format of value i given a Bind message's format
+// codes: none means all text, a single code applies to every value, and
+// otherwise there is one code per value
+func formatCode(codes []int16, i int) int16 {
+	switch {
+	case len(codes) == 0:
+		return FormatText
+	case len(codes) == 1:
+		return codes[0]
+	case i < len(codes):
+		return codes[i]
+	}
+	return FormatText
+}
+
+// valueText renders a value in PostgreSQL's text format
+func valueText(value interface{}) string {
+	return fmt.Sprintf("%v", value)
+}
+
+// encodeBinary encodes a value in the binary format of the type oid.
+// Values of another type are converted through their text form.
+func encodeBinary(value interface{}, oid int32) ([]byte, error) {
+	var buf bytes.Buffer
+	text := valueText(value)
+	invalid := func() error {
+		return fmt.Errorf("cannot send %q in binary as type OID %d", text, oid)
+	}
+
+	if elemOID, ok := arrayElementOIDs[oid]; ok {
+		array, isArray := value.(storage.Array)
+		if !isArray {
+			elems, err := storage.ParseArrayText(text)
+			if err != nil {
+				return nil, err
+			}
+			array = storage.Array{Elems: elems}
+		}
+		return encodeBinaryArray(array, elemOID)
+	}
+
+	switch oid {
+	case OIDBool:
+		b, ok := value.(bool)
+		if !ok {
+			parsed, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, invalid()
+			}
+			b = parsed
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case OIDInt2, OIDInt4, OIDInt8:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		switch oid {
+		case OIDInt2:
+			binary.Write(&buf, binary.BigEndian, int16(n))
+		case OIDInt4:
+			binary.Write(&buf, binary.BigEndian, int32(n))
+		default:
+			binary.Write(&buf, binary.BigEndian, n)
+		}
+	case OIDFloat4, OIDFloat8:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		if oid == OIDFloat4 {
+			binary.Write(&buf, binary.BigEndian, math.Float32bits(float32(f)))
+		} else {
+			binary.Write(&buf, binary.BigEndian, math.Float64bits(f))
+		}
+	case OIDNumeric:
+		return encodeBinaryNumeric(text)
+	case OIDJSONB:
+		// jsonb is its text prefixed with a version number
+		buf.WriteByte(1)
+		buf.WriteString(text)
+	case OIDDate:
+		d, err := storage.ParseDate(text)
+		if err != nil {
+			return nil, err
+		}
+		days := d.Sub(postgresEpoch).Hours() / 24
+		binary.Write(&buf, binary.BigEndian, int32(math.Floor(days)))
+	case OIDTime:
+		t, err := storage.ParseTime(text)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.BigEndian, t.Micros)
+	case OIDTimestamp, OIDTimestampTZ:
+		var t time.Time
+		if oid == OIDTimestamp {
+			ts, err := storage.ParseTimestamp(text)
+			if err != nil {
+				return nil, err
+			}
+			t = ts.Time
+		} else {
+			ts, err := storage.ParseTimestampTZ(text)
+			if err != nil {
+				return nil, err
+			}
+			t = ts.Time
+		}
+		binary.Write(&buf, binary.BigEndian, t.Sub(postgresEpoch).Microseconds())
+	case OIDUUID:
+		u, ok := value.(storage.UUID)
+		if !ok {
+			parsed, err := storage.ParseUUID(text)
+			if err != nil {
+				return nil, err
+			}
+			u = parsed
+		}
+		buf.Write(u[:])
+	case OIDBytea:
+		b, ok := value.(storage.Bytea)
+		if !ok {
+			parsed, err := storage.ParseBytea(text)
+			if err != nil {
+				return nil, err
+			}
+			b = parsed
+		}
+		buf.WriteString(string(b))
+	case OIDInterval:
+		iv, ok := value.(storage.Interval)
+		if !ok {
+			parsed, err := storage.ParseInterval(text)
+			if err != nil {
+				return nil, err
+			}
+			iv = parsed
+		}
+		binary.Write(&buf, binary.BigEndian, iv.Micros)
+		binary.Write(&buf, binary.BigEndian, int32(iv.Days))
+		binary.Write(&buf, binary.BigEndian, int32(iv.Months))
+	default:
+		// Text types, and types without a binary form here, send their text
+		buf.WriteString(text)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeBinaryArray encodes a one-dimensional array: the number of
+// dimensions, a NULL flag, the element type, the dimension's length and
+// lower bound, then each element prefixed by its length
+func encodeBinaryArray(array storage.Array, elemOID int32) ([]byte, error) {
+	var buf bytes.Buffer
+	hasNull := int32(0)
+	for _, elem := range array.Elems {
+		if elem == nil {
+			hasNull = 1
+		}
+	}
+	if len(array.Elems) == 0 {
+		binary.Write(&buf, binary.BigEndian, int32(0))
+		binary.Write(&buf, binary.BigEndian, hasNull)
+		binary.Write(&buf, binary.BigEndian, elemOID)
+		return buf.Bytes(), nil
+	}
+	binary.Write(&buf, binary.BigEndian, int32(1))
+	binary.Write(&buf, binary.BigEndian, hasNull)
+	binary.Write(&buf, binary.BigEndian, elemOID)
+	binary.Write(&buf, binary.BigEndian, int32(len(array.Elems)))
+	binary.Write(&buf, binary.BigEndian, int32(1))
+	for _, elem := range array.Elems {
+		if elem == nil {
+			binary.Write(&buf, binary.BigEndian, int32(-1))
+			continue
+		}
+		data, err := encodeBinary(elem, elemOID)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.BigEndian, int32(len(data)))
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// Signs of a binary numeric
+const (
+	numericPositive = 0x0000
+	numericNegative = 0x4000
+	numericNaN      = 0xC000
+)
+
+// encodeBinaryNumeric encodes a decimal as PostgreSQL does: base-10000
+// digits, the weight of the first digit, the sign and the display scale
+func encodeBinaryNumeric(text string) ([]byte, error) {
+	var buf bytes.Buffer
+	if strings.EqualFold(text, "NaN") {
+		binary.Write(&buf, binary.BigEndian, []int16{0, 0})
+		binary.Write(&buf, binary.BigEndian, uint16(numericNaN))
+		binary.Write(&buf, binary.BigEndian, int16(0))
+		return buf.Bytes(), nil
+	}
+	n, err := storage.ParseNumeric(text)
+	if err != nil {
+		return nil, err
+	}
+	s := n.String()
+	sign := uint16(numericPositive)
+	if strings.HasPrefix(s, "-") {
+		sign = numericNegative
+		s = s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+
+	// Pad both parts to whole base-10000 digits around the decimal point
+	if pad := len(intPart) % 4; pad != 0 {
+		intPart = strings.Repeat("0", 4-pad) + intPart
+	}
+	frac := fracPart
+	if pad := len(frac) % 4; pad != 0 {
+		frac += strings.Repeat("0", 4-pad)
+	}
+	all := intPart + frac
+	digits := make([]int16, 0, len(all)/4)
+	for i := 0; i < len(all); i += 4 {
+		d, _ := strconv.Atoi(all[i : i+4])
+		digits = append(digits, int16(d))
+	}
+	weight := len(intPart)/4 - 1
+
+	// Leading and trailing zero digits are implied by the weight and scale
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+		weight--
+	}
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+	if len(digits) == 0 {
+		weight = 0
+		sign = numericPositive
+	}
+
+	binary.Write(&buf, binary.BigEndian, int16(len(digits)))
+	binary.Write(&buf, binary.BigEndian, int16(weight))
+	binary.Write(&buf, binary.BigEndian, sign)
+	binary.Write(&buf, binary.BigEndian, int16(len(fracPart)))
+	binary.Write(&buf, binary.BigEndian, digits)
+	return buf.Bytes(), nil
+}
+
+// decodeBinary decodes a parameter sent in the binary format of the type
+// oid and returns its PostgreSQL text form
+func decodeBinary(data []byte, oid int32) (string, error) {
+	invalid := fmt.Errorf("incorrect binary data format in bind parameter of type OID %d", oid)
+	r := bytes.NewReader(data)
+	read := func(v interface{}) bool {
+		return binary.Read(r, binary.BigEndian, v) == nil
+	}
+
+	if elemOID, ok := arrayElementOIDs[oid]; ok {
+		return decodeBinaryArray(r, elemOID, invalid)
+	}
+
+	switch oid {
+	case OIDBool:
+		if len(data) != 1 {
+			return "", invalid
+		}
+		return strconv.FormatBool(data[0] != 0), nil
+	case OIDInt2, OIDInt4, OIDInt8:
+		// Drivers may send any integer width for an integer parameter
+		switch len(data) {
+		case 2:
+			return strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(data))), 10), nil
+		case 4:
+			return strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(data))), 10), nil
+		case 8:
+			return strconv.FormatInt(int64(binary.BigEndian.Uint64(data)), 10), nil
+		}
+		return "", invalid
+	case OIDFloat4:
+		if len(data) != 4 {
+			return "", invalid
+		}
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(data))), 'g', -1, 32), nil
+	case OIDFloat8:
+		if len(data) != 8 {
+			return "", invalid
+		}
+		return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(data)), 'g', -1, 64), nil
+	case OIDNumeric:
+		return decodeBinaryNumeric(r, invalid)
+	case OIDJSONB:
+		if len(data) == 0 || data[0] != 1 {
+			return "", invalid
+		}
+		return string(data[1:]), nil
+	case OIDDate:
+		var days int32
+		if !read(&days) {
+			return "", invalid
+		}
+		return storage.DateOf(postgresEpoch.AddDate(0, 0, int(days))).String(), nil
+	case OIDTime:
+		var micros int64
+		if !read(&micros) {
+			return "", invalid
+		}
+		return storage.TimeOfDay{Micros: micros}.String(), nil
+	case OIDTimestamp, OIDTimestampTZ:
+		var micros int64
+		if !read(&micros) {
+			return "", invalid
+		}
+		t := postgresEpoch.Add(time.Duration(micros) * time.Microsecond)
+		if oid == OIDTimestamp {
+			return storage.NewTimestamp(t).String(), nil
+		}
+		return storage.NewTimestampTZ(t).String(), nil
+	case OIDUUID:
+		var u storage.UUID
+		if len(data) != len(u) {
+			return "", invalid
+		}
+		copy(u[:], data)
+		return u.String(), nil
+	case OIDBytea:
+		return storage.Bytea(data).String(), nil
+	case OIDInterval:
+		var micros int64
+		var days, months int32
+		if !read(&micros) || !read(&days) || !read(&months) {
+			return "", invalid
+		}
+		return storage.Interval{Months: int(months), Days: int(days), Micros: micros}.String(), nil
+	}
+	// Text types send their text
+	return string(data), nil
+}
+
+// decodeBinaryArray decodes a one-dimensional binary array into an array
+// literal such as {1,NULL,3}
+func decodeBinaryArray(r *bytes.Reader, elemOID int32, invalid error) (string, error) {
+	var ndim, hasNull, elemType int32
+	if binary.Read(r, binary.BigEndian, &ndim) != nil || binary.Read(r, binary.BigEndian, &hasNull) != nil ||
+		binary.Read(r, binary.BigEndian, &elemType) != nil {
+		return "", invalid
+	}
+	if ndim == 0 {
+		return "{}", nil
+	}
+	if ndim != 1 {
+		return "", fmt.Errorf("multidimensional arrays are not supported")
+	}
+	var length, lowerBound int32
+	if binary.Read(r, binary.BigEndian, &length) != nil || binary.Read(r, binary.BigEndian, &lowerBound) != nil || length < 0 {
+		return "", invalid
+	}
+	elems := make([]interface{}, length)
+	for i := range elems {
+		var size int32
+		if binary.Read(r, binary.BigEndian, &size) != nil {
+			return "", invalid
+		}
+		if size < 0 {
+			continue
+		}
+		data := make([]byte, size)
+		if _, err := r.Read(data); err != nil && size > 0 {
+			return "", invalid
+		}
+		text, err := decodeBinary(data, elemOID)
+		if err != nil {
+			return "", err
+		}
+		elems[i] = text
+	}
+	return storage.Array{ElemType: storage.TypeString, Elems: elems}.String(), nil
+}
+
+// decodeBinaryNumeric decodes base-10000 digits into a decimal
+func decodeBinaryNumeric(r *bytes.Reader, invalid error) (string, error) {
+	var ndigits, weight, dscale int16
+	var sign uint16
+	if binary.Read(r, binary.BigEndian, &ndigits) != nil || binary.Read(r, binary.BigEndian, &weight) != nil ||
+		binary.Read(r, binary.BigEndian, &sign) != nil || binary.Read(r, binary.BigEndian, &dscale) != nil || ndigits < 0 {
+		return "", invalid
+	}
+	if sign == numericNaN {
+		return "NaN", nil
+	}
+	digits := make([]int16, ndigits)
+	if binary.Read(r, binary.BigEndian, digits) != nil {
+		return "", invalid
+	}
+
+	// Write every base-10000 digit from the highest weight down to the
+	// last fractional digit, then place the decimal point
+	var sb strings.Builder
+	lowest := int(weight) - int(ndigits) + 1
+	if lowest > 0 {
+		lowest = 0
+	}
+	for w := int(weight); w >= lowest; w-- {
+		d := 0
+		if i := int(weight) - w; i >= 0 && i < len(digits) {
+			d = int(digits[i])
+		}
+		fmt.Fprintf(&sb, "%04d", d)
+	}
+	all := sb.String()
+	intDigits := (int(weight) + 1) * 4
+	if intDigits < 0 {
+		all = strings.Repeat("0", -intDigits) + all
+		intDigits = 0
+	}
+	intPart := strings.TrimLeft(all[:intDigits], "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	frac := all[intDigits:]
+	if len(frac) < int(dscale) {
+		frac += strings.Repeat("0", int(dscale)-len(frac))
+	}
+	frac = frac[:dscale]
+
+	text := intPart
+	if dscale > 0 {
+		text += "." + frac
+	}
+	if sign == numericNegative {
+		text = "-" + text
+	}
+	return text, nil
+}
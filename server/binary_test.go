@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/satetsu888/vsql/storage"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		oid   int32
+		want  string
+	}{
+		{true, OIDBool, "true"},
+		{-42, OIDInt2, "-42"},
+		{123456, OIDInt4, "123456"},
+		{int64(1) << 40, OIDInt8, "1099511627776"},
+		{1.5, OIDFloat8, "1.5"},
+		{0.25, OIDFloat4, "0.25"},
+		{"hello", OIDText, "hello"},
+		{`{"a": 1}`, OIDJSONB, `{"a": 1}`},
+		{"2024-02-29", OIDDate, "2024-02-29"},
+		{"1999-12-31", OIDDate, "1999-12-31"},
+		{"13:45:30.5", OIDTime, "13:45:30.5"},
+		{"2024-02-29 13:45:30", OIDTimestamp, "2024-02-29 13:45:30"},
+		{"1 year 2 mons 3 days 04:05:06", OIDInterval, "1 year 2 mons 3 days 04:05:06"},
+		{storage.Array{ElemType: storage.TypeInteger, Elems: []interface{}{1, nil, 3}}, OIDInt4Array, "{1,NULL,3}"},
+		{storage.Array{ElemType: storage.TypeString, Elems: []interface{}{"a b", "c"}}, OIDTextArray, `{"a b",c}`},
+		{"{}", OIDTextArray, "{}"},
+		{"A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11", OIDUUID, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{storage.Bytea("\x00\xff"), OIDBytea, `\x00ff`},
+	}
+	for _, tt := range tests {
+		data, err := encodeBinary(tt.value, tt.oid)
+		if err != nil {
+			t.Errorf("encodeBinary(%v, %d) error: %v", tt.value, tt.oid, err)
+			continue
+		}
+		got, err := decodeBinary(data, tt.oid)
+		if err != nil {
+			t.Errorf("decodeBinary(%v, %d) error: %v", tt.value, tt.oid, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("round trip of %v as %d = %q, want %q", tt.value, tt.oid, got, tt.want)
+		}
+	}
+}
+
+func TestBinaryNumeric(t *testing.T) {
+	tests := []struct {
+		text string
+		want []byte
+	}{
+		// ndigits, weight, sign, dscale, then base-10000 digits
+		{"0", []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+		{"12345.678", []byte{0, 3, 0, 1, 0, 0, 0, 3, 0, 1, 0x09, 0x29, 0x1a, 0x7c}},
+		{"-0.0001", []byte{0, 1, 0xff, 0xff, 0x40, 0, 0, 4, 0, 1}},
+		{"10000", []byte{0, 1, 0, 1, 0, 0, 0, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		data, err := encodeBinary(tt.text, OIDNumeric)
+		if err != nil {
+			t.Errorf("encodeBinary(%s) error: %v", tt.text, err)
+			continue
+		}
+		if !bytes.Equal(data, tt.want) {
+			t.Errorf("encodeBinary(%s) = %v, want %v", tt.text, data, tt.want)
+		}
+		got, err := decodeBinary(data, OIDNumeric)
+		if err != nil || got != tt.text {
+			t.Errorf("decodeBinary(%s) = %q, %v", tt.text, got, err)
+		}
+	}
+}
+
+func TestFormatCode(t *testing.T) {
+	if formatCode(nil, 3) != FormatText {
+		t.Error("no codes should mean text")
+	}
+	if formatCode([]int16{FormatBinary}, 3) != FormatBinary {
+		t.Error("a single code should apply to every column")
+	}
+	if formatCode([]int16{FormatText, FormatBinary}, 1) != FormatBinary {
+		t.Error("codes should apply per column")
+	}
+}
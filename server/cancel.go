@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/satetsu888/vsql/parser"
+)
+
+// cancelRequestCode is sent in place of a protocol version by a client
+// asking, on a new connection, to cancel the query of another one
+const cancelRequestCode = 80877102
+
+// errCancelRequest ends the startup of a connection that only carried a
+// CancelRequest; it is closed without a reply
+var errCancelRequest = errors.New("cancel request")
+
+// backend is a started connection a CancelRequest may name
+type backend struct {
+	session   *parser.Session
+	secretKey int32
+}
+
+// register makes a session's running statements cancelable by clients
+// presenting its process ID and secret key
+func (s *Server) register(session *parser.Session, secretKey int32) {
+	s.backendsMu.Lock()
+	defer s.backendsMu.Unlock()
+	if s.backends == nil {
+		s.backends = make(map[int32]backend)
+	}
+	s.backends[session.ProcessID()] = backend{session: session, secretKey: secretKey}
+}
+
+// unregister forgets a session once its connection is closed
+func (s *Server) unregister(session *parser.Session) {
+	s.backendsMu.Lock()
+	defer s.backendsMu.Unlock()
+	if b, ok := s.backends[session.ProcessID()]; ok && b.session == session {
+		delete(s.backends, session.ProcessID())
+	}
+}
+
+// cancelQuery handles the body of a CancelRequest: a process ID and secret
+// key. As in PostgreSQL, a request naming no connection or the wrong key is
+// ignored, and the client is never told the outcome.
+func (s *Server) cancelQuery(params []byte) {
+	if len(params) < 8 {
+		return
+	}
+	processID := int32(binary.BigEndian.Uint32(params[:4]))
+	secretKey := int32(binary.BigEndian.Uint32(params[4:8]))
+
+	s.backendsMu.Lock()
+	b, ok := s.backends[processID]
+	s.backendsMu.Unlock()
+	if !ok || subtle.ConstantTimeEq(secretKey, b.secretKey) != 1 {
+		return
+	}
+	b.session.Cancel()
+}
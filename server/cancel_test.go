@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/satetsu888/vsql/parser"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// startSession runs the startup of a connection to s and returns the
+// process ID and secret key it reported
+func startSession(t *testing.T, s *Server, session *parser.Session) (processID, secretKey int32) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- s.handleStartup(serverConn, bufio.NewReader(serverConn), bufio.NewWriter(serverConn), session)
+	}()
+
+	if err := writeStartupPacket(clientConn, 196608, "user\x00app\x00\x00"); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		msg, err := ReadMessage(clientConn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type == BackendKeyData {
+			processID = int32(binary.BigEndian.Uint32(msg.Data[:4]))
+			secretKey = int32(binary.BigEndian.Uint32(msg.Data[4:]))
+		}
+		if msg.Type == ReadyForQuery {
+			break
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("startup failed: %v", err)
+	}
+	return processID, secretKey
+}
+
+// sendCancelRequest sends a CancelRequest on a connection of its own
+func sendCancelRequest(t *testing.T, s *Server, processID, secretKey int32) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- s.handleStartup(serverConn, bufio.NewReader(serverConn), bufio.NewWriter(serverConn), parser.NewSession())
+	}()
+
+	params := make([]byte, 8)
+	binary.BigEndian.PutUint32(params, uint32(processID))
+	binary.BigEndian.PutUint32(params[4:], uint32(secretKey))
+	if err := writeStartupPacket(clientConn, cancelRequestCode, string(params)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != errCancelRequest {
+		t.Fatalf("startup returned %v, want the cancel request to end it", err)
+	}
+}
+
+// createNumbers creates a table of 100 numbers, which cross joined with
+// itself a few times takes far longer to read than the tests wait
+func createNumbers(t *testing.T, session *parser.Session, db *storage.Database) {
+	t.Helper()
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, query := range []string{
+		"CREATE TABLE numbers (n integer)",
+		"INSERT INTO numbers (n) VALUES " + strings.Join(values, ", "),
+	} {
+		if _, _, _, err := parser.ExecutePgQuery(query, session, db.DataStore, db.MetaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	cluster := storage.NewCluster(parser.DefaultDatabase)
+	db := cluster.Connect(parser.DefaultDatabase)
+	s := New(0, cluster)
+	session := parser.NewSession()
+	processID, secretKey := startSession(t, s, session)
+
+	createNumbers(t, session, db)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := parser.ExecutePgQuery("SELECT count(*) FROM numbers a CROSS JOIN numbers b CROSS JOIN numbers c CROSS JOIN numbers d", session, db.DataStore, db.MetaStore)
+		done <- err
+	}()
+
+	// A request with the wrong key is ignored
+	sendCancelRequest(t, s, processID, secretKey+1)
+	select {
+	case err := <-done:
+		t.Fatalf("statement ended early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	timeout := time.After(10 * time.Second)
+	for {
+		sendCancelRequest(t, s, processID, secretKey)
+		select {
+		case err := <-done:
+			var coded interface{ SQLState() string }
+			if !errors.As(err, &coded) || coded.SQLState() != "57014" {
+				t.Fatalf("error = %v, want 57014", err)
+			}
+			s.unregister(session)
+			if len(s.backends) != 0 {
+				t.Error("the session should be forgotten once unregistered")
+			}
+			return
+		case <-timeout:
+			t.Fatal("the statement was not canceled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// executePortal runs query through Parse, Bind and Execute, returning the
+// error Execute reports
+func executePortal(s *Server, session *parser.Session, db *storage.Database, query string) error {
+	var w bytes.Buffer
+	out := bufio.NewWriter(&w)
+	extState := NewExtendedProtocolState()
+	if err := s.handleParse([]byte("\x00"+query+"\x00\x00\x00"), extState, out); err != nil {
+		return err
+	}
+	if err := s.handleBind([]byte("\x00\x00\x00\x00\x00\x00\x00\x00"), extState, out); err != nil {
+		return err
+	}
+	return s.handleExecute([]byte("\x00\x00\x00\x00\x00"), extState, session, db, out)
+}
+
+// slowPortalQuery streams its rows, so they are produced while Execute
+// fetches them rather than when the portal is opened
+const slowPortalQuery = "SELECT a.n FROM numbers a CROSS JOIN numbers b CROSS JOIN numbers c CROSS JOIN numbers d WHERE a.n + b.n + c.n + d.n < 0"
+
+// TestPortalStatementTimeout checks that statement_timeout stops the rows of
+// a portal being fetched, and that the error fails the transaction block
+func TestPortalStatementTimeout(t *testing.T) {
+	cluster := storage.NewCluster(parser.DefaultDatabase)
+	db := cluster.Connect(parser.DefaultDatabase)
+	s := New(0, cluster)
+	session := parser.NewSession()
+	createNumbers(t, session, db)
+	for _, query := range []string{"SET statement_timeout = 100", "BEGIN"} {
+		if _, _, _, err := parser.ExecutePgQuery(query, session, db.DataStore, db.MetaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- executePortal(s, session, db, slowPortalQuery) }()
+	select {
+	case err := <-done:
+		var coded interface{ SQLState() string }
+		if !errors.As(err, &coded) || coded.SQLState() != "57014" || !strings.Contains(err.Error(), "statement timeout") {
+			t.Fatalf("error = %v, want a statement timeout", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("statement_timeout did not stop the portal")
+	}
+	if session.TransactionStatus() != parser.TransactionFailed {
+		t.Error("the timeout should fail the transaction block")
+	}
+}
+
+// TestPortalCancelRequest checks that a CancelRequest stops the rows of a
+// portal being fetched
+func TestPortalCancelRequest(t *testing.T) {
+	cluster := storage.NewCluster(parser.DefaultDatabase)
+	db := cluster.Connect(parser.DefaultDatabase)
+	s := New(0, cluster)
+	session := parser.NewSession()
+	processID, secretKey := startSession(t, s, session)
+	createNumbers(t, session, db)
+
+	done := make(chan error, 1)
+	go func() { done <- executePortal(s, session, db, slowPortalQuery) }()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		sendCancelRequest(t, s, processID, secretKey)
+		select {
+		case err := <-done:
+			var coded interface{ SQLState() string }
+			if !errors.As(err, &coded) || coded.SQLState() != "57014" || !strings.Contains(err.Error(), "user request") {
+				t.Fatalf("error = %v, want 57014", err)
+			}
+			return
+		case <-timeout:
+			t.Fatal("the portal was not canceled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/satetsu888/vsql/parser"
 	"github.com/satetsu888/vsql/storage"
 	pg_query "github.com/pganalyze/pg_query_go/v5"
 )
@@ -25,6 +26,8 @@ type Portal struct {
 	ParameterValues   [][]byte
 	ParameterFormats  []int16 // 0 = text, 1 = binary
 	ResultFormats     []int16 // 0 = text, 1 = binary
+	Cursor            *parser.Cursor // Open result, set by the first Execute
+	Columns           []ColumnDescription // Result columns, set by the first Execute
 }
 
 // ExtendedProtocolState manages prepared statements and portals for a connection
@@ -133,22 +136,89 @@ func (eps *ExtendedProtocolState) Clear() {
 
 // PostgreSQL type OIDs
 const (
-	OIDUnknown   = 0
-	OIDBool      = 16
-	OIDInt8      = 20
-	OIDInt2      = 21
-	OIDInt4      = 23
-	OIDText      = 25
-	OIDFloat4    = 700
-	OIDFloat8    = 701
-	OIDVarchar   = 1043
-	OIDTimestamp = 1114
-	OIDDate      = 1082
-	OIDTime      = 1083
+	OIDUnknown     = 0
+	OIDBool        = 16
+	OIDInt8        = 20
+	OIDInt2        = 21
+	OIDInt4        = 23
+	OIDText        = 25
+	OIDFloat4      = 700
+	OIDFloat8      = 701
+	OIDVarchar     = 1043
+	OIDBpchar      = 1042
+	OIDTimestamp   = 1114
+	OIDDate        = 1082
+	OIDTime        = 1083
+	OIDTimestampTZ = 1184
+	OIDInterval    = 1186
+	OIDNumeric     = 1700
+	OIDJSON        = 114
+	OIDJSONB       = 3802
+	OIDUUID        = 2950
+	OIDBytea       = 17
+
+	OIDBoolArray        = 1000
+	OIDInt2Array        = 1005
+	OIDInt4Array        = 1007
+	OIDTextArray        = 1009
+	OIDInt8Array        = 1016
+	OIDFloat4Array      = 1021
+	OIDFloat8Array      = 1022
+	OIDVarcharArray     = 1015
+	OIDBpcharArray      = 1014
+	OIDTimestampArray   = 1115
+	OIDDateArray        = 1182
+	OIDTimeArray        = 1183
+	OIDTimestampTZArray = 1185
+	OIDIntervalArray    = 1187
+	OIDNumericArray     = 1231
+	OIDJSONArray        = 199
+	OIDJSONBArray       = 3807
+	OIDUUIDArray        = 2951
+	OIDByteaArray       = 1001
 )
 
+// arrayElementOIDs maps each array type OID to its element type OID
+var arrayElementOIDs = map[int32]int32{
+	OIDBoolArray:        OIDBool,
+	OIDInt2Array:        OIDInt2,
+	OIDInt4Array:        OIDInt4,
+	OIDTextArray:        OIDText,
+	OIDInt8Array:        OIDInt8,
+	OIDFloat4Array:      OIDFloat4,
+	OIDFloat8Array:      OIDFloat8,
+	OIDVarcharArray:     OIDVarchar,
+	OIDBpcharArray:      OIDBpchar,
+	OIDTimestampArray:   OIDTimestamp,
+	OIDDateArray:        OIDDate,
+	OIDTimeArray:        OIDTime,
+	OIDTimestampTZArray: OIDTimestampTZ,
+	OIDIntervalArray:    OIDInterval,
+	OIDNumericArray:     OIDNumeric,
+	OIDJSONArray:        OIDJSON,
+	OIDJSONBArray:       OIDJSONB,
+	OIDUUIDArray:        OIDUUID,
+	OIDByteaArray:       OIDBytea,
+}
+
+// arrayOID returns the OID of the array type with elements of type elemOID
+func arrayOID(elemOID int32) int32 {
+	for array, elem := range arrayElementOIDs {
+		if elem == elemOID {
+			return array
+		}
+	}
+	return OIDTextArray
+}
+
 // VSQLTypeToOID converts VSQL column types to PostgreSQL OIDs
 func VSQLTypeToOID(colType storage.ColumnType) int32 {
+	if storage.IsArrayType(colType) {
+		return arrayOID(VSQLTypeToOID(storage.ElementType(colType)))
+	}
+	if def := storage.EnumOf(colType); def != nil {
+		return int32(def.OID)
+	}
 	switch colType {
 	case storage.TypeBoolean:
 		return OIDBool
@@ -156,10 +226,26 @@ func VSQLTypeToOID(colType storage.ColumnType) int32 {
 		return OIDInt4
 	case storage.TypeFloat:
 		return OIDFloat8
+	case storage.TypeNumeric:
+		return OIDNumeric
 	case storage.TypeString:
 		return OIDText
 	case storage.TypeTimestamp:
 		return OIDTimestamp
+	case storage.TypeTimestampTZ:
+		return OIDTimestampTZ
+	case storage.TypeDate:
+		return OIDDate
+	case storage.TypeTime:
+		return OIDTime
+	case storage.TypeInterval:
+		return OIDInterval
+	case storage.TypeJSON:
+		return OIDJSONB
+	case storage.TypeUUID:
+		return OIDUUID
+	case storage.TypeBytea:
+		return OIDBytea
 	case storage.TypeUnknown:
 		// Return text as a safe default for unknown types
 		// This allows clients to work with the data even if type isn't determined yet
@@ -184,11 +270,36 @@ func GetTypeSizeAndMod(oid int32) (size int16, mod int32) {
 		return 4, -1
 	case OIDFloat8:
 		return 8, -1
-	case OIDText, OIDVarchar:
+	case OIDText, OIDVarchar, OIDBpchar, OIDNumeric, OIDJSON, OIDJSONB:
 		return -1, -1  // Variable length
-	case OIDTimestamp, OIDDate, OIDTime:
+	case OIDDate:
+		return 4, -1
+	case OIDTimestamp, OIDTimestampTZ, OIDTime:
 		return 8, -1
+	case OIDInterval, OIDUUID:
+		return 16, -1
 	default:
 		return -1, -1
 	}
-}
\ No newline at end of file
+}
+
+// TypeModifier returns the type modifier PostgreSQL reports for a type
+// declared with modifiers: the length plus 4 for varchar(n) and char(n),
+// and the precision and scale packed above 4 for numeric(p,s). Types
+// without modifiers report -1.
+func TypeModifier(oid int32, modifiers []int) int32 {
+	if len(modifiers) == 0 {
+		return -1
+	}
+	switch oid {
+	case OIDVarchar, OIDBpchar:
+		return int32(modifiers[0]) + 4
+	case OIDNumeric:
+		scale := 0
+		if len(modifiers) > 1 {
+			scale = modifiers[1]
+		}
+		return int32(modifiers[0]<<16|scale) + 4
+	}
+	return -1
+}
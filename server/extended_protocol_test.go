@@ -0,0 +1,130 @@
+package server
+
+import (
+	"testing"
+
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"github.com/satetsu888/vsql/parser"
+	"github.com/satetsu888/vsql/storage"
+)
+
+func TestTypeModifier(t *testing.T) {
+	tests := []struct {
+		oid       int32
+		modifiers []int
+		want      int32
+	}{
+		{OIDVarchar, []int{20}, 24},
+		{OIDBpchar, []int{1}, 5},
+		{OIDNumeric, []int{10, 2}, 10<<16 | 2 + 4},
+		{OIDNumeric, []int{5}, 5<<16 + 4},
+		{OIDVarchar, nil, -1},
+		{OIDInt4, []int{3}, -1},
+	}
+	for _, tt := range tests {
+		if got := TypeModifier(tt.oid, tt.modifiers); got != tt.want {
+			t.Errorf("TypeModifier(%d, %v) = %d, want %d", tt.oid, tt.modifiers, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeSelectColumnsTableOIDs(t *testing.T) {
+	cluster := storage.NewCluster(parser.DefaultDatabase)
+	db := cluster.Connect(parser.DefaultDatabase)
+	ds, ms := db.DataStore, db.MetaStore
+	ds.CreateTable("users")
+	ms.AddColumns("users", []string{"id", "name"})
+	ds.CreateTable("orders")
+	ms.AddColumns("orders", []string{"id", "user_id", "total"})
+	users, _ := ds.GetTable("users")
+	orders, _ := ds.GetTable("orders")
+
+	result, err := pg_query.Parse("SELECT o.total, name, u.*, 1 + 1 AS two FROM users u JOIN orders o ON o.user_id = u.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(0, cluster)
+	colDescs, err := s.analyzeSelectColumns(result.Stmts[0].Stmt.GetSelectStmt(), parser.NewSession(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name      string
+		tableOID  int32
+		columnNum int16
+	}{
+		{"total", int32(orders.OID), 3},
+		{"name", int32(users.OID), 2},
+		{"id", int32(users.OID), 1},
+		{"name", int32(users.OID), 2},
+		{"two", 0, 0},
+	}
+	if len(colDescs) != len(want) {
+		t.Fatalf("got %d columns, want %d", len(colDescs), len(want))
+	}
+	for i, w := range want {
+		got := colDescs[i]
+		if got.Name != w.name || got.TableOID != w.tableOID || got.ColumnNum != w.columnNum {
+			t.Errorf("column %d = %s (%d, %d), want %s (%d, %d)", i, got.Name, got.TableOID, got.ColumnNum, w.name, w.tableOID, w.columnNum)
+		}
+	}
+}
+
+// TestDescribeQueryColumnTypes checks that simple queries describe their
+// columns with the declared types, as the extended protocol does
+func TestDescribeQueryColumnTypes(t *testing.T) {
+	cluster := storage.NewCluster(parser.DefaultDatabase)
+	db := cluster.Connect(parser.DefaultDatabase)
+	session := parser.NewSession()
+	for _, query := range []string{
+		"CREATE TABLE items (b bigint, s smallint, v varchar(10), c char(5), n numeric(10,2), j json, d jsonb)",
+		`INSERT INTO items VALUES (1, 2, 'abc', 'de', 3.25, '{"a": 1}', '{"a": 1}')`,
+	} {
+		if _, _, _, err := parser.ExecutePgQuery(query, session, db.DataStore, db.MetaStore); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	type columnType struct {
+		oid  int32
+		size int16
+		mod  int32
+	}
+	s := New(0, cluster)
+	for _, tt := range []struct {
+		query string
+		want  []columnType
+	}{
+		{"SELECT * FROM items", []columnType{
+			{OIDInt8, 8, -1},
+			{OIDInt2, 2, -1},
+			{OIDVarchar, -1, 14},
+			{OIDBpchar, -1, 9},
+			{OIDNumeric, -1, 10<<16 | 2 + 4},
+			{OIDJSON, -1, -1},
+			{OIDJSONB, -1, -1},
+		}},
+		{"SELECT s::bigint AS wide, v::varchar(3) AS short, n::numeric(4,1) AS rounded, d::json AS doc FROM items", []columnType{
+			{OIDInt8, 8, -1},
+			{OIDVarchar, -1, 7},
+			{OIDNumeric, -1, 4<<16 | 1 + 4},
+			{OIDJSON, -1, -1},
+		}},
+	} {
+		columns, _, _, err := parser.ExecutePgQuery(tt.query, session, db.DataStore, db.MetaStore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		colDescs := s.describeQueryColumns(tt.query, columns, session, db)
+		if len(colDescs) != len(tt.want) {
+			t.Fatalf("%s: got %d columns, want %d", tt.query, len(colDescs), len(tt.want))
+		}
+		for i, w := range tt.want {
+			got := colDescs[i]
+			if (columnType{got.TypeOID, got.TypeSize, got.TypeMod}) != w {
+				t.Errorf("%s: column %s = (%d, %d, %d), want %v", tt.query, got.Name, got.TypeOID, got.TypeSize, got.TypeMod, w)
+			}
+		}
+	}
+}
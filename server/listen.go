@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// UnixSocketPath returns the path of the Unix socket for a port in dir,
+// named as PostgreSQL names it so clients find it from the port alone
+func UnixSocketPath(dir string, port int) string {
+	return filepath.Join(dir, fmt.Sprintf(".s.PGSQL.%d", port))
+}
+
+// SetUnixSocketDir makes the server also listen on a Unix socket in dir
+func (s *Server) SetUnixSocketDir(dir string) {
+	s.socketDir = dir
+}
+
+// SetTCP sets whether the server listens on TCP, which it does by default.
+// A server that does not must have a Unix socket directory.
+func (s *Server) SetTCP(enabled bool) {
+	s.noTCP = !enabled
+}
+
+// listen opens the server's listeners
+func (s *Server) listen() ([]net.Listener, error) {
+	if s.noTCP && s.socketDir == "" {
+		return nil, errors.New("no TCP port or Unix socket to listen on")
+	}
+
+	var listeners []net.Listener
+	if !s.noTCP {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	if s.socketDir != "" {
+		listener, err := listenUnix(UnixSocketPath(s.socketDir, s.port))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listenUnix listens on a Unix socket that any local user may connect to,
+// replacing a socket file left behind by a server that is no longer running.
+// The socket file is removed when the listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another server is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0777); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
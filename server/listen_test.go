@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/satetsu888/vsql/parser"
+	"github.com/satetsu888/vsql/storage"
+)
+
+func TestUnixSocketListener(t *testing.T) {
+	dir := t.TempDir()
+	path := UnixSocketPath(dir, 5432)
+	if path != filepath.Join(dir, ".s.PGSQL.5432") {
+		t.Fatalf("UnixSocketPath = %s", path)
+	}
+
+	// A socket file left behind by a server that is gone is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := New(5432, storage.NewCluster(parser.DefaultDatabase))
+	s.SetUnixSocketDir(dir)
+	s.SetTCP(false)
+	started := make(chan error, 1)
+	go func() { started <- s.Start() }()
+
+	var conn net.Conn
+	for i := 0; i < 100; i++ {
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("could not connect to %s: %v", path, err)
+	}
+	if err := writeStartupPacket(conn, 196608, "user\x00app\x00\x00"); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ReadMessage(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != AuthenticationOk || binary.BigEndian.Uint32(msg.Data) != 0 {
+		t.Errorf("expected AuthenticationOk, got %c %v", msg.Type, msg.Data)
+	}
+	conn.Close()
+
+	s.Stop()
+	if err := <-started; err != nil {
+		t.Errorf("Start returned %v after Stop", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("socket file should be removed when the server stops")
+	}
+}
+
+func TestListenNeedsTCPOrSocket(t *testing.T) {
+	s := New(0, storage.NewCluster(parser.DefaultDatabase))
+	s.SetTCP(false)
+	if err := s.Start(); err == nil {
+		t.Error("Start should fail with neither TCP nor a Unix socket")
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -36,6 +37,9 @@ const (
 	ParameterDescription MessageType = 't'
 	PortalSuspended     MessageType = 's'
 	CloseComplete       MessageType = '3'
+
+	// PasswordMessage carries a password or a SASL response during startup
+	PasswordMessage     MessageType = 'p'
 )
 
 type Message struct {
@@ -89,8 +93,60 @@ func WriteAuthenticationOk(w io.Writer) error {
 	return WriteMessage(w, AuthenticationOk, buf.Bytes())
 }
 
-func WriteReadyForQuery(w io.Writer) error {
-	return WriteMessage(w, ReadyForQuery, []byte{'I'})
+// Codes of the authentication requests, which share the message type of
+// AuthenticationOk
+const (
+	authCleartextPassword int32 = 3
+	authMD5Password       int32 = 5
+	authSASL              int32 = 10
+	authSASLContinue      int32 = 11
+	authSASLFinal         int32 = 12
+)
+
+// writeAuthenticationRequest sends an authentication request with its data
+func writeAuthenticationRequest(w io.Writer, code int32, data []byte) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, code)
+	buf.Write(data)
+	return WriteMessage(w, AuthenticationOk, buf.Bytes())
+}
+
+// WriteAuthenticationCleartextPassword asks the client for its password
+func WriteAuthenticationCleartextPassword(w io.Writer) error {
+	return writeAuthenticationRequest(w, authCleartextPassword, nil)
+}
+
+// WriteAuthenticationMD5Password asks the client for its password, hashed
+// with the user name and salt
+func WriteAuthenticationMD5Password(w io.Writer, salt [4]byte) error {
+	return writeAuthenticationRequest(w, authMD5Password, salt[:])
+}
+
+// WriteAuthenticationSASL starts a SASL exchange, offering mechanisms
+func WriteAuthenticationSASL(w io.Writer, mechanisms []string) error {
+	var buf bytes.Buffer
+	for _, mechanism := range mechanisms {
+		buf.WriteString(mechanism)
+		buf.WriteByte(0)
+	}
+	buf.WriteByte(0)
+	return writeAuthenticationRequest(w, authSASL, buf.Bytes())
+}
+
+// WriteAuthenticationSASLContinue sends the server's next SASL message
+func WriteAuthenticationSASLContinue(w io.Writer, data []byte) error {
+	return writeAuthenticationRequest(w, authSASLContinue, data)
+}
+
+// WriteAuthenticationSASLFinal sends the server's final SASL message, which
+// the client checks before AuthenticationOk
+func WriteAuthenticationSASLFinal(w io.Writer, data []byte) error {
+	return writeAuthenticationRequest(w, authSASLFinal, data)
+}
+
+// WriteReadyForQuery reports the transaction status: 'I' idle, 'T' in a block, 'E' in a failed block
+func WriteReadyForQuery(w io.Writer, status byte) error {
+	return WriteMessage(w, ReadyForQuery, []byte{status})
 }
 
 func WriteCommandComplete(w io.Writer, tag string) error {
@@ -99,18 +155,62 @@ func WriteCommandComplete(w io.Writer, tag string) error {
 }
 
 func WriteErrorResponse(w io.Writer, msg string) error {
+	return writeErrorFields(w, "ERROR", SQLStateInternalError, msg, "", "")
+}
+
+// SQLStateInternalError is reported for errors that carry no SQLSTATE of their own
+const SQLStateInternalError = "XX000"
+
+// WriteError sends err as an ErrorResponse, including its SQLSTATE, detail
+// and hint when the error provides them
+func WriteError(w io.Writer, err error) error {
+	return writeError(w, "ERROR", err)
+}
+
+// WriteFatal sends err as an ErrorResponse that ends the connection, such
+// as a failed authentication
+func WriteFatal(w io.Writer, err error) error {
+	return writeError(w, "FATAL", err)
+}
+
+func writeError(w io.Writer, severity string, err error) error {
+	code := SQLStateInternalError
+	var coded interface{ SQLState() string }
+	if errors.As(err, &coded) {
+		code = coded.SQLState()
+	}
+	var detail, hint string
+	var detailed interface{ Detail() string }
+	if errors.As(err, &detailed) {
+		detail = detailed.Detail()
+	}
+	var hinted interface{ Hint() string }
+	if errors.As(err, &hinted) {
+		hint = hinted.Hint()
+	}
+	return writeErrorFields(w, severity, code, err.Error(), detail, hint)
+}
+
+func writeErrorFields(w io.Writer, severity, code, msg, detail, hint string) error {
 	var buf bytes.Buffer
-	
-	buf.WriteByte('S')
-	buf.Write([]byte("ERROR"))
-	buf.WriteByte(0)
-	
-	buf.WriteByte('M')
-	buf.Write([]byte(msg))
-	buf.WriteByte(0)
-	
+	field := func(tag byte, value string) {
+		buf.WriteByte(tag)
+		buf.WriteString(value)
+		buf.WriteByte(0)
+	}
+
+	field('S', severity)
+	field('V', severity)
+	field('C', code)
+	field('M', msg)
+	if detail != "" {
+		field('D', detail)
+	}
+	if hint != "" {
+		field('H', hint)
+	}
 	buf.WriteByte(0)
-	
+
 	return WriteMessage(w, ErrorResponse, buf.Bytes())
 }
 
@@ -183,6 +283,34 @@ func WriteDataRow(w io.Writer, values []interface{}) error {
 	return WriteMessage(w, DataRow, buf.Bytes())
 }
 
+// WriteDataRowFormats writes a DataRow message, sending each value in the
+// format its column description asks for
+func WriteDataRowFormats(w io.Writer, values []interface{}, columns []ColumnDescription) error {
+	var buf bytes.Buffer
+	
+	fieldCount := int16(len(values))
+	binary.Write(&buf, binary.BigEndian, fieldCount)
+	
+	for i, val := range values {
+		if val == nil {
+			binary.Write(&buf, binary.BigEndian, int32(-1))
+			continue
+		}
+		data := []byte(valueText(val))
+		if i < len(columns) && columns[i].Format == FormatBinary {
+			encoded, err := encodeBinary(val, columns[i].TypeOID)
+			if err != nil {
+				return err
+			}
+			data = encoded
+		}
+		binary.Write(&buf, binary.BigEndian, int32(len(data)))
+		buf.Write(data)
+	}
+	
+	return WriteMessage(w, DataRow, buf.Bytes())
+}
+
 func WriteParameterStatus(w io.Writer, name, value string) error {
 	var buf bytes.Buffer
 	buf.Write([]byte(name))
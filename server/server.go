@@ -3,13 +3,17 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	pg_query "github.com/pganalyze/pg_query_go/v5"
 	"github.com/satetsu888/vsql/parser"
@@ -18,29 +22,69 @@ import (
 
 type Server struct {
 	port      int
-	dataStore *storage.DataStore
-	metaStore *storage.MetaStore
-	listener  net.Listener
+	cluster   *storage.Cluster // Databases, selected by each connection
+	auth      Auth
+	scram     *scramCredentials // Set for SCRAM-SHA-256 authentication
+	tlsConfig *tls.Config       // Set when clients may request TLS
+	noTCP     bool              // Listen only on the Unix socket
+	socketDir string            // Directory of the Unix socket, if any
+	listeners []net.Listener
+	stopped   bool
+	mu        sync.Mutex // Guards listeners and stopped
 	wg        sync.WaitGroup
+
+	backends   map[int32]backend // Started connections, by process ID
+	backendsMu sync.Mutex
+
+	lastProcessID int32 // Process IDs are reported to connections in turn
 }
 
-func New(port int, dataStore *storage.DataStore, metaStore *storage.MetaStore) *Server {
+func New(port int, cluster *storage.Cluster) *Server {
 	return &Server{
-		port:      port,
-		dataStore: dataStore,
-		metaStore: metaStore,
+		port:    port,
+		cluster: cluster,
+		auth:    Auth{Method: AuthTrust},
+		// Process IDs follow the server's own, as backends' do in PostgreSQL
+		lastProcessID: int32(os.Getpid()),
 	}
 }
 
+// Start listens on TCP and on the Unix socket, if one is set, and serves
+// connections until Stop is called or a listener fails. It returns nil
+// after Stop.
 func (s *Server) Start() error {
-	var err error
-	s.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	listeners, err := s.listen()
 	if err != nil {
 		return err
 	}
+	s.mu.Lock()
+	s.listeners = listeners
+	s.mu.Unlock()
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			errs <- s.serve(listener)
+		}(listener)
+	}
+	err = <-errs
 
+	// Closing the other listeners removes the Unix socket file
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, listener := range listeners {
+		listener.Close()
+	}
+	if s.stopped {
+		return nil
+	}
+	return err
+}
+
+// serve accepts connections from a listener until it is closed
+func (s *Server) serve(listener net.Listener) error {
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			if ne, ok := err.(net.Error); ok && ne.Temporary() {
 				continue
@@ -54,9 +98,12 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Stop() {
-	if s.listener != nil {
-		s.listener.Close()
+	s.mu.Lock()
+	s.stopped = true
+	for _, listener := range s.listeners {
+		listener.Close()
 	}
+	s.mu.Unlock()
 	s.wg.Wait()
 }
 
@@ -68,14 +115,24 @@ func (s *Server) handleConnection(conn net.Conn) {
 	writer := bufio.NewWriter(conn)
 	defer writer.Flush()
 
-	// Create extended protocol state for this connection
+	// Create session and extended protocol state for this connection
+	session := parser.NewSession()
 	extState := NewExtendedProtocolState()
 
-	if err := s.handleStartup(reader, writer); err != nil {
-		fmt.Printf("Startup error: %v\n", err)
+	defer s.unregister(session)
+	if err := s.handleStartup(conn, reader, writer, session); err != nil {
+		if err != errCancelRequest {
+			fmt.Printf("Startup error: %v\n", err)
+		}
 		return
 	}
 
+	// The database the client asked for is created the first time
+	db := s.cluster.Connect(session.Database())
+	defer s.cluster.Disconnect(db)
+	defer session.Close(db.DataStore, db.MetaStore)
+	session.SetCluster(s.cluster)
+
 	for {
 		msg, err := ReadMessage(reader)
 		if err != nil {
@@ -88,43 +145,43 @@ func (s *Server) handleConnection(conn net.Conn) {
 		switch msg.Type {
 		case Query:
 			query := string(bytes.TrimSuffix(msg.Data, []byte{0}))
-			if err := s.handleQuery(writer, query); err != nil {
-				WriteErrorResponse(writer, err.Error())
+			if err := s.handleQuery(writer, query, session, db); err != nil {
+				WriteError(writer, err)
 			}
-			WriteReadyForQuery(writer)
+			writeReadyForQuery(writer, session)
 			writer.Flush()
 		case Parse:
 			if err := s.handleParse(msg.Data, extState, writer); err != nil {
-				WriteErrorResponse(writer, err.Error())
+				WriteError(writer, err)
 			} else {
 				WriteParseComplete(writer)
 			}
 			writer.Flush()
 		case Bind:
 			if err := s.handleBind(msg.Data, extState, writer); err != nil {
-				WriteErrorResponse(writer, err.Error())
+				WriteError(writer, err)
 			} else {
 				WriteBindComplete(writer)
 			}
 			writer.Flush()
 		case Execute:
-			if err := s.handleExecute(msg.Data, extState, writer); err != nil {
-				WriteErrorResponse(writer, err.Error())
+			if err := s.handleExecute(msg.Data, extState, session, db, writer); err != nil {
+				WriteError(writer, err)
 			}
 			writer.Flush()
 		case Describe:
-			if err := s.handleDescribe(msg.Data, extState, writer); err != nil {
-				WriteErrorResponse(writer, err.Error())
+			if err := s.handleDescribe(msg.Data, extState, session, db, writer); err != nil {
+				WriteError(writer, err)
 			}
 			writer.Flush()
 		case Close:
 			if err := s.handleClose(msg.Data, extState, writer); err != nil {
-				WriteErrorResponse(writer, err.Error())
+				WriteError(writer, err)
 			}
 			writer.Flush()
 		case Sync:
 			// Sync completes the current extended query protocol sequence
-			WriteReadyForQuery(writer)
+			writeReadyForQuery(writer, session)
 			writer.Flush()
 		case Flush:
 			// Flush forces any pending output to be sent
@@ -135,13 +192,16 @@ func (s *Server) handleConnection(conn net.Conn) {
 		default:
 			// Send error response for unsupported message types
 			WriteErrorResponse(writer, fmt.Sprintf("unsupported message type: %c", msg.Type))
-			WriteReadyForQuery(writer)
+			writeReadyForQuery(writer, session)
 			writer.Flush()
 		}
 	}
 }
 
-func (s *Server) handleStartup(reader io.Reader, writer *bufio.Writer) error {
+// handleStartup negotiates encryption, authenticates the client and starts
+// its session. When the client asks for TLS, reader and writer are reset to
+// read and write through it.
+func (s *Server) handleStartup(conn net.Conn, reader *bufio.Reader, writer *bufio.Writer, session *parser.Session) error {
 	startupMsg := make([]byte, 8)
 	if _, err := io.ReadFull(reader, startupMsg); err != nil {
 		return err
@@ -155,42 +215,129 @@ func (s *Server) handleStartup(reader io.Reader, writer *bufio.Writer) error {
 		return err
 	}
 
-	if version == 80877103 {
+	switch version {
+	case sslRequestCode:
+		// As in PostgreSQL, Unix socket connections are never encrypted
+		_, encrypted := conn.(*tls.Conn)
+		if s.tlsConfig == nil || encrypted || conn.LocalAddr().Network() == "unix" {
+			writer.WriteByte('N')
+			writer.Flush()
+			return s.handleStartup(conn, reader, writer, session)
+		}
+		writer.WriteByte('S')
+		writer.Flush()
+		// Anything the client sent before the handshake was not encrypted
+		if reader.Buffered() > 0 {
+			err := protocolViolation("received unencrypted data after SSL request")
+			WriteFatal(writer, err)
+			writer.Flush()
+			return err
+		}
+		tlsConn := tls.Server(conn, s.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		reader.Reset(tlsConn)
+		writer.Reset(tlsConn)
+		return s.handleStartup(tlsConn, reader, writer, session)
+	case cancelRequestCode:
+		s.cancelQuery(params)
+		return errCancelRequest
+	case gssEncRequestCode:
+		// GSSAPI encryption is not supported; the client may go on to
+		// request TLS or start up unencrypted
 		writer.WriteByte('N')
 		writer.Flush()
-		return s.handleStartup(reader, writer)
+		return s.handleStartup(conn, reader, writer, session)
 	}
 
+	// The database defaults to the user's name, as in PostgreSQL. Other
+	// parameters, such as application_name, start as session settings.
+	startup := parseStartupParameters(params)
+	user := startup["user"]
+	if user == "" {
+		user = "postgres"
+	}
+	database := startup["database"]
+	if database == "" {
+		database = user
+	}
+	for name, value := range startup {
+		switch name {
+		case "user", "database", "options", "replication":
+		default:
+			session.SetSetting(name, value)
+		}
+	}
+	if err := s.authenticate(reader, writer, writer.Flush, user); err != nil {
+		WriteFatal(writer, err)
+		writer.Flush()
+		return err
+	}
+	processID := atomic.AddInt32(&s.lastProcessID, 1)
+	session.SetConnection(database, user, processID)
+
 	if err := WriteAuthenticationOk(writer); err != nil {
 		return err
 	}
 
-	WriteParameterStatus(writer, "server_version", "12.0")
-	WriteParameterStatus(writer, "server_encoding", "UTF8")
-	WriteParameterStatus(writer, "client_encoding", "UTF8")
-	WriteParameterStatus(writer, "DateStyle", "ISO, MDY")
-	WriteBackendKeyData(writer, 12345, 67890)
+	for _, change := range session.ParameterChanges() {
+		WriteParameterStatus(writer, change[0], change[1])
+	}
+	secretKey := newSecretKey()
+	s.register(session, secretKey)
+	WriteBackendKeyData(writer, processID, secretKey)
 
-	if err := WriteReadyForQuery(writer); err != nil {
+	if err := writeReadyForQuery(writer, session); err != nil {
 		return err
 	}
 
 	return writer.Flush()
 }
 
-func (s *Server) handleQuery(w *bufio.Writer, query string) error {
+// writeReadyForQuery reports the reported parameters that changed since the
+// client last heard of them, then the session's transaction status
+func writeReadyForQuery(writer io.Writer, session *parser.Session) error {
+	for _, change := range session.ParameterChanges() {
+		if err := WriteParameterStatus(writer, change[0], change[1]); err != nil {
+			return err
+		}
+	}
+	return WriteReadyForQuery(writer, byte(session.TransactionStatus()))
+}
+
+// parseStartupParameters reads the name and value pairs of a startup
+// message, which end with an empty name
+func parseStartupParameters(data []byte) map[string]string {
+	params := make(map[string]string)
+	fields := strings.Split(string(data), "\x00")
+	for i := 0; i+1 < len(fields) && fields[i] != ""; i += 2 {
+		params[fields[i]] = fields[i+1]
+	}
+	return params
+}
+
+// newSecretKey returns the random key a client must present to cancel a
+// query of its connection
+func newSecretKey() int32 {
+	var key [4]byte
+	rand.Read(key[:])
+	return int32(binary.BigEndian.Uint32(key[:]))
+}
+
+func (s *Server) handleQuery(w *bufio.Writer, query string, session *parser.Session, db *storage.Database) error {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil
 	}
 
-	columns, rows, tag, err := parser.ExecutePgQuery(query, s.dataStore, s.metaStore)
+	columns, rows, tag, err := parser.ExecutePgQuery(query, session, db.DataStore, db.MetaStore)
 	if err != nil {
 		return err
 	}
 
 	if columns != nil {
-		if err := WriteRowDescription(w, columns); err != nil {
+		if err := WriteRowDescriptionExt(w, s.describeQueryColumns(query, columns, session, db)); err != nil {
 			return err
 		}
 
@@ -204,6 +351,43 @@ func (s *Server) handleQuery(w *bufio.Writer, query string) error {
 	return WriteCommandComplete(w, tag)
 }
 
+// describeQueryColumns describes the result columns of a simple query with
+// the same types, table OIDs and column numbers as the extended protocol.
+// Columns whose type can't be worked out are described as text.
+func (s *Server) describeQueryColumns(query string, columns []string, session *parser.Session, db *storage.Database) []ColumnDescription {
+	colDescs := make([]ColumnDescription, len(columns))
+	for i, name := range columns {
+		colDescs[i] = ColumnDescription{
+			Name:     name,
+			TypeOID:  OIDText,
+			TypeSize: -1,
+			TypeMod:  -1,
+		}
+	}
+
+	// Only the first statement of a query runs
+	result, err := parser.ParsePostgreSQL(query)
+	if err != nil || len(result.Stmts) == 0 {
+		return colDescs
+	}
+	selectStmt, ok := result.Stmts[0].Stmt.Node.(*pg_query.Node_SelectStmt)
+	if !ok {
+		return colDescs
+	}
+	analyzed, err := s.analyzeSelectColumns(selectStmt.SelectStmt, session, db)
+	if err != nil || len(analyzed) != len(colDescs) {
+		return colDescs
+	}
+	for i := range colDescs {
+		colDescs[i].TableOID = analyzed[i].TableOID
+		colDescs[i].ColumnNum = analyzed[i].ColumnNum
+		colDescs[i].TypeOID = analyzed[i].TypeOID
+		colDescs[i].TypeSize = analyzed[i].TypeSize
+		colDescs[i].TypeMod = analyzed[i].TypeMod
+	}
+	return colDescs
+}
+
 // handleParse handles the Parse message (P)
 func (s *Server) handleParse(data []byte, extState *ExtendedProtocolState, w *bufio.Writer) error {
 	buf := bytes.NewReader(data)
@@ -356,7 +540,7 @@ func (s *Server) handleBind(data []byte, extState *ExtendedProtocolState, w *buf
 }
 
 // handleExecute handles the Execute message (E)
-func (s *Server) handleExecute(data []byte, extState *ExtendedProtocolState, w *bufio.Writer) error {
+func (s *Server) handleExecute(data []byte, extState *ExtendedProtocolState, session *parser.Session, db *storage.Database, w *bufio.Writer) error {
 	buf := bytes.NewReader(data)
 	
 	// Read portal name
@@ -377,20 +561,28 @@ func (s *Server) handleExecute(data []byte, extState *ExtendedProtocolState, w *
 		return err
 	}
 	
-	// Execute the query with bound parameters
-	columns, rows, tag, err := s.executePortal(portal)
-	if err != nil {
-		return err
+	// The first Execute starts the query; later ones resume the same cursor
+	firstExecute := portal.Cursor == nil
+	if firstExecute {
+		portal.Cursor, err = s.openPortal(portal, session, db)
+		if err != nil {
+			return err
+		}
 	}
+	cursor := portal.Cursor
 	
 	// Send row description if this is a SELECT-like query
-	if columns != nil {
+	if !cursor.ReturnsRows() {
+		return WriteCommandComplete(w, cursor.Tag(0))
+	}
+	
+	if firstExecute {
 		// Analyze the query to get proper column descriptions with types
 		var colDescs []ColumnDescription
 		if portal.Statement.ParsedQuery != nil && len(portal.Statement.ParsedQuery.Stmts) > 0 {
 			if stmt := portal.Statement.ParsedQuery.Stmts[0].Stmt; stmt != nil {
 				if selectStmt, ok := stmt.Node.(*pg_query.Node_SelectStmt); ok {
-					colDescs, err = s.analyzeSelectColumns(selectStmt.SelectStmt)
+					colDescs, err = s.analyzeSelectColumns(selectStmt.SelectStmt, session, db)
 					if err != nil {
 						return err
 					}
@@ -399,8 +591,9 @@ func (s *Server) handleExecute(data []byte, extState *ExtendedProtocolState, w *
 		}
 		
 		// If we couldn't analyze the query, fall back to simple column names
-		if len(colDescs) == 0 {
-			for _, col := range columns {
+		if len(colDescs) != len(cursor.Columns()) {
+			colDescs = nil
+			for _, col := range cursor.Columns() {
 				colDescs = append(colDescs, ColumnDescription{
 					Name:      col,
 					TableOID:  0,
@@ -413,33 +606,43 @@ func (s *Server) handleExecute(data []byte, extState *ExtendedProtocolState, w *
 			}
 		}
 		
+		applyResultFormats(colDescs, portal.ResultFormats)
+		portal.Columns = colDescs
 		if err := WriteRowDescriptionExt(w, colDescs); err != nil {
 			return err
 		}
-		
-		// Send data rows (respecting maxRows if specified)
-		rowCount := len(rows)
-		if maxRows > 0 && int32(rowCount) > maxRows {
-			rowCount = int(maxRows)
-		}
-		
-		for i := 0; i < rowCount; i++ {
-			if err := WriteDataRow(w, rows[i]); err != nil {
-				return err
-			}
+	}
+	
+	// Rows are read as a statement of their own (respecting maxRows if
+	// specified), which statement_timeout and CancelRequest stop
+	var rows [][]interface{}
+	suspended := false
+	err = session.RunStatement(func() (err error) {
+		if rows, err = cursor.Fetch(int(maxRows)); err != nil {
+			return err
 		}
-		
-		// If we hit the row limit, send PortalSuspended instead of CommandComplete
-		if maxRows > 0 && int32(len(rows)) > maxRows {
-			return WritePortalSuspended(w)
+		suspended = maxRows > 0 && !cursor.Exhausted()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := WriteDataRowFormats(w, row, portal.Columns); err != nil {
+			return err
 		}
 	}
 	
-	return WriteCommandComplete(w, tag)
+	// If we hit the row limit, send PortalSuspended instead of CommandComplete
+	if suspended {
+		return WritePortalSuspended(w)
+	}
+	
+	return WriteCommandComplete(w, cursor.Tag(len(rows)))
 }
 
 // handleDescribe handles the Describe message (D)
-func (s *Server) handleDescribe(data []byte, extState *ExtendedProtocolState, w *bufio.Writer) error {
+func (s *Server) handleDescribe(data []byte, extState *ExtendedProtocolState, session *parser.Session, db *storage.Database, w *bufio.Writer) error {
 	buf := bytes.NewReader(data)
 	
 	// Read type ('S' for statement, 'P' for portal)
@@ -472,7 +675,7 @@ func (s *Server) handleDescribe(data []byte, extState *ExtendedProtocolState, w
 				switch node := stmtNode.Node.(type) {
 				case *pg_query.Node_SelectStmt:
 					// Analyze the SELECT query to get column descriptions
-					colDescs, err := s.analyzeSelectColumns(node.SelectStmt)
+					colDescs, err := s.analyzeSelectColumns(node.SelectStmt, session, db)
 					if err != nil {
 						return err
 					}
@@ -519,10 +722,11 @@ func (s *Server) handleDescribe(data []byte, extState *ExtendedProtocolState, w
 				case *pg_query.Node_SelectStmt:
 					// Analyze the SELECT query to get column descriptions
 					if selectStmt, ok := stmt.Node.(*pg_query.Node_SelectStmt); ok {
-						colDescs, err := s.analyzeSelectColumns(selectStmt.SelectStmt)
+						colDescs, err := s.analyzeSelectColumns(selectStmt.SelectStmt, session, db)
 						if err != nil {
 							return err
 						}
+						applyResultFormats(colDescs, portal.ResultFormats)
 						return WriteRowDescriptionExt(w, colDescs)
 					}
 					// Fallback to generic description
@@ -574,8 +778,26 @@ func (s *Server) handleClose(data []byte, extState *ExtendedProtocolState, w *bu
 	return WriteMessage(w, CloseComplete, []byte{})
 }
 
-// executePortal executes a portal with bound parameters
-func (s *Server) executePortal(portal *Portal) ([]string, [][]interface{}, string, error) {
+// applyResultFormats sets each column's format from the result format codes
+// given in Bind
+func applyResultFormats(colDescs []ColumnDescription, formats []int16) {
+	for i := range colDescs {
+		colDescs[i].Format = formatCode(formats, i)
+	}
+}
+
+// openPortal starts executing a portal with bound parameters
+func (s *Server) openPortal(portal *Portal, session *parser.Session, db *storage.Database) (*parser.Cursor, error) {
+	query, err := portalQuery(portal)
+	if err != nil {
+		return nil, err
+	}
+	return parser.OpenPgQuery(query, session, db.DataStore, db.MetaStore)
+}
+
+// portalQuery substitutes a portal's parameter values into its query text.
+// Parameters sent in binary are decoded to their text form first.
+func portalQuery(portal *Portal) (string, error) {
 	// Replace parameters in the query
 	query := portal.Statement.Query
 	
@@ -597,46 +819,19 @@ func (s *Server) executePortal(portal *Portal) ([]string, [][]interface{}, strin
 			}
 			
 			// Check the parameter format (text vs binary)
-			paramFormat := int16(0) // Default to text
-			if i < len(portal.ParameterFormats) {
-				paramFormat = portal.ParameterFormats[i]
+			if formatCode(portal.ParameterFormats, i) == FormatBinary {
+				text, err := decodeBinary(paramValue, paramType)
+				if err != nil {
+					return "", err
+				}
+				paramValue = []byte(text)
 			}
 			
 			// Format the value based on type
 			switch paramType {
 			case OIDInt2, OIDInt4, OIDInt8:
 				// Numeric types - don't quote
-				if paramFormat == 1 {
-					// Binary format - decode as integer
-					if len(paramValue) == 8 {
-						// int64 in big-endian
-						intVal := int64(0)
-						for j := 0; j < 8; j++ {
-							intVal = (intVal << 8) | int64(paramValue[j])
-						}
-						value = fmt.Sprintf("%d", intVal)
-					} else if len(paramValue) == 4 {
-						// int32 in big-endian
-						intVal := int32(0)
-						for j := 0; j < 4; j++ {
-							intVal = (intVal << 8) | int32(paramValue[j])
-						}
-						value = fmt.Sprintf("%d", intVal)
-					} else if len(paramValue) == 2 {
-						// int16 in big-endian
-						intVal := int16(0)
-						for j := 0; j < 2; j++ {
-							intVal = (intVal << 8) | int16(paramValue[j])
-						}
-						value = fmt.Sprintf("%d", intVal)
-					} else {
-						// Fallback to text
-						value = string(paramValue)
-					}
-				} else {
-					// Text format
-					value = string(paramValue)
-				}
+				value = string(paramValue)
 			case OIDFloat4, OIDFloat8:
 				// Float types - don't quote
 				value = string(paramValue)
@@ -663,8 +858,7 @@ func (s *Server) executePortal(portal *Portal) ([]string, [][]interface{}, strin
 		query = strings.ReplaceAll(query, placeholder, value)
 	}
 	
-	// Execute the substituted query
-	return parser.ExecutePgQuery(query, s.dataStore, s.metaStore)
+	return query, nil
 }
 
 // readCString reads a null-terminated string from the buffer
@@ -683,22 +877,56 @@ func readCString(buf *bytes.Reader) (string, error) {
 	return string(result), nil
 }
 
-// analyzeSelectColumns analyzes a SELECT statement and returns column descriptions
-func (s *Server) analyzeSelectColumns(stmt *pg_query.SelectStmt) ([]ColumnDescription, error) {
+// analyzeSelectColumns analyzes a SELECT statement and returns column
+// descriptions. Tables are found on the session's search path.
+func (s *Server) analyzeSelectColumns(stmt *pg_query.SelectStmt, session *parser.Session, db *storage.Database) ([]ColumnDescription, error) {
 	var colDescs []ColumnDescription
+	dataStore, metaStore := parser.WithSystemCatalog(stmt, db.DataStore, db.MetaStore)
+	sources := fromSources(stmt.FromClause, func(rv *pg_query.RangeVar) string {
+		return parser.ResolveTableName(rv, session, db.DataStore)
+	})
 	
 	// Extract table name from FROM clause
 	var tableName string
-	if len(stmt.FromClause) > 0 {
-		tableName = extractTableNameFromNode(stmt.FromClause[0])
-		// Handle quoted names
-		tableName = strings.Trim(tableName, `"`)
+	if len(sources) > 0 {
+		tableName = sources[0].table
+	}
+	
+	// tableColumn describes a column read straight from a table, with the
+	// table's OID and the column's number
+	tableColumn := func(name, table, col string) ColumnDescription {
+		typeOID, typeSize, typeMod := columnType(metaStore, table, col)
+		colDesc := ColumnDescription{
+			Name:     name,
+			TypeOID:  typeOID,
+			TypeSize: typeSize,
+			TypeMod:  typeMod,
+			Format:   0, // Text format
+		}
+		if t, exists := dataStore.GetTable(table); exists {
+			colDesc.TableOID = int32(t.OID)
+			colDesc.ColumnNum = int16(metaStore.GetColumnNumber(table, col))
+		}
+		return colDesc
 	}
 	
 	// Process target list
-	colNum := int16(1)
 	for _, target := range stmt.TargetList {
 		if resTarget, ok := target.Node.(*pg_query.Node_ResTarget); ok {
+			// * and t.* expand to the columns of every table they cover
+			if colRef, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_ColumnRef); ok && isStarRef(colRef.ColumnRef) {
+				qualifier := columnQualifier(colRef.ColumnRef)
+				for _, source := range sources {
+					if qualifier != "" && qualifier != source.alias {
+						continue
+					}
+					for _, col := range tableColumns(dataStore, metaStore, source.table) {
+						colDescs = append(colDescs, tableColumn(col, source.table, col))
+					}
+				}
+				continue
+			}
+			
 			// Get column name
 			var colName string
 			if resTarget.ResTarget.Name != "" {
@@ -710,6 +938,9 @@ func (s *Server) analyzeSelectColumns(stmt *pg_query.SelectStmt) ([]ColumnDescri
 			} else if funcCall, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_FuncCall); ok {
 				// Function call
 				colName = extractFunctionName(funcCall.FuncCall)
+			} else if svf, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_SqlvalueFunction); ok {
+				// Keyword such as CURRENT_USER
+				colName = parser.SQLValueFunctionName(svf.SqlvalueFunction)
 			} else if _, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_AConst); ok {
 				// Constant
 				colName = "?column?"
@@ -718,74 +949,58 @@ func (s *Server) analyzeSelectColumns(stmt *pg_query.SelectStmt) ([]ColumnDescri
 				colName = "?column?"
 			}
 			
+			// Columns read from a table take its column's type
+			if colRef, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_ColumnRef); ok {
+				if table := resolveColumnTable(colRef.ColumnRef, sources, metaStore); table != "" {
+					colDescs = append(colDescs, tableColumn(colName, table, extractColumnName(colRef.ColumnRef)))
+					continue
+				}
+			}
+			
 			// Get column type
 			var typeOID int32 = OIDText  // Default to text
 			var typeSize int16 = -1
 			var typeMod int32 = -1
 			
-			// Try to determine type from metadata if it's a column reference
-			if tableName != "" && resTarget.ResTarget.Val != nil {
-				if colRef, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_ColumnRef); ok {
-					actualColName := extractColumnName(colRef.ColumnRef)
-					colType := s.metaStore.GetColumnType(tableName, actualColName)
-					// Always use the actual type, even if unknown
-					typeOID = VSQLTypeToOID(colType)
-					typeSize, typeMod = GetTypeSizeAndMod(typeOID)
+			// -> and #> extract jsonb; ->> and #>> extract text
+			if resTarget.ResTarget.Val != nil {
+				if aExpr, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_AExpr); ok && len(aExpr.AExpr.Name) == 1 {
+					if op, ok := aExpr.AExpr.Name[0].Node.(*pg_query.Node_String_); ok && (op.String_.Sval == "->" || op.String_.Sval == "#>") {
+						typeOID = OIDJSONB
+						typeSize, typeMod = GetTypeSizeAndMod(typeOID)
+					}
 				}
 			}
-			
-			colDesc := ColumnDescription{
-				Name:      colName,
-				TableOID:  0,  // We don't track table OIDs yet
-				ColumnNum: colNum,
-				TypeOID:   typeOID,
-				TypeSize:  typeSize,
-				TypeMod:   typeMod,
-				Format:    0,  // Text format
+			// Casts take the type they name
+			if resTarget.ResTarget.Val != nil {
+				if typeCast, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_TypeCast); ok {
+					oid, modifiers := parser.CastType(typeCast.TypeCast.TypeName, db.MetaStore)
+					typeOID = int32(oid)
+					typeSize, _ = GetTypeSizeAndMod(typeOID)
+					typeMod = TypeModifier(typeOID, modifiers)
+				}
 			}
-			colDescs = append(colDescs, colDesc)
-			colNum++
-		}
-	}
-	
-	// Handle SELECT * case
-	if len(colDescs) == 0 && tableName != "" {
-		// Get all columns from the table
-		columns := s.metaStore.GetTableColumns(tableName)
-		if len(columns) == 0 {
-			// If no columns defined in metastore, try to get from first row
-			// This supports truly schema-less operation
-			table, exists := s.dataStore.GetTable(tableName)
-			if exists {
-				rows := table.GetRows()
-				if len(rows) > 0 {
-					// Get columns from first row
-					for colName := range rows[0] {
-						columns = append(columns, colName)
+			// Function results take the return type registered for the function
+			if resTarget.ResTarget.Val != nil {
+				if funcCall, ok := resTarget.ResTarget.Val.Node.(*pg_query.Node_FuncCall); ok {
+					if colType, ok := functionResultType(metaStore, funcCall.FuncCall, tableName); ok {
+						typeOID = VSQLTypeToOID(colType)
+						typeSize, typeMod = GetTypeSizeAndMod(typeOID)
 					}
-					// Sort for consistent ordering
-					sort.Strings(columns)
 				}
 			}
-		}
-		
-		colNum := int16(1)
-		for _, col := range columns {
-			colType := s.metaStore.GetColumnType(tableName, col)
-			typeOID := VSQLTypeToOID(colType)
-			typeSize, typeMod := GetTypeSizeAndMod(typeOID)
 			
+			// Computed columns have no table or column number
 			colDesc := ColumnDescription{
-				Name:      col,
+				Name:      colName,
 				TableOID:  0,
-				ColumnNum: colNum,
+				ColumnNum: 0,
 				TypeOID:   typeOID,
 				TypeSize:  typeSize,
 				TypeMod:   typeMod,
-				Format:    0,
+				Format:    0,  // Text format
 			}
 			colDescs = append(colDescs, colDesc)
-			colNum++
 		}
 	}
 	
@@ -805,6 +1020,179 @@ func (s *Server) analyzeSelectColumns(stmt *pg_query.SelectStmt) ([]ColumnDescri
 	return colDescs, nil
 }
 
+// fromSource is a table named in a FROM clause, with the name queries use
+// for it: its alias, or the table name itself
+type fromSource struct {
+	alias string
+	table string
+}
+
+// fromSources lists the tables of a FROM clause, including joined ones, in
+// the order they appear. resolve gives the name each table is stored under.
+func fromSources(fromClause []*pg_query.Node, resolve func(*pg_query.RangeVar) string) []fromSource {
+	var sources []fromSource
+	var walk func(node *pg_query.Node)
+	walk = func(node *pg_query.Node) {
+		if node == nil {
+			return
+		}
+		switch n := node.Node.(type) {
+		case *pg_query.Node_RangeVar:
+			table := resolve(n.RangeVar)
+			alias := strings.Trim(n.RangeVar.Relname, `"`)
+			if n.RangeVar.Alias != nil && n.RangeVar.Alias.Aliasname != "" {
+				alias = n.RangeVar.Alias.Aliasname
+			}
+			sources = append(sources, fromSource{alias: alias, table: table})
+		case *pg_query.Node_JoinExpr:
+			walk(n.JoinExpr.Larg)
+			walk(n.JoinExpr.Rarg)
+		}
+	}
+	for _, node := range fromClause {
+		walk(node)
+	}
+	return sources
+}
+
+// resolveColumnTable returns the table a column reference reads from: the
+// table its qualifier names, or else the first table with such a column
+func resolveColumnTable(colRef *pg_query.ColumnRef, sources []fromSource, metaStore *storage.MetaStore) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	col := extractColumnName(colRef)
+	qualifier := columnQualifier(colRef)
+	for _, source := range sources {
+		if qualifier != "" {
+			if qualifier == source.alias {
+				return source.table
+			}
+			continue
+		}
+		if metaStore.GetColumnNumber(source.table, col) > 0 {
+			return source.table
+		}
+	}
+	if qualifier == "" {
+		return sources[0].table
+	}
+	return ""
+}
+
+// tableColumns returns a table's columns in order. Tables whose columns
+// were never recorded take them from their first row, sorted.
+func tableColumns(dataStore *storage.DataStore, metaStore *storage.MetaStore, tableName string) []string {
+	columns := metaStore.GetTableColumns(tableName)
+	if len(columns) > 0 {
+		return columns
+	}
+	if table, exists := dataStore.GetTable(tableName); exists {
+		if rows := table.GetRows(); len(rows) > 0 {
+			for colName := range rows[0] {
+				columns = append(columns, colName)
+			}
+			sort.Strings(columns)
+		}
+	}
+	return columns
+}
+
+// isStarRef reports whether a column reference is * or t.*
+func isStarRef(colRef *pg_query.ColumnRef) bool {
+	if len(colRef.Fields) == 0 {
+		return false
+	}
+	_, ok := colRef.Fields[len(colRef.Fields)-1].Node.(*pg_query.Node_AStar)
+	return ok
+}
+
+// columnQualifier returns the table name or alias a column reference is
+// qualified with, or "" if it has none
+func columnQualifier(colRef *pg_query.ColumnRef) string {
+	if len(colRef.Fields) < 2 {
+		return ""
+	}
+	if str, ok := colRef.Fields[len(colRef.Fields)-2].Node.(*pg_query.Node_String_); ok {
+		return strings.Trim(str.String_.Sval, `"`)
+	}
+	return ""
+}
+
+// columnType returns the OID, size and type modifier of a table column's
+// type. Columns without values yet report the type they were declared with.
+// Integer columns report their declared width, json columns json rather
+// than jsonb, and varchar(n), char(n) and numeric(p,s) columns their
+// declared modifiers.
+func columnType(metaStore *storage.MetaStore, tableName, columnName string) (oid int32, size int16, mod int32) {
+	colType := metaStore.GetColumnType(tableName, columnName)
+	if colType == storage.TypeUnknown {
+		if declared, ok := metaStore.GetDeclaredType(tableName, columnName); ok {
+			colType = declared
+		}
+	}
+	oid = VSQLTypeToOID(colType)
+	switch colType {
+	case storage.TypeInteger:
+		switch metaStore.GetColumnIntegerSize(tableName, columnName) {
+		case 2:
+			oid = OIDInt2
+		case 8:
+			oid = OIDInt8
+		}
+	case storage.TypeString:
+		switch metaStore.GetColumnTypeName(tableName, columnName) {
+		case "varchar":
+			oid = OIDVarchar
+		case "bpchar":
+			oid = OIDBpchar
+		}
+	case storage.TypeJSON:
+		if metaStore.GetColumnTypeName(tableName, columnName) == "json" {
+			oid = OIDJSON
+		}
+	}
+	size, _ = GetTypeSizeAndMod(oid)
+	return oid, size, TypeModifier(oid, metaStore.GetColumnTypeModifiers(tableName, columnName))
+}
+
+// functionResultType looks up the return type of a function call from the
+// function registry, using the types of its column and constant arguments
+func functionResultType(metaStore *storage.MetaStore, funcCall *pg_query.FuncCall, tableName string) (storage.ColumnType, bool) {
+	var argTypes []storage.ColumnType
+	for _, arg := range funcCall.Args {
+		argType := storage.TypeUnknown
+		switch n := arg.Node.(type) {
+		case *pg_query.Node_ColumnRef:
+			if tableName != "" {
+				argType = metaStore.GetColumnType(tableName, extractColumnName(n.ColumnRef))
+			}
+		case *pg_query.Node_AConst:
+			argType = constType(n.AConst)
+		}
+		argTypes = append(argTypes, argType)
+	}
+	if funcCall.AggStar {
+		argTypes = []storage.ColumnType{storage.TypeUnknown}
+	}
+	return parser.FunctionResultType(extractFunctionName(funcCall), argTypes)
+}
+
+// constType returns the column type of a constant
+func constType(c *pg_query.A_Const) storage.ColumnType {
+	switch c.Val.(type) {
+	case *pg_query.A_Const_Ival:
+		return storage.TypeInteger
+	case *pg_query.A_Const_Fval:
+		return storage.TypeNumeric
+	case *pg_query.A_Const_Sval:
+		return storage.TypeString
+	case *pg_query.A_Const_Boolval:
+		return storage.TypeBoolean
+	}
+	return storage.TypeUnknown
+}
+
 // extractTableNameFromNode extracts table name from a FROM clause node
 func extractTableNameFromNode(node *pg_query.Node) string {
 	if rangeVar, ok := node.Node.(*pg_query.Node_RangeVar); ok && rangeVar.RangeVar != nil {
@@ -836,7 +1224,7 @@ func extractColumnName(colRef *pg_query.ColumnRef) string {
 // extractFunctionName extracts function name from a FuncCall
 func extractFunctionName(funcCall *pg_query.FuncCall) string {
 	if len(funcCall.Funcname) > 0 {
-		if str, ok := funcCall.Funcname[0].Node.(*pg_query.Node_String_); ok {
+		if str, ok := funcCall.Funcname[len(funcCall.Funcname)-1].Node.(*pg_query.Node_String_); ok {
 			return str.String_.Sval
 		}
 	}
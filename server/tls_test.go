@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/satetsu888/vsql/parser"
+	"github.com/satetsu888/vsql/storage"
+)
+
+// writeStartupPacket sends a startup-phase packet: a request code or
+// protocol version, then its parameters
+func writeStartupPacket(w io.Writer, code uint32, params string) error {
+	packet := make([]byte, 8, 8+len(params))
+	binary.BigEndian.PutUint32(packet, uint32(8+len(params)))
+	binary.BigEndian.PutUint32(packet[4:], code)
+	_, err := w.Write(append(packet, params...))
+	return err
+}
+
+func TestStartupNegotiatesTLS(t *testing.T) {
+	config, err := SelfSignedTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(0, storage.NewCluster(parser.DefaultDatabase))
+	s.SetTLS(config)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- s.handleStartup(serverConn, bufio.NewReader(serverConn), bufio.NewWriter(serverConn), parser.NewSession())
+	}()
+
+	response := make([]byte, 1)
+	expect := func(code uint32, want byte) {
+		t.Helper()
+		if err := writeStartupPacket(clientConn, code, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadFull(clientConn, response); err != nil {
+			t.Fatal(err)
+		}
+		if response[0] != want {
+			t.Fatalf("response to request %d = %q, want %q", code, response[0], want)
+		}
+	}
+	expect(gssEncRequestCode, 'N')
+	expect(sslRequestCode, 'S')
+
+	tlsConn := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if err := writeStartupPacket(tlsConn, 196608, "user\x00app\x00\x00"); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ReadMessage(tlsConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != AuthenticationOk || binary.BigEndian.Uint32(msg.Data) != 0 {
+		t.Errorf("expected AuthenticationOk over TLS, got %c %v", msg.Type, msg.Data)
+	}
+	go io.Copy(io.Discard, tlsConn)
+	if err := <-done; err != nil {
+		t.Errorf("startup failed: %v", err)
+	}
+}
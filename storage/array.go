@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeArray marks an array column type. The low bits hold the element
+// type, so ArrayOf(TypeInteger) is integer[].
+const TypeArray ColumnType = 1 << 8
+
+// ArrayOf returns the type of arrays of elem
+func ArrayOf(elem ColumnType) ColumnType {
+	return TypeArray | elem
+}
+
+// IsArrayType reports whether t is an array type
+func IsArrayType(t ColumnType) bool {
+	return t&TypeArray != 0
+}
+
+// ElementType returns the element type of an array type
+func ElementType(t ColumnType) ColumnType {
+	return t &^ TypeArray
+}
+
+// Array is a one-dimensional SQL array. Elements are values of ElemType,
+// or nil for NULL, and are subscripted from 1. An array whose elements
+// are all NULL, or that has none, may have an unknown element type.
+type Array struct {
+	ElemType ColumnType
+	Elems    []interface{}
+}
+
+// MalformedArrayError reports text that is not an array literal
+type MalformedArrayError struct {
+	Input  string
+	Reason string
+}
+
+func (e MalformedArrayError) Error() string {
+	return fmt.Sprintf("malformed array literal: \"%s\"", e.Input)
+}
+
+// SQLState returns the PostgreSQL error code for invalid_text_representation
+func (e MalformedArrayError) SQLState() string {
+	return "22P02"
+}
+
+// Detail explains what is wrong with the literal
+func (e MalformedArrayError) Detail() string {
+	return e.Reason
+}
+
+// String formats the array as PostgreSQL outputs it, such as {1,NULL,"a b"}
+func (a Array) String() string {
+	parts := make([]string, len(a.Elems))
+	for i, elem := range a.Elems {
+		parts[i] = arrayElementText(elem)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// arrayElementText formats an element, quoting it when it would otherwise
+// read back differently
+func arrayElementText(elem interface{}) string {
+	switch v := elem.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	}
+	s := fmt.Sprint(elem)
+	if s != "" && !strings.EqualFold(s, "NULL") && !strings.ContainsAny(s, "{},\"\\ \t\n\r\v\f") {
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// ParseArrayText reads an array literal such as {a,"b c",NULL}. Elements
+// are returned as text, or nil for an unquoted NULL, for the caller to
+// convert to the element type.
+func ParseArrayText(s string) ([]interface{}, error) {
+	malformed := func(reason string) error {
+		return MalformedArrayError{Input: s, Reason: reason}
+	}
+	text := strings.TrimSpace(s)
+	if !strings.HasPrefix(text, "{") {
+		return nil, malformed("Array value must start with \"{\" or dimension information.")
+	}
+	if !strings.HasSuffix(text, "}") {
+		return nil, malformed("Unexpected end of input.")
+	}
+	inner := text[1 : len(text)-1]
+	if strings.TrimSpace(inner) == "" {
+		return []interface{}{}, nil
+	}
+
+	var elems []interface{}
+	i := 0
+	for {
+		for i < len(inner) && isArraySpace(inner[i]) {
+			i++
+		}
+		var sb strings.Builder
+		quoted := false
+		if i < len(inner) && inner[i] == '"' {
+			quoted = true
+			i++
+			closed := false
+			for i < len(inner) {
+				c := inner[i]
+				if c == '\\' && i+1 < len(inner) {
+					sb.WriteByte(inner[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if c == '"' {
+					closed = true
+					break
+				}
+				sb.WriteByte(c)
+			}
+			if !closed {
+				return nil, malformed("Unexpected end of input.")
+			}
+			for i < len(inner) && isArraySpace(inner[i]) {
+				i++
+			}
+		} else {
+			for i < len(inner) && inner[i] != ',' {
+				c := inner[i]
+				switch {
+				case c == '{' || c == '}':
+					return nil, malformed("Multidimensional arrays are not supported.")
+				case c == '"':
+					return nil, malformed("Unexpected \"\"\" character.")
+				case c == '\\' && i+1 < len(inner):
+					sb.WriteByte(inner[i+1])
+					i += 2
+					continue
+				}
+				sb.WriteByte(c)
+				i++
+			}
+		}
+
+		elem := sb.String()
+		if !quoted {
+			elem = strings.TrimRight(elem, " \t\n\r\v\f")
+			if elem == "" {
+				return nil, malformed("Unexpected \",\" character.")
+			}
+		}
+		if !quoted && strings.EqualFold(elem, "NULL") {
+			elems = append(elems, nil)
+		} else {
+			elems = append(elems, elem)
+		}
+
+		if i >= len(inner) {
+			return elems, nil
+		}
+		if inner[i] != ',' {
+			return nil, malformed(fmt.Sprintf("Unexpected \"%c\" character.", inner[i]))
+		}
+		i++
+	}
+}
+
+func isArraySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArrayText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []interface{}
+	}{
+		{"{}", []interface{}{}},
+		{"{a,b}", []interface{}{"a", "b"}},
+		{"{ a , b }", []interface{}{"a", "b"}},
+		{`{"a b","c,d",NULL,"NULL"}`, []interface{}{"a b", "c,d", nil, "NULL"}},
+		{`{"say \"hi\"",a\,b}`, []interface{}{`say "hi"`, "a,b"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseArrayText(tt.input)
+		if err != nil {
+			t.Errorf("ParseArrayText(%q) error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseArrayText(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseArrayTextMalformed(t *testing.T) {
+	for _, input := range []string{"a,b", "{a,b", "{a,,b}", `{"a}`, "{{1},{2}}", `{"a"b}`} {
+		_, err := ParseArrayText(input)
+		if _, ok := err.(MalformedArrayError); !ok {
+			t.Errorf("ParseArrayText(%q) error = %v, want MalformedArrayError", input, err)
+		}
+	}
+}
+
+func TestArrayString(t *testing.T) {
+	tests := []struct {
+		array Array
+		want  string
+	}{
+		{Array{ElemType: TypeInteger, Elems: []interface{}{1, nil, 3}}, "{1,NULL,3}"},
+		{Array{ElemType: TypeString, Elems: []interface{}{"a b", "", "null", `q"`}}, `{"a b","","null","q\""}`},
+		{Array{ElemType: TypeBoolean, Elems: []interface{}{true, false}}, "{t,f}"},
+		{Array{ElemType: TypeString, Elems: []interface{}{}}, "{}"},
+	}
+	for _, tt := range tests {
+		if got := tt.array.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestArrayTypes(t *testing.T) {
+	intArray := ArrayOf(TypeInteger)
+	if !IsArrayType(intArray) || IsArrayType(TypeInteger) {
+		t.Fatal("IsArrayType does not distinguish arrays")
+	}
+	if ElementType(intArray) != TypeInteger {
+		t.Errorf("ElementType = %v, want integer", ElementType(intArray))
+	}
+	if got := TypeToString(intArray); got != TypeToString(TypeInteger)+"[]" {
+		t.Errorf("TypeToString = %s", got)
+	}
+}
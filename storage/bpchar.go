@@ -0,0 +1,13 @@
+package storage
+
+import "strings"
+
+// Bpchar is a value of char(n), kept padded with spaces to the declared
+// length. Its trailing spaces are not significant: they are ignored when
+// values are compared and removed when a value becomes text.
+type Bpchar string
+
+// Text returns the value without its padding
+func (b Bpchar) Text() string {
+	return strings.TrimRight(string(b), " ")
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Bytea is a binary string. It is kept as a Go string so that values stay
+// comparable and can be used as index keys.
+type Bytea string
+
+// InvalidByteaError reports text that is not a bytea literal
+type InvalidByteaError struct {
+	Message string
+	Code    string
+}
+
+func (e InvalidByteaError) Error() string {
+	return e.Message
+}
+
+// SQLState returns the PostgreSQL error code for the failure
+func (e InvalidByteaError) SQLState() string {
+	return e.Code
+}
+
+// ParseBytea reads a bytea literal in hex format (\x0a1b) or escape format,
+// where \\ is a backslash and \nnn an octal byte
+func ParseBytea(s string) (Bytea, error) {
+	if strings.HasPrefix(s, `\x`) {
+		var digits strings.Builder
+		for _, r := range s[2:] {
+			switch {
+			case r < 0x80 && isArraySpace(byte(r)):
+				// Whitespace may separate pairs of digits
+			case strings.ContainsRune("0123456789abcdefABCDEF", r):
+				digits.WriteRune(r)
+			default:
+				return "", InvalidByteaError{Message: fmt.Sprintf("invalid hexadecimal digit: \"%c\"", r), Code: "22023"}
+			}
+		}
+		if digits.Len()%2 != 0 {
+			return "", InvalidByteaError{Message: "invalid hexadecimal data: odd number of digits", Code: "22023"}
+		}
+		data, _ := hex.DecodeString(digits.String())
+		return Bytea(data), nil
+	}
+
+	var data []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			data = append(data, s[i])
+			continue
+		}
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			data = append(data, '\\')
+			i++
+		case i+3 < len(s) && isOctalByte(s[i+1:i+4]):
+			data = append(data, (s[i+1]-'0')<<6|(s[i+2]-'0')<<3|(s[i+3]-'0'))
+			i += 3
+		default:
+			return "", InvalidByteaError{Message: "invalid input syntax for type bytea", Code: "22P02"}
+		}
+	}
+	return Bytea(data), nil
+}
+
+func isOctalByte(s string) bool {
+	return s[0] >= '0' && s[0] <= '3' && s[1] >= '0' && s[1] <= '7' && s[2] >= '0' && s[2] <= '7'
+}
+
+// String formats the value in hex format, as PostgreSQL outputs it
+func (b Bytea) String() string {
+	return `\x` + hex.EncodeToString([]byte(b))
+}
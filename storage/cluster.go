@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Database is a named database of a cluster, with its own data and meta
+// stores
+type Database struct {
+	Name      string
+	OID       uint32
+	DataStore *DataStore
+	MetaStore *MetaStore
+
+	connections int // Sessions connected to the database
+}
+
+// DatabaseInUseError is returned when dropping a database other sessions
+// are connected to
+type DatabaseInUseError struct {
+	Name     string
+	Sessions int
+}
+
+func (e *DatabaseInUseError) Error() string {
+	return fmt.Sprintf("database \"%s\" is being accessed by other users", e.Name)
+}
+
+// EmptyTemplate names an empty template database, as PostgreSQL's pristine
+// template0 does, for clients that create databases from it
+const EmptyTemplate = "template0"
+
+// Cluster holds the databases of a server by name. New databases start out
+// as a copy of the template database, which cannot be dropped.
+type Cluster struct {
+	template  string
+	databases map[string]*Database
+	mu        sync.Mutex
+}
+
+// NewCluster creates a cluster holding an empty template database
+func NewCluster(template string) *Cluster {
+	c := &Cluster{
+		template:  template,
+		databases: make(map[string]*Database),
+	}
+	c.databases[template] = &Database{
+		Name:      template,
+		OID:       NextOID(),
+		DataStore: NewDataStore(),
+		MetaStore: NewMetaStore(),
+	}
+	return c
+}
+
+// Template returns the name of the template database
+func (c *Cluster) Template() string {
+	return c.template
+}
+
+// Get returns a database by name
+func (c *Cluster) Get(name string) (*Database, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db, exists := c.databases[name]
+	return db, exists
+}
+
+// List returns the names of all databases, sorted
+func (c *Cluster) List() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.databases))
+	for name := range c.databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create adds a database copied from the named template, or from the
+// cluster's template database when template is empty. EmptyTemplate can
+// be named without being created.
+func (c *Cluster) Create(name, template string) (*Database, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.databases[name]; exists {
+		return nil, fmt.Errorf("database \"%s\" already exists", name)
+	}
+	if template == "" {
+		template = c.template
+	}
+	source, exists := c.databases[template]
+	if !exists && template == EmptyTemplate {
+		source = &Database{DataStore: NewDataStore(), MetaStore: NewMetaStore()}
+	} else if !exists {
+		return nil, fmt.Errorf("template database \"%s\" does not exist", template)
+	}
+	return c.create(name, source), nil
+}
+
+// create copies source into a new database. Callers must hold the lock.
+func (c *Cluster) create(name string, source *Database) *Database {
+	db := &Database{
+		Name:      name,
+		OID:       NextOID(),
+		DataStore: source.DataStore.Copy(),
+		MetaStore: source.MetaStore.Copy(),
+	}
+	c.databases[name] = db
+	return db
+}
+
+// Connect returns the named database for a new session, creating it from
+// the template database the first time it is asked for. Each call must be
+// matched by a call to Disconnect.
+func (c *Cluster) Connect(name string) *Database {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	db, exists := c.databases[name]
+	if !exists {
+		db = c.create(name, c.databases[c.template])
+	}
+	db.connections++
+	return db
+}
+
+// Disconnect records that a session connected with Connect has ended
+func (c *Cluster) Disconnect(db *Database) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db.connections--
+}
+
+// Drop removes a database no session is connected to. Dropping a database
+// that does not exist is not an error.
+func (c *Cluster) Drop(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if name == c.template {
+		return fmt.Errorf("cannot drop a template database")
+	}
+	db, exists := c.databases[name]
+	if !exists {
+		return nil
+	}
+	if db.connections > 0 {
+		return &DatabaseInUseError{Name: name, Sessions: db.connections}
+	}
+	delete(c.databases, name)
+	return nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import "testing"
+
+func TestClusterDatabases(t *testing.T) {
+	cluster := NewCluster("main")
+	template := cluster.Connect("main")
+	template.DataStore.CreateTable("users")
+	template.MetaStore.AddColumns("users", []string{"id", "name"})
+	users, _ := template.DataStore.GetTable("users")
+	users.Insert(Row{"id": 1, "name": "Alice"})
+	template.DataStore.CreateIndex(NewIndex("users_id", "users", []string{"id"}, IndexMethodBTree, true))
+
+	// A database connected to for the first time starts as a copy of the template
+	ci := cluster.Connect("ci")
+	copied, exists := ci.DataStore.GetTable("users")
+	if !exists || copied == users || copied.OID != users.OID || len(copied.GetRows()) != 1 {
+		t.Fatal("new database should hold a copy of the template's tables")
+	}
+	copied.Insert(Row{"id": 2, "name": "Bob"})
+	ci.MetaStore.AddColumn("users", "email")
+	if len(users.GetRows()) != 1 || len(template.MetaStore.GetTableColumns("users")) != 2 {
+		t.Error("writes to a copied database should leave the template unchanged")
+	}
+	idx, exists := ci.DataStore.GetIndex("users_id")
+	if !exists || len(copied.IndexLookup(idx, []interface{}{2})) != 1 {
+		t.Error("indexes of a copied database should cover its own rows")
+	}
+	if err := copied.Insert(Row{"id": 1, "name": "Carol"}); err == nil {
+		t.Error("unique indexes should be copied")
+	}
+
+	if _, err := cluster.Create("ci", ""); err == nil {
+		t.Error("creating an existing database should fail")
+	}
+	empty, err := cluster.Create("fresh", EmptyTemplate)
+	if err != nil || len(empty.DataStore.ListTables()) != 0 {
+		t.Errorf("a database created from %s should be empty: %v", EmptyTemplate, err)
+	}
+	if _, err := cluster.Create("other", "missing"); err == nil {
+		t.Error("creating from a missing template should fail")
+	}
+
+	if err := cluster.Drop("ci"); err == nil {
+		t.Error("dropping a database in use should fail")
+	}
+	cluster.Disconnect(ci)
+	if err := cluster.Drop("ci"); err != nil {
+		t.Errorf("Drop failed: %v", err)
+	}
+	if err := cluster.Drop("main"); err == nil {
+		t.Error("dropping the template database should fail")
+	}
+	if got := cluster.List(); len(got) != 2 || got[0] != "fresh" || got[1] != "main" {
+		t.Errorf("List = %v", got)
+	}
+}
+
+func TestClusterCreateSkipsTemporaryTables(t *testing.T) {
+	cluster := NewCluster("main")
+	template := cluster.Connect("main")
+	template.DataStore.CreateTable("users")
+	template.DataStore.CreateSchema("pg_temp_3")
+	template.DataStore.CreateTable("pg_temp_3.scratch")
+	template.MetaStore.AddColumns("pg_temp_3.scratch", []string{"id"})
+	template.DataStore.CreateIndex(NewIndex("pg_temp_3.scratch_id", "pg_temp_3.scratch", []string{"id"}, IndexMethodBTree, false))
+
+	// Another session's temporary tables are not part of a new database
+	copied, err := cluster.Create("copy", "main")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := copied.DataStore.ListTables(); len(got) != 1 || got[0] != "users" {
+		t.Errorf("tables = %v, want [users]", got)
+	}
+	if _, exists := copied.DataStore.GetSchema("pg_temp_3"); exists {
+		t.Error("temporary schema should not be copied")
+	}
+	if len(copied.DataStore.ListIndexes()) != 0 || len(copied.MetaStore.GetTableColumns("pg_temp_3.scratch")) != 0 {
+		t.Error("temporary indexes and columns should not be copied")
+	}
+}
+
+// TestClusterEnumIsolation checks that enum types belong to their database,
+// and that a new database starts with a copy of the template's
+func TestClusterEnumIsolation(t *testing.T) {
+	cluster := NewCluster("main")
+	template := cluster.Connect("main")
+	mood, err := template.MetaStore.DefineEnum("mood", []string{"sad", "happy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbA, dbB := cluster.Connect("db_a"), cluster.Connect("db_b")
+	if got, ok := dbA.MetaStore.LookupEnum("mood"); !ok || got != mood {
+		t.Error("a new database should have the template's enum types")
+	}
+	if _, err := dbB.MetaStore.DefineEnum("status", []string{"open", "closed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dbA.MetaStore.LookupEnum("status"); ok {
+		t.Error("an enum type created in db_b should not be visible in db_a")
+	}
+	status, err := dbA.MetaStore.DefineEnum("status", []string{"new", "done"})
+	if err != nil {
+		t.Fatalf("db_a should be able to create its own status type: %v", err)
+	}
+	if def := EnumOf(status); def == nil || def.Labels[0] != "new" {
+		t.Errorf("db_a's status type = %v, want its own labels", def)
+	}
+
+	dbB.MetaStore.DropEnum("mood")
+	if _, ok := dbA.MetaStore.LookupEnum("mood"); !ok {
+		t.Error("dropping an enum type in db_b should leave db_a's unchanged")
+	}
+	if len(template.MetaStore.ListEnums()) != 1 {
+		t.Error("enum types created in other databases should leave the template unchanged")
+	}
+}
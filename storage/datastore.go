@@ -1,21 +1,75 @@
 package storage
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 )
 
 type Row map[string]interface{}
 
 type Table struct {
-	Name string
-	Rows []Row
-	mu   sync.RWMutex
+	Name    string
+	OID     uint32 // Object identifier, stable for the life of the table
+	Rows    []Row
+	ids     []int // Row ID of each row in Rows, ascending
+	nextID  int
+	indexes map[string]*Index
+	mu      sync.RWMutex
 }
 
-func (t *Table) Insert(row Row) {
+// Insert appends a row, maintaining all indexes.
+// Returns UniqueViolationError if the row duplicates a key of a unique index.
+func (t *Table) Insert(row Row) error {
+	return t.InsertRows([]Row{row})
+}
+
+// InsertRows appends rows, maintaining all indexes. Every row is checked
+// against the unique indexes before any is written, so on a
+// UniqueViolationError the table is left unchanged.
+func (t *Table) InsertRows(rows []Row) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	t.Rows = append(t.Rows, row)
+
+	if err := t.checkUnique(rows, nil); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		id := t.nextID
+		t.nextID++
+		t.Rows = append(t.Rows, row)
+		t.ids = append(t.ids, id)
+		for _, idx := range t.indexes {
+			idx.add(row, id)
+		}
+	}
+	return nil
+}
+
+// checkUnique returns a UniqueViolationError if any of rows duplicates a key
+// of a unique index, either among themselves or with a stored row other than
+// those whose IDs are in replaced
+func (t *Table) checkUnique(rows []Row, replaced map[int]bool) error {
+	for _, idx := range t.indexes {
+		if !idx.Unique {
+			continue
+		}
+		seen := make(map[string]bool, len(rows))
+		for _, row := range rows {
+			key := idx.keyFor(row)
+			hk, ok := hashKey(key)
+			if !ok {
+				// NULLs never collide
+				continue
+			}
+			if seen[hk] || idx.conflict(key, replaced) {
+				return idx.uniqueViolation(row)
+			}
+			seen[hk] = true
+		}
+	}
+	return nil
 }
 
 func (t *Table) GetRows() []Row {
@@ -26,32 +80,527 @@ func (t *Table) GetRows() []Row {
 	return result
 }
 
+// IndexScan selects the candidate rows an index returns for equality keys,
+// or for a range when there are no keys
+type IndexScan struct {
+	Index *Index
+	Keys  [][]interface{}
+	Lower *IndexBound
+	Upper *IndexBound
+}
+
+// Update replaces every row accepted by match with the row returned by apply,
+// which receives a copy of the original. All replacement rows are built and
+// checked against the unique indexes before any is written, so on an error
+// the table is left unchanged.
+// match and apply run under the table lock and must not access the table.
+func (t *Table) Update(match func(Row) bool, apply func(Row) (Row, error)) (int, error) {
+	return t.UpdateScan(nil, match, apply)
+}
+
+// UpdateScan is Update visiting only the candidate rows scan returns, or
+// every row when scan is nil
+func (t *Table) UpdateScan(scan *IndexScan, match func(Row) bool, apply func(Row) (Row, error)) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var positions []int
+	var newRows []Row
+	replaced := make(map[int]bool)
+	for _, pos := range t.scanPositions(scan) {
+		row := t.Rows[pos]
+		if !match(row) {
+			continue
+		}
+
+		newRow := make(Row, len(row))
+		for k, v := range row {
+			newRow[k] = v
+		}
+		newRow, err := apply(newRow)
+		if err != nil {
+			return 0, err
+		}
+		positions = append(positions, pos)
+		newRows = append(newRows, newRow)
+		replaced[t.ids[pos]] = true
+	}
+
+	if err := t.checkUnique(newRows, replaced); err != nil {
+		return 0, err
+	}
+	for i, pos := range positions {
+		id := t.ids[pos]
+		for _, idx := range t.indexes {
+			idx.remove(t.Rows[pos], id)
+			idx.add(newRows[i], id)
+		}
+		t.Rows[pos] = newRows[i]
+	}
+	return len(positions), nil
+}
+
+// Delete removes every row accepted by match and returns the number removed.
+// match runs under the table lock and must not access the table.
+func (t *Table) Delete(match func(Row) bool) int {
+	return t.DeleteScan(nil, match)
+}
+
+// DeleteScan is Delete visiting only the candidate rows scan returns, or
+// every row when scan is nil
+func (t *Table) DeleteScan(scan *IndexScan, match func(Row) bool) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deleted := make(map[int]bool)
+	for _, pos := range t.scanPositions(scan) {
+		if match(t.Rows[pos]) {
+			deleted[pos] = true
+		}
+	}
+	kept := 0
+	for pos, row := range t.Rows {
+		id := t.ids[pos]
+		if deleted[pos] {
+			for _, idx := range t.indexes {
+				idx.remove(row, id)
+			}
+			continue
+		}
+		t.Rows[kept] = row
+		t.ids[kept] = id
+		kept++
+	}
+	for pos := kept; pos < len(t.Rows); pos++ {
+		t.Rows[pos] = nil
+	}
+	t.Rows = t.Rows[:kept]
+	t.ids = t.ids[:kept]
+	return len(deleted)
+}
+
+// AddIndex builds idx over the existing rows and attaches it to the table
+func (t *Table) AddIndex(idx *Index) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	idx.rebuild(t.Rows, t.ids)
+	if idx.Unique {
+		for pos, row := range t.Rows {
+			if idx.conflict(idx.keyFor(row), map[int]bool{t.ids[pos]: true}) {
+				violation := idx.uniqueViolation(row)
+				return fmt.Errorf("could not create unique index \"%s\": key %s is duplicated", idx.Name, violation.key())
+			}
+		}
+	}
+
+	if t.indexes == nil {
+		t.indexes = make(map[string]*Index)
+	}
+	t.indexes[idx.Name] = idx
+	return nil
+}
+
+// RemoveIndex detaches an index from the table
+func (t *Table) RemoveIndex(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.indexes, name)
+}
+
+// Indexes returns the table's indexes sorted by name
+func (t *Table) Indexes() []*Index {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]*Index, 0, len(t.indexes))
+	for _, idx := range t.indexes {
+		result = append(result, idx)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// IndexLookup returns the rows whose indexed columns equal any of the given keys.
+// A key may cover a leading prefix of the columns of a B-tree index.
+func (t *Table) IndexLookup(idx *Index, keys ...[]interface{}) []Row {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.rowsWithIDs(lookupIDs(idx, keys))
+}
+
+// lookupIDs returns the IDs of the rows whose indexed columns equal any of
+// the given keys, each once
+func lookupIDs(idx *Index, keys [][]interface{}) []int {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, values := range keys {
+		for _, id := range idx.lookup(idx.keyForValues(values)) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// IndexRange returns candidate rows whose leading indexed column lies between
+// lower and upper (either may be nil). The result may contain rows outside the
+// range when the column mixes numeric and text values, so callers must still
+// apply their predicate.
+func (t *Table) IndexRange(idx *Index, lower, upper *IndexBound) []Row {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.rowsWithIDs(idx.rangeScan(lower, upper))
+}
+
+// IndexOrderedRows returns all rows in index order (NULLs last), or in reverse
+// order if desc is set. The second return value is false when the index can't
+// produce an order that matches the executor's sort order.
+func (t *Table) IndexOrderedRows(idx *Index, desc bool) ([]Row, bool) {
+	cursor, ok := t.IndexOrderedScan(idx, desc)
+	if !ok {
+		return nil, false
+	}
+	var result []Row
+	for row, ok := cursor.Next(); ok; row, ok = cursor.Next() {
+		result = append(result, row)
+	}
+	return result, true
+}
+
+// IndexOrderedScan returns a cursor over the rows in index order (NULLs
+// last), or in reverse order if desc is set. Rows are read one leaf page at a
+// time, so stopping early only reads the pages visited. The second return
+// value is false when the index can't produce an order that matches the
+// executor's sort order.
+func (t *Table) IndexOrderedScan(idx *Index, desc bool) (*IndexCursor, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !idx.orderable() {
+		return nil, false
+	}
+	return &IndexCursor{table: t, index: idx, desc: desc}, true
+}
+
+// IndexOrderable reports whether IndexOrderedRows can currently use idx
+func (t *Table) IndexOrderable(idx *Index) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return idx.orderable()
+}
+
+// IndexCursor walks the rows of a table in the order of a B-tree index. The
+// table lock is only held while a page is read, so the table may be written
+// between calls to Next; the cursor then continues after the last entry it
+// returned.
+type IndexCursor struct {
+	table   *Table
+	index   *Index
+	desc    bool
+	started bool
+	last    indexEntry
+	batch   []Row
+	done    bool
+}
+
+// Next returns the next row, or false once every row has been returned
+func (c *IndexCursor) Next() (Row, bool) {
+	if len(c.batch) == 0 && !c.done {
+		c.fill()
+	}
+	if len(c.batch) == 0 {
+		return nil, false
+	}
+	row := c.batch[0]
+	c.batch = c.batch[1:]
+	return row, true
+}
+
+// fill reads the rows of up to one page of index entries following the last
+// entry returned
+func (c *IndexCursor) fill() {
+	t := c.table
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	entries := &c.index.ordered
+	var ids []int
+	collect := func(e indexEntry) bool {
+		ids = append(ids, e.id)
+		c.last = e
+		return len(ids) < indexPageSize
+	}
+	if c.desc {
+		p, i := entries.last()
+		if c.started {
+			p, i = entries.before(c.last)
+		}
+		if p >= 0 {
+			entries.descend(p, i, collect)
+		}
+	} else {
+		p, i := 0, 0
+		if c.started {
+			p, i = entries.after(c.last)
+		}
+		entries.ascend(p, i, collect)
+	}
+	c.started = true
+
+	if len(ids) < indexPageSize {
+		c.done = true
+	}
+	for _, id := range ids {
+		if pos, ok := t.position(id); ok {
+			c.batch = append(c.batch, t.Rows[pos])
+		}
+	}
+}
+
+// position returns the position in Rows of the row with the given ID
+func (t *Table) position(id int) (int, bool) {
+	pos := sort.SearchInts(t.ids, id)
+	return pos, pos < len(t.ids) && t.ids[pos] == id
+}
+
+// scanPositions returns the positions in Rows of the candidates scan
+// returns, in table order, or of every row when scan is nil
+func (t *Table) scanPositions(scan *IndexScan) []int {
+	if scan == nil {
+		positions := make([]int, len(t.Rows))
+		for pos := range positions {
+			positions[pos] = pos
+		}
+		return positions
+	}
+	var ids []int
+	if len(scan.Keys) > 0 {
+		ids = lookupIDs(scan.Index, scan.Keys)
+	} else {
+		ids = scan.Index.rangeScan(scan.Lower, scan.Upper)
+	}
+	sort.Ints(ids)
+	var positions []int
+	for _, id := range ids {
+		if pos, ok := t.position(id); ok {
+			positions = append(positions, pos)
+		}
+	}
+	return positions
+}
+
+// rowsWithIDs returns the rows with the given IDs in table order
+func (t *Table) rowsWithIDs(ids []int) []Row {
+	sort.Ints(ids)
+	result := make([]Row, 0, len(ids))
+	for _, id := range ids {
+		if pos, ok := t.position(id); ok {
+			result = append(result, t.Rows[pos])
+		}
+	}
+	return result
+}
+
+// PublicSchema is the schema every data store starts with. Its tables are
+// stored under their plain names.
+const PublicSchema = "public"
+
+// TableKey returns the name a table or index in a schema is stored under:
+// its own name in the public schema, and "schema.name" in any other
+func TableKey(schema, name string) string {
+	if schema == "" || schema == PublicSchema {
+		return name
+	}
+	return schema + "." + name
+}
+
+// SplitTableKey returns the schema and name of a table or index stored
+// under key
+func SplitTableKey(key string) (schema, name string) {
+	for i := 0; i < len(key); i++ {
+		if key[i] == '.' {
+			return key[:i], key[i+1:]
+		}
+	}
+	return PublicSchema, key
+}
+
+// IsTempSchema reports whether a schema name refers to a session's temporary
+// schema: pg_temp for the session's own, pg_temp_N for a particular one
+func IsTempSchema(name string) bool {
+	return strings.HasPrefix(name, "pg_temp")
+}
+
+// isTempKey reports whether a table or index is stored in a temporary schema
+func isTempKey(key string) bool {
+	schema, _ := SplitTableKey(key)
+	return IsTempSchema(schema)
+}
+
 type DataStore struct {
-	tables map[string]*Table
-	mu     sync.RWMutex
+	schemas map[string]uint32 // Schema OIDs by name
+	tables  map[string]*Table // Tables by TableKey
+	indexes map[string]*Index // All indexes by TableKey
+	mu      sync.RWMutex
 }
 
 func NewDataStore() *DataStore {
 	return &DataStore{
-		tables: make(map[string]*Table),
+		schemas: map[string]uint32{PublicSchema: PublicNamespaceOID},
+		tables:  make(map[string]*Table),
+		indexes: make(map[string]*Index),
+	}
+}
+
+// CreateSchema adds an empty schema
+func (ds *DataStore) CreateSchema(name string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, exists := ds.schemas[name]; exists {
+		return fmt.Errorf("schema \"%s\" already exists", name)
+	}
+	ds.schemas[name] = NextOID()
+	return nil
+}
+
+// GetSchema returns the OID of a schema
+func (ds *DataStore) GetSchema(name string) (uint32, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	oid, exists := ds.schemas[name]
+	return oid, exists
+}
+
+// ListSchemas returns the names of all schemas, sorted
+func (ds *DataStore) ListSchemas() []string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	schemas := make([]string, 0, len(ds.schemas))
+	for name := range ds.schemas {
+		schemas = append(schemas, name)
+	}
+	sort.Strings(schemas)
+	return schemas
+}
+
+// SchemaTables returns the keys of the tables in a schema, sorted
+func (ds *DataStore) SchemaTables(schema string) []string {
+	var tables []string
+	for _, key := range ds.ListTables() {
+		if tableSchema, _ := SplitTableKey(key); tableSchema == schema {
+			tables = append(tables, key)
+		}
 	}
+	return tables
+}
+
+// DropSchema removes a schema. Its tables must be dropped first.
+func (ds *DataStore) DropSchema(name string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	delete(ds.schemas, name)
 }
 
 func (ds *DataStore) CreateTable(name string) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
-	
+
 	if _, exists := ds.tables[name]; exists {
 		return nil
 	}
-	
-	ds.tables[name] = &Table{
-		Name: name,
-		Rows: make([]Row, 0),
-	}
+
+	ds.tables[name] = NewTable(name, NextOID(), make([]Row, 0))
 	return nil
 }
 
+// NewTable returns a table holding rows that is not part of any data store,
+// such as a table of the system catalog generated for a query
+func NewTable(name string, oid uint32, rows []Row) *Table {
+	ids := make([]int, len(rows))
+	for i := range ids {
+		ids[i] = i
+	}
+	return &Table{Name: name, OID: oid, Rows: rows, ids: ids, nextID: len(rows)}
+}
+
+// Overlay returns a data store that starts out with this store's tables and
+// indexes. Tables added to or dropped from the overlay leave this store
+// unchanged, while rows written to a shared table are seen by both.
+func (ds *DataStore) Overlay() *DataStore {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	overlay := NewDataStore()
+	overlay.schemas = make(map[string]uint32, len(ds.schemas))
+	for name, oid := range ds.schemas {
+		overlay.schemas[name] = oid
+	}
+	for name, table := range ds.tables {
+		overlay.tables[name] = table
+	}
+	for name, idx := range ds.indexes {
+		overlay.indexes[name] = idx
+	}
+	return overlay
+}
+
+// Copy returns an independent copy of the data store. Tables, indexes and
+// schemas keep their OIDs, and writes to either store leave the other
+// unchanged.
+func (ds *DataStore) Copy() *DataStore {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	clone := NewDataStore()
+	clone.schemas = make(map[string]uint32, len(ds.schemas))
+	for name, oid := range ds.schemas {
+		if !IsTempSchema(name) {
+			clone.schemas[name] = oid
+		}
+	}
+	for name, table := range ds.tables {
+		// Temporary tables belong to the sessions of the copied database
+		if isTempKey(name) {
+			continue
+		}
+		rows := table.GetRows()
+		for i, row := range rows {
+			copied := make(Row, len(row))
+			for k, v := range row {
+				copied[k] = v
+			}
+			rows[i] = copied
+		}
+		clone.tables[name] = NewTable(table.Name, table.OID, rows)
+	}
+	for name, idx := range ds.indexes {
+		if isTempKey(name) {
+			continue
+		}
+		copied := NewIndex(idx.Name, idx.Table, idx.Columns, idx.Method, idx.Unique)
+		copied.OID = idx.OID
+		if table, ok := clone.tables[idx.Table]; ok {
+			table.AddIndex(copied)
+		}
+		clone.indexes[name] = copied
+	}
+	return clone
+}
+
+// AddTable adds a table built with NewTable, replacing any table of the
+// same name
+func (ds *DataStore) AddTable(table *Table) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.tables[table.Name] = table
+}
+
 func (ds *DataStore) GetTable(name string) (*Table, bool) {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
@@ -63,15 +612,82 @@ func (ds *DataStore) DropTable(name string) {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 	delete(ds.tables, name)
+	for indexName, idx := range ds.indexes {
+		if idx.Table == name {
+			delete(ds.indexes, indexName)
+		}
+	}
 }
 
+// ListTables returns the names of all tables, sorted
 func (ds *DataStore) ListTables() []string {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
-	
+
 	tables := make([]string, 0, len(ds.tables))
 	for name := range ds.tables {
 		tables = append(tables, name)
 	}
+	sort.Strings(tables)
 	return tables
-}
\ No newline at end of file
+}
+
+// CreateIndex builds a new index on an existing table.
+// Index names are unique across the whole data store.
+func (ds *DataStore) CreateIndex(idx *Index) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, exists := ds.indexes[idx.Name]; exists {
+		return fmt.Errorf("relation \"%s\" already exists", idx.Name)
+	}
+	table, exists := ds.tables[idx.Table]
+	if !exists {
+		return fmt.Errorf("relation \"%s\" does not exist", idx.Table)
+	}
+	if err := table.AddIndex(idx); err != nil {
+		return err
+	}
+	if idx.OID == 0 {
+		idx.OID = NextOID()
+	}
+	ds.indexes[idx.Name] = idx
+	return nil
+}
+
+// ListIndexes returns all indexes sorted by name
+func (ds *DataStore) ListIndexes() []*Index {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	result := make([]*Index, 0, len(ds.indexes))
+	for _, idx := range ds.indexes {
+		result = append(result, idx)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// GetIndex returns an index by name
+func (ds *DataStore) GetIndex(name string) (*Index, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	idx, exists := ds.indexes[name]
+	return idx, exists
+}
+
+// DropIndex removes an index by name
+func (ds *DataStore) DropIndex(name string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	idx, exists := ds.indexes[name]
+	if !exists {
+		return fmt.Errorf("index \"%s\" does not exist", name)
+	}
+	if table, ok := ds.tables[idx.Table]; ok {
+		table.RemoveIndex(name)
+	}
+	delete(ds.indexes, name)
+	return nil
+}
@@ -446,4 +446,66 @@ func TestTableListConcurrency(t *testing.T) {
 	}
 	
 	wg.Wait()
-}
\ No newline at end of file
+}
+// TestTableOIDsAndOverlay tests that tables keep their OIDs and that
+// overlays add tables without changing the underlying store
+func TestTableOIDsAndOverlay(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("users")
+	ds.CreateTable("orders")
+	users, _ := ds.GetTable("users")
+	orders, _ := ds.GetTable("orders")
+	if users.OID < FirstNormalOID || orders.OID == users.OID {
+		t.Fatalf("unexpected OIDs %d and %d", users.OID, orders.OID)
+	}
+	ds.CreateTable("users")
+	if again, _ := ds.GetTable("users"); again.OID != users.OID {
+		t.Errorf("OID changed from %d to %d", users.OID, again.OID)
+	}
+
+	overlay := ds.Overlay()
+	overlay.AddTable(NewTable("pg_class", 1259, []Row{{"relname": "users"}}))
+	if _, exists := ds.GetTable("pg_class"); exists {
+		t.Error("tables added to an overlay should not appear in the original store")
+	}
+	if shared, exists := overlay.GetTable("users"); !exists || shared != users {
+		t.Error("an overlay should share the original store's tables")
+	}
+	if got := overlay.ListTables(); len(got) != 3 || got[0] != "orders" {
+		t.Errorf("overlay tables = %v", got)
+	}
+}
+
+// TestSchemas tests that tables are kept apart by schema
+func TestSchemas(t *testing.T) {
+	ds := NewDataStore()
+	if oid, exists := ds.GetSchema(PublicSchema); !exists || oid != PublicNamespaceOID {
+		t.Fatalf("public schema = %d, %v", oid, exists)
+	}
+	if err := ds.CreateSchema("tenant"); err != nil {
+		t.Fatalf("CreateSchema failed: %v", err)
+	}
+	if err := ds.CreateSchema("tenant"); err == nil {
+		t.Error("creating an existing schema should fail")
+	}
+
+	ds.CreateTable(TableKey(PublicSchema, "users"))
+	ds.CreateTable(TableKey("tenant", "users"))
+	public, _ := ds.GetTable("users")
+	tenant, _ := ds.GetTable("tenant.users")
+	if public == nil || tenant == nil || public == tenant {
+		t.Fatal("tables of the same name in different schemas should be separate")
+	}
+	if schema, name := SplitTableKey("tenant.users"); schema != "tenant" || name != "users" {
+		t.Errorf("SplitTableKey = %q, %q", schema, name)
+	}
+	if schema, name := SplitTableKey("users"); schema != PublicSchema || name != "users" {
+		t.Errorf("SplitTableKey = %q, %q", schema, name)
+	}
+	if got := ds.SchemaTables("tenant"); len(got) != 1 || got[0] != "tenant.users" {
+		t.Errorf("SchemaTables = %v", got)
+	}
+	if got := ds.Overlay().ListSchemas(); len(got) != 2 || got[0] != PublicSchema || got[1] != "tenant" {
+		t.Errorf("overlay schemas = %v", got)
+	}
+}
@@ -0,0 +1,570 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date and time values. Each type prints itself the way PostgreSQL outputs
+// it with DateStyle ISO, so rows can be written to clients and compared as
+// text. Times are kept in UTC and carry microsecond precision.
+
+const (
+	MicrosPerSecond = int64(1000000)
+	MicrosPerMinute = 60 * MicrosPerSecond
+	MicrosPerHour   = 60 * MicrosPerMinute
+	MicrosPerDay    = 24 * MicrosPerHour
+)
+
+// Date is a calendar date
+type Date struct{ time.Time }
+
+// TimeOfDay is a time of day without a date, in microseconds since midnight
+type TimeOfDay struct{ Micros int64 }
+
+// Timestamp is a date and time without a time zone
+type Timestamp struct{ time.Time }
+
+// TimestampTZ is an absolute point in time, displayed in the time zone of
+// its Time, which is UTC unless it was converted for a session
+type TimestampTZ struct{ time.Time }
+
+// Interval is a span of time. Months, days and microseconds are kept apart
+// as PostgreSQL does, because months and days vary in length.
+type Interval struct {
+	Months int
+	Days   int
+	Micros int64
+}
+
+// InvalidDateTimeError reports text that cannot be read as a date/time value
+type InvalidDateTimeError struct {
+	Type  string
+	Input string
+}
+
+func (e InvalidDateTimeError) Error() string {
+	return fmt.Sprintf("invalid input syntax for type %s: \"%s\"", e.Type, e.Input)
+}
+
+// SQLState returns the PostgreSQL error code for invalid_datetime_format
+func (e InvalidDateTimeError) SQLState() string {
+	return "22007"
+}
+
+// NewDate returns the date for a year, month and day
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// DateOf returns the date part of t
+func DateOf(t time.Time) Date {
+	return NewDate(t.Date())
+}
+
+// TimeOf returns the time of day of t
+func TimeOf(t time.Time) TimeOfDay {
+	return TimeOfDay{int64(t.Hour())*MicrosPerHour + int64(t.Minute())*MicrosPerMinute +
+		int64(t.Second())*MicrosPerSecond + int64(t.Nanosecond()/1000)}
+}
+
+// NewTimestamp returns a timestamp with t's wall clock, rounded to microseconds
+func NewTimestamp(t time.Time) Timestamp {
+	y, m, d := t.Date()
+	return Timestamp{time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).Round(time.Microsecond)}
+}
+
+// NewTimestampTZ returns the point in time t, rounded to microseconds
+func NewTimestampTZ(t time.Time) TimestampTZ {
+	return TimestampTZ{t.UTC().Round(time.Microsecond)}
+}
+
+func (d Date) String() string {
+	return d.Format("2006-01-02")
+}
+
+func (t TimeOfDay) String() string {
+	return formatClock(t.Micros)
+}
+
+func (t Timestamp) String() string {
+	return t.Format("2006-01-02") + " " + TimeOf(t.Time).String()
+}
+
+func (t TimestampTZ) String() string {
+	_, offset := t.Zone()
+	return Timestamp{t.Time}.String() + formatOffset(offset)
+}
+
+// formatOffset formats a UTC offset in seconds as PostgreSQL does: "+09",
+// "-05" or "+05:30"
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	s := fmt.Sprintf("%s%02d", sign, offset/3600)
+	if minutes := offset % 3600 / 60; minutes != 0 {
+		s += fmt.Sprintf(":%02d", minutes)
+	}
+	return s
+}
+
+// FormatDateStyle formats a date, timestamp or timestamptz in a DateStyle
+// output style other than ISO: SQL, Postgres or German, with the day before
+// the month when order is DMY. It reports false for other values.
+func FormatDateStyle(value interface{}, style, order string) (string, bool) {
+	var t time.Time
+	var clock, zone bool
+	switch v := value.(type) {
+	case Date:
+		t = v.Time
+	case Timestamp:
+		t, clock = v.Time, true
+	case TimestampTZ:
+		t, clock, zone = v.Time, true, true
+	default:
+		return "", false
+	}
+
+	dmy := order == "DMY"
+	var s string
+	switch style {
+	case "SQL":
+		if dmy {
+			s = t.Format("02/01/2006")
+		} else {
+			s = t.Format("01/02/2006")
+		}
+	case "German":
+		s = t.Format("02.01.2006")
+	case "Postgres":
+		if !clock {
+			if dmy {
+				return t.Format("02-01-2006"), true
+			}
+			return t.Format("01-02-2006"), true
+		}
+		// Postgres style spells out the date around the time of day
+		if dmy {
+			s = t.Format("Mon 02 Jan ") + TimeOf(t).String() + t.Format(" 2006")
+		} else {
+			s = t.Format("Mon Jan 02 ") + TimeOf(t).String() + t.Format(" 2006")
+		}
+		if zone {
+			name, _ := t.Zone()
+			s += " " + name
+		}
+		return s, true
+	default:
+		return "", false
+	}
+	if clock {
+		s += " " + TimeOf(t).String()
+	}
+	if zone {
+		name, _ := t.Zone()
+		s += " " + name
+	}
+	return s, true
+}
+
+// String formats the interval in PostgreSQL's default output style,
+// e.g. "1 year 2 mons 3 days 04:05:06"
+func (iv Interval) String() string {
+	var parts []string
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	years, months := iv.Months/12, iv.Months%12
+	if years != 0 {
+		parts = append(parts, plural(years, "year"))
+	}
+	if months != 0 {
+		parts = append(parts, plural(months, "mon"))
+	}
+	if iv.Days != 0 {
+		parts = append(parts, plural(iv.Days, "day"))
+	}
+	if iv.Micros != 0 || len(parts) == 0 {
+		clock := formatClock(iv.Micros)
+		if iv.Micros > 0 && (iv.Months < 0 || iv.Days < 0) {
+			clock = "+" + clock
+		}
+		parts = append(parts, clock)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatClock formats microseconds as HH:MM:SS with any fractional seconds.
+// Hours are not wrapped at 24, so intervals can use it too.
+func formatClock(micros int64) string {
+	sign := ""
+	if micros < 0 {
+		sign = "-"
+		micros = -micros
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, micros/MicrosPerHour, micros/MicrosPerMinute%60, micros/MicrosPerSecond%60)
+	if frac := micros % MicrosPerSecond; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+	}
+	return s
+}
+
+// dateTimeLayouts are the accepted input formats, tried in order
+var dateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04Z07:00",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseDateTime reads a date with an optional time and zone. The second
+// return value reports whether the input named a zone.
+func parseDateTime(s string) (time.Time, bool, bool) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "epoch") {
+		return time.Unix(0, 0).UTC(), true, true
+	}
+	if strings.HasSuffix(strings.ToUpper(s), " UTC") {
+		t, _, ok := parseDateTime(s[:len(s)-4] + "Z")
+		return t, true, ok
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, strings.Contains(layout, "Z07"), true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// ParseDate reads a date; a time of day in the input is ignored
+func ParseDate(s string) (Date, error) {
+	t, _, ok := parseDateTime(s)
+	if !ok {
+		return Date{}, InvalidDateTimeError{Type: "date", Input: s}
+	}
+	return DateOf(t), nil
+}
+
+// ParseTime reads a time of day
+func ParseTime(s string) (TimeOfDay, error) {
+	input := strings.TrimSpace(s)
+	for _, layout := range []string{"15:04:05.999999999", "15:04:05.999999999Z07:00", "15:04", "15:04Z07:00"} {
+		if t, err := time.Parse(layout, input); err == nil {
+			return TimeOf(t.Round(time.Microsecond)), nil
+		}
+	}
+	if t, _, ok := parseDateTime(input); ok {
+		return TimeOf(t.Round(time.Microsecond)), nil
+	}
+	return TimeOfDay{}, InvalidDateTimeError{Type: "time without time zone", Input: s}
+}
+
+// ParseTimestamp reads a timestamp; a zone in the input is ignored
+func ParseTimestamp(s string) (Timestamp, error) {
+	t, _, ok := parseDateTime(s)
+	if !ok {
+		return Timestamp{}, InvalidDateTimeError{Type: "timestamp", Input: s}
+	}
+	return NewTimestamp(t), nil
+}
+
+// ParseTimestampTZ reads a timestamp with time zone; input without a zone is taken as UTC
+func ParseTimestampTZ(s string) (TimestampTZ, error) {
+	t, _, ok := parseDateTime(s)
+	if !ok {
+		return TimestampTZ{}, InvalidDateTimeError{Type: "timestamp with time zone", Input: s}
+	}
+	return NewTimestampTZ(t), nil
+}
+
+// intervalUnits maps the unit names accepted in interval input to a canonical unit
+var intervalUnits = map[string]string{
+	"microsecond": "microsecond", "microseconds": "microsecond", "us": "microsecond", "usec": "microsecond", "usecs": "microsecond",
+	"millisecond": "millisecond", "milliseconds": "millisecond", "ms": "millisecond", "msec": "millisecond", "msecs": "millisecond",
+	"second": "second", "seconds": "second", "sec": "second", "secs": "second", "s": "second",
+	"minute": "minute", "minutes": "minute", "min": "minute", "mins": "minute", "m": "minute",
+	"hour": "hour", "hours": "hour", "hr": "hour", "hrs": "hour", "h": "hour",
+	"day": "day", "days": "day", "d": "day",
+	"week": "week", "weeks": "week", "w": "week",
+	"month": "month", "months": "month", "mon": "month", "mons": "month",
+	"year": "year", "years": "year", "yr": "year", "yrs": "year", "y": "year",
+	"decade": "decade", "decades": "decade",
+	"century": "century", "centuries": "century",
+	"millennium": "millennium", "millennia": "millennium",
+}
+
+// ParseInterval reads an interval such as "1 year 2 months", "3 days 04:05:06",
+// "@ 2 hours ago" or ISO 8601 "P1DT2H"
+func ParseInterval(s string) (Interval, error) {
+	invalid := InvalidDateTimeError{Type: "interval", Input: s}
+	input := strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(input, "p") {
+		iv, ok := parseISOInterval(input[1:])
+		if !ok {
+			return Interval{}, invalid
+		}
+		return iv, nil
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(input, "@"))
+	if len(fields) == 0 {
+		return Interval{}, invalid
+	}
+	var iv Interval
+	ago := false
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if field == "ago" && i == len(fields)-1 {
+			ago = true
+			continue
+		}
+		if strings.Contains(field, ":") {
+			micros, ok := parseClock(field)
+			if !ok {
+				return Interval{}, invalid
+			}
+			iv.Micros += micros
+			continue
+		}
+
+		// A number, followed by a unit in the same or the next field
+		end := 0
+		for end < len(field) && (field[end] >= '0' && field[end] <= '9' || field[end] == '.' || field[end] == '-' || field[end] == '+') {
+			end++
+		}
+		n, err := strconv.ParseFloat(field[:end], 64)
+		if err != nil {
+			return Interval{}, invalid
+		}
+		unit := field[end:]
+		if unit == "" && i+1 < len(fields) {
+			if _, ok := intervalUnits[fields[i+1]]; ok {
+				unit = fields[i+1]
+				i++
+			}
+		}
+		if unit == "" {
+			unit = "second"
+		}
+		canonical, ok := intervalUnits[unit]
+		if !ok {
+			return Interval{}, invalid
+		}
+		iv = iv.Add(intervalOf(n, canonical))
+	}
+	if ago {
+		iv = iv.Neg()
+	}
+	return iv, nil
+}
+
+// parseISOInterval reads the part of an ISO 8601 duration after the "P"
+func parseISOInterval(s string) (Interval, bool) {
+	var iv Interval
+	inTime := false
+	for len(s) > 0 {
+		if s[0] == 't' {
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		end := 0
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || s[end] == '-') {
+			end++
+		}
+		if end == 0 || end == len(s) {
+			return Interval{}, false
+		}
+		n, err := strconv.ParseFloat(s[:end], 64)
+		if err != nil {
+			return Interval{}, false
+		}
+		var unit string
+		switch s[end] {
+		case 'y':
+			unit = "year"
+		case 'm':
+			unit = "month"
+			if inTime {
+				unit = "minute"
+			}
+		case 'w':
+			unit = "week"
+		case 'd':
+			unit = "day"
+		case 'h':
+			unit = "hour"
+		case 's':
+			unit = "second"
+		default:
+			return Interval{}, false
+		}
+		iv = iv.Add(intervalOf(n, unit))
+		s = s[end+1:]
+	}
+	return iv, true
+}
+
+// parseClock reads [-]H:MM[:SS[.ffffff]] as microseconds
+func parseClock(s string) (int64, bool) {
+	negative := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimLeft(s, "+-"), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	micros := int64(hours)*MicrosPerHour + int64(minutes)*MicrosPerMinute
+	if len(parts) == 3 {
+		seconds, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return 0, false
+		}
+		micros += int64(math.Round(seconds * float64(MicrosPerSecond)))
+	}
+	if negative {
+		micros = -micros
+	}
+	return micros, true
+}
+
+// intervalOf returns n units as an interval. Fractions of months and days
+// spill into days and microseconds, assuming 30-day months.
+func intervalOf(n float64, unit string) Interval {
+	months := func(m float64) Interval {
+		whole := math.Trunc(m)
+		return Interval{Months: int(whole)}.Add(intervalOf((m-whole)*30, "day"))
+	}
+	switch unit {
+	case "millennium":
+		return months(n * 12000)
+	case "century":
+		return months(n * 1200)
+	case "decade":
+		return months(n * 120)
+	case "year":
+		return months(n * 12)
+	case "month":
+		return months(n)
+	case "week":
+		return intervalOf(n*7, "day")
+	case "day":
+		whole := math.Trunc(n)
+		return Interval{Days: int(whole), Micros: int64(math.Round((n - whole) * float64(MicrosPerDay)))}
+	case "hour":
+		return Interval{Micros: int64(math.Round(n * float64(MicrosPerHour)))}
+	case "minute":
+		return Interval{Micros: int64(math.Round(n * float64(MicrosPerMinute)))}
+	case "second":
+		return Interval{Micros: int64(math.Round(n * float64(MicrosPerSecond)))}
+	case "millisecond":
+		return Interval{Micros: int64(math.Round(n * 1000))}
+	default:
+		return Interval{Micros: int64(math.Round(n))}
+	}
+}
+
+// Add returns the sum of two intervals
+func (iv Interval) Add(other Interval) Interval {
+	return Interval{Months: iv.Months + other.Months, Days: iv.Days + other.Days, Micros: iv.Micros + other.Micros}
+}
+
+// Neg returns the interval with its sign flipped
+func (iv Interval) Neg() Interval {
+	return Interval{Months: -iv.Months, Days: -iv.Days, Micros: -iv.Micros}
+}
+
+// Mul scales the interval; fractional months and days spill into the smaller fields
+func (iv Interval) Mul(f float64) Interval {
+	return intervalOf(float64(iv.Months)*f, "month").
+		Add(intervalOf(float64(iv.Days)*f, "day")).
+		Add(Interval{Micros: int64(math.Round(float64(iv.Micros) * f))})
+}
+
+// Span returns the interval's length in microseconds, counting 30-day
+// months and 24-hour days; intervals are ordered by it
+func (iv Interval) Span() int64 {
+	return int64(iv.Months)*30*MicrosPerDay + int64(iv.Days)*MicrosPerDay + iv.Micros
+}
+
+// AddInterval adds an interval to a time. Months are added first, keeping
+// the day of month where it exists and clamping it to the month's end otherwise.
+func AddInterval(t time.Time, iv Interval) time.Time {
+	if iv.Months != 0 {
+		y, m, d := t.Date()
+		total := int(m) - 1 + iv.Months
+		year := y + total/12
+		month := total % 12
+		if month < 0 {
+			month += 12
+			year--
+		}
+		if last := DaysInMonth(year, time.Month(month+1)); d > last {
+			d = last
+		}
+		t = time.Date(year, time.Month(month+1), d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	}
+	return t.AddDate(0, 0, iv.Days).Add(time.Duration(iv.Micros) * time.Microsecond)
+}
+
+// Difference returns a - b as an interval of whole days and microseconds
+func Difference(a, b time.Time) Interval {
+	micros := a.UnixMicro() - b.UnixMicro()
+	return Interval{Days: int(micros / MicrosPerDay), Micros: micros % MicrosPerDay}
+}
+
+// Age returns a - b in years, months and days, as PostgreSQL's age() does
+func Age(a, b time.Time) Interval {
+	if a.Before(b) {
+		return Age(b, a).Neg()
+	}
+	years := a.Year() - b.Year()
+	months := int(a.Month()) - int(b.Month())
+	days := a.Day() - b.Day()
+	micros := TimeOf(a).Micros - TimeOf(b).Micros
+	if micros < 0 {
+		micros += MicrosPerDay
+		days--
+	}
+	if days < 0 {
+		days += DaysInMonth(b.Year(), b.Month())
+		months--
+	}
+	if months < 0 {
+		months += 12
+		years--
+	}
+	return Interval{Months: years*12 + months, Days: days, Micros: micros}
+}
+
+// DaysInMonth returns the number of days in a month
+func DaysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// IsTemporalType reports whether a column type holds date/time values
+func IsTemporalType(t ColumnType) bool {
+	switch t {
+	case TypeDate, TypeTime, TypeTimestamp, TypeTimestampTZ, TypeInterval:
+		return true
+	}
+	return false
+}
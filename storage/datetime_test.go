@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseIntervalRoundTrip checks that intervals parse from the forms
+// PostgreSQL accepts and print in its default output style
+func TestParseIntervalRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 day", "1 day"},
+		{"1 year 2 months 3 days 04:05:06", "1 year 2 mons 3 days 04:05:06"},
+		{"90 minutes", "01:30:00"},
+		{"2 weeks", "14 days"},
+		{"1 day ago", "-1 days"},
+		{"@ 3 hours", "03:00:00"},
+		{"P1Y2M3DT4H5M6S", "1 year 2 mons 3 days 04:05:06"},
+		{"0 seconds", "00:00:00"},
+	}
+	for _, tt := range tests {
+		iv, err := ParseInterval(tt.input)
+		if err != nil {
+			t.Errorf("ParseInterval(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if iv.String() != tt.expected {
+			t.Errorf("ParseInterval(%q) = %q, expected %q", tt.input, iv.String(), tt.expected)
+		}
+	}
+
+	if _, err := ParseInterval("3 fortnights"); err == nil {
+		t.Error("Expected an error for an unknown interval unit")
+	}
+}
+
+// TestAddIntervalClampsToMonthEnd checks that adding months never overflows
+// into the following month
+func TestAddIntervalClampsToMonthEnd(t *testing.T) {
+	tests := []struct {
+		start    string
+		interval Interval
+		expected string
+	}{
+		{"2024-01-31 10:00:00", Interval{Months: 1}, "2024-02-29 10:00:00"},
+		{"2023-01-31 10:00:00", Interval{Months: 1}, "2023-02-28 10:00:00"},
+		{"2024-03-31 00:00:00", Interval{Months: -1}, "2024-02-29 00:00:00"},
+		{"2024-02-29 00:00:00", Interval{Months: 12}, "2025-02-28 00:00:00"},
+		{"2024-01-01 23:00:00", Interval{Days: 1, Micros: 2 * MicrosPerHour}, "2024-01-03 01:00:00"},
+	}
+	for _, tt := range tests {
+		start, err := ParseTimestamp(tt.start)
+		if err != nil {
+			t.Fatalf("ParseTimestamp(%q) failed: %v", tt.start, err)
+		}
+		result := Timestamp{Time: AddInterval(start.Time, tt.interval)}
+		if result.String() != tt.expected {
+			t.Errorf("%s + %s = %s, expected %s", tt.start, tt.interval, result, tt.expected)
+		}
+	}
+}
+
+// TestAgeBorrowsFromMonth checks that age counts whole months before days
+func TestAgeBorrowsFromMonth(t *testing.T) {
+	a := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	b := time.Date(1990, 6, 15, 8, 5, 0, 0, time.UTC)
+	if age := Age(a, b).String(); age != "33 years 8 mons 15 days 15:55:00" {
+		t.Errorf("Age = %q, expected %q", age, "33 years 8 mons 15 days 15:55:00")
+	}
+}
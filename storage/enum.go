@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TypeEnum marks a user-defined enum type. The low bits hold the number of
+// the enum's definition.
+const TypeEnum ColumnType = 1 << 9
+
+// EnumDefinition is a type created by CREATE TYPE ... AS ENUM
+type EnumDefinition struct {
+	Name      string
+	Labels    []string // In declaration order, which is also the sort order
+	OID       uint32
+	LabelOIDs []uint32 // Object identifiers of the labels, as listed in pg_enum
+}
+
+// Enum is a value of an enum type
+type Enum struct {
+	Type  ColumnType
+	Label string
+}
+
+// InvalidEnumError reports a label that is not one of an enum's labels
+type InvalidEnumError struct {
+	Type  string
+	Input string
+}
+
+func (e InvalidEnumError) Error() string {
+	return fmt.Sprintf("invalid input value for enum %s: \"%s\"", e.Type, e.Input)
+}
+
+// SQLState returns the PostgreSQL error code for invalid_text_representation
+func (e InvalidEnumError) SQLState() string {
+	return "22P02"
+}
+
+// DuplicateTypeError reports a CREATE TYPE for a name already in use
+type DuplicateTypeError struct {
+	Name string
+}
+
+func (e DuplicateTypeError) Error() string {
+	return fmt.Sprintf("type \"%s\" already exists", e.Name)
+}
+
+// SQLState returns the PostgreSQL error code for duplicate_object
+func (e DuplicateTypeError) SQLState() string {
+	return "42710"
+}
+
+// Enum types belong to a database, whose MetaStore holds them by name. A
+// value of an enum carries its column type, whose low bits number the
+// type's definition in a list shared by the whole process, so values can be
+// formatted and ordered without their database. Definitions never change
+// and are never removed, so a dropped enum's values still format; only its
+// name is released.
+var enumTypes = struct {
+	sync.RWMutex
+	defs []*EnumDefinition
+}{}
+
+// newEnum adds the definition of an enum type, returning its column type
+func newEnum(name string, labels []string) ColumnType {
+	def := &EnumDefinition{Name: name, Labels: append([]string(nil), labels...), OID: NextOID()}
+	for range labels {
+		def.LabelOIDs = append(def.LabelOIDs, NextOID())
+	}
+	enumTypes.Lock()
+	defer enumTypes.Unlock()
+	enumTypes.defs = append(enumTypes.defs, def)
+	return TypeEnum | ColumnType(len(enumTypes.defs)-1)
+}
+
+// LookupEnumOID returns the column type of the enum type with the given
+// OID, in whichever database it was created
+func LookupEnumOID(oid uint32) (ColumnType, bool) {
+	enumTypes.RLock()
+	defer enumTypes.RUnlock()
+	for i, def := range enumTypes.defs {
+		if def.OID == oid {
+			return TypeEnum | ColumnType(i), true
+		}
+	}
+	return TypeUnknown, false
+}
+
+// IsEnumType reports whether t is an enum type
+func IsEnumType(t ColumnType) bool {
+	return t&TypeArray == 0 && t&TypeEnum != 0
+}
+
+// EnumOf returns the definition of an enum type, or nil if t is not one
+func EnumOf(t ColumnType) *EnumDefinition {
+	if !IsEnumType(t) {
+		return nil
+	}
+	enumTypes.RLock()
+	defer enumTypes.RUnlock()
+	i := int(t &^ TypeEnum)
+	if i >= len(enumTypes.defs) {
+		return nil
+	}
+	return enumTypes.defs[i]
+}
+
+// ParseEnum returns the value of enum type t with the given label
+func ParseEnum(t ColumnType, label string) (Enum, error) {
+	def := EnumOf(t)
+	if def == nil {
+		return Enum{}, fmt.Errorf("type %d is not an enum", t)
+	}
+	for _, l := range def.Labels {
+		if l == label {
+			return Enum{Type: t, Label: label}, nil
+		}
+	}
+	return Enum{}, InvalidEnumError{Type: def.Name, Input: label}
+}
+
+func (e Enum) String() string {
+	return e.Label
+}
+
+// Position returns the label's place in declaration order
+func (e Enum) Position() int {
+	if def := EnumOf(e.Type); def != nil {
+		for i, l := range def.Labels {
+			if l == e.Label {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// builtinTypeNames are the type names an enum cannot take
+var builtinTypeNames = map[string]bool{
+	"bool": true, "boolean": true, "int": true, "integer": true, "int2": true, "int4": true, "int8": true,
+	"smallint": true, "bigint": true, "float4": true, "float8": true, "real": true, "numeric": true,
+	"decimal": true, "text": true, "varchar": true, "char": true, "bpchar": true, "date": true, "time": true,
+	"timestamp": true, "timestamptz": true, "interval": true, "json": true, "jsonb": true, "uuid": true, "bytea": true,
+}
@@ -0,0 +1,94 @@
+package storage
+
+import "testing"
+
+func TestEnumOrderAndLabels(t *testing.T) {
+	ms := NewMetaStore()
+	moodType, err := ms.DefineEnum("test_enum_mood", []string{"sad", "ok", "happy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ms.DefineEnum("test_enum_mood", []string{"x"}); err == nil {
+		t.Error("defining an enum twice should fail")
+	}
+	if got, ok := ms.LookupEnum("test_enum_mood"); !ok || got != moodType {
+		t.Errorf("LookupEnum = %v, %v", got, ok)
+	}
+	if !IsEnumType(moodType) || IsEnumType(ArrayOf(moodType)) || IsEnumType(TypeString) {
+		t.Error("IsEnumType does not distinguish enums")
+	}
+	if TypeToString(moodType) != "test_enum_mood" {
+		t.Errorf("TypeToString = %s", TypeToString(moodType))
+	}
+
+	happy, err := ParseEnum(moodType, "happy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sad, _ := ParseEnum(moodType, "sad")
+	if happy.Position() <= sad.Position() {
+		t.Error("labels should sort in declaration order")
+	}
+	if _, err := ParseEnum(moodType, "angry"); err == nil {
+		t.Error("an unknown label should be rejected")
+	} else if e, ok := err.(InvalidEnumError); !ok || e.SQLState() != "22P02" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParseUUID(t *testing.T) {
+	want := "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+	for _, input := range []string{
+		"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11",
+		"A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11",
+		"{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11}",
+		"a0eebc999c0b4ef8bb6d6bb9bd380a11",
+		"a0ee-bc99-9c0b-4ef8-bb6d-6bb9-bd38-0a11",
+	} {
+		u, err := ParseUUID(input)
+		if err != nil {
+			t.Errorf("ParseUUID(%q) error: %v", input, err)
+			continue
+		}
+		if u.String() != want {
+			t.Errorf("ParseUUID(%q) = %s", input, u)
+		}
+	}
+	for _, input := range []string{"", "a0eebc99", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a1g", "-a0eebc999c0b4ef8bb6d6bb9bd380a11", "a0e-ebc999c0b4ef8bb6d6bb9bd380a11"} {
+		if _, err := ParseUUID(input); err == nil {
+			t.Errorf("ParseUUID(%q) should fail", input)
+		}
+	}
+	if r := NewRandomUUID(); r[6]>>4 != 4 {
+		t.Errorf("random UUID %s is not version 4", r)
+	}
+}
+
+func TestParseBytea(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`\xDEADbeef`, `\xdeadbeef`},
+		{`\x de ad`, `\xdead`},
+		{`abc`, `\x616263`},
+		{`a\\b`, `\x615c62`},
+		{`\001\377`, `\x01ff`},
+	}
+	for _, tt := range tests {
+		b, err := ParseBytea(tt.input)
+		if err != nil {
+			t.Errorf("ParseBytea(%q) error: %v", tt.input, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("ParseBytea(%q) = %s, want %s", tt.input, b, tt.want)
+		}
+	}
+	for _, input := range []string{`\xabc`, `\xzz`, `a\b`} {
+		if _, err := ParseBytea(input); err == nil {
+			t.Errorf("ParseBytea(%q) should fail", input)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexMethod identifies the access method of a secondary index
+type IndexMethod int
+
+const (
+	IndexMethodBTree IndexMethod = iota // Ordered index: equality, range and ordered scans
+	IndexMethodHash                     // Hash index: equality lookups only
+)
+
+// IndexMethodToString converts an IndexMethod to its PostgreSQL access method name
+func IndexMethodToString(m IndexMethod) string {
+	switch m {
+	case IndexMethodHash:
+		return "hash"
+	default:
+		return "btree"
+	}
+}
+
+// indexPageSize is the target number of entries per leaf page of a B-tree index.
+// Pages are split when they grow to twice this size.
+const indexPageSize = 256
+
+// indexKeyPart is a single normalised column value inside an index key.
+// Values that parse as numbers compare numerically and sort before all other
+// values, which compare by their text form. NULLs sort last. This mirrors how
+// the executor compares values, so an index lookup never misses a matching row.
+type indexKeyPart struct {
+	null    bool
+	numeric bool
+	num     float64
+	text    string
+	enum    bool // Enum labels are keyed by text but sort in declaration order
+}
+
+func makeIndexKeyPart(value interface{}) indexKeyPart {
+	if value == nil {
+		return indexKeyPart{null: true}
+	}
+	if b, ok := value.(Bpchar); ok {
+		// char(n) values are keyed without their padding, as they compare
+		value = b.Text()
+	}
+	if num, ok := numericValue(value); ok {
+		if num == 0 {
+			num = 0 // Normalise negative zero
+		}
+		return indexKeyPart{numeric: true, num: num}
+	}
+	if _, ok := value.(Enum); ok {
+		return indexKeyPart{text: fmt.Sprintf("%v", value), enum: true}
+	}
+	return indexKeyPart{text: fmt.Sprintf("%v", value)}
+}
+
+// numericValue converts a value to float64 the same way the executor does
+// when it compares values
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, !math.IsNaN(v)
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil && !math.IsNaN(f)
+	}
+	return 0, false
+}
+
+func compareKeyParts(a, b indexKeyPart) int {
+	if a.null || b.null {
+		switch {
+		case a.null && b.null:
+			return 0
+		case a.null:
+			return 1
+		default:
+			return -1
+		}
+	}
+	if a.numeric != b.numeric {
+		if a.numeric {
+			return -1
+		}
+		return 1
+	}
+	if a.numeric {
+		switch {
+		case a.num < b.num:
+			return -1
+		case a.num > b.num:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.text, b.text)
+}
+
+func compareKeys(a, b []indexKeyPart) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareKeyParts(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// hashKey returns the hash bucket key for an index key. The second return
+// value is false when the key contains a NULL, which never equals anything.
+func hashKey(key []indexKeyPart) (string, bool) {
+	var sb strings.Builder
+	for i, part := range key {
+		if part.null {
+			return "", false
+		}
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		if part.numeric {
+			sb.WriteString("n:")
+			sb.WriteString(strconv.FormatFloat(part.num, 'g', -1, 64))
+		} else {
+			sb.WriteString("s:")
+			sb.WriteString(part.text)
+		}
+	}
+	return sb.String(), true
+}
+
+type indexEntry struct {
+	key []indexKeyPart
+	id  int // Row ID of the row in its table
+}
+
+func compareEntries(a, b indexEntry) int {
+	if c := compareKeys(a.key, b.key); c != 0 {
+		return c
+	}
+	return a.id - b.id
+}
+
+// orderedEntries keeps index entries sorted in a list of leaf pages, like the
+// leaf level of a B+tree. Inserts and deletes only touch a single page.
+type orderedEntries struct {
+	pages [][]indexEntry
+}
+
+// findPage returns the first page whose last entry is >= e, or the last page
+func (o *orderedEntries) findPage(e indexEntry) int {
+	p := sort.Search(len(o.pages), func(i int) bool {
+		page := o.pages[i]
+		return compareEntries(page[len(page)-1], e) >= 0
+	})
+	if p == len(o.pages) {
+		p = len(o.pages) - 1
+	}
+	return p
+}
+
+func (o *orderedEntries) insert(e indexEntry) {
+	if len(o.pages) == 0 {
+		o.pages = append(o.pages, []indexEntry{e})
+		return
+	}
+	p := o.findPage(e)
+	page := o.pages[p]
+	i := sort.Search(len(page), func(i int) bool { return compareEntries(page[i], e) >= 0 })
+	page = append(page, indexEntry{})
+	copy(page[i+1:], page[i:])
+	page[i] = e
+
+	if len(page) >= 2*indexPageSize {
+		// Split the page in half
+		left := append([]indexEntry(nil), page[:indexPageSize]...)
+		right := append([]indexEntry(nil), page[indexPageSize:]...)
+		o.pages = append(o.pages, nil)
+		copy(o.pages[p+2:], o.pages[p+1:])
+		o.pages[p] = left
+		o.pages[p+1] = right
+		return
+	}
+	o.pages[p] = page
+}
+
+func (o *orderedEntries) remove(e indexEntry) {
+	if len(o.pages) == 0 {
+		return
+	}
+	p := o.findPage(e)
+	page := o.pages[p]
+	i := sort.Search(len(page), func(i int) bool { return compareEntries(page[i], e) >= 0 })
+	if i >= len(page) || compareEntries(page[i], e) != 0 {
+		return
+	}
+	page = append(page[:i], page[i+1:]...)
+	if len(page) == 0 {
+		o.pages = append(o.pages[:p], o.pages[p+1:]...)
+		return
+	}
+	o.pages[p] = page
+}
+
+// after returns the page and offset of the first entry that sorts after e
+func (o *orderedEntries) after(e indexEntry) (int, int) {
+	if len(o.pages) == 0 {
+		return 0, 0
+	}
+	p := o.findPage(e)
+	page := o.pages[p]
+	i := sort.Search(len(page), func(i int) bool { return compareEntries(page[i], e) > 0 })
+	if i == len(page) {
+		return p + 1, 0
+	}
+	return p, i
+}
+
+// before returns the page and offset of the last entry that sorts before e,
+// or -1, -1 if there is none
+func (o *orderedEntries) before(e indexEntry) (int, int) {
+	if len(o.pages) == 0 {
+		return -1, -1
+	}
+	p := o.findPage(e)
+	page := o.pages[p]
+	i := sort.Search(len(page), func(i int) bool { return compareEntries(page[i], e) >= 0 }) - 1
+	if i < 0 {
+		p--
+		if p < 0 {
+			return -1, -1
+		}
+		i = len(o.pages[p]) - 1
+	}
+	return p, i
+}
+
+// last returns the page and offset of the last entry, or -1, -1 if empty
+func (o *orderedEntries) last() (int, int) {
+	p := len(o.pages) - 1
+	if p < 0 {
+		return -1, -1
+	}
+	return p, len(o.pages[p]) - 1
+}
+
+// build replaces the contents with the given entries, sorting them first
+func (o *orderedEntries) build(entries []indexEntry) {
+	sort.Slice(entries, func(i, j int) bool { return compareEntries(entries[i], entries[j]) < 0 })
+	o.pages = nil
+	for start := 0; start < len(entries); start += indexPageSize {
+		end := start + indexPageSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		o.pages = append(o.pages, append([]indexEntry(nil), entries[start:end]...))
+	}
+}
+
+// seek returns the page and offset of the first entry whose key compares >= key
+// on the leading len(key) key parts
+func (o *orderedEntries) seek(key []indexKeyPart) (int, int) {
+	cmp := func(e indexEntry) int { return compareKeys(e.key[:len(key)], key) }
+	p := sort.Search(len(o.pages), func(i int) bool {
+		page := o.pages[i]
+		return cmp(page[len(page)-1]) >= 0
+	})
+	if p == len(o.pages) {
+		return p, 0
+	}
+	page := o.pages[p]
+	i := sort.Search(len(page), func(i int) bool { return cmp(page[i]) >= 0 })
+	return p, i
+}
+
+// ascend calls fn for every entry from (p, i) onwards until fn returns false
+func (o *orderedEntries) ascend(p, i int, fn func(indexEntry) bool) {
+	for ; p < len(o.pages); p++ {
+		page := o.pages[p]
+		for ; i < len(page); i++ {
+			if !fn(page[i]) {
+				return
+			}
+		}
+		i = 0
+	}
+}
+
+// descend calls fn for every entry from (p, i) backwards until fn returns false
+func (o *orderedEntries) descend(p, i int, fn func(indexEntry) bool) {
+	for ; p >= 0; p-- {
+		page := o.pages[p]
+		if i < 0 || i >= len(page) {
+			i = len(page) - 1
+		}
+		for ; i >= 0; i-- {
+			if !fn(page[i]) {
+				return
+			}
+		}
+		i = -1
+	}
+}
+
+// IndexBound is one end of a range scan over the leading column of an index
+type IndexBound struct {
+	Value     interface{}
+	Inclusive bool
+}
+
+// Index is a secondary index over one or more columns of a table.
+// Entries refer to rows by the row IDs their table assigns, so an index is
+// only meaningful while the owning table's lock is held.
+type Index struct {
+	Name    string
+	OID     uint32 // Assigned when the index is added to a data store
+	Table   string
+	Columns []string
+	Method  IndexMethod
+	Unique  bool
+
+	hash    map[string][]int
+	ordered orderedEntries
+
+	// Number of non-NULL numeric and text values in the leading column.
+	// Ordered scans only agree with the executor's sort order when the
+	// leading column does not mix the two, and holds no enum values.
+	numericCount int
+	textCount    int
+	enumCount    int
+}
+
+// NewIndex creates an empty index
+func NewIndex(name, table string, columns []string, method IndexMethod, unique bool) *Index {
+	return &Index{
+		Name:    name,
+		Table:   table,
+		Columns: append([]string{}, columns...),
+		Method:  method,
+		Unique:  unique,
+		hash:    make(map[string][]int),
+	}
+}
+
+func (idx *Index) keyFor(row Row) []indexKeyPart {
+	key := make([]indexKeyPart, len(idx.Columns))
+	for i, col := range idx.Columns {
+		key[i] = makeIndexKeyPart(row[col])
+	}
+	return key
+}
+
+func (idx *Index) keyForValues(values []interface{}) []indexKeyPart {
+	key := make([]indexKeyPart, len(values))
+	for i, v := range values {
+		key[i] = makeIndexKeyPart(v)
+	}
+	return key
+}
+
+func (idx *Index) countLeading(key []indexKeyPart, delta int) {
+	switch {
+	case key[0].null:
+	case key[0].numeric:
+		idx.numericCount += delta
+	default:
+		idx.textCount += delta
+		if key[0].enum {
+			idx.enumCount += delta
+		}
+	}
+}
+
+func (idx *Index) add(row Row, id int) {
+	key := idx.keyFor(row)
+	idx.countLeading(key, 1)
+	if idx.Method == IndexMethodHash {
+		if hk, ok := hashKey(key); ok {
+			idx.hash[hk] = append(idx.hash[hk], id)
+		}
+		return
+	}
+	idx.ordered.insert(indexEntry{key: key, id: id})
+}
+
+func (idx *Index) remove(row Row, id int) {
+	key := idx.keyFor(row)
+	idx.countLeading(key, -1)
+	if idx.Method == IndexMethodHash {
+		hk, ok := hashKey(key)
+		if !ok {
+			return
+		}
+		bucket := idx.hash[hk]
+		for i, p := range bucket {
+			if p == id {
+				bucket = append(bucket[:i], bucket[i+1:]...)
+				break
+			}
+		}
+		if len(bucket) == 0 {
+			delete(idx.hash, hk)
+		} else {
+			idx.hash[hk] = bucket
+		}
+		return
+	}
+	idx.ordered.remove(indexEntry{key: key, id: id})
+}
+
+// rebuild discards all entries and re-indexes the given rows, whose row IDs
+// are given by ids
+func (idx *Index) rebuild(rows []Row, ids []int) {
+	idx.hash = make(map[string][]int)
+	idx.numericCount = 0
+	idx.textCount = 0
+	idx.enumCount = 0
+	if idx.Method == IndexMethodHash {
+		for pos, row := range rows {
+			idx.add(row, ids[pos])
+		}
+		return
+	}
+	entries := make([]indexEntry, len(rows))
+	for pos, row := range rows {
+		entries[pos] = indexEntry{key: idx.keyFor(row), id: ids[pos]}
+		idx.countLeading(entries[pos].key, 1)
+	}
+	idx.ordered.build(entries)
+}
+
+// lookup returns the row IDs of rows whose indexed columns equal key
+func (idx *Index) lookup(key []indexKeyPart) []int {
+	if idx.Method == IndexMethodHash {
+		hk, ok := hashKey(key)
+		if !ok {
+			return nil
+		}
+		return append([]int(nil), idx.hash[hk]...)
+	}
+	for _, part := range key {
+		if part.null {
+			return nil
+		}
+	}
+	var ids []int
+	p, i := idx.ordered.seek(key)
+	idx.ordered.ascend(p, i, func(e indexEntry) bool {
+		if compareKeys(e.key[:len(key)], key) != 0 {
+			return false
+		}
+		ids = append(ids, e.id)
+		return true
+	})
+	return ids
+}
+
+// conflict returns true if a row other than those in ignore already holds
+// key in a unique index
+func (idx *Index) conflict(key []indexKeyPart, ignore map[int]bool) bool {
+	for _, id := range idx.lookup(key) {
+		if !ignore[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeScan returns the row IDs of candidate rows whose leading column lies
+// between lower and upper. Values of the other kind (text for a numeric bound,
+// numbers for a text bound) are always returned as candidates because the
+// executor compares them differently; callers must re-check their predicate.
+func (idx *Index) rangeScan(lower, upper *IndexBound) []int {
+	var lowerPart, upperPart indexKeyPart
+	numericBound := false
+	if lower != nil {
+		lowerPart = makeIndexKeyPart(lower.Value)
+		numericBound = lowerPart.numeric
+	}
+	if upper != nil {
+		upperPart = makeIndexKeyPart(upper.Value)
+		numericBound = upperPart.numeric
+	}
+	if (lower == nil && upper == nil) || (lower != nil && lowerPart.null) || (upper != nil && upperPart.null) {
+		return nil
+	}
+	if idx.enumCount > 0 {
+		// The index's text order is not the enum's order
+		return idx.allNonNull()
+	}
+	if lower != nil && upper != nil && lowerPart.numeric != upperPart.numeric {
+		// Mixed bounds can't be answered from the index order
+		return idx.allNonNull()
+	}
+
+	var ids []int
+	collect := func(e indexEntry) bool {
+		ids = append(ids, e.id)
+		return true
+	}
+	// All numbers sort before all text, so the smallest text key marks the
+	// start of the text section of the index
+	textStart := []indexKeyPart{{text: ""}}
+
+	// Numbers are candidates for a text bound and vice versa
+	if !numericBound {
+		idx.ordered.ascend(0, 0, func(e indexEntry) bool {
+			return e.key[0].numeric && collect(e)
+		})
+	}
+
+	p, i := 0, 0
+	switch {
+	case lower != nil:
+		p, i = idx.ordered.seek([]indexKeyPart{lowerPart})
+	case !numericBound:
+		p, i = idx.ordered.seek(textStart)
+	}
+	idx.ordered.ascend(p, i, func(e indexEntry) bool {
+		part := e.key[0]
+		if part.null || part.numeric != numericBound {
+			return false
+		}
+		if lower != nil && !lower.Inclusive && compareKeyParts(part, lowerPart) == 0 {
+			return true
+		}
+		if upper != nil {
+			c := compareKeyParts(part, upperPart)
+			if c > 0 || (c == 0 && !upper.Inclusive) {
+				return false
+			}
+		}
+		return collect(e)
+	})
+
+	if numericBound {
+		p, i = idx.ordered.seek(textStart)
+		idx.ordered.ascend(p, i, func(e indexEntry) bool {
+			return !e.key[0].null && collect(e)
+		})
+	}
+	return ids
+}
+
+// allNonNull returns the row IDs of all rows whose leading column is not NULL
+func (idx *Index) allNonNull() []int {
+	var ids []int
+	idx.ordered.ascend(0, 0, func(e indexEntry) bool {
+		if e.key[0].null {
+			return false
+		}
+		ids = append(ids, e.id)
+		return true
+	})
+	return ids
+}
+
+// SupportsRange reports whether the index can answer range and ordered scans
+func (idx *Index) SupportsRange() bool {
+	return idx.Method == IndexMethodBTree
+}
+
+// orderable reports whether walking the index yields the executor's sort order
+func (idx *Index) orderable() bool {
+	return idx.SupportsRange() && !(idx.numericCount > 0 && idx.textCount > 0) && idx.enumCount == 0
+}
+
+// uniqueViolation builds the error reported when key values collide in this index
+func (idx *Index) uniqueViolation(row Row) UniqueViolationError {
+	values := make([]interface{}, len(idx.Columns))
+	for i, col := range idx.Columns {
+		values[i] = row[col]
+	}
+	return UniqueViolationError{
+		Index:   idx.Name,
+		Columns: idx.Columns,
+		Values:  values,
+	}
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestIndexLookupAfterWrites checks index lookups against a sequential scan
+// after enough inserts, updates and deletes to split and empty index pages
+func TestIndexLookupAfterWrites(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("items")
+	table, _ := ds.GetTable("items")
+
+	btree := NewIndex("items_group_idx", "items", []string{"group"}, IndexMethodBTree, false)
+	hash := NewIndex("items_group_hash", "items", []string{"group"}, IndexMethodHash, false)
+	if err := ds.CreateIndex(btree); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := ds.CreateIndex(hash); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	for i := 0; i < 3000; i++ {
+		if err := table.Insert(Row{"id": i, "group": i % 7}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	table.Update(func(row Row) bool { return row["id"].(int)%5 == 0 }, func(row Row) (Row, error) {
+		row["group"] = "moved"
+		return row, nil
+	})
+	table.Delete(func(row Row) bool { return row["id"].(int)%3 == 0 })
+
+	for _, key := range []interface{}{0, 3, "3", "moved", 99} {
+		var expected []Row
+		for _, row := range table.GetRows() {
+			if fmt.Sprintf("%v", row["group"]) == fmt.Sprintf("%v", key) {
+				expected = append(expected, row)
+			}
+		}
+		for _, idx := range []*Index{btree, hash} {
+			got := table.IndexLookup(idx, []interface{}{key})
+			if len(got) != len(expected) {
+				t.Fatalf("%s lookup %v: expected %d rows, got %d", idx.Name, key, len(expected), len(got))
+			}
+			for i := range got {
+				if got[i]["id"] != expected[i]["id"] {
+					t.Fatalf("%s lookup %v: row %d has id %v, expected %v", idx.Name, key, i, got[i]["id"], expected[i]["id"])
+				}
+			}
+		}
+	}
+}
+
+// TestIndexRange checks range scans and ordered rows on a B-tree index
+func TestIndexRange(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("items")
+	table, _ := ds.GetTable("items")
+	for i := 1000; i > 0; i-- {
+		table.Insert(Row{"n": i})
+	}
+	table.Insert(Row{"n": nil})
+
+	idx := NewIndex("items_n_idx", "items", []string{"n"}, IndexMethodBTree, false)
+	if err := ds.CreateIndex(idx); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	rows := table.IndexRange(idx, &IndexBound{Value: 100, Inclusive: false}, &IndexBound{Value: "200", Inclusive: true})
+	if len(rows) != 100 {
+		t.Fatalf("Expected 100 rows in (100, 200], got %d", len(rows))
+	}
+
+	ordered, ok := table.IndexOrderedRows(idx, false)
+	if !ok {
+		t.Fatal("Expected ordered rows for a numeric column")
+	}
+	if ordered[0]["n"] != 1 || ordered[999]["n"] != 1000 || ordered[1000]["n"] != nil {
+		t.Fatalf("Unexpected order: first %v, last non-NULL %v, last %v", ordered[0]["n"], ordered[999]["n"], ordered[1000]["n"])
+	}
+
+	table.Insert(Row{"n": "abc"})
+	if _, ok := table.IndexOrderedRows(idx, false); ok {
+		t.Fatal("Expected no ordered rows when numbers and text are mixed")
+	}
+}
+
+// TestUniqueIndex checks that unique indexes reject duplicates but allow NULLs
+func TestUniqueIndex(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("users")
+	table, _ := ds.GetTable("users")
+
+	idx := NewIndex("users_email_key", "users", []string{"email"}, IndexMethodBTree, true)
+	if err := ds.CreateIndex(idx); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	if err := table.Insert(Row{"email": "a@example.com"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := table.Insert(Row{"email": "a@example.com"}); err == nil {
+		t.Fatal("Expected unique violation on duplicate insert")
+	}
+	for i := 0; i < 2; i++ {
+		if err := table.Insert(Row{"email": nil}); err != nil {
+			t.Fatalf("NULL insert failed: %v", err)
+		}
+	}
+
+	_, err := table.Update(func(row Row) bool { return row["email"] == nil }, func(row Row) (Row, error) {
+		row["email"] = "a@example.com"
+		return row, nil
+	})
+	if _, ok := err.(UniqueViolationError); !ok {
+		t.Fatalf("Expected UniqueViolationError from update, got %v", err)
+	}
+	if len(table.IndexLookup(idx, []interface{}{"a@example.com"})) != 1 {
+		t.Fatal("Failed update must leave the index unchanged")
+	}
+}
+
+// TestUniqueViolationLeavesTableUnchanged checks that a multi-row insert or
+// update that hits a duplicate key writes none of its rows
+func TestUniqueViolationLeavesTableUnchanged(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("users")
+	table, _ := ds.GetTable("users")
+
+	idx := NewIndex("users_id_key", "users", []string{"id"}, IndexMethodBTree, true)
+	if err := ds.CreateIndex(idx); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := table.InsertRows([]Row{{"id": 1}, {"id": 2}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	for _, rows := range [][]Row{
+		{{"id": 3}, {"id": 1}, {"id": 4}}, // Duplicates a stored row
+		{{"id": 5}, {"id": 5}},            // Duplicates within the batch
+	} {
+		if err := table.InsertRows(rows); err == nil {
+			t.Fatalf("Expected unique violation inserting %v", rows)
+		}
+		if n := len(table.GetRows()); n != 2 {
+			t.Fatalf("Failed insert must write no rows, table has %d", n)
+		}
+	}
+
+	// Both rows move to the same key
+	_, err := table.Update(func(Row) bool { return true }, func(row Row) (Row, error) {
+		row["id"] = 9
+		return row, nil
+	})
+	if _, ok := err.(UniqueViolationError); !ok {
+		t.Fatalf("Expected UniqueViolationError from update, got %v", err)
+	}
+	if len(table.IndexLookup(idx, []interface{}{9})) != 0 || len(table.IndexLookup(idx, []interface{}{1})) != 1 {
+		t.Fatal("Failed update must leave the table unchanged")
+	}
+
+	// Swapping keys between updated rows is not a conflict
+	n, err := table.Update(func(Row) bool { return true }, func(row Row) (Row, error) {
+		row["id"] = 3 - row["id"].(int)
+		return row, nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 rows swapped, got %d, %v", n, err)
+	}
+}
+
+// TestIndexOrderedScan checks that an ordered scan reads across index pages
+// and keeps its place when rows are written between calls
+func TestIndexOrderedScan(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("items")
+	table, _ := ds.GetTable("items")
+	for i := 0; i < 3*indexPageSize; i++ {
+		table.Insert(Row{"n": i})
+	}
+	idx := NewIndex("items_n_idx", "items", []string{"n"}, IndexMethodBTree, false)
+	if err := ds.CreateIndex(idx); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	for _, desc := range []bool{false, true} {
+		cursor, ok := table.IndexOrderedScan(idx, desc)
+		if !ok {
+			t.Fatal("Expected an ordered scan for a numeric column")
+		}
+		var got []int
+		for row, ok := cursor.Next(); ok; row, ok = cursor.Next() {
+			got = append(got, row["n"].(int))
+			if len(got) == 10 {
+				// Rows already returned and rows still ahead are removed
+				table.Delete(func(row Row) bool {
+					n := row["n"].(int)
+					return n == 3 || n == 3*indexPageSize-4 || n == 300
+				})
+			}
+		}
+		// One removed row was already returned, two were still ahead
+		if len(got) != 3*indexPageSize-2 {
+			t.Fatalf("desc=%v: expected %d rows, got %d", desc, 3*indexPageSize-2, len(got))
+		}
+		for i := 1; i < len(got); i++ {
+			if (got[i] > got[i-1]) == desc || got[i] == 300 {
+				t.Fatalf("desc=%v: unexpected row %d after %d", desc, got[i], got[i-1])
+			}
+		}
+		table.Insert(Row{"n": 3})
+		table.Insert(Row{"n": 3*indexPageSize - 4})
+		table.Insert(Row{"n": 300})
+	}
+}
+
+// TestIndexScanWrites checks that UpdateScan and DeleteScan only visit the
+// rows the index returns, and keep the index in step with their changes
+func TestIndexScanWrites(t *testing.T) {
+	ds := NewDataStore()
+	ds.CreateTable("items")
+	table, _ := ds.GetTable("items")
+	idx := NewIndex("items_group_idx", "items", []string{"group"}, IndexMethodBTree, false)
+	ds.CreateIndex(idx)
+	for i := 0; i < 100; i++ {
+		table.Insert(Row{"id": i, "group": i % 10})
+	}
+
+	visited := 0
+	updated, err := table.UpdateScan(&IndexScan{Index: idx, Keys: [][]interface{}{{3}}}, func(row Row) bool {
+		visited++
+		return row["id"].(int) < 50
+	}, func(row Row) (Row, error) {
+		row["group"] = 30
+		return row, nil
+	})
+	if err != nil || updated != 5 || visited != 10 {
+		t.Fatalf("UpdateScan updated %d of %d visited rows: %v", updated, visited, err)
+	}
+	if got := len(table.IndexLookup(idx, []interface{}{30})); got != 5 {
+		t.Errorf("lookup after update: got %d rows, want 5", got)
+	}
+
+	visited = 0
+	deleted := table.DeleteScan(&IndexScan{Index: idx, Lower: &IndexBound{Value: 8, Inclusive: true}}, func(row Row) bool {
+		visited++
+		return row["group"] != 30
+	})
+	if deleted != 20 || visited != 25 || len(table.GetRows()) != 80 {
+		t.Errorf("DeleteScan deleted %d of %d visited rows, %d left", deleted, visited, len(table.GetRows()))
+	}
+	if got := len(table.IndexLookup(idx, []interface{}{9})); got != 0 {
+		t.Errorf("lookup after delete: got %d rows, want 0", got)
+	}
+}
@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON is a JSON document. A jsonb value is stored the way PostgreSQL
+// stores it: object keys are deduplicated (the last value wins) and sorted,
+// numbers are exact numerics, and whitespace is not kept. A json value keeps
+// the text it was given instead, key order, whitespace and duplicate keys
+// included, and is parsed when an operator or function reads it.
+//
+// Inside a document, objects are map[string]interface{}, arrays are
+// []interface{}, numbers are Numeric, and JSON null is nil. A jsonb whose
+// document is nil is the JSON null value, which is not an SQL NULL.
+type JSON struct {
+	Doc  interface{}
+	Text string // The text of a json value, "" for jsonb
+}
+
+// InvalidJSONError reports text that is not a JSON document
+type InvalidJSONError struct {
+	Input string
+}
+
+func (e InvalidJSONError) Error() string {
+	return fmt.Sprintf("invalid input syntax for type json: \"%s\"", e.Input)
+}
+
+// SQLState returns the PostgreSQL error code for invalid_text_representation
+func (e InvalidJSONError) SQLState() string {
+	return "22P02"
+}
+
+// ParseJSON reads a JSON document
+func ParseJSON(s string) (JSON, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return JSON{}, InvalidJSONError{Input: s}
+	}
+	// Only whitespace may follow the document
+	if _, err := decoder.Token(); err != io.EOF {
+		return JSON{}, InvalidJSONError{Input: s}
+	}
+	doc, err := fromEncoding(raw)
+	if err != nil {
+		return JSON{}, InvalidJSONError{Input: s}
+	}
+	return JSON{Doc: doc}, nil
+}
+
+// ParseJSONText checks that s is a JSON document and returns it as a json
+// value, which keeps s as it is
+func ParseJSONText(s string) (JSON, error) {
+	if _, err := ParseJSON(s); err != nil {
+		return JSON{}, err
+	}
+	return JSON{Text: s}, nil
+}
+
+// Jsonb returns the value as jsonb, parsing the text of a json value
+func (j JSON) Jsonb() JSON {
+	if j.Text == "" {
+		return j
+	}
+	// The text was checked when the json value was made
+	parsed, _ := ParseJSON(j.Text)
+	return parsed
+}
+
+// fromEncoding replaces the json.Number values encoding/json produces with numerics
+func fromEncoding(raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case json.Number:
+		return ParseNumeric(string(v))
+	case []interface{}:
+		for i, elem := range v {
+			converted, err := fromEncoding(elem)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+	case map[string]interface{}:
+		for key, elem := range v {
+			converted, err := fromEncoding(elem)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = converted
+		}
+	}
+	return raw, nil
+}
+
+// String formats the document as PostgreSQL outputs it: a json value as
+// its text, and jsonb normalized
+func (j JSON) String() string {
+	if j.Text != "" {
+		return j.Text
+	}
+	var buf bytes.Buffer
+	writeJSON(&buf, j.Doc)
+	return buf.String()
+}
+
+func writeJSON(buf *bytes.Buffer, doc interface{}) {
+	switch v := doc.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case Numeric:
+		buf.WriteString(v.String())
+	case string:
+		writeJSONString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeJSON(buf, elem)
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		buf.WriteByte('{')
+		for i, key := range JSONKeys(v) {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeJSONString(buf, key)
+			buf.WriteString(": ")
+			writeJSON(buf, v[key])
+		}
+		buf.WriteByte('}')
+	default:
+		writeJSONString(buf, fmt.Sprintf("%v", v))
+	}
+}
+
+// writeJSONString quotes s, escaping only what JSON requires
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// JSONKeys returns an object's keys in jsonb order: shorter keys first,
+// then bytewise
+func JSONKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// TypeName returns the name jsonb_typeof reports for the document
+func (j JSON) TypeName() string {
+	switch j.Doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case Numeric:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// Equal reports whether two documents hold the same value
+func (j JSON) Equal(other JSON) bool {
+	return j.String() == other.String()
+}
+
+// Contains reports whether other is contained in j, as the @> operator
+// tests: objects contain a subset of their pairs, arrays contain any
+// subset of their elements, and scalars contain only themselves. As a
+// special case, a top-level array contains a scalar it has as an element.
+func (j JSON) Contains(other JSON) bool {
+	if array, ok := j.Doc.([]interface{}); ok && isJSONScalar(other.Doc) {
+		for _, elem := range array {
+			if jsonContains(elem, other.Doc) {
+				return true
+			}
+		}
+		return false
+	}
+	return jsonContains(j.Doc, other.Doc)
+}
+
+func jsonContains(doc, sub interface{}) bool {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		object, ok := sub.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, want := range object {
+			have, exists := v[key]
+			if !exists || !jsonContains(have, want) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		array, ok := sub.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, want := range array {
+			found := false
+			for _, have := range v {
+				// Scalars in the contained array must match elements, and
+				// containers match containers
+				if isJSONScalar(want) != isJSONScalar(have) {
+					continue
+				}
+				if jsonContains(have, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case Numeric:
+		n, ok := sub.(Numeric)
+		return ok && v.Cmp(n) == 0
+	default:
+		return isJSONScalar(sub) && doc == sub
+	}
+}
+
+func isJSONScalar(doc interface{}) bool {
+	switch doc.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// HasKey reports whether an object has the key, or an array has the
+// string as an element, as the ? operator tests
+func (j JSON) HasKey(key string) bool {
+	switch v := j.Doc.(type) {
+	case map[string]interface{}:
+		_, ok := v[key]
+		return ok
+	case []interface{}:
+		for _, elem := range v {
+			if s, ok := elem.(string); ok && s == key {
+				return true
+			}
+		}
+	case string:
+		return v == key
+	}
+	return false
+}
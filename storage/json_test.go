@@ -0,0 +1,76 @@
+package storage
+
+import "testing"
+
+// TestParseJSONNormalizes checks that documents print in jsonb form
+func TestParseJSONNormalizes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b":1,"a":2}`, `{"a": 2, "b": 1}`},
+		{`{"long": 1, "z": 2}`, `{"z": 2, "long": 1}`},
+		{`{"a": 1, "a": 2}`, `{"a": 2}`},
+		{` [1, 1.50, "x\ny", null, false] `, `[1, 1.50, "x\ny", null, false]`},
+		{`"plain"`, `"plain"`},
+		{`null`, `null`},
+	}
+	for _, tt := range tests {
+		doc, err := ParseJSON(tt.input)
+		if err != nil {
+			t.Errorf("ParseJSON(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if doc.String() != tt.expected {
+			t.Errorf("ParseJSON(%q) = %s, expected %s", tt.input, doc, tt.expected)
+		}
+	}
+
+	for _, input := range []string{`{"a": }`, `[1, 2`, `{} {}`, ``} {
+		if _, err := ParseJSON(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+// TestParseJSONTextKeepsText checks that json values print their text as
+// given, and are normalized only when read as jsonb
+func TestParseJSONTextKeepsText(t *testing.T) {
+	input := `{"b": 1,  "a": 2, "b": 3}`
+	value, err := ParseJSONText(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.String() != input {
+		t.Errorf("String() = %s, expected %s", value, input)
+	}
+	if got := value.Jsonb().String(); got != `{"a": 2, "b": 3}` {
+		t.Errorf("Jsonb() = %s, expected {\"a\": 2, \"b\": 3}", got)
+	}
+	if _, err := ParseJSONText(`{"a": }`); err == nil {
+		t.Error("Expected an error for invalid json")
+	}
+}
+
+// TestJSONContains checks the containment rules of the @> operator
+func TestJSONContains(t *testing.T) {
+	tests := []struct {
+		doc, sub string
+		expected bool
+	}{
+		{`{"a": 1, "b": {"c": 2, "d": 3}}`, `{"b": {"c": 2}}`, true},
+		{`{"a": 1}`, `{"a": 1, "b": 2}`, false},
+		{`[1, 2, [3, 4]]`, `[[3], 1]`, true},
+		{`[1, 2, [3, 4]]`, `[3]`, false},
+		{`["a", "b"]`, `"a"`, true},
+		{`{"n": 1.0}`, `{"n": 1}`, true},
+		{`"a"`, `["a"]`, false},
+	}
+	for _, tt := range tests {
+		doc, _ := ParseJSON(tt.doc)
+		sub, _ := ParseJSON(tt.sub)
+		if doc.Contains(sub) != tt.expected {
+			t.Errorf("%s @> %s = %v, expected %v", tt.doc, tt.sub, !tt.expected, tt.expected)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
@@ -9,6 +10,8 @@ type MetaStore struct {
 	tableColumns map[string]map[string]bool
 	columnOrder  map[string][]string // Maintains column order for each table
 	columnTypes  map[string]map[string]*ColumnTypeInfo // Column type information
+	columnNums   map[string]map[string]int // Column numbers (attnum), never reused
+	enums        map[string]ColumnType     // Enum types by name
 	mu           sync.RWMutex
 }
 
@@ -17,9 +20,107 @@ func NewMetaStore() *MetaStore {
 		tableColumns: make(map[string]map[string]bool),
 		columnOrder:  make(map[string][]string),
 		columnTypes:  make(map[string]map[string]*ColumnTypeInfo),
+		columnNums:   make(map[string]map[string]int),
+		enums:        make(map[string]ColumnType),
 	}
 }
 
+// Overlay returns a meta store that starts out with this store's tables.
+// Tables added to or dropped from the overlay leave this store unchanged.
+func (ms *MetaStore) Overlay() *MetaStore {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	overlay := NewMetaStore()
+	for name, columns := range ms.tableColumns {
+		overlay.tableColumns[name] = columns
+	}
+	for name, order := range ms.columnOrder {
+		overlay.columnOrder[name] = order
+	}
+	for name, types := range ms.columnTypes {
+		overlay.columnTypes[name] = types
+	}
+	for name, nums := range ms.columnNums {
+		overlay.columnNums[name] = nums
+	}
+	for name, t := range ms.enums {
+		overlay.enums[name] = t
+	}
+	return overlay
+}
+
+// Copy returns an independent copy of the meta store
+func (ms *MetaStore) Copy() *MetaStore {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	clone := NewMetaStore()
+	for name, columns := range ms.tableColumns {
+		if isTempKey(name) {
+			continue
+		}
+		copied := make(map[string]bool, len(columns))
+		for col, present := range columns {
+			copied[col] = present
+		}
+		clone.tableColumns[name] = copied
+	}
+	for name, order := range ms.columnOrder {
+		if isTempKey(name) {
+			continue
+		}
+		clone.columnOrder[name] = append([]string{}, order...)
+	}
+	for name, types := range ms.columnTypes {
+		if isTempKey(name) {
+			continue
+		}
+		copied := make(map[string]*ColumnTypeInfo, len(types))
+		for col, info := range types {
+			infoCopy := *info
+			infoCopy.TypeModifiers = append([]int(nil), info.TypeModifiers...)
+			copied[col] = &infoCopy
+		}
+		clone.columnTypes[name] = copied
+	}
+	for name, nums := range ms.columnNums {
+		if isTempKey(name) {
+			continue
+		}
+		copied := make(map[string]int, len(nums))
+		for col, num := range nums {
+			copied[col] = num
+		}
+		clone.columnNums[name] = copied
+	}
+	for name, t := range ms.enums {
+		clone.enums[name] = t
+	}
+	return clone
+}
+
+// numberColumn gives a column the next column number of its table the
+// first time it is seen. Callers must hold the write lock.
+func (ms *MetaStore) numberColumn(tableName, columnName string) {
+	nums, exists := ms.columnNums[tableName]
+	if !exists {
+		nums = make(map[string]int)
+		ms.columnNums[tableName] = nums
+	}
+	if _, numbered := nums[columnName]; !numbered {
+		nums[columnName] = len(nums) + 1
+	}
+}
+
+// GetColumnNumber returns a column's number within its table, counting
+// from 1 in the order columns were added, or 0 for an unknown column
+func (ms *MetaStore) GetColumnNumber(tableName, columnName string) int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.columnNums[tableName][columnName]
+}
+
 func (ms *MetaStore) AddColumn(tableName, columnName string) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
@@ -28,6 +129,7 @@ func (ms *MetaStore) AddColumn(tableName, columnName string) {
 		ms.tableColumns[tableName] = make(map[string]bool)
 	}
 	ms.tableColumns[tableName][columnName] = true
+	ms.numberColumn(tableName, columnName)
 }
 
 func (ms *MetaStore) AddColumns(tableName string, columns []string) {
@@ -43,6 +145,7 @@ func (ms *MetaStore) AddColumns(tableName string, columns []string) {
 	
 	for _, col := range columns {
 		ms.tableColumns[tableName][col] = true
+		ms.numberColumn(tableName, col)
 	}
 }
 
@@ -74,6 +177,7 @@ func (ms *MetaStore) DropTable(tableName string) {
 	delete(ms.tableColumns, tableName)
 	delete(ms.columnOrder, tableName)
 	delete(ms.columnTypes, tableName)
+	delete(ms.columnNums, tableName)
 }
 
 func (ms *MetaStore) UpdateFromRow(tableName string, row Row) {
@@ -90,6 +194,7 @@ func (ms *MetaStore) UpdateFromRow(tableName string, row Row) {
 			ms.tableColumns[tableName][col] = true
 			// Append new columns to the order
 			ms.columnOrder[tableName] = append(ms.columnOrder[tableName], col)
+			ms.numberColumn(tableName, col)
 		}
 	}
 }
@@ -109,6 +214,86 @@ func (ms *MetaStore) GetColumnType(tableName, columnName string) ColumnType {
 	return TypeUnknown
 }
 
+// GetDeclaredType returns the type a column was declared with in CREATE TABLE
+func (ms *MetaStore) GetDeclaredType(tableName, columnName string) (ColumnType, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists && typeInfo.IsDeclared {
+		return typeInfo.DeclaredType, true
+	}
+	return TypeUnknown, false
+}
+
+// SetColumnTypeModifiers records the modifiers a column's type was declared
+// with, such as the precision and scale of numeric(10,2)
+func (ms *MetaStore) SetColumnTypeModifiers(tableName, columnName string, modifiers []int) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists {
+		typeInfo.TypeModifiers = modifiers
+	}
+}
+
+// GetColumnTypeModifiers returns the modifiers a column's type was declared with
+func (ms *MetaStore) GetColumnTypeModifiers(tableName, columnName string) []int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists {
+		return typeInfo.TypeModifiers
+	}
+	return nil
+}
+
+// SetColumnIntegerSize records the width in bytes a column was declared
+// with: 2 for smallint, 4 for integer and 8 for bigint
+func (ms *MetaStore) SetColumnIntegerSize(tableName, columnName string, size int) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists {
+		typeInfo.IntegerSize = size
+	}
+}
+
+// GetColumnIntegerSize returns the width in bytes a column was declared
+// with, or 0 when it was not declared as an integer type
+func (ms *MetaStore) GetColumnIntegerSize(tableName, columnName string) int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists {
+		return typeInfo.IntegerSize
+	}
+	return 0
+}
+
+// SetColumnTypeName records the name of the character type a column was
+// declared with, which tells varchar(n) from char(n), or json, which tells
+// json from jsonb
+func (ms *MetaStore) SetColumnTypeName(tableName, columnName, typeName string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists {
+		typeInfo.TypeName = typeName
+	}
+}
+
+// GetColumnTypeName returns the name of the character type a column was
+// declared with, or json for a json column, or "" otherwise
+func (ms *MetaStore) GetColumnTypeName(tableName, columnName string) string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if typeInfo, exists := ms.columnTypes[tableName][columnName]; exists {
+		return typeInfo.TypeName
+	}
+	return ""
+}
+
 // SetColumnType sets or updates the type of a column based on a value
 func (ms *MetaStore) SetColumnType(tableName, columnName string, value interface{}) error {
 	ms.mu.Lock()
@@ -222,4 +407,75 @@ func (ms *MetaStore) ValidateValueType(tableName, columnName string, value inter
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+// DefineEnum creates an enum type and returns its column type
+func (ms *MetaStore) DefineEnum(name string, labels []string) (ColumnType, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, exists := ms.enums[name]; exists || builtinTypeNames[name] {
+		return TypeUnknown, DuplicateTypeError{Name: name}
+	}
+	t := newEnum(name, labels)
+	ms.enums[name] = t
+	return t, nil
+}
+
+// DropEnum removes the name of an enum type, reporting whether it existed
+func (ms *MetaStore) DropEnum(name string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, exists := ms.enums[name]; !exists {
+		return false
+	}
+	delete(ms.enums, name)
+	return true
+}
+
+// LookupEnum returns the column type of the enum with the given name
+func (ms *MetaStore) LookupEnum(name string) (ColumnType, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	t, ok := ms.enums[name]
+	return t, ok
+}
+
+// ListEnums returns the definitions of the enum types that have not been
+// dropped, sorted by name
+func (ms *MetaStore) ListEnums() []*EnumDefinition {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	names := make([]string, 0, len(ms.enums))
+	for name := range ms.enums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*EnumDefinition, len(names))
+	for i, name := range names {
+		result[i] = EnumOf(ms.enums[name])
+	}
+	return result
+}
+
+// ColumnsOfType lists the table and column names of the columns declared
+// with type t, or with arrays of it, ordered by table and column
+func (ms *MetaStore) ColumnsOfType(t ColumnType) [][2]string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var columns [][2]string
+	for tableName, tableTypes := range ms.columnTypes {
+		for columnName, typeInfo := range tableTypes {
+			if typeInfo.IsDeclared && (typeInfo.DeclaredType == t || typeInfo.DeclaredType == ArrayOf(t)) {
+				columns = append(columns, [2]string{tableName, columnName})
+			}
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i][0] != columns[j][0] {
+			return columns[i][0] < columns[j][0]
+		}
+		return columns[i][1] < columns[j][1]
+	})
+	return columns
+}
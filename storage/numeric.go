@@ -0,0 +1,428 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Numeric is an exact decimal number: an unscaled integer and the number of
+// digits after the decimal point. Like PostgreSQL's numeric it keeps its
+// display scale, so 1.50 and 1.5 compare equal but print differently.
+type Numeric struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// Precision limits, as in PostgreSQL
+const (
+	NumericMaxPrecision   = 1000
+	NumericMaxScale       = 1000
+	numericMinSigDigits   = 16
+	numericDivisionDigits = 4 // PostgreSQL computes in base-10000 digits
+)
+
+// InvalidNumericError reports text that is not a number
+type InvalidNumericError struct {
+	Input string
+}
+
+func (e InvalidNumericError) Error() string {
+	return fmt.Sprintf("invalid input syntax for type numeric: \"%s\"", e.Input)
+}
+
+// SQLState returns the PostgreSQL error code for invalid_text_representation
+func (e InvalidNumericError) SQLState() string {
+	return "22P02"
+}
+
+// NumericOverflowError reports a value that does not fit a numeric(p,s) column
+type NumericOverflowError struct {
+	Precision int
+	Scale     int
+}
+
+func (e NumericOverflowError) Error() string {
+	return "numeric field overflow"
+}
+
+// SQLState returns the PostgreSQL error code for numeric_value_out_of_range
+func (e NumericOverflowError) SQLState() string {
+	return "22003"
+}
+
+// Detail explains the limit the value exceeded
+func (e NumericOverflowError) Detail() string {
+	if e.Precision == e.Scale {
+		return fmt.Sprintf("A field with precision %d, scale %d must round to an absolute value less than 1.", e.Precision, e.Scale)
+	}
+	return fmt.Sprintf("A field with precision %d, scale %d must round to an absolute value less than 10^%d.", e.Precision, e.Scale, e.Precision-e.Scale)
+}
+
+// NewNumeric returns unscaled × 10^-scale
+func NewNumeric(unscaled int64, scale int) Numeric {
+	return Numeric{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+// NumericFromInt returns n as a numeric with no fractional digits
+func NumericFromInt(n int64) Numeric {
+	return NewNumeric(n, 0)
+}
+
+// NumericFromFloat converts a float to the shortest numeric that reads back as it
+func NumericFromFloat(f float64) (Numeric, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Numeric{}, InvalidNumericError{Input: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	return ParseNumeric(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// ParseNumeric reads a decimal number such as "-12.50" or "1.5e3"
+func ParseNumeric(s string) (Numeric, error) {
+	input := s
+	s = strings.TrimSpace(s)
+	exponent := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > NumericMaxScale || e < -NumericMaxScale {
+			return Numeric{}, InvalidNumericError{Input: input}
+		}
+		exponent = e
+		s = s[:i]
+	}
+
+	negative := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Numeric{}, InvalidNumericError{Input: input}
+	}
+
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	n := Numeric{unscaled: unscaled, scale: len(fracPart)}
+	switch {
+	case exponent > 0 && exponent <= n.scale:
+		n.scale -= exponent
+	case exponent > 0:
+		n = Numeric{unscaled: unscaled.Mul(unscaled, pow10(exponent-n.scale)), scale: 0}
+	case exponent < 0:
+		n.scale -= exponent
+	}
+	return n, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// value returns the unscaled integer, treating the zero Numeric as 0
+func (n Numeric) value() *big.Int {
+	if n.unscaled == nil {
+		return new(big.Int)
+	}
+	return n.unscaled
+}
+
+// Scale returns the number of digits after the decimal point
+func (n Numeric) Scale() int {
+	return n.scale
+}
+
+// Sign returns -1, 0 or +1
+func (n Numeric) Sign() int {
+	return n.value().Sign()
+}
+
+func (n Numeric) String() string {
+	digits := new(big.Int).Abs(n.value()).String()
+	if n.scale > 0 {
+		if len(digits) <= n.scale {
+			digits = strings.Repeat("0", n.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-n.scale] + "." + digits[len(digits)-n.scale:]
+	}
+	if n.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 returns the nearest float to n
+func (n Numeric) Float64() float64 {
+	f, _ := strconv.ParseFloat(n.String(), 64)
+	return f
+}
+
+// Int64 returns n rounded to an integer, and false if it does not fit
+func (n Numeric) Int64() (int64, bool) {
+	r := n.Round(0).value()
+	return r.Int64(), r.IsInt64()
+}
+
+// IsInteger reports whether n has no fractional part
+func (n Numeric) IsInteger() bool {
+	return n.Trunc(0).Cmp(n) == 0
+}
+
+// rescale changes the scale, truncating digits when it shrinks
+func (n Numeric) rescale(scale int) Numeric {
+	v := n.value()
+	switch {
+	case scale > n.scale:
+		v = new(big.Int).Mul(v, pow10(scale-n.scale))
+	case scale < n.scale:
+		v = new(big.Int).Quo(v, pow10(n.scale-scale))
+	default:
+		v = new(big.Int).Set(v)
+	}
+	return Numeric{unscaled: v, scale: scale}
+}
+
+// align returns a and b at the larger of their scales
+func align(a, b Numeric) (*big.Int, *big.Int, int) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescale(scale).unscaled, b.rescale(scale).unscaled, scale
+}
+
+// Cmp returns -1, 0 or +1 as n is less than, equal to or greater than other
+func (n Numeric) Cmp(other Numeric) int {
+	a, b, _ := align(n, other)
+	return a.Cmp(b)
+}
+
+// Add returns n + other
+func (n Numeric) Add(other Numeric) Numeric {
+	a, b, scale := align(n, other)
+	return Numeric{unscaled: a.Add(a, b), scale: scale}
+}
+
+// Sub returns n - other
+func (n Numeric) Sub(other Numeric) Numeric {
+	a, b, scale := align(n, other)
+	return Numeric{unscaled: a.Sub(a, b), scale: scale}
+}
+
+// Mul returns n × other, keeping every digit of the product
+func (n Numeric) Mul(other Numeric) Numeric {
+	return Numeric{unscaled: new(big.Int).Mul(n.value(), other.value()), scale: n.scale + other.scale}
+}
+
+// Neg returns -n
+func (n Numeric) Neg() Numeric {
+	return Numeric{unscaled: new(big.Int).Neg(n.value()), scale: n.scale}
+}
+
+// Abs returns |n|
+func (n Numeric) Abs() Numeric {
+	return Numeric{unscaled: new(big.Int).Abs(n.value()), scale: n.scale}
+}
+
+// QuoScale returns n / other rounded to scale digits, half away from zero.
+// other must not be zero.
+func (n Numeric) QuoScale(other Numeric, scale int) Numeric {
+	// n/other = (a × 10^-sa) / (b × 10^-sb); scale the dividend so the
+	// integer quotient has scale+1 digits, then round the last one away
+	num := new(big.Int).Mul(n.value(), pow10(scale+1+other.scale))
+	den := new(big.Int).Mul(other.value(), pow10(n.scale))
+	q := new(big.Int).Quo(num, den)
+	return Numeric{unscaled: roundLastDigit(q), scale: scale}
+}
+
+// roundLastDigit drops the last decimal digit of v, rounding half away from zero
+func roundLastDigit(v *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(v, big.NewInt(10), new(big.Int))
+	if r.CmpAbs(big.NewInt(5)) >= 0 {
+		if v.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// Quo returns n / other at the scale PostgreSQL chooses: at least 16
+// significant digits, and no fewer digits than either operand
+func (n Numeric) Quo(other Numeric) Numeric {
+	w1, d1 := n.leadingGroup()
+	w2, d2 := other.leadingGroup()
+	qweight := w1 - w2
+	if d1 <= d2 {
+		qweight--
+	}
+	scale := numericMinSigDigits - qweight*numericDivisionDigits
+	scale = maxInt(scale, n.scale, other.scale, 0)
+	if scale > NumericMaxScale {
+		scale = NumericMaxScale
+	}
+	return n.QuoScale(other, scale)
+}
+
+// leadingGroup returns the weight and value of the first base-10000 digit of
+// n, which is how PostgreSQL estimates the size of a quotient
+func (n Numeric) leadingGroup() (int, int) {
+	digits := new(big.Int).Abs(n.value()).String()
+	if digits == "0" {
+		return 0, 0
+	}
+	exp := len(digits) - 1 - n.scale // power of ten of the leading digit
+	weight := floorDiv(exp, numericDivisionDigits)
+	width := exp - weight*numericDivisionDigits + 1
+	for len(digits) < width {
+		digits += "0"
+	}
+	group, _ := strconv.Atoi(digits[:width])
+	return weight, group
+}
+
+// Mod returns the remainder of n / other truncated toward zero, with the
+// sign of n. other must not be zero.
+func (n Numeric) Mod(other Numeric) Numeric {
+	a, b, scale := align(n, other)
+	return Numeric{unscaled: a.Rem(a, b), scale: scale}
+}
+
+// Round rounds n to scale digits after the decimal point, half away from
+// zero. A negative scale rounds to the left of the decimal point.
+func (n Numeric) Round(scale int) Numeric {
+	if scale >= n.scale {
+		return n.rescale(scale)
+	}
+	q := new(big.Int).Quo(n.value(), pow10(n.scale-scale-1))
+	return atScale(roundLastDigit(q), scale)
+}
+
+// Trunc truncates n to scale digits after the decimal point
+func (n Numeric) Trunc(scale int) Numeric {
+	if scale >= n.scale {
+		return n.rescale(scale)
+	}
+	return atScale(new(big.Int).Quo(n.value(), pow10(n.scale-scale)), scale)
+}
+
+// atScale builds a numeric from digits at scale, which may be negative
+func atScale(v *big.Int, scale int) Numeric {
+	if scale < 0 {
+		return Numeric{unscaled: v.Mul(v, pow10(-scale)), scale: 0}
+	}
+	return Numeric{unscaled: v, scale: scale}
+}
+
+// Floor returns the largest integer not greater than n
+func (n Numeric) Floor() Numeric {
+	t := n.Trunc(0)
+	if n.Sign() < 0 && t.Cmp(n) != 0 {
+		return t.Sub(NumericFromInt(1))
+	}
+	return t
+}
+
+// Ceil returns the smallest integer not less than n
+func (n Numeric) Ceil() Numeric {
+	t := n.Trunc(0)
+	if n.Sign() > 0 && t.Cmp(n) != 0 {
+		return t.Add(NumericFromInt(1))
+	}
+	return t
+}
+
+// Sqrt returns the square root of a non-negative n at the scale PostgreSQL
+// chooses for it
+func (n Numeric) Sqrt() Numeric {
+	weight, _ := n.leadingGroup()
+	sweight := (weight+1)*numericDivisionDigits/2 - 1
+	scale := maxInt(numericMinSigDigits-sweight, n.scale, 0)
+
+	// isqrt(v × 10^k) with the result at scale+1 digits, then round
+	shift := 2*(scale+1) - n.scale
+	v := new(big.Int).Set(n.value())
+	if shift >= 0 {
+		v.Mul(v, pow10(shift))
+	} else {
+		v.Quo(v, pow10(-shift))
+	}
+	return Numeric{unscaled: roundLastDigit(v.Sqrt(v)), scale: scale}
+}
+
+// Pow returns n raised to an integer power at the scale PostgreSQL chooses
+// for it: 16 significant digits and no fewer than n has. A negative power
+// of zero is undefined and must be checked by the caller.
+func (n Numeric) Pow(exp int64) Numeric {
+	negative := exp < 0
+	if negative {
+		exp = -exp
+	}
+	result := Numeric{unscaled: new(big.Int).Exp(n.value(), big.NewInt(exp), nil), scale: n.scale * int(exp)}
+	if negative {
+		result = NumericFromInt(1).QuoScale(result, NumericMaxScale)
+	}
+	return result.Round(powScale(result, n.scale))
+}
+
+// PowFloat returns n raised to a fractional power, computed in floating
+// point and rounded to the same scale Pow uses
+func (n Numeric) PowFloat(exp float64) (Numeric, error) {
+	result, err := NumericFromFloat(math.Pow(n.Float64(), exp))
+	if err != nil {
+		return Numeric{}, err
+	}
+	return result.Round(powScale(result, n.scale)), nil
+}
+
+// powScale is PostgreSQL's result scale for a power: 16 significant digits,
+// estimated from the result's magnitude, and no fewer digits than the base
+func powScale(result Numeric, baseScale int) int {
+	magnitude := 0
+	if result.Sign() != 0 {
+		magnitude = int(math.Log10(math.Abs(result.Float64())))
+	}
+	return maxInt(numericMinSigDigits-magnitude, baseScale, 0)
+}
+
+// WithTypmod rounds n to a numeric(precision, scale) column, failing if the
+// integer part needs more than precision-scale digits
+func (n Numeric) WithTypmod(precision, scale int) (Numeric, error) {
+	rounded := n.Round(scale)
+	digits := new(big.Int).Abs(rounded.value()).String()
+	intDigits := len(digits) - scale
+	if digits == "0" {
+		intDigits = 0
+	}
+	if intDigits > precision-scale {
+		return Numeric{}, NumericOverflowError{Precision: precision, Scale: scale}
+	}
+	return rounded, nil
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func maxInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
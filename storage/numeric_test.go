@@ -0,0 +1,84 @@
+package storage
+
+import "testing"
+
+// TestParseNumericRoundTrip checks that numerics keep the scale they were
+// written with
+func TestParseNumericRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0.1", "0.1"},
+		{"10.50", "10.50"},
+		{"-0.005", "-0.005"},
+		{"+42", "42"},
+		{"1.5e3", "1500"},
+		{"12e-3", "0.012"},
+		{".5", "0.5"},
+	}
+	for _, tt := range tests {
+		n, err := ParseNumeric(tt.input)
+		if err != nil {
+			t.Errorf("ParseNumeric(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if n.String() != tt.expected {
+			t.Errorf("ParseNumeric(%q) = %q, expected %q", tt.input, n.String(), tt.expected)
+		}
+	}
+
+	if _, err := ParseNumeric("1.2.3"); err == nil {
+		t.Error("Expected an error for malformed numeric input")
+	}
+}
+
+// TestNumericQuoScale checks that division picks PostgreSQL's result scale
+func TestNumericQuoScale(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"1", "3", "0.33333333333333333333"},
+		{"7.0", "2", "3.5000000000000000"},
+		{"10", "4", "2.5000000000000000"},
+		{"100.01", "2", "50.0050000000000000"},
+	}
+	for _, tt := range tests {
+		a, _ := ParseNumeric(tt.a)
+		b, _ := ParseNumeric(tt.b)
+		if result := a.Quo(b).String(); result != tt.expected {
+			t.Errorf("%s / %s = %s, expected %s", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+// TestNumericRoundAndTypmod checks half-away-from-zero rounding and the
+// overflow check applied by NUMERIC(p,s)
+func TestNumericRoundAndTypmod(t *testing.T) {
+	tests := []struct {
+		input    string
+		scale    int
+		expected string
+	}{
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"2.345", 2, "2.35"},
+		{"1234.5", -2, "1200"},
+	}
+	for _, tt := range tests {
+		n, _ := ParseNumeric(tt.input)
+		if result := n.Round(tt.scale).String(); result != tt.expected {
+			t.Errorf("round(%s, %d) = %s, expected %s", tt.input, tt.scale, result, tt.expected)
+		}
+	}
+
+	n, _ := ParseNumeric("10.005")
+	if result, err := n.WithTypmod(6, 2); err != nil || result.String() != "10.01" {
+		t.Errorf("10.005::numeric(6,2) = %v (%v), expected 10.01", result, err)
+	}
+	n, _ = ParseNumeric("999.995")
+	if _, err := n.WithTypmod(5, 2); err == nil {
+		t.Error("Expected 999.995::numeric(5,2) to overflow")
+	}
+}
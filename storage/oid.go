@@ -0,0 +1,20 @@
+package storage
+
+import "sync/atomic"
+
+// FirstNormalOID is the first OID PostgreSQL assigns to user-defined objects
+const FirstNormalOID = 16384
+
+// PublicNamespaceOID is the OID of the public schema
+const PublicNamespaceOID = 2200
+
+var lastOID atomic.Uint32
+
+func init() {
+	lastOID.Store(FirstNormalOID - 1)
+}
+
+// NextOID allocates the OID of a new user-defined object
+func NextOID() uint32 {
+	return lastOID.Add(1)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -15,6 +16,14 @@ const (
 	TypeString                    // String type
 	TypeBoolean                   // Boolean type
 	TypeTimestamp                 // Timestamp type
+	TypeDate                      // Calendar date
+	TypeTime                      // Time of day
+	TypeTimestampTZ               // Timestamp with time zone
+	TypeInterval                  // Time span
+	TypeNumeric                   // Exact decimal number
+	TypeJSON                      // JSON document (jsonb)
+	TypeUUID                      // Universally unique identifier
+	TypeBytea                     // Binary string
 )
 
 // ColumnTypeInfo stores type information for a column
@@ -23,6 +32,9 @@ type ColumnTypeInfo struct {
 	DeclaredType   ColumnType // Type declared in CREATE TABLE (if any)
 	IsConfirmed    bool      // Whether type has been confirmed by non-NULL value
 	IsDeclared     bool      // Whether type was explicitly declared in CREATE TABLE
+	TypeModifiers  []int     // Declared modifiers, such as numeric(precision, scale)
+	IntegerSize    int       // Declared width in bytes of smallint (2), integer (4) or bigint (8)
+	TypeName       string    // Declared name of a character type: text, varchar or bpchar
 	LastUpdateTime time.Time
 }
 
@@ -39,8 +51,48 @@ func (e TypeMismatchError) Error() string {
 		e.Table, e.Column, TypeToString(e.Expected), TypeToString(e.Actual))
 }
 
+// SQLState returns the PostgreSQL error code for datatype_mismatch
+func (e TypeMismatchError) SQLState() string {
+	return "42804"
+}
+
+// UniqueViolationError represents a write that would duplicate a key in a unique index
+type UniqueViolationError struct {
+	Index   string
+	Columns []string
+	Values  []interface{}
+}
+
+func (e UniqueViolationError) Error() string {
+	return fmt.Sprintf("duplicate key value violates unique constraint \"%s\"", e.Index)
+}
+
+// SQLState returns the PostgreSQL error code for unique_violation
+func (e UniqueViolationError) SQLState() string {
+	return "23505"
+}
+
+// Detail describes the conflicting key the way PostgreSQL does
+func (e UniqueViolationError) Detail() string {
+	return fmt.Sprintf("Key %s already exists.", e.key())
+}
+
+func (e UniqueViolationError) key() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = fmt.Sprintf("%v", v)
+	}
+	return fmt.Sprintf("(%s)=(%s)", strings.Join(e.Columns, ", "), strings.Join(values, ", "))
+}
+
 // TypeToString converts a ColumnType to its string representation
 func TypeToString(t ColumnType) string {
+	if IsArrayType(t) {
+		return TypeToString(ElementType(t)) + "[]"
+	}
+	if def := EnumOf(t); def != nil {
+		return def.Name
+	}
 	switch t {
 	case TypeUnknown:
 		return "unknown"
@@ -54,6 +106,22 @@ func TypeToString(t ColumnType) string {
 		return "boolean"
 	case TypeTimestamp:
 		return "timestamp"
+	case TypeDate:
+		return "date"
+	case TypeTime:
+		return "time"
+	case TypeTimestampTZ:
+		return "timestamptz"
+	case TypeInterval:
+		return "interval"
+	case TypeNumeric:
+		return "numeric"
+	case TypeJSON:
+		return "jsonb"
+	case TypeUUID:
+		return "uuid"
+	case TypeBytea:
+		return "bytea"
 	default:
 		return "invalid"
 	}
@@ -67,8 +135,18 @@ func IsTypeCompatible(currentType, newType ColumnType) bool {
 	if currentType == newType {
 		return true // Same type is always compatible
 	}
-	if currentType == TypeInteger && newType == TypeFloat {
-		return true // Integer can be promoted to Float
+	if IsArrayType(currentType) && IsArrayType(newType) {
+		// Arrays of NULLs have no element type of their own
+		return ElementType(newType) == TypeUnknown || IsTypeCompatible(ElementType(currentType), ElementType(newType))
+	}
+	if currentType == TypeInteger && (newType == TypeFloat || newType == TypeNumeric) {
+		return true // Integer can be promoted to Float or Numeric
+	}
+	if currentType == TypeNumeric && (newType == TypeInteger || newType == TypeFloat) {
+		return true // Numeric columns hold integers, and floats as decimals
+	}
+	if currentType == TypeFloat && newType == TypeNumeric {
+		return true // Decimal literals are numeric
 	}
 	return false
 }
@@ -84,8 +162,30 @@ func InferTypeFromValue(value interface{}) ColumnType {
 		return TypeInteger
 	case float32, float64:
 		return TypeFloat
-	case time.Time:
+	case time.Time, Timestamp:
 		return TypeTimestamp
+	case Date:
+		return TypeDate
+	case TimeOfDay:
+		return TypeTime
+	case TimestampTZ:
+		return TypeTimestampTZ
+	case Interval:
+		return TypeInterval
+	case Numeric:
+		return TypeNumeric
+	case JSON:
+		return TypeJSON
+	case Array:
+		return ArrayOf(v.ElemType)
+	case UUID:
+		return TypeUUID
+	case Bytea:
+		return TypeBytea
+	case Bpchar:
+		return TypeString
+	case Enum:
+		return v.Type
 	case string:
 		// Check if it looks like a timestamp in common formats
 		if _, err := time.Parse(time.RFC3339, v); err == nil {
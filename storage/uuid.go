@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// UUID is a 128-bit universally unique identifier
+type UUID [16]byte
+
+// InvalidUUIDError reports text that is not a UUID
+type InvalidUUIDError struct {
+	Input string
+}
+
+func (e InvalidUUIDError) Error() string {
+	return fmt.Sprintf("invalid input syntax for type uuid: \"%s\"", e.Input)
+}
+
+// SQLState returns the PostgreSQL error code for invalid_text_representation
+func (e InvalidUUIDError) SQLState() string {
+	return "22P02"
+}
+
+// NewRandomUUID returns a random (version 4) UUID
+func NewRandomUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = u[6]&0x0f | 0x40 // Version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return u
+}
+
+// ParseUUID reads a UUID as PostgreSQL accepts it: 32 hex digits in either
+// case, optionally in braces, with hyphens allowed after any group of four
+// digits
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	text := strings.TrimSpace(s)
+	if strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}") {
+		text = text[1 : len(text)-1]
+	}
+	var digits strings.Builder
+	for i, r := range text {
+		if r == '-' {
+			n := digits.Len()
+			if n == 0 || n%4 != 0 || i == len(text)-1 || text[i+1] == '-' {
+				return u, InvalidUUIDError{Input: s}
+			}
+			continue
+		}
+		digits.WriteRune(r)
+	}
+	if digits.Len() != 32 {
+		return u, InvalidUUIDError{Input: s}
+	}
+	if _, err := hex.Decode(u[:], []byte(digits.String())); err != nil {
+		return u, InvalidUUIDError{Input: s}
+	}
+	return u, nil
+}
+
+// String formats the UUID in the standard lower-case hyphenated form
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
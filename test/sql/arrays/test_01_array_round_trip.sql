@@ -0,0 +1,13 @@
+-- Test: Arrays are stored from ARRAY constructors and text literals and output as text
+-- Expected: 3 rows
+
+CREATE TABLE test_posts (id int, tags text[], scores int[]);
+INSERT INTO test_posts VALUES
+  (1, ARRAY['go', 'sql'], ARRAY[1, 2]),
+  (2, '{"hello world",NULL}', '{3,4,5}'),
+  (3, '{}', NULL);
+
+-- Expected: {go,sql} {1,2}; {"hello world",NULL} {3,4,5}; {} NULL
+SELECT id, tags, scores FROM test_posts ORDER BY id;
+
+DROP TABLE test_posts;
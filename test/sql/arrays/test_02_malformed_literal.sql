@@ -0,0 +1,6 @@
+-- Test: Text that is not an array literal is rejected
+-- Expected: error (malformed array literal)
+
+CREATE TABLE test_posts (id int, tags text[]);
+INSERT INTO test_posts VALUES (1, '{go,sql');
+DROP TABLE test_posts;
@@ -0,0 +1,12 @@
+-- Test: Subscripts are 1-based, and out-of-range subscripts give NULL
+-- Expected: 2 rows
+
+CREATE TABLE test_posts (id int, tags text[]);
+INSERT INTO test_posts VALUES (1, ARRAY['a', 'b', 'c']), (2, ARRAY['d']);
+
+-- Expected: (1, a, NULL, {b,c}), (2, d, NULL, {})
+SELECT id, tags[1] AS first, tags[4] AS missing, tags[2:3] AS rest
+FROM test_posts
+ORDER BY id;
+
+DROP TABLE test_posts;
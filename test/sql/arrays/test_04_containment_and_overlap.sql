@@ -0,0 +1,16 @@
+-- Test: @> finds arrays containing all given elements, && finds arrays sharing any
+-- Expected: 2 rows
+
+CREATE TABLE test_posts (id int, tags text[]);
+INSERT INTO test_posts VALUES
+  (1, ARRAY['go', 'sql']),
+  (2, ARRAY['rust']),
+  (3, ARRAY['go', 'rust', 'sql']);
+
+-- Expected: 1, 3
+SELECT id FROM test_posts WHERE tags @> ARRAY['go', 'sql'] ORDER BY id;
+
+-- Expected: 2, 3
+SELECT id FROM test_posts WHERE tags && '{rust,zig}' ORDER BY id;
+
+DROP TABLE test_posts;
@@ -0,0 +1,16 @@
+-- Test: = ANY compares with each element of an array, as drivers send for IN-lists
+-- Expected: 2 rows
+
+CREATE TABLE test_users (id int, name text);
+INSERT INTO test_users VALUES (1, 'alice'), (2, 'bob'), (3, 'carol');
+
+-- Expected: 3
+SELECT id FROM test_users WHERE id > ALL(ARRAY[1, 2]);
+
+-- Expected: 1, 3
+SELECT id FROM test_users WHERE id = ANY(ARRAY[1, 3]) ORDER BY id;
+
+-- Expected: bob, carol
+SELECT name FROM test_users WHERE name = ANY('{bob,carol,dave}') ORDER BY name;
+
+DROP TABLE test_users;
@@ -0,0 +1,17 @@
+-- Test: array_agg collects each group's values in the aggregate's order
+-- Expected: 2 rows
+
+CREATE TABLE test_posts (id int, author text, tag text);
+INSERT INTO test_posts VALUES
+  (1, 'alice', 'go'),
+  (2, 'alice', 'sql'),
+  (3, 'bob', 'rust'),
+  (4, 'alice', NULL);
+
+-- Expected: (alice, {NULL,sql,go}), (bob, {rust})
+SELECT author, array_agg(tag ORDER BY id DESC) AS tags
+FROM test_posts
+GROUP BY author
+ORDER BY author;
+
+DROP TABLE test_posts;
@@ -0,0 +1,11 @@
+-- Test: unnest expands arrays into rows, laterally over a table
+-- Expected: 5 rows
+
+CREATE TABLE test_posts (id int, tags text[]);
+INSERT INTO test_posts VALUES (1, ARRAY['go', 'sql']), (2, ARRAY['rust', 'zig', 'c']);
+
+-- Expected: (1, go), (1, sql), (2, rust), (2, zig), (2, c)
+SELECT p.id, tag
+FROM test_posts p, unnest(p.tags) AS tag;
+
+DROP TABLE test_posts;
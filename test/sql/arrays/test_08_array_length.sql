@@ -0,0 +1,12 @@
+-- Test: array_length gives NULL for empty arrays and for dimensions other than 1
+-- Expected: 3 rows
+
+CREATE TABLE test_posts (id int, tags text[]);
+INSERT INTO test_posts VALUES (1, ARRAY['go', 'sql']), (2, '{}'), (3, NULL);
+
+-- Expected: (1, 2, NULL, 2), (2, NULL, NULL, 0), (3, NULL, NULL, NULL)
+SELECT id, array_length(tags, 1) AS len, array_length(tags, 2) AS len2, cardinality(tags) AS n
+FROM test_posts
+ORDER BY id;
+
+DROP TABLE test_posts;
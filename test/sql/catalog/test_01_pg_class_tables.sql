@@ -0,0 +1,8 @@
+-- Test: pg_class lists user tables in the public namespace
+-- Expected: 2 rows
+
+CREATE TABLE test_users (id integer, name text);
+CREATE TABLE test_orders (id integer, user_id integer);
+SELECT c.relname FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind = 'r' AND n.nspname = 'public' ORDER BY c.relname;
+DROP TABLE test_users;
+DROP TABLE test_orders;
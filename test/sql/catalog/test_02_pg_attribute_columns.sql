@@ -0,0 +1,6 @@
+-- Test: pg_attribute lists a table's columns in order with their types
+-- Expected: 3 rows
+
+CREATE TABLE test_users (id bigint, name varchar(40), balance numeric(10,2));
+SELECT a.attnum, a.attname, format_type(a.atttypid, a.atttypmod) AS data_type FROM pg_attribute a JOIN pg_class c ON a.attrelid = c.oid WHERE c.relname = 'test_users' AND a.attnum > 0 ORDER BY a.attnum;
+DROP TABLE test_users;
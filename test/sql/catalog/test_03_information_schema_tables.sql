@@ -0,0 +1,6 @@
+-- Test: information_schema.tables lists user tables as base tables
+-- Expected: 1 rows
+
+CREATE TABLE test_users (id integer);
+SELECT table_schema, table_name, table_type FROM information_schema.tables WHERE table_schema = 'public';
+DROP TABLE test_users;
@@ -0,0 +1,6 @@
+-- Test: information_schema.columns describes column types, lengths and precision
+-- Expected: 4 rows
+
+CREATE TABLE test_products (id integer, code char(8), price numeric(8,2), tags text[]);
+SELECT column_name, ordinal_position, data_type, udt_name, character_maximum_length, numeric_precision, numeric_scale FROM information_schema.columns WHERE table_name = 'test_products' ORDER BY ordinal_position;
+DROP TABLE test_products;
@@ -0,0 +1,7 @@
+-- Test: pg_index links an index to its table and key columns
+-- Expected: 1 rows
+
+CREATE TABLE test_users (id integer, email text);
+CREATE UNIQUE INDEX test_users_email ON test_users (email);
+SELECT i.relname, t.relname, x.indkey, x.indisunique FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid JOIN pg_class t ON t.oid = x.indrelid WHERE t.relname = 'test_users';
+DROP TABLE test_users;
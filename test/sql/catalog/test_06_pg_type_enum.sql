@@ -0,0 +1,6 @@
+-- Test: pg_type and pg_enum list enum types and their labels in order
+-- Expected: 3 rows
+
+CREATE TYPE test_status AS ENUM ('new', 'active', 'closed');
+SELECT t.typname, t.typtype, e.enumlabel FROM pg_type t JOIN pg_enum e ON e.enumtypid = t.oid WHERE t.typname = 'test_status' ORDER BY e.enumsortorder;
+DROP TYPE test_status;
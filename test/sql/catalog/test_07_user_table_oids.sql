@@ -0,0 +1,6 @@
+-- Test: User tables get OIDs from the range PostgreSQL uses for user objects
+-- Expected: 1 rows
+
+CREATE TABLE test_users (id integer);
+SELECT relname FROM pg_class WHERE relname = 'test_users' AND oid >= 16384 AND oid = (SELECT attrelid FROM pg_attribute WHERE attname = 'id');
+DROP TABLE test_users;
@@ -0,0 +1,12 @@
+-- Test: The column listing ORMs run finds a table through a regclass name
+-- Expected: 2 rows
+
+CREATE TABLE test_users (id integer, name varchar(20));
+SELECT a.attname, format_type(a.atttypid, a.atttypmod), pg_get_expr(d.adbin, d.adrelid), a.attnotnull, a.atttypid, a.atttypmod, c.collname, col_description(a.attrelid, a.attnum) AS comment, attidentity AS identity, attgenerated AS attgenerated
+FROM pg_attribute a
+LEFT JOIN pg_attrdef d ON a.attrelid = d.adrelid AND a.attnum = d.adnum
+LEFT JOIN pg_type t ON a.atttypid = t.oid
+LEFT JOIN pg_collation c ON a.attcollation = c.oid AND a.attcollation <> t.typcollation
+WHERE a.attrelid = '"test_users"'::regclass AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum;
+DROP TABLE test_users;
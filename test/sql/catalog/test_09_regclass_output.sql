@@ -0,0 +1,10 @@
+-- Test: An OID cast to regclass prints as the relation's name
+-- Expected: 1 rows
+
+CREATE TABLE test_users (id integer, email text);
+CREATE INDEX test_users_email_idx ON test_users (email);
+SELECT indexrelid::regclass FROM pg_index
+WHERE indrelid = 'test_users'::regclass
+  AND indexrelid::regclass::text = 'test_users_email_idx'
+  AND indrelid::regclass::text = 'test_users';
+DROP TABLE test_users;
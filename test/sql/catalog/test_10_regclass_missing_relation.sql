@@ -0,0 +1,4 @@
+-- Test: A regclass name matching no relation is an error, not an empty result
+-- Expected: error (relation "test_missing" does not exist)
+
+SELECT attname FROM pg_attribute WHERE attrelid = 'test_missing'::regclass;
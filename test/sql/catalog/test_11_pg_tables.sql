@@ -0,0 +1,7 @@
+-- Test: pg_tables lists user tables with their schema
+-- Expected: 1 rows
+
+CREATE TABLE test_users (id integer);
+CREATE INDEX test_users_id_idx ON test_users (id);
+SELECT tablename FROM pg_tables WHERE schemaname = 'public' AND tablename = 'test_users' AND hasindexes;
+DROP TABLE test_users;
@@ -0,0 +1,16 @@
+-- Test 1: FETCH resumes where the previous FETCH stopped
+-- Expected: 3 rows (ids 3, 4, 5)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c CURSOR FOR SELECT id FROM items ORDER BY id;
+FETCH 2 FROM c;
+
+-- Test Query
+FETCH 10 FROM c;
+
+-- Cleanup
+COMMIT;
+DROP TABLE items;
@@ -0,0 +1,16 @@
+-- Test 2: A SCROLL cursor can fetch backward from the end
+-- Expected: 2 rows (ids 4, 3)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c SCROLL CURSOR FOR SELECT id FROM items ORDER BY id;
+FETCH LAST FROM c;
+
+-- Test Query
+FETCH BACKWARD 2 FROM c;
+
+-- Cleanup
+COMMIT;
+DROP TABLE items;
@@ -0,0 +1,16 @@
+-- Test 3: FETCH ABSOLUTE and RELATIVE position a SCROLL cursor on one row
+-- Expected: 1 row (id 2)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c SCROLL CURSOR FOR SELECT id FROM items ORDER BY id;
+FETCH ABSOLUTE 3 FROM c;
+
+-- Test Query
+FETCH RELATIVE -1 FROM c;
+
+-- Cleanup
+COMMIT;
+DROP TABLE items;
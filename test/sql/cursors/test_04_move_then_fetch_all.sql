@@ -0,0 +1,16 @@
+-- Test 4: MOVE skips rows without returning them
+-- Expected: 2 rows (ids 4, 5)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c CURSOR FOR SELECT id FROM items ORDER BY id;
+MOVE 3 IN c;
+
+-- Test Query
+FETCH ALL FROM c;
+
+-- Cleanup
+COMMIT;
+DROP TABLE items;
@@ -0,0 +1,15 @@
+-- Test 5: A cursor declared without SCROLL cannot move backward
+-- Expected: error (cursor can only scan forward)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c CURSOR FOR SELECT id FROM items;
+FETCH 2 FROM c;
+
+-- Test Query
+FETCH PRIOR FROM c;
+
+-- Cleanup
+DROP TABLE items;
@@ -0,0 +1,15 @@
+-- Test 6: Fetching from a closed cursor fails
+-- Expected: error (cursor "c" does not exist)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c CURSOR FOR SELECT id FROM items;
+CLOSE c;
+
+-- Test Query
+FETCH NEXT FROM c;
+
+-- Cleanup
+DROP TABLE items;
@@ -0,0 +1,14 @@
+-- Test 7: Declaring a cursor with a name already in use fails
+-- Expected: error (cursor "c" already exists)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+BEGIN;
+DECLARE c CURSOR FOR SELECT id FROM items;
+
+-- Test Query
+DECLARE c CURSOR FOR SELECT name FROM items;
+
+-- Cleanup
+DROP TABLE items;
@@ -0,0 +1,15 @@
+-- Test 8: A WITH HOLD cursor does not see rows inserted after it was declared
+-- Expected: 5 rows (the rows present at DECLARE)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+DECLARE c CURSOR WITH HOLD FOR SELECT id FROM items;
+INSERT INTO items (id, name) VALUES (6, 'f');
+
+-- Test Query
+FETCH ALL FROM c;
+
+-- Cleanup
+CLOSE ALL;
+DROP TABLE items;
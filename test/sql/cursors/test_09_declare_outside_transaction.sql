@@ -0,0 +1,12 @@
+-- Test 9: A cursor without WITH HOLD needs a transaction block
+-- Expected: error (DECLARE CURSOR can only be used in transaction blocks)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b');
+
+-- Test Query
+DECLARE c CURSOR FOR SELECT id FROM items;
+
+-- Cleanup
+DROP TABLE items;
@@ -0,0 +1,16 @@
+-- Test 10: COMMIT closes cursors declared without WITH HOLD
+-- Expected: error (cursor "c" does not exist)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b');
+BEGIN;
+DECLARE c CURSOR FOR SELECT id FROM items;
+FETCH 1 FROM c;
+COMMIT;
+
+-- Test Query
+FETCH 1 FROM c;
+
+-- Cleanup
+DROP TABLE items;
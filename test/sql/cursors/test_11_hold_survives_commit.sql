@@ -0,0 +1,17 @@
+-- Test 11: A WITH HOLD cursor stays open after COMMIT
+-- Expected: 1 row (id 2)
+
+-- Setup
+CREATE TABLE items (id int, name text);
+INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b');
+BEGIN;
+DECLARE c CURSOR WITH HOLD FOR SELECT id FROM items ORDER BY id;
+FETCH 1 FROM c;
+COMMIT;
+
+-- Test Query
+FETCH 1 FROM c;
+
+-- Cleanup
+CLOSE c;
+DROP TABLE items;
@@ -0,0 +1,10 @@
+-- Test: NUMERIC values add and multiply exactly
+-- Expected: 1 rows
+
+CREATE TABLE test_amounts (id int, amount numeric);
+INSERT INTO test_amounts VALUES (1, 0.1), (2, 0.2);
+
+-- Expected: 0.3 is found exactly, which binary floating point would miss
+SELECT SUM(amount) AS total FROM test_amounts HAVING SUM(amount) = 0.3;
+
+DROP TABLE test_amounts;
@@ -0,0 +1,10 @@
+-- Test: NUMERIC(p,s) rounds stored values to the declared scale
+-- Expected: 2 rows
+
+CREATE TABLE test_prices (id int, price numeric(6,2));
+INSERT INTO test_prices VALUES (1, 10.005), (2, 3.14159), (3, 7);
+
+-- Expected: 3.14 and 7.00, while 10.005 was stored as 10.01
+SELECT id, price FROM test_prices WHERE price > 3.1 AND price < 10 ORDER BY id;
+
+DROP TABLE test_prices;
@@ -0,0 +1,6 @@
+-- Test: A value too wide for NUMERIC(p,s) is rejected
+-- Expected: error (numeric field overflow)
+
+CREATE TABLE test_prices (id int, price numeric(5,2));
+INSERT INTO test_prices VALUES (1, 1234.5);
+DROP TABLE test_prices;
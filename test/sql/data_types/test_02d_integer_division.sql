@@ -0,0 +1,5 @@
+-- Test: Integer division truncates while numeric division keeps a fraction
+-- Expected: 1 rows
+
+-- Expected: 3, -3, 1, 3.5000000000000000
+SELECT 7 / 2 AS int_div, -7 / 2 AS neg_div, 7 % 3 AS remainder, 7.0 / 2 AS num_div;
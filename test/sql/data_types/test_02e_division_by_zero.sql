@@ -0,0 +1,7 @@
+-- Test: Dividing by zero is an error
+-- Expected: error (division by zero)
+
+CREATE TABLE test_amounts (id int, amount numeric);
+INSERT INTO test_amounts VALUES (1, 5.5);
+SELECT amount / 0 FROM test_amounts;
+DROP TABLE test_amounts;
@@ -0,0 +1,13 @@
+-- Test: SUM and AVG of NUMERIC columns stay exact
+-- Expected: 2 rows
+
+CREATE TABLE test_ledger (account text, amount numeric(10,2));
+INSERT INTO test_ledger VALUES
+  ('a', 0.10), ('a', 0.20), ('a', 0.05),
+  ('b', 100.00), ('b', 0.01);
+
+-- Expected: a = 0.35 / 0.11666666666666666667, b = 100.01 / 50.0050000000000000
+SELECT account, SUM(amount) AS total, AVG(amount) AS average
+FROM test_ledger GROUP BY account ORDER BY account;
+
+DROP TABLE test_ledger;
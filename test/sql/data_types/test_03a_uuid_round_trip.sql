@@ -0,0 +1,13 @@
+-- Test: uuid values are read in any accepted form and output in canonical form
+-- Expected: 1 rows
+
+CREATE TABLE test_sessions (id uuid, label text);
+INSERT INTO test_sessions VALUES
+  ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', 'upper'),
+  ('{a0eebc999c0b4ef8bb6d6bb9bd380a12}', 'braces'),
+  (gen_random_uuid(), 'random');
+
+-- Expected: a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11
+SELECT id FROM test_sessions WHERE id = 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11';
+
+DROP TABLE test_sessions;
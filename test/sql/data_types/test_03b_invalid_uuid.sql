@@ -0,0 +1,6 @@
+-- Test: Text that is not a uuid is rejected
+-- Expected: error (invalid input syntax for type uuid)
+
+CREATE TABLE test_sessions (id uuid);
+INSERT INTO test_sessions VALUES ('not-a-uuid');
+DROP TABLE test_sessions;
@@ -0,0 +1,10 @@
+-- Test: bytea takes hex and escape input and outputs hex
+-- Expected: 2 rows
+
+CREATE TABLE test_files (id int, content bytea);
+INSERT INTO test_files VALUES (1, '\xDEADbeef'), (2, 'abc');
+
+-- Expected: (1, \xdeadbeef, 4), (2, \x616263, 3)
+SELECT id, content, length(content) AS bytes FROM test_files ORDER BY id;
+
+DROP TABLE test_files;
@@ -0,0 +1,15 @@
+-- Test: Enums sort and compare in declaration order, not alphabetically
+-- Expected: 2 rows
+
+CREATE TYPE test_mood AS ENUM ('sad', 'ok', 'happy');
+CREATE TABLE test_people (name text, mood test_mood);
+INSERT INTO test_people VALUES ('alice', 'happy'), ('bob', 'sad'), ('carol', 'ok');
+
+-- Expected: bob, carol, alice
+SELECT name FROM test_people ORDER BY mood;
+
+-- Expected: alice, carol
+SELECT name FROM test_people WHERE mood > 'sad' ORDER BY mood DESC;
+
+DROP TABLE test_people;
+DROP TYPE test_mood;
@@ -0,0 +1,8 @@
+-- Test: A label that is not part of the enum is rejected
+-- Expected: error (invalid input value for enum test_mood)
+
+CREATE TYPE test_mood AS ENUM ('sad', 'ok', 'happy');
+CREATE TABLE test_people (name text, mood test_mood);
+INSERT INTO test_people VALUES ('dave', 'angry');
+DROP TABLE test_people;
+DROP TYPE test_mood;
@@ -0,0 +1,6 @@
+-- Test: An enum still used by a column cannot be dropped without CASCADE
+-- Expected: error (cannot drop type test_mood because other objects depend on it)
+
+CREATE TYPE test_mood AS ENUM ('sad', 'ok', 'happy');
+CREATE TABLE test_people (name text, mood test_mood);
+DROP TYPE test_mood;
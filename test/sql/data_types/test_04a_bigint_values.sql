@@ -0,0 +1,9 @@
+-- Test: bigint columns hold values beyond the range of integer
+-- Expected: 3 rows
+
+CREATE TABLE test_counters (id bigint, small smallint, medium integer);
+INSERT INTO test_counters VALUES (9223372036854775807, 32767, 2147483647);
+INSERT INTO test_counters VALUES (-9223372036854775808, -32768, -2147483648);
+INSERT INTO test_counters VALUES (3000000000, 1, 1);
+SELECT id, small, medium FROM test_counters WHERE id > 2147483647 OR id < -2147483648 ORDER BY id;
+DROP TABLE test_counters;
@@ -0,0 +1,6 @@
+-- Test: Inserting a value wider than an integer column is rejected
+-- Expected: error (integer out of range)
+
+CREATE TABLE test_counters (id integer);
+INSERT INTO test_counters VALUES (3000000000);
+DROP TABLE test_counters;
@@ -0,0 +1,4 @@
+-- Test: Casting a value wider than smallint is rejected
+-- Expected: error (smallint out of range)
+
+SELECT 40000::smallint;
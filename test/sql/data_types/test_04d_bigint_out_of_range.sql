@@ -0,0 +1,6 @@
+-- Test: Inserting a value wider than a bigint column is rejected
+-- Expected: error (bigint out of range)
+
+CREATE TABLE test_counters (id bigint);
+INSERT INTO test_counters VALUES (9223372036854775808);
+DROP TABLE test_counters;
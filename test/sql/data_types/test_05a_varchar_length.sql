@@ -0,0 +1,7 @@
+-- Test: varchar(n) rejects longer strings
+-- Expected: error (value too long for type character varying(5))
+
+CREATE TABLE test_codes (code varchar(5));
+INSERT INTO test_codes VALUES ('abcde');
+INSERT INTO test_codes VALUES ('abcdef');
+DROP TABLE test_codes;
@@ -0,0 +1,7 @@
+-- Test: Only spaces are cut off a string longer than varchar(n)
+-- Expected: 1 rows
+
+CREATE TABLE test_codes (code varchar(3));
+INSERT INTO test_codes VALUES ('abc   ');
+SELECT code, length(code) FROM test_codes WHERE code = 'abc';
+DROP TABLE test_codes;
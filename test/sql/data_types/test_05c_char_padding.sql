@@ -0,0 +1,13 @@
+-- Test: char(n) pads shorter strings with spaces that comparisons, length, casts to text and || ignore
+-- Expected: 1 rows
+
+CREATE TABLE test_codes (code char(5));
+INSERT INTO test_codes VALUES ('ab'), ('abc');
+
+-- Expected: only 'ab', padded to 'ab   ' yet equal to 'ab', with length 2,
+-- octet_length 5 and no padding once cast to text or concatenated
+SELECT code, length(code) AS len, octet_length(code) AS bytes, code::text || '|' AS as_text, code || '|' AS joined
+FROM test_codes
+WHERE code = 'ab' AND length(code) = 2 AND code::text = 'ab' AND code || '|' = 'ab|';
+
+DROP TABLE test_codes;
@@ -0,0 +1,6 @@
+-- Test: char(n) rejects longer strings
+-- Expected: error (value too long for type character(2))
+
+CREATE TABLE test_codes (code char(2));
+INSERT INTO test_codes VALUES ('abc');
+DROP TABLE test_codes;
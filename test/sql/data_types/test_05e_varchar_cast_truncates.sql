@@ -0,0 +1,4 @@
+-- Test: Casting to varchar(n) truncates instead of failing
+-- Expected: 1 rows
+
+SELECT 'hello world'::varchar(5) AS short, 'x'::char(3) || '|' AS padded;
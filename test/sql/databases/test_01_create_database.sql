@@ -0,0 +1,10 @@
+-- Test 1: CREATE DATABASE accepts a template and ignores other options
+-- Expected: 1 rows
+
+-- Setup
+CREATE DATABASE ci_job_1;
+CREATE DATABASE ci_job_2 TEMPLATE template0 ENCODING 'UTF8' OWNER postgres;
+CREATE DATABASE ci_job_3 WITH TEMPLATE = ci_job_1;
+
+-- Test Query
+SELECT current_database();
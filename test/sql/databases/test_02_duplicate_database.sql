@@ -0,0 +1,5 @@
+-- Test 2: CREATE DATABASE fails for an existing database
+-- Expected: error (database "ci_job" already exists)
+
+CREATE DATABASE ci_job;
+CREATE DATABASE ci_job;
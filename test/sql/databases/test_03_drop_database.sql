@@ -0,0 +1,11 @@
+-- Test 3: A dropped database can be created again
+-- Expected: 1 rows
+
+-- Setup
+CREATE DATABASE scratch;
+DROP DATABASE scratch;
+DROP DATABASE IF EXISTS scratch;
+CREATE DATABASE scratch;
+
+-- Test Query
+SELECT current_database();
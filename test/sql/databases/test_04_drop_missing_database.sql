@@ -0,0 +1,5 @@
+-- Test 4: DROP DATABASE fails for a database that does not exist
+-- Expected: error (database "missing" does not exist)
+
+DROP DATABASE IF EXISTS missing;
+DROP DATABASE missing;
@@ -0,0 +1,4 @@
+-- Test 5: The database a session is connected to cannot be dropped
+-- Expected: error (cannot drop the currently open database)
+
+DROP DATABASE vsql;
@@ -0,0 +1,4 @@
+-- Test 6: CREATE DATABASE fails when the template does not exist
+-- Expected: error (template database "missing" does not exist)
+
+CREATE DATABASE ci_job TEMPLATE missing;
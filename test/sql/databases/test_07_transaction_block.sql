@@ -0,0 +1,5 @@
+-- Test 7: CREATE DATABASE cannot run inside a transaction block
+-- Expected: error (CREATE DATABASE cannot run inside a transaction block)
+
+BEGIN;
+CREATE DATABASE ci_job;
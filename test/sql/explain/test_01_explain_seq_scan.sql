@@ -0,0 +1,12 @@
+-- Test 1: EXPLAIN shows a sequential scan with its filter
+-- Expected: 2 rows (Seq Scan on users, Filter)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30);
+
+-- Test Query
+EXPLAIN SELECT * FROM users WHERE age > 25;
+
+-- Cleanup
+DROP TABLE users;
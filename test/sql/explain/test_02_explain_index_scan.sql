@@ -0,0 +1,13 @@
+-- Test 2: EXPLAIN shows the index chosen by the executor
+-- Expected: 3 rows (Index Scan using users_age_idx, Index Cond, Filter)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+CREATE INDEX users_age_idx ON users (age);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30);
+
+-- Test Query
+EXPLAIN SELECT * FROM users WHERE age = 30 AND name <> 'Carol';
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,12 @@
+-- Test 3: EXPLAIN of ORDER BY with LIMIT shows Limit, Sort and the scan
+-- Expected: 4 rows (Limit, Sort, Sort Key, Seq Scan)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30);
+
+-- Test Query
+EXPLAIN SELECT name FROM users ORDER BY age DESC LIMIT 1;
+
+-- Cleanup
+DROP TABLE users;
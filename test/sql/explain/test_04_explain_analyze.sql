@@ -0,0 +1,15 @@
+-- Test 4: EXPLAIN ANALYZE adds actual rows per node and timing lines
+-- Expected: 8 rows (HashAggregate, Group Key, Nested Loop, Join Filter, two scans, Planning Time, Execution Time)
+
+-- Setup
+CREATE TABLE users (id int, name text);
+CREATE TABLE orders (id int, user_id int);
+INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob');
+INSERT INTO orders (id, user_id) VALUES (1, 1), (2, 1), (3, 2);
+
+-- Test Query
+EXPLAIN ANALYZE SELECT u.name, COUNT(*) FROM users u JOIN orders o ON u.id = o.user_id GROUP BY u.name;
+
+-- Cleanup
+DROP TABLE users;
+DROP TABLE orders;
@@ -0,0 +1,12 @@
+-- Test 5: EXPLAIN (FORMAT JSON) returns the plan as a single JSON document
+-- Expected: 1 rows
+
+-- Setup
+CREATE TABLE users (id int, name text);
+INSERT INTO users (id, name) VALUES (1, 'Alice');
+
+-- Test Query
+EXPLAIN (ANALYZE, FORMAT JSON) SELECT * FROM users WHERE id = 1;
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,13 @@
+-- Test 6: EXPLAIN ANALYZE executes the statement
+-- Expected: 1 rows (the UPDATE ran)
+
+-- Setup
+CREATE TABLE users (id int, name text);
+INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob');
+EXPLAIN ANALYZE UPDATE users SET name = 'Carol' WHERE id = 2;
+
+-- Test Query
+SELECT * FROM users WHERE name = 'Carol';
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,5 @@
+-- Test 7: Unsupported EXPLAIN formats are rejected
+-- Expected: error (EXPLAIN format XML is not supported)
+
+-- Test Query
+EXPLAIN (FORMAT XML) SELECT 1;
@@ -0,0 +1,9 @@
+-- Test: Calling an undefined function
+-- Expected: error (function no_such_function(integer) does not exist)
+
+CREATE TABLE test_funcs (id int, name text);
+INSERT INTO test_funcs VALUES (1, 'Alice');
+
+SELECT no_such_function(id) FROM test_funcs;
+
+DROP TABLE test_funcs;
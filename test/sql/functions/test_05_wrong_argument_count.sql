@@ -0,0 +1,9 @@
+-- Test: Calling a function with the wrong number of arguments
+-- Expected: error (function upper(text, text) does not exist)
+
+CREATE TABLE test_funcs (id int, name text);
+INSERT INTO test_funcs VALUES (1, 'Alice');
+
+SELECT UPPER(name, name) FROM test_funcs;
+
+DROP TABLE test_funcs;
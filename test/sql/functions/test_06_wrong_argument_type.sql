@@ -0,0 +1,9 @@
+-- Test: Calling a function with an argument of the wrong type
+-- Expected: error (function upper(integer) does not exist)
+
+CREATE TABLE test_funcs (id int, name text);
+INSERT INTO test_funcs VALUES (1, 'Alice');
+
+SELECT UPPER(id) FROM test_funcs;
+
+DROP TABLE test_funcs;
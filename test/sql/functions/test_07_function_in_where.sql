@@ -0,0 +1,9 @@
+-- Test: Function call in WHERE clause
+-- Expected: 2 rows
+
+CREATE TABLE test_funcs (id int, name text);
+INSERT INTO test_funcs VALUES (1, 'Alice'), (2, 'bob'), (3, 'ALICE'), (4, NULL);
+
+SELECT id, name FROM test_funcs WHERE LOWER(name) = 'alice' ORDER BY id;
+
+DROP TABLE test_funcs;
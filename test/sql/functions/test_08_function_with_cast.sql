@@ -0,0 +1,9 @@
+-- Test: Explicit cast makes a function argument match
+-- Expected: 3 rows
+
+CREATE TABLE test_funcs (id int, name text);
+INSERT INTO test_funcs VALUES (1, 'Alice'), (2, 'bob'), (3, 'Carol');
+
+SELECT id, UPPER(id::text) AS id_text, pg_catalog.lower(name) AS lower_name FROM test_funcs ORDER BY id;
+
+DROP TABLE test_funcs;
@@ -0,0 +1,11 @@
+-- Test: length, substring and position count characters, not bytes
+-- Expected: 3 rows
+
+CREATE TABLE test_strings (id int, name text);
+INSERT INTO test_strings VALUES (1, 'héllo wörld'), (2, NULL), (3, '日本語テキスト');
+
+-- Expected: (1, 11, éll, llo wörld, 3), (2, NULL, NULL, NULL, NULL), (3, 7, 本語テ, 語テキスト, 0)
+SELECT id, length(name) AS len, substring(name from 2 for 3) AS mid, substring(name, 3) AS tail, position('l' in name) AS pos
+FROM test_strings ORDER BY id;
+
+DROP TABLE test_strings;
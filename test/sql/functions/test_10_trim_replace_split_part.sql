@@ -0,0 +1,19 @@
+-- Test: trim, ltrim, rtrim, replace and split_part
+-- Expected: 3 rows
+
+CREATE TABLE test_strings (id int, path text);
+INSERT INTO test_strings VALUES (1, '  /usr/local/bin  '), (2, NULL), (3, 'xx/etc/xx');
+
+-- Expected: row 1 trims to '/usr/local/bin' with third part 'local' and last part 'bin',
+-- row 2 is all NULL, row 3 trims the x characters to '/etc/'
+SELECT id,
+       trim(path) AS trimmed,
+       trim(both 'x' from path) AS no_x,
+       ltrim(path) AS left_trimmed,
+       rtrim(path) AS right_trimmed,
+       replace(trim(path), '/', '::') AS replaced,
+       split_part(trim(path), '/', 3) AS third,
+       split_part(trim(path), '/', -1) AS last
+FROM test_strings ORDER BY id;
+
+DROP TABLE test_strings;
@@ -0,0 +1,14 @@
+-- Test: concat and concat_ws skip NULLs while || yields NULL
+-- Expected: 3 rows
+
+CREATE TABLE test_people (id int, first_name text, last_name text);
+INSERT INTO test_people VALUES (1, 'Ada', 'Lovelace'), (2, 'Grace', NULL), (3, NULL, NULL);
+
+-- Expected: (1, 'AdaLovelace', 'Ada Lovelace', 'Ada Lovelace'), (2, 'Grace', 'Grace', NULL), (3, '', '', NULL)
+SELECT id,
+       concat(first_name, last_name) AS joined,
+       concat_ws(' ', first_name, last_name) AS full_name,
+       first_name || ' ' || last_name AS operator_concat
+FROM test_people ORDER BY id;
+
+DROP TABLE test_people;
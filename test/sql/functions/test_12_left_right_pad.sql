@@ -0,0 +1,19 @@
+-- Test: left, right, lpad, rpad, repeat, reverse and initcap
+-- Expected: 2 rows
+
+CREATE TABLE test_strings (id int, word text);
+INSERT INTO test_strings VALUES (1, 'hello world'), (2, 'ñandú');
+
+-- Expected: (1, 'he', 'llo world', 'hello wo', 'hel...', 'hhh', 'dlrow olleh', 'Hello World')
+--           (2, 'ña', 'ndú', '***ñandú', 'ñan...', 'ñññ', 'údnañ', 'Ñandú')
+SELECT id,
+       left(word, 2) AS first_two,
+       right(word, -2) AS without_first_two,
+       lpad(word, 8, '*') AS left_padded,
+       rpad(left(word, 3), 6, '.') AS right_padded,
+       repeat(left(word, 1), 3) AS repeated,
+       reverse(word) AS reversed,
+       initcap(word) AS capitalized
+FROM test_strings ORDER BY id;
+
+DROP TABLE test_strings;
@@ -0,0 +1,16 @@
+-- Test: md5 and format with %s, %I, %L and positional arguments
+-- Expected: 2 rows
+
+CREATE TABLE test_strings (id int, name text);
+INSERT INTO test_strings VALUES (1, 'Alice'), (2, NULL);
+
+-- Expected: (1, '64489c85dc2fe0787b85cd87214b3810', 'UPDATE "My Table" SET note = ''Alice''', 'Alice/Alice', '[Alice ][     1]')
+--           (2, NULL, 'UPDATE "My Table" SET note = NULL', '/', '[      ][     2]')
+SELECT id,
+       md5(name) AS hash,
+       format('UPDATE %I SET note = %L', 'My Table', name) AS statement,
+       format('%1$s/%1$s', name) AS repeated,
+       format('[%-6s][%6s]', name, id) AS padded
+FROM test_strings ORDER BY id;
+
+DROP TABLE test_strings;
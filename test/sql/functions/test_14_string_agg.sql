@@ -0,0 +1,11 @@
+-- Test: string_agg with ORDER BY and GROUP BY, skipping NULLs
+-- Expected: 3 rows
+
+CREATE TABLE test_tags (post_id int, tag text);
+INSERT INTO test_tags VALUES (1, 'go'), (1, 'sql'), (1, 'db'), (2, 'rust'), (2, NULL), (3, NULL);
+
+-- Expected: (1, 'sql,go,db'), (2, 'rust'), (3, NULL)
+SELECT post_id, string_agg(tag, ',' ORDER BY tag DESC) AS tags
+FROM test_tags GROUP BY post_id ORDER BY post_id;
+
+DROP TABLE test_tags;
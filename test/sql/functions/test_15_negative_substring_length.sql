@@ -0,0 +1,4 @@
+-- Test: substring with a negative length
+-- Expected: error (negative substring length not allowed)
+
+SELECT substring('abc' from 1 for -1);
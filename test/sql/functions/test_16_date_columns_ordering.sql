@@ -0,0 +1,16 @@
+-- Test: Declared date and timestamp columns compare and sort as times, not text
+-- Expected: 3 rows
+
+CREATE TABLE test_events (id int, name text, happened_at timestamp, day date);
+INSERT INTO test_events VALUES
+  (1, 'launch', '2024-01-15 10:30:00', '2024-01-15'),
+  (2, 'freeze', '2023-12-31 23:59:59', '2023-12-31'),
+  (3, 'leap',   '2024-02-29 08:00:00', '2024-02-29'),
+  (4, 'early',  '2023-09-02 07:00:00', '2023-09-02');
+
+-- Expected: freeze, launch, leap; '2023-9-2' would sort after '2023-12-31' as text
+SELECT id, name, happened_at FROM test_events
+WHERE day > '2023-10-01'
+ORDER BY happened_at;
+
+DROP TABLE test_events;
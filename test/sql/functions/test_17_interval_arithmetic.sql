@@ -0,0 +1,20 @@
+-- Test: timestamp and date arithmetic with intervals
+-- Expected: 3 rows
+
+CREATE TABLE test_events (id int, happened_at timestamp, day date);
+INSERT INTO test_events VALUES
+  (1, '2024-01-31 10:00:00', '2024-01-31'),
+  (2, '2024-02-29 12:00:00', '2024-02-29'),
+  (3, '2023-12-31 23:30:00', '2023-12-31');
+
+-- Expected: adding a month clamps to the month's end (2024-02-29, 2024-03-29, 2024-01-31),
+-- date + integer adds days, and date - date is a day count
+SELECT id,
+       happened_at + interval '1 month' AS next_month,
+       happened_at - interval '1 day 2 hours' AS earlier,
+       happened_at + interval '45 minutes' AS later,
+       day + 7 AS next_week,
+       day - '2023-12-25'::date AS days_since
+FROM test_events ORDER BY id;
+
+DROP TABLE test_events;
@@ -0,0 +1,21 @@
+-- Test: date_trunc, EXTRACT and date_part
+-- Expected: 3 rows
+
+CREATE TABLE test_events (id int, happened_at timestamp);
+INSERT INTO test_events VALUES
+  (1, '2024-01-15 10:30:45'),
+  (2, '2024-05-04 23:59:59'),
+  (3, '2024-11-30 00:00:01');
+
+-- Expected: month starts 2024-01-01, 2024-05-01, 2024-11-01; quarters 1, 2, 4;
+-- day of week 1 (Monday), 6 (Saturday), 6 (Saturday)
+SELECT id,
+       date_trunc('month', happened_at) AS month_start,
+       date_trunc('hour', happened_at) AS hour_start,
+       EXTRACT(year FROM happened_at) AS year,
+       EXTRACT(quarter FROM happened_at) AS quarter,
+       date_part('dow', happened_at) AS dow,
+       date_part('doy', happened_at) AS doy
+FROM test_events ORDER BY id;
+
+DROP TABLE test_events;
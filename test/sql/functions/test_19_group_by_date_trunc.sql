@@ -0,0 +1,17 @@
+-- Test: Grouping rows by a truncated timestamp
+-- Expected: 2 rows
+
+CREATE TABLE test_orders (id int, ordered_at timestamp, amount int);
+INSERT INTO test_orders VALUES
+  (1, '2024-01-03 09:00:00', 10),
+  (2, '2024-01-28 17:45:00', 20),
+  (3, '2024-02-01 00:00:00', 30),
+  (4, '2024-02-14 12:00:00', 40);
+
+-- Expected: 2024-01-01 with total 30 and 2024-02-01 with total 70
+SELECT date_trunc('month', ordered_at) AS month, SUM(amount) AS total
+FROM test_orders
+GROUP BY date_trunc('month', ordered_at)
+ORDER BY month;
+
+DROP TABLE test_orders;
@@ -0,0 +1,15 @@
+-- Test: age and to_char
+-- Expected: 2 rows
+
+CREATE TABLE test_people (id int, born timestamp);
+INSERT INTO test_people VALUES (1, '1990-06-15 08:05:00'), (2, '2000-02-29 18:30:00');
+
+-- Expected: ages relative to 2024-03-01 are '33 years 8 mons 15 days 15:55:00' and '24 years 05:30:00';
+-- formatted births are 'Friday, June 15, 1990 08:05 AM' and 'Tuesday, February 29, 2000 06:30 PM'
+SELECT id,
+       age('2024-03-01 00:00:00'::timestamp, born) AS age,
+       to_char(born, 'FMDay, FMMonth DD, YYYY HH12:MI AM') AS formatted,
+       to_char(born, 'YYYY-MM-DD"T"HH24:MI:SS') AS iso
+FROM test_people ORDER BY id;
+
+DROP TABLE test_people;
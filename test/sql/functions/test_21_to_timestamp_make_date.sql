@@ -0,0 +1,10 @@
+-- Test: to_timestamp, to_date, make_date, now() and current_date
+-- Expected: 1 rows
+
+-- Expected: 2024-05-06 07:08:09+00, 1970-01-01 00:00:00+00, 2024-03-05, 2024-02-29, true, true
+SELECT to_timestamp('2024-05-06 07:08:09', 'YYYY-MM-DD HH24:MI:SS') AS parsed,
+       to_timestamp(0) AS epoch,
+       to_date('05 Mar 2024', 'DD Mon YYYY') AS parsed_date,
+       make_date(2024, 2, 29) AS leap_day,
+       now() IS NOT NULL AS has_now,
+       current_date <= now() AS today_not_future;
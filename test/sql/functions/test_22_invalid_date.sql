@@ -0,0 +1,6 @@
+-- Test: Inserting an invalid date into a date column
+-- Expected: error (invalid input syntax for type date: "2024-02-30")
+
+CREATE TABLE test_events (id int, day date);
+INSERT INTO test_events VALUES (1, '2024-02-30');
+DROP TABLE test_events;
@@ -0,0 +1,4 @@
+-- Test: make_date rejects a day that does not exist
+-- Expected: error (date field value out of range: 2023-02-29)
+
+SELECT make_date(2023, 2, 29);
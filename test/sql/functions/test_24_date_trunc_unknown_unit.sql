@@ -0,0 +1,7 @@
+-- Test: date_trunc rejects an unknown unit
+-- Expected: error (unit "fortnight" not recognized for type timestamp without time zone)
+
+CREATE TABLE test_events (id int, happened_at timestamp);
+INSERT INTO test_events VALUES (1, '2024-01-15 10:30:00');
+SELECT date_trunc('fortnight', happened_at) FROM test_events;
+DROP TABLE test_events;
@@ -0,0 +1,12 @@
+-- Test: Rounding functions on numeric and double precision values
+-- Expected: 1 rows
+
+-- Expected: 3, 2 (halves round to even for floats), 2.57, 1200, 2.56, -2, -3, 5
+SELECT round(2.5) AS r1,
+       round(2.5::float8) AS r2,
+       round(2.567, 2) AS r3,
+       round(1234.5, -2) AS r4,
+       trunc(2.567, 2) AS t1,
+       ceil(-2.5) AS c1,
+       floor(-2.5) AS f1,
+       abs(-5) AS a1;
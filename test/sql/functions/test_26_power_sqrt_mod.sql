@@ -0,0 +1,11 @@
+-- Test: power, sqrt, mod, div and the matching operators
+-- Expected: 1 rows
+
+-- Expected: 1024, 8, 1.4142135623730951, 3, 1, 3, 3 (& and | bind left to right)
+SELECT power(2, 10) AS p,
+       2 ^ 3 AS caret,
+       sqrt(2) AS s,
+       |/ 9 AS root,
+       mod(7, 3) AS m,
+       div(7, 2) AS d,
+       5 & 3 | 2 AS bits;
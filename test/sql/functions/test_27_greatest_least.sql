@@ -0,0 +1,10 @@
+-- Test: GREATEST and LEAST skip NULL arguments
+-- Expected: 3 rows
+
+CREATE TABLE test_scores (id int, a int, b int);
+INSERT INTO test_scores VALUES (1, 3, 7), (2, 9, NULL), (3, NULL, NULL);
+
+-- Expected: (7, 3), (9, 9), (NULL, NULL)
+SELECT id, GREATEST(a, b) AS high, LEAST(a, b) AS low FROM test_scores ORDER BY id;
+
+DROP TABLE test_scores;
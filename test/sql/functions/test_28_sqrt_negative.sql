@@ -0,0 +1,4 @@
+-- Test: The square root of a negative number is an error
+-- Expected: error (cannot take square root of a negative number)
+
+SELECT sqrt(-4.0);
@@ -0,0 +1,9 @@
+-- Test: random() returns a value in [0, 1)
+-- Expected: 3 rows
+
+CREATE TABLE test_draws (id int);
+INSERT INTO test_draws VALUES (1), (2), (3);
+
+SELECT id FROM test_draws WHERE random() >= 0 AND random() < 1;
+
+DROP TABLE test_draws;
@@ -0,0 +1,4 @@
+-- Test: version() names PostgreSQL and the server version
+-- Expected: 1 rows
+
+SELECT version() WHERE version() LIKE 'PostgreSQL ' || current_setting('server_version') || ' %';
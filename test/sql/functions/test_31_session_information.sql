@@ -0,0 +1,8 @@
+-- Test: Session information functions report the connection's database, user and schema
+-- Expected: 1 rows
+
+SELECT current_database(), current_schema(), current_user, session_user
+WHERE current_database() = current_catalog
+  AND current_schema() = 'public'
+  AND current_user = session_user
+  AND pg_backend_pid() > 0;
@@ -0,0 +1,7 @@
+-- Test: current_setting() reads configuration parameters, ignoring case
+-- Expected: 1 rows
+
+SELECT current_setting('DateStyle') AS datestyle, current_setting('server_encoding') AS encoding
+WHERE current_setting('datestyle') = 'ISO, MDY'
+  AND current_setting('server_version_num')::int >= 100000
+  AND current_setting('no_such_setting', true) IS NULL;
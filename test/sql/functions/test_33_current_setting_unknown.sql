@@ -0,0 +1,4 @@
+-- Test: current_setting() rejects unknown parameters
+-- Expected: error (unrecognized configuration parameter "no_such_setting")
+
+SELECT current_setting('no_such_setting');
@@ -0,0 +1,9 @@
+-- Test: Comparing a function of a NULL column in WHERE is unknown, so the row is filtered out
+-- Expected: 2 rows
+
+CREATE TABLE test_funcs (id int, name text);
+INSERT INTO test_funcs VALUES (1, 'Alice'), (2, NULL), (3, 'Bo'), (4, NULL);
+
+SELECT id, name FROM test_funcs WHERE length(name) > 1 ORDER BY id;
+
+DROP TABLE test_funcs;
@@ -0,0 +1,14 @@
+-- Test: EXTRACT returns an exact numeric, so epoch keeps every digit
+-- Expected: 1 rows
+
+CREATE TABLE test_epochs (id int, happened_at timestamp);
+INSERT INTO test_epochs VALUES
+  (1, '2023-11-14 22:13:20'),
+  (2, '2023-11-14 22:13:20.5');
+
+-- Expected: only id 1; epochs are 1700000000.000000 and 1700000000.500000
+SELECT id, EXTRACT(epoch FROM happened_at) AS epoch
+FROM test_epochs
+WHERE EXTRACT(epoch FROM happened_at)::text = '1700000000.000000';
+
+DROP TABLE test_epochs;
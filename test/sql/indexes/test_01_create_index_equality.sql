@@ -0,0 +1,18 @@
+-- Test 1: Equality lookup through a B-tree index
+-- Expected: 1 rows (Bob)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+
+INSERT INTO users (id, name, age) VALUES
+  (1, 'Alice', 25),
+  (2, 'Bob', 30),
+  (3, 'Charlie', 35);
+
+CREATE INDEX users_name_idx ON users (name);
+
+-- Test Query
+SELECT * FROM users WHERE name = 'Bob';
+
+-- Cleanup
+DROP TABLE users;
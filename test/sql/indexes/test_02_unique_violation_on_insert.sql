@@ -0,0 +1,14 @@
+-- Test 2: Inserting a duplicate key into a unique index fails
+-- Expected: error (duplicate key value violates unique constraint)
+
+-- Setup
+CREATE TABLE users (id int, name text);
+CREATE UNIQUE INDEX users_id_key ON users (id);
+
+INSERT INTO users (id, name) VALUES (1, 'Alice');
+
+-- Test Query
+INSERT INTO users (id, name) VALUES (1, 'Bob');
+
+-- Cleanup
+DROP TABLE users;
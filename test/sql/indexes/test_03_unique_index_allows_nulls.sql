@@ -0,0 +1,16 @@
+-- Test 3: A unique index allows multiple NULLs
+-- Expected: 3 rows
+
+-- Setup
+CREATE TABLE users (id int, email text);
+CREATE UNIQUE INDEX ON users (email);
+
+INSERT INTO users (id, email) VALUES (1, NULL);
+INSERT INTO users (id, email) VALUES (2, NULL);
+INSERT INTO users (id, email) VALUES (3, 'carol@example.com');
+
+-- Test Query
+SELECT * FROM users;
+
+-- Cleanup
+DROP TABLE users;
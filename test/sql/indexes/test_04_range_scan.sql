@@ -0,0 +1,19 @@
+-- Test 4: Range predicates use a B-tree index
+-- Expected: 2 rows (ages 30 and 35)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+CREATE INDEX users_age_idx ON users (age);
+
+INSERT INTO users (id, name, age) VALUES
+  (1, 'Alice', 25),
+  (2, 'Bob', 30),
+  (3, 'Charlie', 35),
+  (4, 'David', NULL),
+  (5, 'Eve', 40);
+
+-- Test Query
+SELECT name FROM users WHERE age BETWEEN 30 AND 39;
+
+-- Cleanup
+DROP TABLE users;
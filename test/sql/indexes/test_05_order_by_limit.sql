@@ -0,0 +1,19 @@
+-- Test 5: ORDER BY an indexed column with LIMIT
+-- Expected: 2 rows (Eve, Charlie)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+CREATE INDEX users_age_idx ON users (age);
+
+INSERT INTO users (id, name, age) VALUES
+  (1, 'Alice', 25),
+  (2, 'Bob', 30),
+  (3, 'Charlie', 35),
+  (4, 'David', NULL),
+  (5, 'Eve', 40);
+
+-- Test Query
+SELECT name, age FROM users WHERE age IS NOT NULL ORDER BY age DESC LIMIT 2;
+
+-- Cleanup
+DROP TABLE users;
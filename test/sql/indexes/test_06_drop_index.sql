@@ -0,0 +1,16 @@
+-- Test 6: Dropping a unique index allows duplicates again
+-- Expected: 2 rows
+
+-- Setup
+CREATE TABLE users (id int, name text);
+CREATE UNIQUE INDEX users_id_key ON users (id);
+INSERT INTO users (id, name) VALUES (1, 'Alice');
+DROP INDEX users_id_key;
+DROP INDEX IF EXISTS users_id_key;
+INSERT INTO users (id, name) VALUES (1, 'Bob');
+
+-- Test Query
+SELECT * FROM users WHERE id = 1;
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,14 @@
+-- Test 7: Updating a row to a duplicate key of a unique index fails
+-- Expected: error (duplicate key value violates unique constraint)
+
+-- Setup
+CREATE TABLE users (id int, name text);
+CREATE UNIQUE INDEX users_name_key ON users (name);
+
+INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob');
+
+-- Test Query
+UPDATE users SET name = 'Alice' WHERE id = 2;
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,15 @@
+-- Test 8: Hash index lookups with IN and after DELETE
+-- Expected: 2 rows (Alice, Charlie)
+
+-- Setup
+CREATE TABLE users (id int, name text);
+CREATE INDEX users_id_hash ON users USING hash (id);
+
+INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob'), (3, 'Charlie'), (4, 'David');
+DELETE FROM users WHERE id = 2;
+
+-- Test Query
+SELECT name FROM users WHERE id IN (1, 2, 3);
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,12 @@
+-- Test 9: Creating a unique index over existing duplicates fails
+-- Expected: error (could not create unique index)
+
+-- Setup
+CREATE TABLE users (id int, name text);
+INSERT INTO users (id, name) VALUES (1, 'Alice'), (1, 'Bob');
+
+-- Test Query
+CREATE UNIQUE INDEX users_id_key ON users (id);
+
+-- Cleanup
+DROP TABLE users;
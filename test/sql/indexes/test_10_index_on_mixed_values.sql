@@ -0,0 +1,13 @@
+-- Test 10: Index on a column holding both numbers and text returns the same rows as a scan
+-- Expected: 3 rows (5 and 10 compare numerically, x compares as text)
+
+-- Setup
+CREATE TABLE items (id int, code text);
+INSERT INTO items (id, code) VALUES (1, '5'), (2, '10'), (3, 'x');
+CREATE INDEX items_code_idx ON items (code);
+
+-- Test Query
+SELECT * FROM items WHERE code > '1';
+
+-- Cleanup
+DROP TABLE items;
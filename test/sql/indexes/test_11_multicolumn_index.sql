@@ -0,0 +1,18 @@
+-- Test 11: Multi-column index used for a lookup on its leading column after UPDATE
+-- Expected: 2 rows (Tokyo rows)
+
+-- Setup
+CREATE TABLE users (id int, name text, city text);
+CREATE INDEX users_city_name_idx ON users (city, name);
+
+INSERT INTO users (id, name, city) VALUES
+  (1, 'Alice', 'Tokyo'),
+  (2, 'Bob', 'Osaka'),
+  (3, 'Charlie', 'Kyoto');
+UPDATE users SET city = 'Tokyo' WHERE name = 'Bob';
+
+-- Test Query
+SELECT u.name FROM users u WHERE u.city = 'Tokyo' ORDER BY u.name;
+
+-- Cleanup
+DROP TABLE users;
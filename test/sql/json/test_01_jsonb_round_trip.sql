@@ -0,0 +1,12 @@
+-- Test: jsonb documents are stored with sorted, deduplicated keys
+-- Expected: 2 rows
+
+CREATE TABLE test_settings (id int, data jsonb);
+INSERT INTO test_settings VALUES
+  (1, '{"theme": "dark", "font": {"size": 12}, "theme": "light"}'),
+  (2, '[1, 2.50, "three", null, true]');
+
+-- Expected: {"font": {"size": 12}, "theme": "light"} and [1, 2.50, "three", null, true]
+SELECT id, data FROM test_settings ORDER BY id;
+
+DROP TABLE test_settings;
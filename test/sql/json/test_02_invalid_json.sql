@@ -0,0 +1,6 @@
+-- Test: Malformed JSON is rejected on insert
+-- Expected: error (invalid input syntax for type json)
+
+CREATE TABLE test_settings (id int, data jsonb);
+INSERT INTO test_settings VALUES (1, '{"theme": "dark"');
+DROP TABLE test_settings;
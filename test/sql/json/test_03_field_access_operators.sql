@@ -0,0 +1,18 @@
+-- Test: ->, ->>, #> and #>> extract fields and array elements
+-- Expected: 3 rows
+
+CREATE TABLE test_payloads (id int, payload jsonb);
+INSERT INTO test_payloads VALUES
+  (1, '{"user": {"name": "alice", "roles": ["admin", "dev"]}}'),
+  (2, '{"user": {"name": "bob", "roles": []}}'),
+  (3, '{"event": "ping"}');
+
+-- Expected: "alice"/alice/admin/dev, "bob"/bob/NULL/NULL, then NULLs for the missing user
+SELECT id,
+       payload->'user'->'name' AS name_json,
+       payload->'user'->>'name' AS name_text,
+       payload#>>'{user,roles,0}' AS first_role,
+       payload->'user'->'roles'->>-1 AS last_role
+FROM test_payloads ORDER BY id;
+
+DROP TABLE test_payloads;
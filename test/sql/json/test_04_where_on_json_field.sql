@@ -0,0 +1,12 @@
+-- Test: Filtering on an extracted JSON field
+-- Expected: 2 rows
+
+CREATE TABLE test_orders (id int, details jsonb);
+INSERT INTO test_orders VALUES
+  (1, '{"status": "shipped", "total": 120}'),
+  (2, '{"status": "pending", "total": 80}'),
+  (3, '{"status": "shipped", "total": 45}');
+
+SELECT id FROM test_orders WHERE details->>'status' = 'shipped' ORDER BY id;
+
+DROP TABLE test_orders;
@@ -0,0 +1,14 @@
+-- Test: @> containment and ? key existence
+-- Expected: 1 rows
+
+CREATE TABLE test_products (id int, attrs jsonb);
+INSERT INTO test_products VALUES
+  (1, '{"color": "red", "sizes": ["S", "M"], "sale": true}'),
+  (2, '{"color": "red", "sizes": ["L"]}'),
+  (3, '{"color": "blue", "sizes": ["S"], "sale": false}');
+
+-- Expected: only product 1 is red, comes in S and has a sale key
+SELECT id FROM test_products
+WHERE attrs @> '{"color": "red", "sizes": ["S"]}' AND attrs ? 'sale';
+
+DROP TABLE test_products;
@@ -0,0 +1,7 @@
+-- Test: || merges objects and concatenates arrays, - removes a key
+-- Expected: 1 rows
+
+-- Expected: {"a": 1, "b": 3, "c": 4}, [1, 2, 3], {"b": 2}
+SELECT '{"a": 1, "b": 2}'::jsonb || '{"b": 3, "c": 4}' AS merged,
+       '[1, 2]'::jsonb || '3'::jsonb AS appended,
+       '{"a": 1, "b": 2}'::jsonb - 'a' AS removed;
@@ -0,0 +1,11 @@
+-- Test: jsonb_build_object per row and jsonb_agg per group
+-- Expected: 2 rows
+
+CREATE TABLE test_staff (id int, team text, name text);
+INSERT INTO test_staff VALUES (1, 'core', 'alice'), (2, 'core', 'bob'), (3, 'web', 'carol');
+
+-- Expected: core [{"id": 2, "name": "bob"}, {"id": 1, "name": "alice"}], web [{"id": 3, "name": "carol"}]
+SELECT team, jsonb_agg(jsonb_build_object('id', id, 'name', name) ORDER BY id DESC) AS members
+FROM test_staff GROUP BY team ORDER BY team;
+
+DROP TABLE test_staff;
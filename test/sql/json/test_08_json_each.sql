@@ -0,0 +1,12 @@
+-- Test: json_each expands a document into key/value rows, laterally over a table
+-- Expected: 3 rows
+
+CREATE TABLE test_flags (id int, flags jsonb);
+INSERT INTO test_flags VALUES (1, '{"beta": true, "dark": false}'), (2, '{"beta": false}');
+
+-- Expected: (1, beta, true), (1, dark, false), (2, beta, false)
+SELECT f.id, e.key, e.value
+FROM test_flags f, jsonb_each(f.flags) AS e
+ORDER BY f.id, e.key;
+
+DROP TABLE test_flags;
@@ -0,0 +1,12 @@
+-- Test: UPDATE with jsonb_set changes one nested field
+-- Expected: 1 rows
+
+CREATE TABLE test_settings (id int, data jsonb);
+INSERT INTO test_settings VALUES (1, '{"notify": {"email": true, "sms": false}}');
+
+UPDATE test_settings SET data = jsonb_set(data, '{notify,sms}', 'true') WHERE id = 1;
+
+-- Expected: {"notify": {"sms": true, "email": true}}
+SELECT data FROM test_settings WHERE data @> '{"notify": {"sms": true}}';
+
+DROP TABLE test_settings;
@@ -0,0 +1,9 @@
+-- Test: to_jsonb converts SQL values to JSON
+-- Expected: 1 rows
+
+-- Expected: 42, "text", true, 1.50, "2024-05-06T07:08:09"
+SELECT to_jsonb(42) AS n,
+       to_jsonb('text'::text) AS s,
+       to_jsonb(true) AS b,
+       to_jsonb(1.50) AS d,
+       to_jsonb('2024-05-06 07:08:09'::timestamp) AS ts;
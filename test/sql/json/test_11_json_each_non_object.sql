@@ -0,0 +1,4 @@
+-- Test: json_each of an array is an error
+-- Expected: error (cannot call jsonb_each on a non-object)
+
+SELECT * FROM jsonb_each('[1, 2]');
@@ -0,0 +1,16 @@
+-- Test: json values keep their text as given, while jsonb is normalized
+-- Expected: 1 rows
+
+CREATE TABLE test_payloads (id int, raw json, doc jsonb);
+INSERT INTO test_payloads VALUES
+  (1, '{"b": 1,  "a": [1, 2.50],"b": 3}', '{"b": 1,  "a": [1, 2.50],"b": 3}');
+
+-- Expected: raw unchanged, doc as {"a": [1, 2.50], "b": 3}; operators on
+-- raw parse its text
+SELECT id, raw, doc FROM test_payloads
+WHERE raw::text = '{"b": 1,  "a": [1, 2.50],"b": 3}'
+  AND doc::text = '{"a": [1, 2.50], "b": 3}'
+  AND raw ->> 'b' = '3'
+  AND raw::jsonb = doc;
+
+DROP TABLE test_payloads;
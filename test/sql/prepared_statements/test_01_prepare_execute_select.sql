@@ -0,0 +1,14 @@
+-- Test 1: EXECUTE runs a prepared SELECT with its parameter
+-- Expected: 2 rows (Bob, Carol)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE older_than(int) AS SELECT name FROM users WHERE age > $1;
+
+-- Test Query
+EXECUTE older_than(28);
+
+-- Cleanup
+DEALLOCATE older_than;
+DROP TABLE users;
@@ -0,0 +1,16 @@
+-- Test 2: A prepared INSERT can be executed repeatedly
+-- Expected: 5 rows
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE add_user(int, text, int) AS INSERT INTO users (id, name, age) VALUES ($1, $2, $3);
+EXECUTE add_user(4, 'Dave', 40);
+EXECUTE add_user(5, 'O''Brien', 45);
+
+-- Test Query
+SELECT * FROM users;
+
+-- Cleanup
+DEALLOCATE ALL;
+DROP TABLE users;
@@ -0,0 +1,14 @@
+-- Test 3: EXECUTE arguments are converted to the declared parameter types
+-- Expected: 1 row (Bob)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE by_id(int) AS SELECT name FROM users WHERE id = $1;
+
+-- Test Query
+EXECUTE by_id('2');
+
+-- Cleanup
+DEALLOCATE by_id;
+DROP TABLE users;
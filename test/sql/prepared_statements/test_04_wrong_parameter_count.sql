@@ -0,0 +1,13 @@
+-- Test 4: EXECUTE with the wrong number of arguments fails
+-- Expected: error (wrong number of parameters for prepared statement)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE by_id(int) AS SELECT name FROM users WHERE id = $1;
+
+-- Test Query
+EXECUTE by_id(1, 2);
+
+-- Cleanup
+DROP TABLE users;
@@ -0,0 +1,14 @@
+-- Test 5: A deallocated statement can no longer be executed
+-- Expected: error (prepared statement "by_id" does not exist)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE by_id(int) AS SELECT name FROM users WHERE id = $1;
+DEALLOCATE by_id;
+
+-- Test Query
+EXECUTE by_id(1);
+
+-- Cleanup
+DROP TABLE users;
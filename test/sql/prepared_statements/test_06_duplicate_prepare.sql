@@ -0,0 +1,13 @@
+-- Test 6: Preparing a statement under a name already in use fails
+-- Expected: error (prepared statement "q" already exists)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE q AS SELECT name FROM users;
+
+-- Test Query
+PREPARE q AS SELECT age FROM users;
+
+-- Cleanup
+DROP TABLE users;
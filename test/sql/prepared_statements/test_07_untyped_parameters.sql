@@ -0,0 +1,14 @@
+-- Test 7: Parameters without declared types take the argument as given
+-- Expected: 1 row (Alice)
+
+-- Setup
+CREATE TABLE users (id int, name text, age int);
+INSERT INTO users (id, name, age) VALUES (1, 'Alice', 25), (2, 'Bob', 30), (3, 'Carol', 35);
+PREPARE by_name AS SELECT id, age FROM users WHERE name = $1;
+
+-- Test Query
+EXECUTE by_name('Alice');
+
+-- Cleanup
+DEALLOCATE by_name;
+DROP TABLE users;
@@ -0,0 +1,15 @@
+-- Test 1: tables of the same name in different schemas are separate
+-- Expected: 2 rows (tenant_a's rows only)
+
+-- Setup
+CREATE SCHEMA tenant_a;
+CREATE SCHEMA tenant_b;
+CREATE TABLE users (id int, name text);
+CREATE TABLE tenant_a.users (id int, name text);
+CREATE TABLE tenant_b.users (id int, name text);
+INSERT INTO users (id, name) VALUES (1, 'public');
+INSERT INTO tenant_a.users (id, name) VALUES (1, 'alice'), (2, 'anna');
+INSERT INTO tenant_b.users (id, name) VALUES (1, 'bob');
+
+-- Test Query
+SELECT tenant_a.users.id, name FROM tenant_a.users WHERE users.name LIKE 'a%' ORDER BY id;
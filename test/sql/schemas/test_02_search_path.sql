@@ -0,0 +1,17 @@
+-- Test 2: unqualified names are found in the first schema on search_path
+-- that has them
+-- Expected: 1 rows (acme's order joined to the shared product)
+
+-- Setup
+CREATE SCHEMA acme;
+CREATE TABLE products (id int, name text);
+CREATE TABLE orders (id int, product_id int);
+CREATE TABLE acme.orders (id int, product_id int);
+INSERT INTO products (id, name) VALUES (10, 'widget');
+INSERT INTO orders (id, product_id) VALUES (1, 10), (2, 10);
+INSERT INTO acme.orders (id, product_id) VALUES (7, 10);
+SET search_path TO acme, public;
+
+-- Test Query
+SELECT o.id, p.name FROM orders o JOIN products p ON p.id = o.product_id
+WHERE current_schema() = 'acme';
@@ -0,0 +1,17 @@
+-- Test 3: tables named without a schema are created in the first schema on
+-- search_path, and writes go to them
+-- Expected: 1 rows
+
+-- Setup
+CREATE SCHEMA tenant;
+SET search_path = tenant, public;
+CREATE TABLE events (id int, kind text);
+INSERT INTO events (id, kind) VALUES (1, 'login'), (2, 'logout');
+UPDATE events SET kind = 'signin' WHERE id = 1;
+DELETE FROM events WHERE id = 2;
+RESET search_path;
+
+-- Test Query
+SELECT table_schema, table_name FROM information_schema.tables
+WHERE table_name = 'events' AND table_schema = 'tenant'
+  AND (SELECT kind FROM tenant.events WHERE id = 1) = 'signin';
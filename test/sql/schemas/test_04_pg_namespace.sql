@@ -0,0 +1,14 @@
+-- Test 4: schemas and their tables and indexes appear in pg_namespace and pg_class
+-- Expected: 3 rows (two tables and an index in billing)
+
+-- Setup
+CREATE SCHEMA billing;
+CREATE TABLE billing.invoices (id int, total numeric);
+CREATE TABLE billing.payments (id int, invoice_id int);
+CREATE INDEX payments_invoice_idx ON billing.payments (invoice_id);
+
+-- Test Query
+SELECT c.relname, c.relkind
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = 'billing'
+ORDER BY c.relname;
@@ -0,0 +1,17 @@
+-- Test 5: each schema has its own index names
+-- Expected: 1 rows
+
+-- Setup
+CREATE SCHEMA s1;
+CREATE SCHEMA s2;
+CREATE TABLE s1.items (id int);
+CREATE TABLE s2.items (id int);
+CREATE UNIQUE INDEX items_id_idx ON s1.items (id);
+CREATE UNIQUE INDEX items_id_idx ON s2.items (id);
+INSERT INTO s1.items (id) VALUES (1);
+INSERT INTO s2.items (id) VALUES (1);
+DROP INDEX s1.items_id_idx;
+INSERT INTO s1.items (id) VALUES (1);
+
+-- Test Query
+SELECT count(*) FROM s1.items HAVING count(*) = 2;
@@ -0,0 +1,11 @@
+-- Test 6: CREATE SCHEMA creates the tables it lists in the new schema
+-- Expected: 1 rows
+
+-- Setup
+CREATE SCHEMA inventory
+    CREATE TABLE stock (sku text, quantity int)
+    CREATE INDEX stock_sku_idx ON stock (sku);
+INSERT INTO inventory.stock (sku, quantity) VALUES ('A-1', 5);
+
+-- Test Query
+SELECT sku, quantity FROM inventory.stock WHERE sku = 'A-1';
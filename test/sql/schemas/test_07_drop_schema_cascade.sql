@@ -0,0 +1,12 @@
+-- Test 7: DROP SCHEMA ... CASCADE drops the schema's tables
+-- Expected: 0 rows
+
+-- Setup
+CREATE SCHEMA scratch;
+CREATE TABLE scratch.notes (id int);
+INSERT INTO scratch.notes (id) VALUES (1);
+DROP SCHEMA scratch CASCADE;
+DROP SCHEMA IF EXISTS scratch;
+
+-- Test Query
+SELECT table_name FROM information_schema.tables WHERE table_schema = 'scratch';
@@ -0,0 +1,6 @@
+-- Test 8: a schema that holds tables is only dropped with CASCADE
+-- Expected: error (cannot drop schema archive because other objects depend on it)
+
+CREATE SCHEMA archive;
+CREATE TABLE archive.logs (id int);
+DROP SCHEMA archive;
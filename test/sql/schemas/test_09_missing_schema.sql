@@ -0,0 +1,4 @@
+-- Test 9: naming a schema that does not exist is an error
+-- Expected: error (schema "nowhere" does not exist)
+
+SELECT * FROM nowhere.users;
@@ -0,0 +1,6 @@
+-- Test 10: CREATE SCHEMA fails for an existing schema unless IF NOT EXISTS is given
+-- Expected: error (schema "reports" already exists)
+
+CREATE SCHEMA reports;
+CREATE SCHEMA IF NOT EXISTS reports;
+CREATE SCHEMA reports;
@@ -0,0 +1,12 @@
+-- Test 1: SET changes the value current_setting() and SHOW report
+-- Expected: 1 rows
+
+SET DateStyle = 'SQL, DMY';
+SET search_path TO app, public;
+SET application_name = 'reports';
+SHOW DateStyle;
+
+SELECT current_setting('datestyle') AS datestyle
+WHERE current_setting('DateStyle') = 'SQL, DMY'
+  AND current_setting('search_path') = 'app, public'
+  AND current_setting('application_name') = 'reports';
@@ -0,0 +1,13 @@
+-- Test 2: RESET and RESET ALL return parameters to their defaults
+-- Expected: 1 rows
+
+SET TimeZone = 'Europe/Paris';
+SET statement_timeout = '5s';
+SET extra_float_digits = 3;
+RESET TimeZone;
+RESET ALL;
+
+SELECT current_setting('TimeZone') AS timezone
+WHERE current_setting('TimeZone') = 'UTC'
+  AND current_setting('statement_timeout') = '0'
+  AND current_setting('extra_float_digits') = '1';
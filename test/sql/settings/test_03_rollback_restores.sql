@@ -0,0 +1,15 @@
+-- Test 3: ROLLBACK undoes SET inside the block; COMMIT undoes only SET LOCAL
+-- Expected: 1 rows
+
+BEGIN;
+SET application_name = 'rolled_back';
+ROLLBACK;
+BEGIN;
+SET client_min_messages = warning;
+SET LOCAL lock_timeout = '1s';
+COMMIT;
+
+SELECT current_setting('application_name') AS application_name
+WHERE current_setting('application_name') = ''
+  AND current_setting('client_min_messages') = 'warning'
+  AND current_setting('lock_timeout') = '0';
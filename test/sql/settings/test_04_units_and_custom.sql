@@ -0,0 +1,14 @@
+-- Test 4: time values are shown in their largest whole unit, and dotted
+-- custom parameters are accepted
+-- Expected: 1 rows
+
+SET statement_timeout = 120000;
+SET idle_in_transaction_session_timeout = '1500ms';
+SET TIME ZONE 9;
+SET myapp.tenant = 'acme';
+
+SELECT current_setting('statement_timeout') AS statement_timeout
+WHERE current_setting('statement_timeout') = '2min'
+  AND current_setting('idle_in_transaction_session_timeout') = '1500ms'
+  AND current_setting('TimeZone') = '<+09>-09'
+  AND current_setting('myapp.tenant') = 'acme';
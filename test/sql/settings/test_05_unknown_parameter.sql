@@ -0,0 +1,4 @@
+-- Test 5: SET rejects parameters that do not exist
+-- Expected: error (unrecognized configuration parameter "no_such_parameter")
+
+SET no_such_parameter = 1;
@@ -0,0 +1,4 @@
+-- Test 6: SET rejects values a parameter does not accept
+-- Expected: error (invalid value for parameter "TimeZone": "Mars/Olympus")
+
+SET TimeZone = 'Mars/Olympus';
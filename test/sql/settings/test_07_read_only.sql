@@ -0,0 +1,4 @@
+-- Test 7: read-only parameters cannot be changed
+-- Expected: error (parameter "server_version" cannot be changed)
+
+SET server_version = '16.0';
@@ -0,0 +1,15 @@
+-- Test 11: memory limits are shown in their largest whole unit, and joins
+-- within the limits run as usual
+-- Expected: 1 rows
+
+-- Setup
+CREATE TABLE numbers (n integer);
+INSERT INTO numbers (n) VALUES (1), (2), (3);
+SET max_intermediate_rows = 100;
+SET statement_memory_limit = '65536kB';
+
+-- Test Query
+SELECT count(*) AS pairs FROM numbers a CROSS JOIN numbers b
+HAVING count(*) = 9
+   AND current_setting('statement_memory_limit') = '64MB'
+   AND current_setting('max_intermediate_rows') = '100';
@@ -0,0 +1,14 @@
+-- Test: A cast compared in WHERE never matches rows where the value is NULL
+-- Expected: 1 rows
+
+CREATE TABLE test_cast_nulls (id int, v text);
+INSERT INTO test_cast_nulls VALUES
+  (1, '5'),
+  (2, NULL),
+  (3, '0'),
+  (4, NULL);
+
+-- Expected: only id 1; v::int is NULL for ids 2 and 4, so v::int > 1 is unknown
+SELECT id FROM test_cast_nulls WHERE v::int > 1;
+
+DROP TABLE test_cast_nulls;